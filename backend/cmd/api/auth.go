@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// sessionCookie holds a signed-in user's session token.
+const sessionCookie = "ksc_session"
+
+type userContextKey struct{}
+
+// requireUser wraps next so it only runs for a signed-in user whose role
+// grants perm, answering 401 or 403 otherwise. An empty perm lets every
+// signed-in user through.
+func requireUser(queries *database.Queries, perm service.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var token string
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			token = c.Value
+		}
+
+		user, err := service.SessionUser(r.Context(), queries, token)
+		if errors.Is(err, service.ErrNoSession) {
+			http.Error(w, "Sign in required", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to check session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !user.Can(perm) {
+			http.Error(w, "Your role may not "+permissionAction(perm), http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, user)))
+	}
+}
+
+// currentUser returns the user requireUser let through.
+func currentUser(r *http.Request) *service.User {
+	user, _ := r.Context().Value(userContextKey{}).(*service.User)
+	return user
+}
+
+// permissionAction describes a permission for a 403 message.
+func permissionAction(perm service.Permission) string {
+	switch perm {
+	case service.PermissionImportPayApps:
+		return "import pay applications"
+	case service.PermissionImportLedger:
+		return "import the cost ledger"
+	case service.PermissionImportBids:
+		return "import bids"
+	case service.PermissionImportBaselines:
+		return "import baselines"
+	case service.PermissionEditJobs:
+		return "edit jobs"
+	case service.PermissionEditPayApps:
+		return "edit pay applications"
+	case service.PermissionEditAccounting:
+		return "edit accounting setup"
+	case service.PermissionClosePeriods:
+		return "close or reopen periods"
+	case service.PermissionManageUsers:
+		return "manage users"
+	}
+	return "do this"
+}
+
+// setSessionCookie starts (or, with an empty session, clears) the browser's
+// session. The cookie is HttpOnly and SameSite=Lax so scripts can't read it
+// and other sites can't make changes with it; secure limits it to HTTPS.
+func setSessionCookie(w http.ResponseWriter, s *service.Session, secure bool) {
+	c := &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if s == nil {
+		c.MaxAge = -1
+	} else {
+		c.Value = s.Token
+		c.Expires = s.ExpiresAt
+	}
+	http.SetCookie(w, c)
+}
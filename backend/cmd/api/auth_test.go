@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// testSession is a stored session: the signed-in user and when it expires.
+type testSession struct {
+	user      database.User
+	expiresAt time.Time
+}
+
+// sessionConnector is a database/sql connector that answers GetSessionUser
+// from a map of sessions keyed by token hash, as the query would: only
+// unexpired sessions of enabled users are found.
+type sessionConnector struct {
+	sessions map[string]testSession
+}
+
+func (c *sessionConnector) Connect(context.Context) (driver.Conn, error) { return sessionConn{c}, nil }
+func (c *sessionConnector) Driver() driver.Driver                        { return nil }
+
+type sessionConn struct{ c *sessionConnector }
+
+func (sessionConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (sessionConn) Close() error                        { return nil }
+func (sessionConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (conn sessionConn) QueryContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	rows := &userRows{}
+	hash, _ := args[0].Value.(string)
+	if s, ok := conn.c.sessions[hash]; ok && s.expiresAt.After(time.Now()) && !s.user.Disabled {
+		rows.users = append(rows.users, s.user)
+	}
+	return rows, nil
+}
+
+type userRows struct{ users []database.User }
+
+func (*userRows) Columns() []string {
+	return []string{"id", "username", "password_hash", "role", "disabled", "created_at", "updated_at"}
+}
+func (*userRows) Close() error { return nil }
+
+func (r *userRows) Next(dest []driver.Value) error {
+	if len(r.users) == 0 {
+		return io.EOF
+	}
+	u := r.users[0]
+	r.users = r.users[1:]
+	dest[0], dest[1], dest[2], dest[3] = u.ID.String(), u.Username, u.PasswordHash, u.Role
+	dest[4], dest[5], dest[6] = u.Disabled, u.CreatedAt.Time, u.UpdatedAt.Time
+	return nil
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestRequireUser(t *testing.T) {
+	user := func(name, role string) database.User {
+		now := sql.NullTime{Time: time.Now(), Valid: true}
+		return database.User{ID: uuid.New(), Username: name, Role: role, CreatedAt: now, UpdatedAt: now}
+	}
+	later := time.Now().Add(time.Hour)
+	disabled := user("gone", service.RoleAdmin)
+	disabled.Disabled = true
+
+	db := sql.OpenDB(&sessionConnector{sessions: map[string]testSession{
+		tokenHash("viewer"):  {user("vera", service.RoleViewer), later},
+		tokenHash("pm"):      {user("pat", service.RoleProjectManager), later},
+		tokenHash("admin"):   {user("ada", service.RoleAdmin), later},
+		tokenHash("expired"): {user("ed", service.RoleAdmin), time.Now().Add(-time.Minute)},
+		tokenHash("gone"):    {disabled, later},
+	}})
+	defer db.Close()
+	queries := database.New(db)
+
+	tests := []struct {
+		name     string
+		token    string
+		perm     service.Permission
+		want     int
+		wantUser string
+	}{
+		{"no cookie", "", "", http.StatusUnauthorized, ""},
+		{"unknown session", "nope", "", http.StatusUnauthorized, ""},
+		{"expired session", "expired", "", http.StatusUnauthorized, ""},
+		{"disabled user", "gone", "", http.StatusUnauthorized, ""},
+		{"read only", "viewer", "", http.StatusOK, "vera"},
+		{"role lacks permission", "viewer", service.PermissionEditJobs, http.StatusForbidden, ""},
+		{"project manager imports ledger", "pm", service.PermissionImportLedger, http.StatusForbidden, ""},
+		{"project manager edits jobs", "pm", service.PermissionEditJobs, http.StatusOK, "pat"},
+		{"admin manages users", "admin", service.PermissionManageUsers, http.StatusOK, "ada"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := requireUser(queries, tt.perm, func(w http.ResponseWriter, r *http.Request) {
+				seen = currentUser(r).Username
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/jobs", nil)
+			if tt.token != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tt.token})
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+			if seen != tt.wantUser {
+				t.Errorf("handler saw user %q, want %q", seen, tt.wantUser)
+			}
+		})
+	}
+}
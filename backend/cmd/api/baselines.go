@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+type BaselineRequest struct {
+	Method string                  `json:"method"`
+	Note   string                  `json:"note"`
+	Months []service.BaselineMonth `json:"months"`
+}
+
+// handleBaselines lists a job's planned value baselines (GET) or saves a new
+// version (POST). Linear and s-curve baselines are generated from the bid;
+// manual baselines take their months from the request body.
+func handleBaselines(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			var req BaselineRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var baseline *service.Baseline
+			var err error
+			switch req.Method {
+			case service.BaselineLinear, service.BaselineSCurve:
+				baseline, err = service.GenerateBaseline(context.Background(), queries, jobNumber, req.Method, req.Note)
+			case service.BaselineManual:
+				baseline, err = service.SaveManualBaseline(context.Background(), queries, jobNumber, req.Note, req.Months)
+			default:
+				http.Error(w, "method must be linear, s-curve or manual", http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, service.ErrInvalidBaseline) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to save baseline: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(baseline)
+			return
+		}
+
+		baselines, err := service.GetBaselines(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to fetch baselines: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(baselines)
+	}
+}
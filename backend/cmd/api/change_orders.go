@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+type ChangeOrderStatusRequest struct {
+	Status string `json:"status"`
+}
+
+// handleChangeOrders lists (GET) or creates (POST) a job's change orders, and
+// updates the status of one (PATCH, with the change order number in the path).
+func handleChangeOrders(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+
+		switch r.Method {
+		case http.MethodGet:
+			if jobNumber == "" {
+				http.Error(w, "job is required", http.StatusBadRequest)
+				return
+			}
+
+			orders, err := service.GetChangeOrders(context.Background(), queries, jobNumber)
+			if err != nil {
+				http.Error(w, "Failed to fetch change orders: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(orders)
+
+		case http.MethodPost:
+			if jobNumber == "" {
+				http.Error(w, "job is required", http.StatusBadRequest)
+				return
+			}
+
+			var in service.ChangeOrderInput
+			if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidateChangeOrder(in); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			co, err := service.CreateChangeOrder(context.Background(), queries, jobNumber, in)
+			if err != nil {
+				writeChangeOrderError(w, "Failed to create change order: ", err)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(co)
+
+		case http.MethodPatch:
+			coNumber := pathParam(r, "co")
+			if jobNumber == "" || coNumber == "" {
+				http.Error(w, "job and co are required", http.StatusBadRequest)
+				return
+			}
+
+			var req ChangeOrderStatusRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !service.ValidChangeOrderStatus(req.Status) {
+				http.Error(w, "status must be pending, approved or rejected", http.StatusBadRequest)
+				return
+			}
+
+			if err := service.SetChangeOrderStatus(context.Background(), queries, jobNumber, coNumber, req.Status); err != nil {
+				writeChangeOrderError(w, "Failed to update change order: ", err)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// writeChangeOrderError maps change order errors to HTTP statuses.
+func writeChangeOrderError(w http.ResponseWriter, prefix string, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidChangeOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrChangeOrderInUse), errors.Is(err, service.ErrPeriodClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, prefix+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func handleGetContractValue(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		cv, err := service.GetContractValue(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to fetch contract value: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(cv)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// handleCostTypeMappings lists (GET) or replaces (PUT) the ledger cat to bid
+// cost type mapping.
+func handleCostTypeMappings(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var mappings []service.CostTypeMapping
+			if err := json.NewDecoder(r.Body).Decode(&mappings); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidateCostTypeMappings(mappings); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.SetCostTypeMappings(context.Background(), queries, mappings); err != nil {
+				http.Error(w, "Failed to save cost type mappings: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		mappings, err := service.GetCostTypeMappings(context.Background(), queries)
+		if err != nil {
+			http.Error(w, "Failed to fetch cost type mappings: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(mappings)
+	}
+}
+
+func handleGetCostTypeVariance(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		byPhase := r.URL.Query().Get("byPhase") == "true"
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rows, err := service.GetCostTypeVariance(context.Background(), queries, jobNumber, byPhase, period)
+		if err != nil {
+			http.Error(w, "Failed to build cost type variance: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "cost-type-variance-"+jobNumber, rows)
+	}
+}
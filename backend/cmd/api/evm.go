@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// EVMResponse carries every CPIResponse field under the same name, so the
+// frontend can switch endpoints without changes, followed by the full EVM set.
+type EVMResponse struct {
+	Month             string `json:"month" report:"Month"`
+	Budget            int64  `json:"budget" report:"Budget"`
+	TotalScheduledQty string `json:"total_scheduled_qty" report:"Total Scheduled Qty,numeric"`
+	CumulativeQty     string `json:"cumulative_qty" report:"Cumulative Qty,numeric"`
+	PercentComplete   string `json:"percent_complete" report:"Percent Complete,numeric"`
+	EarnedValue       int64  `json:"earned_value" report:"Earned Value"`
+	ActualCost        int64  `json:"actual_cost" report:"Actual Cost"`
+	CPI               string `json:"cpi" report:"CPI,numeric"`
+
+	BaselineVersion   int32  `json:"baseline_version" report:"Baseline"`
+	PlannedValue      int64  `json:"planned_value" report:"Planned Value"`
+	ScheduleVariance  int64  `json:"schedule_variance" report:"Schedule Variance"`
+	CostVariance      int64  `json:"cost_variance" report:"Cost Variance"`
+	SPI               string `json:"spi" report:"SPI,numeric"`
+	EAC               int64  `json:"eac" report:"EAC"`
+	EACBudgetRate     int64  `json:"eac_budget_rate" report:"EAC (Budget Rate)"`
+	EACCompositeIndex int64  `json:"eac_composite_index" report:"EAC (CPI x SPI)"`
+	ETC               int64  `json:"etc" report:"ETC"`
+	VAC               int64  `json:"vac" report:"VAC"`
+	TCPI              string `json:"tcpi" report:"TCPI,numeric"`
+	TCPIEAC           string `json:"tcpi_eac" report:"TCPI (EAC),numeric"`
+}
+
+func handleGetEarnedValue(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		var version int32
+		if v := r.URL.Query().Get("baseline"); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 1 {
+				http.Error(w, "baseline must be a positive version number", http.StatusBadRequest)
+				return
+			}
+			version = int32(n)
+		}
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		months, err := service.GetEarnedValueMetrics(context.Background(), queries, jobNumber, version, period)
+		if err != nil {
+			http.Error(w, "Failed to compute earned value: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]EVMResponse, 0, len(months))
+		for _, m := range months {
+			response = append(response, EVMResponse{
+				Month:             m.Month.Format("2006-01"),
+				Budget:            m.Budget.IntPart(),
+				TotalScheduledQty: m.TotalScheduledQty,
+				CumulativeQty:     m.CumulativeQty,
+				PercentComplete:   m.PercentComplete.String(),
+				EarnedValue:       m.EarnedValue.IntPart(),
+				ActualCost:        m.ActualCost.IntPart(),
+				CPI:               m.CPI.String(),
+				BaselineVersion:   m.BaselineVersion,
+				PlannedValue:      m.PlannedValue.IntPart(),
+				ScheduleVariance:  m.ScheduleVariance.IntPart(),
+				CostVariance:      m.CostVariance.IntPart(),
+				SPI:               m.SPI.String(),
+				EAC:               m.EAC.IntPart(),
+				EACBudgetRate:     m.EACBudgetRate.IntPart(),
+				EACCompositeIndex: m.EACCompositeIndex.IntPart(),
+				ETC:               m.ETC.IntPart(),
+				VAC:               m.VAC.IntPart(),
+				TCPI:              m.TCPI.String(),
+				TCPIEAC:           m.TCPIEAC.String(),
+			})
+		}
+
+		report.Respond(w, r, "earned-value-"+jobNumber, response)
+	}
+}
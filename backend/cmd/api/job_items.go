@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// handleGetJobItems returns the bid items of the job named in the path as a
+// nested tree with rollups. ?maxDepth= collapses the tree below that depth
+// (1 = pay items only) and ?method= keeps only the listed cost methods,
+// comma-separated, e.g. method=Pay Item,Crew.
+func handleGetJobItems(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := r.PathValue("job")
+		if err := service.ValidateJobNumber(jobNumber); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var maxDepth int32
+		if v := r.URL.Query().Get("maxDepth"); v != "" {
+			d, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || d < 1 {
+				http.Error(w, "maxDepth must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			maxDepth = int32(d)
+		}
+
+		var methods []string
+		if v := r.URL.Query().Get("method"); v != "" {
+			methods = strings.Split(v, ",")
+		}
+
+		items, err := service.GetJobItemTree(context.Background(), queries, jobNumber, maxDepth, methods)
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+	}
+}
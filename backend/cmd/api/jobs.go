@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// handleJob creates (POST), reads (GET), edits (PATCH) or deletes (DELETE) the
+// job named in the path. PATCH changes only the fields present in the body;
+// send "" to clear a text or date field, or null to clear contract_value.
+func handleJob(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := r.PathValue("job")
+		if err := service.ValidateJobNumber(jobNumber); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		var job *service.JobDetails
+		var err error
+		status := http.StatusOK
+
+		switch r.Method {
+		case http.MethodGet:
+			job, err = service.GetJob(ctx, queries, jobNumber)
+
+		case http.MethodPost:
+			fields := service.JobFields{Status: service.JobActive}
+			if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidateJobFields(fields); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			job, err = service.CreateJob(ctx, queries, jobNumber, fields)
+			status = http.StatusCreated
+
+		case http.MethodPatch:
+			current, err := service.GetJob(ctx, queries, jobNumber)
+			if err != nil {
+				writeJobError(w, err)
+				return
+			}
+			fields := current.JobFields
+			if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidateJobFields(fields); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			job, err = service.UpdateJob(ctx, queries, jobNumber, fields)
+			if err != nil {
+				writeJobError(w, err)
+				return
+			}
+
+		case http.MethodDelete:
+			if err := service.DeleteJob(ctx, queries, jobNumber); err != nil {
+				writeJobError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err != nil {
+			writeJobError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
+// writeJobError maps job service errors to HTTP statuses.
+func writeJobError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrJobNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, service.ErrJobExists), errors.Is(err, service.ErrJobInUse):
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
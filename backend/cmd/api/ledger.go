@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+	"github.com/shopspring/decimal"
+)
+
+// handleGetLedger pages through the cost ledger entries of the job named in
+// the path. Filters: phase, cat, type, class, from and to (YYYY-MM-DD,
+// inclusive), minAmount and maxAmount. sort and order pick the order,
+// groupBy adds subtotals (phase, cat, transaction_type or month), and limit
+// and cursor page through the results. CSV and Excel get the page's entries.
+func handleGetLedger(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := r.PathValue("job")
+		if err := service.ValidateJobNumber(jobNumber); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := r.URL.Query()
+		search := service.LedgerSearch{
+			LedgerFilter: service.LedgerFilter{
+				Phase:           query.Get("phase"),
+				Cat:             query.Get("cat"),
+				TransactionType: query.Get("type"),
+				Class:           query.Get("class"),
+			},
+			SortBy:  query.Get("sort"),
+			GroupBy: query.Get("groupBy"),
+		}
+
+		if search.Class != "" && !service.ValidTransactionClass(search.Class) {
+			http.Error(w, "Unknown transaction class: "+search.Class, http.StatusBadRequest)
+			return
+		}
+		if search.SortBy != "" && !service.ValidLedgerSort(search.SortBy) {
+			http.Error(w, "Unknown sort field: "+search.SortBy, http.StatusBadRequest)
+			return
+		}
+		if search.GroupBy != "" && !service.ValidLedgerGroup(search.GroupBy) {
+			http.Error(w, "groupBy must be phase, cat, transaction_type or month", http.StatusBadRequest)
+			return
+		}
+
+		order := query.Get("order")
+		if order != "" && order != "asc" && order != "desc" {
+			http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+			return
+		}
+		search.Descending = order == "desc"
+
+		for _, d := range []struct {
+			dst  *sql.NullTime
+			name string
+		}{
+			{&search.From, "from"},
+			{&search.To, "to"},
+		} {
+			v := query.Get(d.name)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				http.Error(w, d.name+" must be a date like 2006-01-02", http.StatusBadRequest)
+				return
+			}
+			*d.dst = sql.NullTime{Time: t, Valid: true}
+		}
+		if search.From.Valid && search.To.Valid && search.To.Time.Before(search.From.Time) {
+			http.Error(w, "to must not be before from", http.StatusBadRequest)
+			return
+		}
+
+		for _, a := range []struct {
+			dst  *decimal.NullDecimal
+			name string
+		}{
+			{&search.MinAmount, "minAmount"},
+			{&search.MaxAmount, "maxAmount"},
+		} {
+			v := query.Get(a.name)
+			if v == "" {
+				continue
+			}
+			amount, err := decimal.NewFromString(v)
+			if err != nil {
+				http.Error(w, a.name+" must be a number", http.StatusBadRequest)
+				return
+			}
+			*a.dst = decimal.NewNullDecimal(amount)
+		}
+
+		if v := query.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > service.MaxLedgerLimit {
+				http.Error(w, "limit must be between 1 and "+strconv.Itoa(service.MaxLedgerLimit), http.StatusBadRequest)
+				return
+			}
+			search.Limit = n
+		}
+
+		if v := query.Get("cursor"); v != "" {
+			cursor, err := service.ParseLedgerCursor(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			search.After = cursor
+		}
+
+		format, err := report.NegotiateFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := service.SearchLedger(context.Background(), queries, jobNumber, search)
+		if errors.Is(err, service.ErrInvalidLedgerSearch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to search ledger: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if format == report.FormatJSON {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(page)
+			return
+		}
+		report.Respond(w, r, "ledger-"+jobNumber, page.Entries)
+	}
+}
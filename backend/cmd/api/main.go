@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
@@ -15,9 +16,11 @@ import (
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/shopspring/decimal"
 	"github.com/xuri/excelize/v2"
 
 	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
 	"github.com/lostboys08/ksc-go/backend/internal/service"
 )
 
@@ -83,6 +86,34 @@ func main() {
 
 	queries := database.New(db)
 
+	overrunThreshold := service.DefaultOverrunThreshold
+	if v := os.Getenv("OVERRUN_THRESHOLD_PERCENT"); v != "" {
+		t, err := decimal.NewFromString(v)
+		if err == nil {
+			err = service.ValidateOverrunThreshold(t)
+		}
+		if err != nil {
+			log.Fatal("Invalid OVERRUN_THRESHOLD_PERCENT:", err)
+		}
+		overrunThreshold = t
+	}
+	log.Printf("Quantity overrun threshold: %s%%", overrunThreshold)
+
+	// Session cookies are HTTPS-only unless turned off for local HTTP use.
+	secureCookies := os.Getenv("SESSION_COOKIE_INSECURE") != "true"
+
+	// A new install has no accounts; ADMIN_USERNAME and ADMIN_PASSWORD create
+	// the first admin, who can then add everyone else.
+	if v := os.Getenv("ADMIN_USERNAME"); v != "" {
+		created, err := service.EnsureAdmin(context.Background(), queries, v, os.Getenv("ADMIN_PASSWORD"))
+		if err != nil {
+			log.Fatal("Failed to create admin user:", err)
+		}
+		if created {
+			log.Printf("Created admin user %s", service.NormalizeUsername(v))
+		}
+	}
+
 	// Example: list all jobs
 	jobs, err := queries.GetAllJobs(context.Background())
 	if err != nil {
@@ -93,26 +124,19 @@ func main() {
 
 	log.Println("Server starting on :8080")
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	http.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("ok"))
 	})
 
-	http.HandleFunc("/api/upload", handleUpload(queries))
-	http.HandleFunc("/api/jobs", handleGetJobs(queries))
-	http.HandleFunc("/api/jobs/cost-over-time", handleGetCostOverTime(queries))
-	http.HandleFunc("/api/jobs/cost-performance-index", handleGetCostPerformanceIndex(queries))
-	http.HandleFunc("/api/jobs/over-budget-phases", handleGetOverBudgetPhases(queries))
+	routes := apiRoutes(queries, overrunThreshold, secureCookies)
+	registerRoutes(queries, routes)
+	http.HandleFunc("GET /api/openapi.json", handleOpenAPI(routes))
 
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
 
-func handleUpload(queries *database.Queries) http.HandlerFunc {
+func handleUpload(queries *database.Queries, overrunThreshold decimal.Decimal) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
 		// Parse multipart form (max 32MB)
 		err := r.ParseMultipartForm(32 << 20)
 		if err != nil {
@@ -132,6 +156,10 @@ func handleUpload(queries *database.Queries) http.HandlerFunc {
 			http.Error(w, "Upload type is required", http.StatusBadRequest)
 			return
 		}
+		if perm, ok := service.ImportPermission(uploadType); ok && !currentUser(r).Can(perm) {
+			http.Error(w, "Your role may not "+permissionAction(perm), http.StatusForbidden)
+			return
+		}
 
 		// Open Excel file from uploaded content
 		f, err := excelize.OpenReader(file)
@@ -164,7 +192,11 @@ func handleUpload(queries *database.Queries) http.HandlerFunc {
 				return
 			}
 
-			result, err = service.ImportPayApplication(ctx, f, queries, jobNumber, "", targetDate)
+			result, err = service.ImportPayApplication(ctx, f, queries, jobNumber, "", targetDate, header.Filename, overrunThreshold)
+			if errors.Is(err, service.ErrPeriodClosed) {
+				http.Error(w, "Import failed: "+err.Error(), http.StatusConflict)
+				return
+			}
 			if err != nil {
 				http.Error(w, "Import failed: "+err.Error(), http.StatusInternalServerError)
 				return
@@ -192,6 +224,24 @@ func handleUpload(queries *database.Queries) http.HandlerFunc {
 				return
 			}
 
+		case "baseline":
+			jobNumber := r.FormValue("jobNumber")
+
+			if jobNumber == "" {
+				http.Error(w, "Job number is required for baseline import", http.StatusBadRequest)
+				return
+			}
+
+			result, err = service.ImportBaseline(ctx, f, queries, jobNumber, r.FormValue("note"))
+			if errors.Is(err, service.ErrInvalidBaseline) {
+				http.Error(w, "Import failed: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Import failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
 		default:
 			http.Error(w, "Unknown upload type: "+uploadType, http.StatusBadRequest)
 			return
@@ -222,13 +272,44 @@ func parseTargetDate(s string) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("unable to parse date '%s' - use format like '2006-01'", s)
 }
 
-func handleGetJobs(queries *database.Queries) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
+// parsePeriod reads the optional from, to and asOf query parameters shared by
+// the job analytics endpoints. from and to are months; asOf is an RFC 3339
+// timestamp or a date, which covers everything recorded through that day.
+func parsePeriod(r *http.Request) (service.Period, error) {
+	var period service.Period
+	q := r.URL.Query()
+
+	if v := q.Get("from"); v != "" {
+		t, err := parseTargetDate(v)
+		if err != nil {
+			return period, fmt.Errorf("invalid from: %w", err)
+		}
+		period.From = sql.NullTime{Time: t, Valid: true}
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := parseTargetDate(v)
+		if err != nil {
+			return period, fmt.Errorf("invalid to: %w", err)
+		}
+		period.To = sql.NullTime{Time: t, Valid: true}
+	}
+	if v := q.Get("asOf"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			d, derr := time.Parse("2006-01-02", v)
+			if derr != nil {
+				return period, fmt.Errorf("invalid asOf '%s' - use a date like '2006-01-02' or an RFC 3339 timestamp", v)
+			}
+			t = d.AddDate(0, 0, 1).Add(-time.Microsecond)
 		}
+		period.AsOf = sql.NullTime{Time: t, Valid: true}
+	}
 
+	return period, period.Validate()
+}
+
+func handleGetJobs(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		jobs, err := queries.GetAllJobs(context.Background())
 		if err != nil {
 			http.Error(w, "Failed to fetch jobs: "+err.Error(), http.StatusInternalServerError)
@@ -241,27 +322,33 @@ func handleGetJobs(queries *database.Queries) http.HandlerFunc {
 }
 
 type MonthlyPerformanceResponse struct {
-	Month            string `json:"month"`
-	CostTotal        int64  `json:"cost_total"`
-	PayAppTotal      int64  `json:"pay_app_total"`
-	CumulativeCost   int64  `json:"cumulative_cost"`
-	CumulativePayApp int64  `json:"cumulative_pay_app"`
+	Month            string `json:"month" report:"Month"`
+	CostTotal        int64  `json:"cost_total" report:"Cost Total"`
+	PayAppTotal      int64  `json:"pay_app_total" report:"Pay App Total"`
+	CumulativeCost   int64  `json:"cumulative_cost" report:"Cumulative Cost"`
+	CumulativePayApp int64  `json:"cumulative_pay_app" report:"Cumulative Pay App"`
 }
 
 func handleGetCostOverTime(queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
 			return
 		}
 
-		jobNumber := r.URL.Query().Get("job")
-		if jobNumber == "" {
-			http.Error(w, "job query parameter is required", http.StatusBadRequest)
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		rows, err := queries.GetMonthlyPerformance(context.Background(), jobNumber)
+		rows, err := queries.GetMonthlyPerformance(context.Background(), database.GetMonthlyPerformanceParams{
+			Job:       jobNumber,
+			AsOf:      period.AsOf,
+			FromMonth: period.From,
+			ToMonth:   period.To,
+		})
 		if err != nil {
 			http.Error(w, "Failed to fetch performance data: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -278,36 +365,41 @@ func handleGetCostOverTime(queries *database.Queries) http.HandlerFunc {
 			})
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		report.Respond(w, r, "cost-over-time-"+jobNumber, response)
 	}
 }
 
 type CPIResponse struct {
-	Month             string `json:"month"`
-	Budget            int64  `json:"budget"`
-	TotalScheduledQty string `json:"total_scheduled_qty"`
-	CumulativeQty     string `json:"cumulative_qty"`
-	PercentComplete   string `json:"percent_complete"`
-	EarnedValue       int64  `json:"earned_value"`
-	ActualCost        int64  `json:"actual_cost"`
-	CPI               string `json:"cpi"`
+	Month             string `json:"month" report:"Month"`
+	Budget            int64  `json:"budget" report:"Budget"`
+	TotalScheduledQty string `json:"total_scheduled_qty" report:"Total Scheduled Qty,numeric"`
+	CumulativeQty     string `json:"cumulative_qty" report:"Cumulative Qty,numeric"`
+	PercentComplete   string `json:"percent_complete" report:"Percent Complete,numeric"`
+	EarnedValue       int64  `json:"earned_value" report:"Earned Value"`
+	ActualCost        int64  `json:"actual_cost" report:"Actual Cost"`
+	CPI               string `json:"cpi" report:"CPI,numeric"`
 }
 
 func handleGetCostPerformanceIndex(queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
 			return
 		}
 
-		jobNumber := r.URL.Query().Get("job")
-		if jobNumber == "" {
-			http.Error(w, "job query parameter is required", http.StatusBadRequest)
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		rows, err := queries.GetCostPerformanceIndex(context.Background(), jobNumber)
+		rows, err := queries.GetCostPerformanceIndex(context.Background(), database.GetCostPerformanceIndexParams{
+			JobNumber: jobNumber,
+			AsOf:      period.AsOf,
+			FromMonth: period.From,
+			ToMonth:   period.To,
+		})
 		if err != nil {
 			http.Error(w, "Failed to fetch CPI data: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -327,33 +419,38 @@ func handleGetCostPerformanceIndex(queries *database.Queries) http.HandlerFunc {
 			})
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		report.Respond(w, r, "cost-performance-index-"+jobNumber, response)
 	}
 }
 
 type OverBudgetPhaseResponse struct {
-	Phase       string `json:"phase"`
-	Description string `json:"description"`
-	Budget      int64  `json:"budget"`
-	ActualCost  int64  `json:"actual_cost"`
-	Variance    int64  `json:"variance"`
+	Phase       string `json:"phase" report:"Phase"`
+	Description string `json:"description" report:"Description"`
+	Budget      int64  `json:"budget" report:"Budget"`
+	ActualCost  int64  `json:"actual_cost" report:"Actual Cost"`
+	Variance    int64  `json:"variance" report:"Variance"`
 }
 
 func handleGetOverBudgetPhases(queries *database.Queries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodGet {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
 			return
 		}
 
-		jobNumber := r.URL.Query().Get("job")
-		if jobNumber == "" {
-			http.Error(w, "job query parameter is required", http.StatusBadRequest)
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		rows, err := queries.GetOverBudgetPhases(context.Background(), jobNumber)
+		rows, err := queries.GetOverBudgetPhases(context.Background(), database.GetOverBudgetPhasesParams{
+			Job:       jobNumber,
+			FromMonth: period.From,
+			ToMonth:   period.To,
+			AsOf:      period.AsOf,
+		})
 		if err != nil {
 			http.Error(w, "Failed to fetch over-budget phases: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -374,7 +471,29 @@ func handleGetOverBudgetPhases(queries *database.Queries) http.HandlerFunc {
 			})
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(response)
+		report.Respond(w, r, "over-budget-phases-"+jobNumber, response)
+	}
+}
+
+func handleExportBid(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		f, err := service.ExportBid(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Export failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", report.ContentTypeXLSX)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", jobNumber+"-bid.xlsx"))
+		if err := f.Write(w); err != nil {
+			log.Println("Error writing bid export:", err)
+		}
 	}
 }
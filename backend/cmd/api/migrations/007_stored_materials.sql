@@ -0,0 +1,153 @@
+-- Stored materials movement per item and month
+-- Using DO block to handle idempotency; existing months are backfilled once from the balance change
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name='pay_applications' AND column_name='materials_added') THEN
+        ALTER TABLE pay_applications
+          ADD COLUMN materials_added NUMERIC NOT NULL DEFAULT 0,
+          ADD COLUMN materials_installed NUMERIC NOT NULL DEFAULT 0;
+
+        UPDATE pay_applications pa
+        SET
+            materials_added = GREATEST(d.delta, 0),
+            materials_installed = GREATEST(-d.delta, 0)
+        FROM (
+            SELECT
+                id,
+                stored_materials - COALESCE(LAG(stored_materials) OVER (
+                    PARTITION BY job_item_id
+                    ORDER BY pay_app_month
+                ), 0) AS delta
+            FROM pay_applications
+        ) d
+        WHERE pa.id = d.id;
+    END IF;
+END $$;
+
+-- Stored materials carried forward per item
+CREATE OR REPLACE VIEW stored_materials_ledger AS
+SELECT
+    pa.job_item_id,
+    ji.job_id,
+    ji.item_number,
+    pa.pay_app_month,
+    COALESCE(SUM(pa.materials_added - pa.materials_installed) OVER (
+        PARTITION BY pa.job_item_id
+        ORDER BY pa.pay_app_month
+        ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ), 0)::TEXT AS previous_balance,
+    pa.materials_added::TEXT AS materials_added,
+    pa.materials_installed::TEXT AS materials_installed,
+    SUM(pa.materials_added - pa.materials_installed) OVER (
+        PARTITION BY pa.job_item_id
+        ORDER BY pa.pay_app_month
+        ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+    )::TEXT AS balance
+FROM pay_applications pa
+JOIN job_items ji ON pa.job_item_id = ji.id;
+
+-- Pay application math uses the carried-forward balance
+CREATE OR REPLACE VIEW pay_application_cumulative AS
+WITH ordered_months AS (
+    SELECT
+        pa.job_item_id,
+        pa.pay_app_month,
+        pa.qty,
+        SUM(pa.materials_added - pa.materials_installed) OVER (
+            PARTITION BY pa.job_item_id
+            ORDER BY pa.pay_app_month
+        ) AS stored_materials,
+        ji.qty AS total_qty,
+        ji.unit_price,
+        ji.budget,
+        ji.parent_id,
+        ji.job_id
+    FROM pay_applications pa
+    JOIN job_items_revised ji ON pa.job_item_id = ji.id
+),
+cumulative AS (
+    SELECT
+        job_item_id,
+        pay_app_month,
+        qty AS this_month_qty,
+        stored_materials,
+        total_qty,
+        unit_price,
+        budget,
+        parent_id,
+        job_id,
+        SUM(qty::NUMERIC) OVER (
+            PARTITION BY job_item_id
+            ORDER BY pay_app_month
+            ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+        ) AS cumulative_qty_num,
+        COALESCE(
+            SUM(qty::NUMERIC) OVER (
+                PARTITION BY job_item_id
+                ORDER BY pay_app_month
+                ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+            ),
+            0
+        ) AS previous_cumulative_qty_num
+    FROM ordered_months
+)
+SELECT
+    job_item_id,
+    pay_app_month,
+    job_id,
+    parent_id,
+    this_month_qty,
+    stored_materials,
+    total_qty,
+    unit_price,
+    budget,
+    cumulative_qty_num::TEXT AS cumulative_qty,
+    previous_cumulative_qty_num::TEXT AS previous_cumulative_qty,
+    (total_qty::NUMERIC - cumulative_qty_num)::TEXT AS remaining_qty,
+    CASE
+        WHEN total_qty::NUMERIC = 0 THEN '0'
+        ELSE ROUND((cumulative_qty_num / total_qty::NUMERIC) * 100, 4)::TEXT
+    END AS percent_complete,
+    (this_month_qty::NUMERIC * unit_price::NUMERIC)::TEXT AS this_month_amount,
+    (cumulative_qty_num * unit_price::NUMERIC)::TEXT AS cumulative_amount,
+    (previous_cumulative_qty_num * unit_price::NUMERIC)::TEXT AS previous_cumulative_amount
+FROM cumulative;
+
+CREATE OR REPLACE VIEW pay_app_monthly_totals AS
+WITH contract AS (
+    SELECT job_id, SUM(scheduled_value) AS scheduled_value
+    FROM job_items_revised
+    WHERE parent_id IS NULL
+    GROUP BY job_id
+),
+monthly AS (
+    SELECT
+        ji.job_id,
+        pa.pay_app_month,
+        SUM(
+            CASE
+                WHEN ji.unit_price = 0 THEN pa.qty
+                ELSE pa.qty * ji.unit_price
+            END
+        ) AS work_this_period,
+        SUM(pa.materials_added - pa.materials_installed) AS materials_net
+    FROM pay_applications pa
+    JOIN job_items_revised ji ON pa.job_item_id = ji.id
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id, pa.pay_app_month
+)
+SELECT
+    m.job_id,
+    m.pay_app_month,
+    COALESCE(c.scheduled_value, 0)::TEXT AS scheduled_value,
+    m.work_this_period::TEXT AS work_this_period,
+    SUM(m.work_this_period) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS work_to_date,
+    SUM(m.materials_net) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS stored_materials
+FROM monthly m
+LEFT JOIN contract c ON c.job_id = m.job_id;
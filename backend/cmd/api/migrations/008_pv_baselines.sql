@@ -0,0 +1,21 @@
+-- Planned value baselines, versioned per job
+CREATE TABLE IF NOT EXISTS pv_baselines (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  version INT NOT NULL,
+  method VARCHAR(20) NOT NULL
+    CHECK (method IN ('linear', 's-curve', 'manual')),
+  note TEXT,
+
+  created_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(job_id, version)
+);
+
+-- Planned value earned in each month (not cumulative)
+CREATE TABLE IF NOT EXISTS pv_baseline_months (
+  baseline_id UUID NOT NULL REFERENCES pv_baselines(id) ON DELETE CASCADE,
+  month DATE NOT NULL,
+  planned_value NUMERIC NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (baseline_id, month)
+);
@@ -0,0 +1,7 @@
+-- Ledger cat to bid cost type mapping
+CREATE TABLE IF NOT EXISTS cost_type_mappings (
+  cat VARCHAR(50) PRIMARY KEY,
+  cost_type VARCHAR(20) NOT NULL CHECK (cost_type IN ('labor', 'equip', 'material', 'sub', 'trucking', 'misc', 'plug')),
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
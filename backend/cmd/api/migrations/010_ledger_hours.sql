@@ -0,0 +1,4 @@
+-- Hours and units reported on payroll cost lines
+ALTER TABLE job_cost_ledger
+  ADD COLUMN IF NOT EXISTS hours NUMERIC,
+  ADD COLUMN IF NOT EXISTS units NUMERIC;
@@ -0,0 +1,4 @@
+-- Job status for filtering the portfolio
+ALTER TABLE jobs
+  ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'complete', 'closed'));
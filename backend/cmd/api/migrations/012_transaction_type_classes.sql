@@ -0,0 +1,21 @@
+-- Ledger transaction type classification
+CREATE TABLE IF NOT EXISTS transaction_type_classes (
+  transaction_type VARCHAR(100) PRIMARY KEY,
+  class VARCHAR(20) NOT NULL
+    CHECK (class IN ('cost', 'billing', 'original_budget', 'budget_revision', 'ignore')),
+
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Seed the types the ERP emits; existing classifications are left alone
+INSERT INTO transaction_type_classes (transaction_type, class) VALUES
+  ('AP cost', 'cost'),
+  ('JC cost', 'cost'),
+  ('PR cost', 'cost'),
+  ('EQ cost', 'cost'),
+  ('IN cost', 'cost'),
+  ('work billed', 'billing'),
+  ('Original estimate', 'original_budget'),
+  ('Change estimate', 'budget_revision')
+ON CONFLICT (transaction_type) DO NOTHING;
@@ -0,0 +1,24 @@
+-- Closed months per job
+CREATE TABLE IF NOT EXISTS period_closes (
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  period_month DATE NOT NULL,
+  closed_by TEXT NOT NULL,
+
+  closed_at TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (job_id, period_month)
+);
+
+-- Audit trail of month closes and reopens
+CREATE TABLE IF NOT EXISTS period_close_events (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  period_month DATE NOT NULL,
+  action VARCHAR(10) NOT NULL
+    CHECK (action IN ('close', 'reopen')),
+  actor TEXT NOT NULL,
+  reason TEXT,
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_period_close_events_job ON period_close_events(job_id, period_month);
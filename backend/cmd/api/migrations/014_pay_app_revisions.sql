@@ -0,0 +1,41 @@
+-- Numbered pay application submissions per job and month
+CREATE TABLE IF NOT EXISTS pay_app_revisions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  pay_app_month DATE NOT NULL,
+  revision INT NOT NULL,
+  source TEXT,
+
+  created_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(job_id, pay_app_month, revision)
+);
+
+CREATE TABLE IF NOT EXISTS pay_app_revision_items (
+  revision_id UUID NOT NULL REFERENCES pay_app_revisions(id) ON DELETE CASCADE,
+  job_item_id UUID NOT NULL REFERENCES job_items(id),
+  qty NUMERIC NOT NULL DEFAULT 0,
+  stored_materials NUMERIC NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (revision_id, job_item_id)
+);
+
+-- Months imported before revisions existed become revision 1, dated when the
+-- month was last written. Every import and edit records its own revision, so
+-- this only backfills while the table is still empty.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pay_app_revisions) THEN
+        INSERT INTO pay_app_revisions (job_id, pay_app_month, revision, source, created_at)
+        SELECT ji.job_id, pa.pay_app_month, 1, 'existing', MAX(pa.updated_at)
+        FROM pay_applications pa
+        JOIN job_items ji ON pa.job_item_id = ji.id
+        GROUP BY ji.job_id, pa.pay_app_month;
+
+        INSERT INTO pay_app_revision_items (revision_id, job_item_id, qty, stored_materials)
+        SELECT r.id, pa.job_item_id, pa.qty, pa.stored_materials
+        FROM pay_applications pa
+        JOIN job_items ji ON pa.job_item_id = ji.id
+        JOIN pay_app_revisions r
+          ON r.job_id = ji.job_id AND r.pay_app_month = pa.pay_app_month AND r.revision = 1;
+    END IF;
+END $$;
@@ -0,0 +1,18 @@
+-- Audit trail of pay application edits made through the API
+CREATE TABLE IF NOT EXISTS pay_app_edits (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  job_item_id UUID NOT NULL REFERENCES job_items(id) ON DELETE CASCADE,
+  pay_app_month DATE NOT NULL,
+  revision INT NOT NULL,
+  field VARCHAR(20) NOT NULL
+    CHECK (field IN ('qty', 'stored_materials')),
+  old_value NUMERIC NOT NULL,
+  new_value NUMERIC NOT NULL,
+  edited_by TEXT NOT NULL,
+  reason TEXT,
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pay_app_edits_job ON pay_app_edits(job_id, pay_app_month);
@@ -0,0 +1,167 @@
+-- Installed materials are kept as reported on the pay app, or null when the
+-- pay app had no installed column. Everything else is derived from the
+-- reported balances, so re-importing a month carries into the months after;
+-- materials_added is no longer read.
+-- Using DO block so the inferred installed amounts are cleared once
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name='pay_applications' AND column_name='materials_installed' AND is_nullable='NO') THEN
+        ALTER TABLE pay_applications
+          ALTER COLUMN materials_installed DROP NOT NULL,
+          ALTER COLUMN materials_installed DROP DEFAULT;
+
+        UPDATE pay_applications pa
+        SET materials_installed = NULL
+        FROM (
+            SELECT
+                id,
+                stored_materials - COALESCE(LAG(stored_materials) OVER (
+                    PARTITION BY job_item_id
+                    ORDER BY pay_app_month
+                ), 0) AS delta
+            FROM pay_applications
+        ) d
+        WHERE pa.id = d.id
+          AND pa.materials_added = GREATEST(d.delta, 0)
+          AND pa.materials_installed = GREATEST(-d.delta, 0);
+    END IF;
+END $$;
+
+-- Stored materials per item and month, from the balances reported on the pay
+-- apps. Materials added is the rise in balance; installed is as reported, or
+-- the fall in balance when the pay app had no installed column.
+CREATE OR REPLACE VIEW stored_materials_ledger AS
+WITH balances AS (
+    SELECT
+        job_item_id,
+        pay_app_month,
+        stored_materials AS balance,
+        COALESCE(LAG(stored_materials) OVER (
+            PARTITION BY job_item_id
+            ORDER BY pay_app_month
+        ), 0) AS previous_balance,
+        materials_installed
+    FROM pay_applications
+)
+SELECT
+    b.job_item_id,
+    ji.job_id,
+    ji.item_number,
+    b.pay_app_month,
+    b.previous_balance::TEXT AS previous_balance,
+    GREATEST(b.balance - b.previous_balance, 0)::TEXT AS materials_added,
+    COALESCE(b.materials_installed, GREATEST(b.previous_balance - b.balance, 0))::TEXT AS materials_installed,
+    b.balance::TEXT AS balance
+FROM balances b
+JOIN job_items ji ON b.job_item_id = ji.id;
+
+-- Pay application math uses the reported balance
+CREATE OR REPLACE VIEW pay_application_cumulative AS
+WITH ordered_months AS (
+    SELECT
+        pa.job_item_id,
+        pa.pay_app_month,
+        pa.qty,
+        pa.stored_materials,
+        ji.qty AS total_qty,
+        ji.unit_price,
+        ji.budget,
+        ji.parent_id,
+        ji.job_id
+    FROM pay_applications pa
+    JOIN job_items_revised ji ON pa.job_item_id = ji.id
+),
+cumulative AS (
+    SELECT
+        job_item_id,
+        pay_app_month,
+        qty AS this_month_qty,
+        stored_materials,
+        total_qty,
+        unit_price,
+        budget,
+        parent_id,
+        job_id,
+        SUM(qty::NUMERIC) OVER (
+            PARTITION BY job_item_id
+            ORDER BY pay_app_month
+            ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+        ) AS cumulative_qty_num,
+        COALESCE(
+            SUM(qty::NUMERIC) OVER (
+                PARTITION BY job_item_id
+                ORDER BY pay_app_month
+                ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+            ),
+            0
+        ) AS previous_cumulative_qty_num
+    FROM ordered_months
+)
+SELECT
+    job_item_id,
+    pay_app_month,
+    job_id,
+    parent_id,
+    this_month_qty,
+    stored_materials,
+    total_qty,
+    unit_price,
+    budget,
+    cumulative_qty_num::TEXT AS cumulative_qty,
+    previous_cumulative_qty_num::TEXT AS previous_cumulative_qty,
+    (total_qty::NUMERIC - cumulative_qty_num)::TEXT AS remaining_qty,
+    CASE
+        WHEN total_qty::NUMERIC = 0 THEN '0'
+        ELSE ROUND((cumulative_qty_num / total_qty::NUMERIC) * 100, 4)::TEXT
+    END AS percent_complete,
+    (this_month_qty::NUMERIC * unit_price::NUMERIC)::TEXT AS this_month_amount,
+    (cumulative_qty_num * unit_price::NUMERIC)::TEXT AS cumulative_amount,
+    (previous_cumulative_qty_num * unit_price::NUMERIC)::TEXT AS previous_cumulative_amount
+FROM cumulative;
+
+CREATE OR REPLACE VIEW pay_app_monthly_totals AS
+WITH contract AS (
+    SELECT job_id, SUM(scheduled_value) AS scheduled_value
+    FROM job_items_revised
+    WHERE parent_id IS NULL
+    GROUP BY job_id
+),
+monthly AS (
+    SELECT
+        ji.job_id,
+        pa.pay_app_month,
+        SUM(
+            CASE
+                WHEN ji.unit_price = 0 THEN pa.qty
+                ELSE pa.qty * ji.unit_price
+            END
+        ) AS work_this_period,
+        SUM(pa.stored_materials - pa.previous_stored_materials) AS materials_net
+    FROM (
+        SELECT
+            *,
+            COALESCE(LAG(stored_materials) OVER (
+                PARTITION BY job_item_id
+                ORDER BY pay_app_month
+            ), 0) AS previous_stored_materials
+        FROM pay_applications
+    ) pa
+    JOIN job_items_revised ji ON pa.job_item_id = ji.id
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id, pa.pay_app_month
+)
+SELECT
+    m.job_id,
+    m.pay_app_month,
+    COALESCE(c.scheduled_value, 0)::TEXT AS scheduled_value,
+    m.work_this_period::TEXT AS work_this_period,
+    SUM(m.work_this_period) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS work_to_date,
+    SUM(m.materials_net) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS stored_materials
+FROM monthly m
+LEFT JOIN contract c ON c.job_id = m.job_id;
@@ -0,0 +1,26 @@
+-- Pay application quantities as they stood at a point in time: the latest
+-- revision of each month submitted by as_of, or the current rows when as_of
+-- is NULL. updated_at is when that version was written.
+CREATE OR REPLACE FUNCTION pay_applications_as_of(as_of TIMESTAMP)
+RETURNS TABLE (
+  job_item_id UUID,
+  pay_app_month DATE,
+  qty NUMERIC,
+  stored_materials NUMERIC,
+  updated_at TIMESTAMP
+)
+LANGUAGE SQL STABLE AS $$
+    SELECT pa.job_item_id, pa.pay_app_month, pa.qty, pa.stored_materials, pa.updated_at
+    FROM pay_applications pa
+    WHERE as_of IS NULL
+    UNION ALL
+    SELECT ri.job_item_id, r.pay_app_month, ri.qty, ri.stored_materials, r.created_at
+    FROM (
+        SELECT DISTINCT ON (rev.job_id, rev.pay_app_month) rev.id, rev.pay_app_month, rev.created_at
+        FROM pay_app_revisions rev
+        WHERE as_of IS NOT NULL
+          AND rev.created_at <= as_of
+        ORDER BY rev.job_id, rev.pay_app_month, rev.revision DESC
+    ) r
+    JOIN pay_app_revision_items ri ON ri.revision_id = r.id
+$$;
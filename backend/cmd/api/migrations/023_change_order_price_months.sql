@@ -0,0 +1,164 @@
+-- Unit prices by month. A change order's new price applies from the month of
+-- its date on, so months billed before it keep the price they were billed at.
+-- until_month is the month the next price takes over, or NULL for the
+-- current price.
+CREATE OR REPLACE VIEW job_item_prices AS
+WITH price_changes AS (
+    -- The latest approved CO in a month that sets a unit price wins
+    SELECT DISTINCT ON (coi.job_item_id, DATE_TRUNC('month', co.co_date))
+        coi.job_item_id,
+        DATE_TRUNC('month', co.co_date)::DATE AS from_month,
+        coi.new_unit_price AS unit_price
+    FROM change_order_items coi
+    JOIN change_orders co ON coi.change_order_id = co.id
+    WHERE co.status = 'approved'
+      AND coi.job_item_id IS NOT NULL
+      AND coi.new_unit_price IS NOT NULL
+    ORDER BY coi.job_item_id, DATE_TRUNC('month', co.co_date), co.co_date DESC, co.created_at DESC
+),
+prices AS (
+    SELECT id AS job_item_id, '-infinity'::DATE AS from_month, unit_price
+    FROM job_items
+    UNION ALL
+    SELECT job_item_id, from_month, unit_price
+    FROM price_changes
+)
+SELECT
+    job_item_id,
+    from_month,
+    LEAD(from_month) OVER (
+        PARTITION BY job_item_id
+        ORDER BY from_month
+    ) AS until_month,
+    unit_price
+FROM prices;
+
+-- Pay application amounts use the price in effect in each month
+CREATE OR REPLACE VIEW pay_application_cumulative AS
+WITH ordered_months AS (
+    SELECT
+        pa.job_item_id,
+        pa.pay_app_month,
+        pa.qty,
+        pa.stored_materials,
+        ji.qty AS total_qty,
+        p.unit_price,
+        ji.budget,
+        ji.parent_id,
+        ji.job_id
+    FROM pay_applications pa
+    JOIN job_items_revised ji ON pa.job_item_id = ji.id
+    JOIN job_item_prices p ON p.job_item_id = pa.job_item_id
+     AND pa.pay_app_month >= p.from_month
+     AND (p.until_month IS NULL OR pa.pay_app_month < p.until_month)
+),
+cumulative AS (
+    SELECT
+        job_item_id,
+        pay_app_month,
+        qty AS this_month_qty,
+        stored_materials,
+        total_qty,
+        unit_price,
+        budget,
+        parent_id,
+        job_id,
+        SUM(qty::NUMERIC) OVER (
+            PARTITION BY job_item_id
+            ORDER BY pay_app_month
+            ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+        ) AS cumulative_qty_num,
+        COALESCE(
+            SUM(qty::NUMERIC) OVER (
+                PARTITION BY job_item_id
+                ORDER BY pay_app_month
+                ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+            ),
+            0
+        ) AS previous_cumulative_qty_num,
+        SUM(qty::NUMERIC * unit_price::NUMERIC) OVER (
+            PARTITION BY job_item_id
+            ORDER BY pay_app_month
+            ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+        ) AS cumulative_amount_num,
+        COALESCE(
+            SUM(qty::NUMERIC * unit_price::NUMERIC) OVER (
+                PARTITION BY job_item_id
+                ORDER BY pay_app_month
+                ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+            ),
+            0
+        ) AS previous_cumulative_amount_num
+    FROM ordered_months
+)
+SELECT
+    job_item_id,
+    pay_app_month,
+    job_id,
+    parent_id,
+    this_month_qty,
+    stored_materials,
+    total_qty,
+    unit_price,
+    budget,
+    cumulative_qty_num::TEXT AS cumulative_qty,
+    previous_cumulative_qty_num::TEXT AS previous_cumulative_qty,
+    (total_qty::NUMERIC - cumulative_qty_num)::TEXT AS remaining_qty,
+    CASE
+        WHEN total_qty::NUMERIC = 0 THEN '0'
+        ELSE ROUND((cumulative_qty_num / total_qty::NUMERIC) * 100, 4)::TEXT
+    END AS percent_complete,
+    (this_month_qty::NUMERIC * unit_price::NUMERIC)::TEXT AS this_month_amount,
+    cumulative_amount_num::TEXT AS cumulative_amount,
+    previous_cumulative_amount_num::TEXT AS previous_cumulative_amount
+FROM cumulative;
+
+CREATE OR REPLACE VIEW pay_app_monthly_totals AS
+WITH contract AS (
+    SELECT job_id, SUM(scheduled_value) AS scheduled_value
+    FROM job_items_revised
+    WHERE parent_id IS NULL
+    GROUP BY job_id
+),
+monthly AS (
+    SELECT
+        ji.job_id,
+        pa.pay_app_month,
+        SUM(
+            CASE
+                WHEN p.unit_price = 0 THEN pa.qty
+                ELSE pa.qty * p.unit_price
+            END
+        ) AS work_this_period,
+        SUM(pa.stored_materials - pa.previous_stored_materials) AS materials_net
+    FROM (
+        SELECT
+            *,
+            COALESCE(LAG(stored_materials) OVER (
+                PARTITION BY job_item_id
+                ORDER BY pay_app_month
+            ), 0) AS previous_stored_materials
+        FROM pay_applications
+    ) pa
+    JOIN job_items_revised ji ON pa.job_item_id = ji.id
+    JOIN job_item_prices p ON p.job_item_id = pa.job_item_id
+     AND pa.pay_app_month >= p.from_month
+     AND (p.until_month IS NULL OR pa.pay_app_month < p.until_month)
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id, pa.pay_app_month
+)
+SELECT
+    m.job_id,
+    m.pay_app_month,
+    COALESCE(c.scheduled_value, 0)::TEXT AS scheduled_value,
+    m.work_this_period::TEXT AS work_this_period,
+    SUM(m.work_this_period) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS work_to_date,
+    SUM(m.materials_net) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS stored_materials
+FROM monthly m
+LEFT JOIN contract c ON c.job_id = m.job_id;
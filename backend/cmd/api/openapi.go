@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/shopspring/decimal"
+
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	uuidType        = reflect.TypeOf(uuid.UUID{})
+	decimalType     = reflect.TypeOf(decimal.Decimal{})
+	nullDecimalType = reflect.TypeOf(decimal.NullDecimal{})
+	rawMessageType  = reflect.TypeOf(json.RawMessage{})
+)
+
+// pathWildcard matches a ServeMux wildcard such as {job}.
+var pathWildcard = regexp.MustCompile(`\{(\w+)\}`)
+
+// handleOpenAPI serves the OpenAPI 3 document for routes. It is built once
+// from the route table, so response schemas follow the handler structs.
+func handleOpenAPI(routes []route) http.HandlerFunc {
+	doc, err := json.Marshal(openAPIDocument(routes))
+	if err != nil {
+		panic("openapi: " + err.Error())
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	}
+}
+
+// openAPIDocument describes routes as an OpenAPI 3 document. Aliases are
+// listed as deprecated operations with their path wildcards as query
+// parameters.
+func openAPIDocument(routes []route) map[string]any {
+	s := &schemaBuilder{schemas: map[string]any{}, names: map[reflect.Type]string{}}
+	paths := map[string]map[string]any{}
+	add := func(path string, op map[string]any, method string) {
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = op
+	}
+
+	for _, rt := range routes {
+		add(rt.Path, s.operation(rt, false), rt.Method)
+		for _, alias := range rt.Aliases {
+			add(alias, s.operation(rt, true), rt.Method)
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "KSC job cost API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"security": []any{
+			map[string]any{"session": []string{}},
+		},
+		"components": map[string]any{
+			"schemas": s.schemas,
+			"securitySchemes": map[string]any{
+				"session": map[string]any{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": sessionCookie,
+				},
+			},
+		},
+	}
+}
+
+// operation describes one route, or one of its aliases.
+func (s *schemaBuilder) operation(rt route, alias bool) map[string]any {
+	op := map[string]any{"summary": rt.Summary}
+	if alias {
+		op["deprecated"] = true
+	}
+	if rt.Public {
+		op["security"] = []any{}
+	}
+	if rt.Permission != "" {
+		op["description"] = "Requires a role that may " + permissionAction(rt.Permission) + "."
+	}
+
+	var params []map[string]any
+	for _, m := range pathWildcard.FindAllStringSubmatch(rt.Path, -1) {
+		in := "path"
+		if alias {
+			in = "query"
+		}
+		params = append(params, map[string]any{
+			"name":     m[1],
+			"in":       in,
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	for _, p := range rt.Query {
+		params = append(params, p.openAPI("query"))
+	}
+	if rt.Report {
+		params = append(params, map[string]any{
+			"name":        "format",
+			"in":          "query",
+			"description": "json, csv or xlsx; defaults to the Accept header, then json",
+			"schema":      map[string]any{"type": "string", "enum": []string{"json", "csv", "xlsx"}},
+		})
+	}
+	if params != nil {
+		op["parameters"] = params
+	}
+
+	switch {
+	case rt.Form != nil:
+		props := map[string]any{}
+		var required []string
+		for _, p := range rt.Form {
+			schema := map[string]any{"type": paramType(p.Type)}
+			if p.Type == "file" {
+				schema["format"] = "binary"
+			}
+			if p.Description != "" {
+				schema["description"] = p.Description
+			}
+			props[p.Name] = schema
+			if p.Required {
+				required = append(required, p.Name)
+			}
+		}
+		form := map[string]any{"type": "object", "properties": props}
+		if required != nil {
+			form["required"] = required
+		}
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"multipart/form-data": map[string]any{"schema": form},
+			},
+		}
+	case rt.Request != nil:
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				report.ContentTypeJSON: map[string]any{"schema": s.schema(reflect.TypeOf(rt.Request))},
+			},
+		}
+	}
+
+	status := rt.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := map[string]any{"description": http.StatusText(status)}
+	content := map[string]any{}
+	if rt.Response != nil {
+		content[report.ContentTypeJSON] = map[string]any{"schema": s.schema(reflect.TypeOf(rt.Response))}
+	}
+	if rt.Report {
+		content[report.ContentTypeCSV] = map[string]any{"schema": map[string]any{"type": "string"}}
+		content[report.ContentTypeXLSX] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+	}
+	if rt.Download != "" {
+		content[rt.Download] = map[string]any{"schema": map[string]any{"type": "string", "format": "binary"}}
+	}
+	if len(content) > 0 {
+		resp["content"] = content
+	}
+	responses := map[string]any{
+		strconv.Itoa(status): resp,
+		"default":            map[string]any{"description": "Error message as plain text"},
+	}
+	if !rt.Public {
+		responses["401"] = map[string]any{"description": "Not signed in"}
+		responses["403"] = map[string]any{"description": "The signed-in user's role doesn't allow this"}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func (p param) openAPI(in string) map[string]any {
+	v := map[string]any{
+		"name":   p.Name,
+		"in":     in,
+		"schema": map[string]any{"type": paramType(p.Type)},
+	}
+	if p.Required {
+		v["required"] = true
+	}
+	if p.Description != "" {
+		v["description"] = p.Description
+	}
+	return v
+}
+
+func paramType(t string) string {
+	switch t {
+	case "":
+		return "string"
+	case "file":
+		return "string"
+	}
+	return t
+}
+
+// schemaBuilder turns Go types into JSON schemas the way encoding/json would
+// encode them. Named structs go in components/schemas and are referenced, so
+// recursive types such as service.JobItemNode terminate.
+type schemaBuilder struct {
+	schemas map[string]any
+	names   map[reflect.Type]string
+}
+
+func (s *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case uuidType:
+		return map[string]any{"type": "string", "format": "uuid"}
+	case decimalType:
+		return map[string]any{"type": "string", "format": "decimal"}
+	case nullDecimalType:
+		return map[string]any{"type": "string", "format": "decimal", "nullable": true}
+	case rawMessageType:
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		v := s.schema(t.Elem())
+		if _, ok := v["$ref"]; ok {
+			return map[string]any{"allOf": []any{v}, "nullable": true}
+		}
+		v["nullable"] = true
+		return v
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": s.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	}
+	return map[string]any{}
+}
+
+// ref returns a reference to the named struct t, adding its schema to the
+// components on first use. Names clashing across packages get the package
+// name as a prefix.
+func (s *schemaBuilder) ref(t reflect.Type) map[string]any {
+	name, ok := s.names[t]
+	if !ok {
+		name = t.Name()
+		if _, taken := s.schemas[name]; taken {
+			pkg := t.PkgPath()
+			pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+			name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+		}
+		s.names[t] = name
+		s.schemas[name] = map[string]any{} // placeholder while recursing
+		s.schemas[name] = s.object(t)
+	}
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+// object describes a struct's JSON fields. Embedded structs are flattened,
+// and fields without omitempty are required.
+func (s *schemaBuilder) object(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	var required []string
+	s.fields(t, props, &required)
+	sort.Strings(required)
+
+	v := map[string]any{"type": "object", "properties": props}
+	if required != nil {
+		v["required"] = required
+	}
+	return v
+}
+
+func (s *schemaBuilder) fields(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				s.fields(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		props[name] = s.schema(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+	"github.com/shopspring/decimal"
+)
+
+// handleGetOverrunAlerts lists a job's pay items billed past their contract
+// quantity or within the threshold of it. ?threshold= overrides the server's
+// configured percent.
+func handleGetOverrunAlerts(queries *database.Queries, defaultThreshold decimal.Decimal) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		threshold := defaultThreshold
+		if v := r.URL.Query().Get("threshold"); v != "" {
+			t, err := decimal.NewFromString(v)
+			if err != nil {
+				http.Error(w, "threshold must be a number", http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidateOverrunThreshold(t); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			threshold = t
+		}
+
+		overruns, err := service.GetQuantityOverruns(context.Background(), queries, jobNumber, threshold)
+		if err != nil {
+			http.Error(w, "Failed to check quantity overruns: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "overrun-alerts-"+jobNumber, overruns)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// handleGetPayAppRevisions lists every submission of a job's pay application
+// for a month, effective revision first.
+func handleGetPayAppRevisions(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+		month, err := parseTargetDate(pathParam(r, "month"))
+		if err != nil {
+			http.Error(w, "Invalid month: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		revisions, err := service.GetPayAppRevisions(context.Background(), queries, jobNumber, month)
+		if err != nil {
+			http.Error(w, "Failed to fetch pay app revisions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "pay-app-revisions-"+jobNumber+"-"+month.Format("2006-01"), revisions)
+	}
+}
+
+// handleGetPayAppRevisionDiff compares two revisions of a month's pay
+// application. from and to default to the revision before the effective one
+// and the effective one; all=true includes unchanged items.
+func handleGetPayAppRevisionDiff(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+		month, err := parseTargetDate(pathParam(r, "month"))
+		if err != nil {
+			http.Error(w, "Invalid month: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var revisions [2]int32
+		for i, name := range []string{"from", "to"} {
+			v := r.URL.Query().Get(name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 1 {
+				http.Error(w, name+" must be a positive revision number", http.StatusBadRequest)
+				return
+			}
+			revisions[i] = int32(n)
+		}
+		all := r.URL.Query().Get("all") == "true"
+
+		format, err := report.NegotiateFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		diff, err := service.DiffPayAppRevisions(context.Background(), queries, jobNumber, month, revisions[0], revisions[1], all)
+		if err != nil {
+			http.Error(w, "Failed to diff pay app revisions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if format == report.FormatJSON {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(diff)
+			return
+		}
+		filename := fmt.Sprintf("pay-app-diff-%s-%s-r%d-r%d", jobNumber, diff.Month, diff.FromRevision, diff.ToRevision)
+		report.Respond(w, r, filename, diff.Changes)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+type PayAppEditRequest struct {
+	Reason string                   `json:"reason"`
+	Items  []service.PayAppItemEdit `json:"items"`
+}
+
+// handlePayApp reads (GET) or edits (PUT, PATCH) a job's pay application for
+// the month in the path. PUT must give both qty and stored_materials for each
+// listed item; PATCH changes only the fields present. A listed item not yet on
+// the month is added, and items not listed are left alone either way. Edits
+// are recorded against the signed-in user.
+func handlePayApp(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := r.PathValue("job")
+		if err := service.ValidateJobNumber(jobNumber); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		month, err := parseTargetDate(r.PathValue("month"))
+		if err != nil {
+			http.Error(w, "Invalid month: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		var payApp *service.PayApp
+
+		switch r.Method {
+		case http.MethodGet:
+			format, err := report.NegotiateFormat(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			payApp, err = service.GetPayApp(ctx, queries, jobNumber, month)
+			if err != nil {
+				writePayAppError(w, err)
+				return
+			}
+			if format != report.FormatJSON {
+				report.Respond(w, r, "pay-app-"+jobNumber+"-"+payApp.Month, payApp.Items)
+				return
+			}
+
+		case http.MethodPut, http.MethodPatch:
+			var req PayAppEditRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if r.Method == http.MethodPut {
+				for _, item := range req.Items {
+					if !item.Qty.Valid || !item.StoredMaterials.Valid {
+						http.Error(w, "PUT requires qty and stored_materials for every item; use PATCH to change one", http.StatusBadRequest)
+						return
+					}
+				}
+			}
+
+			payApp, err = service.EditPayApp(ctx, queries, jobNumber, month, req.Items, currentUser(r).Username, req.Reason)
+			if err != nil {
+				writePayAppError(w, err)
+				return
+			}
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payApp)
+	}
+}
+
+// handleGetPayAppEdits lists the edits made to a job's pay application for
+// the month in the path, newest first.
+func handleGetPayAppEdits(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := r.PathValue("job")
+		if err := service.ValidateJobNumber(jobNumber); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		month, err := parseTargetDate(r.PathValue("month"))
+		if err != nil {
+			http.Error(w, "Invalid month: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		edits, err := service.GetPayAppEdits(context.Background(), queries, jobNumber, month)
+		if err != nil {
+			writePayAppError(w, err)
+			return
+		}
+
+		report.Respond(w, r, "pay-app-edits-"+jobNumber+"-"+month.Format("2006-01"), edits)
+	}
+}
+
+// writePayAppError maps pay application edit errors to HTTP statuses, falling
+// back to writeJobError.
+func writePayAppError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidPayAppEdit):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrPeriodClosed):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeJobError(w, err)
+	}
+}
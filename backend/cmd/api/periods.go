@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+type PeriodCloseRequest struct {
+	Month  string `json:"month"`
+	Reason string `json:"reason"`
+}
+
+// handlePeriodCloses lists a job's closed months.
+func handlePeriodCloses(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		periods, err := service.GetClosedPeriods(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to fetch closed periods: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(periods)
+	}
+}
+
+// handleChangePeriod closes or reopens (POST) a job's month, depending on
+// action, as the signed-in user, then returns the job's closed months.
+func handleChangePeriod(queries *database.Queries, action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		var req PeriodCloseRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		month, err := parseTargetDate(req.Month)
+		if err != nil {
+			http.Error(w, "Invalid month: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx := context.Background()
+		user := currentUser(r)
+		if action == service.PeriodActionReopen {
+			if strings.TrimSpace(req.Reason) == "" {
+				http.Error(w, "reason is required to reopen a period", http.StatusBadRequest)
+				return
+			}
+			err = service.ReopenPeriod(ctx, queries, jobNumber, month, user.Username, req.Reason)
+		} else {
+			err = service.ClosePeriod(ctx, queries, jobNumber, month, user.Username, req.Reason)
+		}
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, service.ErrPeriodClosed) || errors.Is(err, service.ErrPeriodOpen) {
+				status = http.StatusConflict
+			}
+			http.Error(w, "Failed to "+action+" period: "+err.Error(), status)
+			return
+		}
+
+		periods, err := service.GetClosedPeriods(ctx, queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to fetch closed periods: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(periods)
+	}
+}
+
+func handleGetPeriodHistory(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		events, err := service.GetPeriodCloseEvents(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to fetch period history: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "period-history-"+jobNumber, events)
+	}
+}
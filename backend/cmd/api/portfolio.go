@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// handleGetPortfolio returns headline metrics for every job. Optional query
+// parameters: status (active, complete, closed), sort (any metric's JSON name),
+// order (asc or desc), and to and asOf as read by parsePeriod.
+func handleGetPortfolio(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		if status != "" && !service.ValidJobStatus(status) {
+			http.Error(w, "status must be active, complete or closed", http.StatusBadRequest)
+			return
+		}
+
+		sortBy := r.URL.Query().Get("sort")
+		if sortBy != "" && !service.ValidPortfolioSort(sortBy) {
+			http.Error(w, "Unknown sort field: "+sortBy, http.StatusBadRequest)
+			return
+		}
+
+		order := r.URL.Query().Get("order")
+		if order != "" && order != "asc" && order != "desc" {
+			http.Error(w, "order must be asc or desc", http.StatusBadRequest)
+			return
+		}
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if period.From.Valid {
+			http.Error(w, "portfolio figures are to date; use to instead of from", http.StatusBadRequest)
+			return
+		}
+
+		jobs, err := service.GetPortfolio(context.Background(), queries, status, sortBy, order == "desc", period)
+		if err != nil {
+			http.Error(w, "Failed to build portfolio: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "portfolio", jobs)
+	}
+}
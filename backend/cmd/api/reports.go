@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+func handleGetPhaseCostPivot(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		format, err := report.NegotiateFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		byCat := r.URL.Query().Get("byCat") == "true"
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pivot, err := service.GetPhaseCostPivot(context.Background(), queries, jobNumber, byCat, period)
+		if err != nil {
+			http.Error(w, "Failed to build phase cost pivot: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if format == report.FormatJSON {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(pivot)
+			return
+		}
+		report.WriteTable(w, format, "phase-costs-"+jobNumber, pivot.Table())
+	}
+}
+
+// handleGetPhaseCPI ranks a job's cost codes by CPI, worst first. An optional
+// limit returns only the worst phases.
+func handleGetPhaseCPI(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		limit := 0
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "limit must be a positive number", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		phases, err := service.GetPhaseCPI(context.Background(), queries, jobNumber, period)
+		if err != nil {
+			http.Error(w, "Failed to compute phase CPI: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if limit > 0 && len(phases) > limit {
+			phases = phases[:limit]
+		}
+
+		report.Respond(w, r, "phase-cpi-"+jobNumber, phases)
+	}
+}
+
+func handleGetUnitCosts(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rows, err := service.GetUnitCosts(context.Background(), queries, jobNumber, period)
+		if err != nil {
+			http.Error(w, "Failed to compute unit costs: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "unit-costs-"+jobNumber, rows)
+	}
+}
+
+func handleGetProductivity(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		period, err := parsePeriod(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		rows, err := service.GetProductivity(context.Background(), queries, jobNumber, period)
+		if err != nil {
+			http.Error(w, "Failed to compute productivity: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "productivity-"+jobNumber, rows)
+	}
+}
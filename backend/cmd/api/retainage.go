@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+	"github.com/shopspring/decimal"
+)
+
+// handleRetainageTiers lists (GET) or replaces (PUT) a job's retainage schedule.
+func handleRetainageTiers(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodPut {
+			var tiers []service.RetainageTier
+			if err := json.NewDecoder(r.Body).Decode(&tiers); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidateRetainageTiers(tiers); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			err := service.SetRetainageTiers(context.Background(), queries, jobNumber, tiers)
+			if errors.Is(err, service.ErrPeriodClosed) {
+				http.Error(w, "Failed to save retainage tiers: "+err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to save retainage tiers: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		tiers, err := service.GetRetainageTiers(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to fetch retainage tiers: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tiers)
+	}
+}
+
+type RetainageReleaseRequest struct {
+	Month  string          `json:"month"`
+	Amount decimal.Decimal `json:"amount"`
+	Note   string          `json:"note"`
+}
+
+// handleRetainageReleases lists (GET) or records (POST) retainage releases.
+func handleRetainageReleases(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			var req RetainageReleaseRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			month, err := parseTargetDate(req.Month)
+			if err != nil {
+				http.Error(w, "Invalid month: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !req.Amount.IsPositive() {
+				http.Error(w, "amount must be greater than zero", http.StatusBadRequest)
+				return
+			}
+			err = service.AddRetainageRelease(context.Background(), queries, jobNumber, month, req.Amount, req.Note)
+			if errors.Is(err, service.ErrInvalidRetainageRelease) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errors.Is(err, service.ErrPeriodClosed) {
+				http.Error(w, "Failed to record retainage release: "+err.Error(), http.StatusConflict)
+				return
+			}
+			if err != nil {
+				http.Error(w, "Failed to record retainage release: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		releases, err := service.GetRetainageReleases(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to fetch retainage releases: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(releases)
+	}
+}
+
+func handleGetPayAppSummaries(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		summaries, err := service.GetPayAppSummaries(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to build pay application summaries: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "pay-apps-"+jobNumber, summaries)
+	}
+}
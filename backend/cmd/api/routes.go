@@ -0,0 +1,630 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// route is one API operation. The same table registers the ServeMux patterns
+// and builds the OpenAPI document, so the two can't drift apart.
+type route struct {
+	Method  string
+	Path    string
+	Summary string
+
+	// Aliases are the older query-string paths for the operation. Each of
+	// Path's wildcards is a query parameter of the same name on an alias.
+	Aliases []string
+
+	Query []param // query parameters
+	Form  []param // multipart form fields; a "file" field is the upload
+
+	Request  any // JSON request body, nil for none
+	Response any // JSON response body, nil for none
+	Status   int // success status, 0 for 200
+
+	// Report routes also answer in CSV or Excel (see report.NegotiateFormat).
+	Report bool
+	// Download is the content type of a file response in place of JSON.
+	Download string
+
+	// Public routes need no session. Others need a signed-in user whose role
+	// grants Permission, or any signed-in user if Permission is empty.
+	Public     bool
+	Permission service.Permission
+
+	Handler http.HandlerFunc
+}
+
+// param is a query parameter or form field.
+type param struct {
+	Name        string
+	Type        string // OpenAPI type, "" for string
+	Required    bool
+	Description string
+}
+
+// periodParams are the range parameters read by parsePeriod.
+var periodParams = []param{
+	{Name: "from", Description: "First month to include, e.g. 2025-01"},
+	{Name: "to", Description: "Last month to include, e.g. 2025-06"},
+	{Name: "asOf", Description: "Leave out data recorded after this date or RFC 3339 timestamp"},
+}
+
+// apiRoutes lists every API operation.
+func apiRoutes(queries *database.Queries, overrunThreshold decimal.Decimal, secureCookies bool) []route {
+	return []route{
+		{
+			Method:   http.MethodPost,
+			Path:     "/api/login",
+			Summary:  "Sign in and start a session cookie",
+			Request:  LoginRequest{},
+			Response: service.User{},
+			Public:   true,
+			Handler:  handleLogin(queries, secureCookies),
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/api/logout",
+			Summary: "End the current session",
+			Status:  http.StatusNoContent,
+			Public:  true,
+			Handler: handleLogout(queries, secureCookies),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/me",
+			Summary:  "The signed-in user",
+			Response: service.User{},
+			Handler:  handleGetMe(),
+		},
+		{
+			Method:  http.MethodPut,
+			Path:    "/api/me/password",
+			Summary: "Change your password; ends every session",
+			Request: PasswordRequest{},
+			Status:  http.StatusNoContent,
+			Handler: handleChangeMyPassword(queries, secureCookies),
+		},
+		{
+			Method:     http.MethodGet,
+			Path:       "/api/users",
+			Permission: service.PermissionManageUsers,
+			Summary:    "List user accounts",
+			Response:   []service.User{},
+			Handler:    handleUsers(queries),
+		},
+		{
+			Method:     http.MethodPost,
+			Path:       "/api/users",
+			Permission: service.PermissionManageUsers,
+			Summary:    "Create a user account",
+			Request:    CreateUserRequest{},
+			Response:   service.User{},
+			Status:     http.StatusCreated,
+			Handler:    handleUsers(queries),
+		},
+		{
+			Method:     http.MethodPatch,
+			Path:       "/api/users/{username}",
+			Permission: service.PermissionManageUsers,
+			Summary:    "Change a user's role or disable them",
+			Request:    UpdateUserRequest{},
+			Response:   service.User{},
+			Handler:    handleUser(queries),
+		},
+		{
+			Method:     http.MethodDelete,
+			Path:       "/api/users/{username}",
+			Permission: service.PermissionManageUsers,
+			Summary:    "Delete a user account",
+			Status:     http.StatusNoContent,
+			Handler:    handleUser(queries),
+		},
+		{
+			Method:     http.MethodPut,
+			Path:       "/api/users/{username}/password",
+			Permission: service.PermissionManageUsers,
+			Summary:    "Reset a user's password; ends their sessions",
+			Request:    PasswordRequest{},
+			Status:     http.StatusNoContent,
+			Handler:    handleResetPassword(queries),
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/api/upload",
+			Summary: "Import a pay application, cost ledger, bid or baseline workbook; the permission needed depends on type",
+			Form: []param{
+				{Name: "file", Type: "file", Required: true, Description: "Excel workbook"},
+				{Name: "type", Required: true, Description: "pay-application, cost-ledger, bid or baseline"},
+				{Name: "jobNumber", Description: "Job number; required for pay-application, bid and baseline"},
+				{Name: "jobName", Description: "Job name for a new job (bid)"},
+				{Name: "date", Description: "Pay application month, e.g. 2025-01"},
+				{Name: "note", Description: "Baseline note"},
+			},
+			Response: service.UploadResult{},
+			Handler:  handleUpload(queries, overrunThreshold),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs",
+			Summary:  "List jobs",
+			Response: []database.GetAllJobsRow{},
+			Handler:  handleGetJobs(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}",
+			Summary:  "Get a job",
+			Response: service.JobDetails{},
+			Handler:  handleJob(queries),
+		},
+		{
+			Method:     http.MethodPost,
+			Path:       "/api/jobs/{job}",
+			Permission: service.PermissionEditJobs,
+			Summary:    "Create a job",
+			Request:    service.JobFields{},
+			Response:   service.JobDetails{},
+			Status:     http.StatusCreated,
+			Handler:    handleJob(queries),
+		},
+		{
+			Method:     http.MethodPatch,
+			Path:       "/api/jobs/{job}",
+			Permission: service.PermissionEditJobs,
+			Summary:    "Edit a job's contract metadata",
+			Request:    service.JobFields{},
+			Response:   service.JobDetails{},
+			Handler:    handleJob(queries),
+		},
+		{
+			Method:     http.MethodDelete,
+			Path:       "/api/jobs/{job}",
+			Permission: service.PermissionEditJobs,
+			Summary:    "Delete a job with no imported data",
+			Status:     http.StatusNoContent,
+			Handler:    handleJob(queries),
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/api/jobs/{job}/items",
+			Summary: "Bid items as a nested tree with rollups",
+			Query: []param{
+				{Name: "maxDepth", Type: "integer", Description: "Leave out items below this depth (1 = pay items only)"},
+				{Name: "method", Description: "Comma-separated cost methods to keep, e.g. Pay Item,Crew"},
+			},
+			Response: []service.JobItemNode{},
+			Handler:  handleGetJobItems(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/pay-apps",
+			Aliases:  []string{"/api/jobs/pay-apps"},
+			Summary:  "Monthly pay application totals with retainage",
+			Response: []service.PayAppSummary{},
+			Report:   true,
+			Handler:  handleGetPayAppSummaries(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/pay-apps/{month}",
+			Summary:  "A month's pay application by item",
+			Response: service.PayApp{},
+			Report:   true,
+			Handler:  handlePayApp(queries),
+		},
+		{
+			Method:     http.MethodPut,
+			Path:       "/api/jobs/{job}/pay-apps/{month}",
+			Permission: service.PermissionEditPayApps,
+			Summary:    "Set quantities and stored materials on a month's pay application",
+			Request:    PayAppEditRequest{},
+			Response:   service.PayApp{},
+			Handler:    handlePayApp(queries),
+		},
+		{
+			Method:     http.MethodPatch,
+			Path:       "/api/jobs/{job}/pay-apps/{month}",
+			Permission: service.PermissionEditPayApps,
+			Summary:    "Change quantities or stored materials on a month's pay application",
+			Request:    PayAppEditRequest{},
+			Response:   service.PayApp{},
+			Handler:    handlePayApp(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/pay-apps/{month}/edits",
+			Summary:  "Edit history of a month's pay application",
+			Response: []service.PayAppEdit{},
+			Report:   true,
+			Handler:  handleGetPayAppEdits(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/pay-apps/{month}/revisions",
+			Aliases:  []string{"/api/jobs/pay-app-revisions"},
+			Summary:  "Revisions of a month's pay application",
+			Response: []service.PayAppRevision{},
+			Report:   true,
+			Handler:  handleGetPayAppRevisions(queries),
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/api/jobs/{job}/pay-apps/{month}/revisions/diff",
+			Aliases: []string{"/api/jobs/pay-app-revisions/diff"},
+			Summary: "Compare two revisions of a month's pay application",
+			Query: []param{
+				{Name: "from", Type: "integer", Description: "Older revision, default the one before to"},
+				{Name: "to", Type: "integer", Description: "Newer revision, default the effective one"},
+				{Name: "all", Type: "boolean", Description: "Include unchanged items"},
+			},
+			Response: service.PayAppRevisionDiff{},
+			Report:   true,
+			Handler:  handleGetPayAppRevisionDiff(queries),
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/api/jobs/{job}/ledger",
+			Summary: "Search a job's cost ledger",
+			Query: []param{
+				{Name: "phase", Description: "Phase code"},
+				{Name: "cat", Description: "Cost category"},
+				{Name: "type", Description: "Transaction type"},
+				{Name: "class", Description: "Transaction type class, e.g. cost"},
+				{Name: "from", Description: "First transaction date, e.g. 2025-01-01"},
+				{Name: "to", Description: "Last transaction date, e.g. 2025-01-31"},
+				{Name: "minAmount", Type: "number"},
+				{Name: "maxAmount", Type: "number"},
+				{Name: "sort", Description: "transaction_date, phase, cat, transaction_type, amount, hours or units"},
+				{Name: "order", Description: "asc or desc"},
+				{Name: "groupBy", Description: "Subtotal by phase, cat, transaction_type or month"},
+				{Name: "limit", Type: "integer", Description: "Entries per page, at most 1000"},
+				{Name: "cursor", Description: "next_cursor from the previous page"},
+			},
+			Response: service.LedgerPage{},
+			Report:   true,
+			Handler:  handleGetLedger(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/cost-over-time",
+			Aliases:  []string{"/api/jobs/cost-over-time"},
+			Summary:  "Monthly cost and billed totals",
+			Query:    periodParams,
+			Response: []MonthlyPerformanceResponse{},
+			Report:   true,
+			Handler:  handleGetCostOverTime(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/cpi",
+			Aliases:  []string{"/api/jobs/cost-performance-index"},
+			Summary:  "Monthly cost performance index",
+			Query:    periodParams,
+			Response: []CPIResponse{},
+			Report:   true,
+			Handler:  handleGetCostPerformanceIndex(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/earned-value",
+			Aliases:  []string{"/api/jobs/earned-value"},
+			Summary:  "Monthly earned value metrics against a baseline",
+			Query:    append([]param{{Name: "baseline", Type: "integer", Description: "Baseline version, default the latest"}}, periodParams...),
+			Response: []EVMResponse{},
+			Report:   true,
+			Handler:  handleGetEarnedValue(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/over-budget-phases",
+			Aliases:  []string{"/api/jobs/over-budget-phases"},
+			Summary:  "Phases whose actual cost exceeds budget",
+			Query:    periodParams,
+			Response: []OverBudgetPhaseResponse{},
+			Report:   true,
+			Handler:  handleGetOverBudgetPhases(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/bid-export",
+			Aliases:  []string{"/api/jobs/bid-export"},
+			Summary:  "Download the bid as an Excel workbook",
+			Download: report.ContentTypeXLSX,
+			Handler:  handleExportBid(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/phase-cost-pivot",
+			Aliases:  []string{"/api/jobs/phase-cost-pivot"},
+			Summary:  "Cost by phase and month",
+			Query:    append([]param{{Name: "byCat", Type: "boolean", Description: "Split each phase by cost category"}}, periodParams...),
+			Response: service.PhaseCostPivot{},
+			Report:   true,
+			Handler:  handleGetPhaseCostPivot(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/phase-cpi",
+			Aliases:  []string{"/api/jobs/phase-cpi"},
+			Summary:  "Phases ranked by CPI, worst first",
+			Query:    append([]param{{Name: "limit", Type: "integer", Description: "Return only the worst phases"}}, periodParams...),
+			Response: []service.PhaseCPI{},
+			Report:   true,
+			Handler:  handleGetPhaseCPI(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/unit-costs",
+			Aliases:  []string{"/api/jobs/unit-costs"},
+			Summary:  "Actual against bid unit cost by item",
+			Query:    periodParams,
+			Response: []service.UnitCostRow{},
+			Report:   true,
+			Handler:  handleGetUnitCosts(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/productivity",
+			Aliases:  []string{"/api/jobs/productivity"},
+			Summary:  "Actual against bid production rates",
+			Query:    periodParams,
+			Response: []service.ProductivityRow{},
+			Report:   true,
+			Handler:  handleGetProductivity(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/overrun-alerts",
+			Aliases:  []string{"/api/jobs/overrun-alerts"},
+			Summary:  "Pay items billed past or near their contract quantity",
+			Query:    []param{{Name: "threshold", Type: "number", Description: "Percent of contract quantity to flag, default from the server"}},
+			Response: []service.QuantityOverrun{},
+			Report:   true,
+			Handler:  handleGetOverrunAlerts(queries, overrunThreshold),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/retainage",
+			Aliases:  []string{"/api/jobs/retainage"},
+			Summary:  "Retainage schedule",
+			Response: []service.RetainageTier{},
+			Handler:  handleRetainageTiers(queries),
+		},
+		{
+			Method:     http.MethodPut,
+			Path:       "/api/jobs/{job}/retainage",
+			Permission: service.PermissionEditJobs,
+			Aliases:    []string{"/api/jobs/retainage"},
+			Summary:    "Replace the retainage schedule",
+			Request:    []service.RetainageTier{},
+			Response:   []service.RetainageTier{},
+			Handler:    handleRetainageTiers(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/retainage-releases",
+			Aliases:  []string{"/api/jobs/retainage-releases"},
+			Summary:  "Retainage releases",
+			Response: []service.RetainageRelease{},
+			Handler:  handleRetainageReleases(queries),
+		},
+		{
+			Method:     http.MethodPost,
+			Path:       "/api/jobs/{job}/retainage-releases",
+			Permission: service.PermissionEditAccounting,
+			Aliases:    []string{"/api/jobs/retainage-releases"},
+			Summary:    "Record a retainage release",
+			Request:    RetainageReleaseRequest{},
+			Response:   []service.RetainageRelease{},
+			Handler:    handleRetainageReleases(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/stored-materials",
+			Aliases:  []string{"/api/jobs/stored-materials"},
+			Summary:  "Stored materials movement by item and month",
+			Response: []service.StoredMaterialsRow{},
+			Report:   true,
+			Handler:  handleGetStoredMaterials(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/change-orders",
+			Aliases:  []string{"/api/jobs/change-orders"},
+			Summary:  "Change orders",
+			Response: []service.ChangeOrder{},
+			Handler:  handleChangeOrders(queries),
+		},
+		{
+			Method:     http.MethodPost,
+			Path:       "/api/jobs/{job}/change-orders",
+			Permission: service.PermissionEditJobs,
+			Aliases:    []string{"/api/jobs/change-orders"},
+			Summary:    "Create a change order",
+			Request:    service.ChangeOrderInput{},
+			Response:   service.ChangeOrder{},
+			Status:     http.StatusCreated,
+			Handler:    handleChangeOrders(queries),
+		},
+		{
+			Method:     http.MethodPatch,
+			Path:       "/api/jobs/{job}/change-orders/{co}",
+			Permission: service.PermissionEditJobs,
+			Aliases:    []string{"/api/jobs/change-orders"},
+			Summary:    "Set a change order's status",
+			Request:    ChangeOrderStatusRequest{},
+			Status:     http.StatusNoContent,
+			Handler:    handleChangeOrders(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/contract-value",
+			Aliases:  []string{"/api/jobs/contract-value"},
+			Summary:  "Original and revised contract value",
+			Response: service.ContractValue{},
+			Handler:  handleGetContractValue(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/baselines",
+			Aliases:  []string{"/api/jobs/baselines"},
+			Summary:  "Planned value baselines",
+			Response: []service.Baseline{},
+			Handler:  handleBaselines(queries),
+		},
+		{
+			Method:     http.MethodPost,
+			Path:       "/api/jobs/{job}/baselines",
+			Permission: service.PermissionEditJobs,
+			Aliases:    []string{"/api/jobs/baselines"},
+			Summary:    "Save a new baseline version",
+			Request:    BaselineRequest{},
+			Response:   service.Baseline{},
+			Status:     http.StatusCreated,
+			Handler:    handleBaselines(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/periods",
+			Aliases:  []string{"/api/jobs/periods"},
+			Summary:  "Closed months",
+			Response: []service.ClosedPeriod{},
+			Handler:  handlePeriodCloses(queries),
+		},
+		{
+			Method:     http.MethodPost,
+			Path:       "/api/jobs/{job}/periods/close",
+			Permission: service.PermissionClosePeriods,
+			Aliases:    []string{"/api/jobs/periods/close"},
+			Summary:    "Close a month",
+			Request:    PeriodCloseRequest{},
+			Response:   []service.ClosedPeriod{},
+			Handler:    handleChangePeriod(queries, service.PeriodActionClose),
+		},
+		{
+			Method:     http.MethodPost,
+			Path:       "/api/jobs/{job}/periods/reopen",
+			Permission: service.PermissionClosePeriods,
+			Aliases:    []string{"/api/jobs/periods/reopen"},
+			Summary:    "Reopen a closed month",
+			Request:    PeriodCloseRequest{},
+			Response:   []service.ClosedPeriod{},
+			Handler:    handleChangePeriod(queries, service.PeriodActionReopen),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/periods/history",
+			Aliases:  []string{"/api/jobs/periods/history"},
+			Summary:  "Close and reopen history",
+			Response: []service.PeriodCloseEvent{},
+			Report:   true,
+			Handler:  handleGetPeriodHistory(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/jobs/{job}/cost-type-variance",
+			Aliases:  []string{"/api/jobs/cost-type-variance"},
+			Summary:  "Estimate against actual cost by cost type",
+			Query:    append([]param{{Name: "byPhase", Type: "boolean", Description: "Split each cost type by phase"}}, periodParams...),
+			Response: []service.CostTypeVarianceRow{},
+			Report:   true,
+			Handler:  handleGetCostTypeVariance(queries),
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/api/portfolio",
+			Summary: "Headline metrics for every job",
+			Query: []param{
+				{Name: "status", Description: "active, complete or closed"},
+				{Name: "sort", Description: "Field to sort by"},
+				{Name: "order", Description: "asc or desc"},
+				periodParams[1],
+				periodParams[2],
+			},
+			Response: []service.PortfolioJob{},
+			Report:   true,
+			Handler:  handleGetPortfolio(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/wip",
+			Summary:  "Work-in-progress schedule for active jobs",
+			Query:    []param{{Name: "month", Required: true, Description: "Schedule month, e.g. 2025-01"}},
+			Response: service.WIPSchedule{},
+			Report:   true,
+			Handler:  handleGetWIPSchedule(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/cost-type-mappings",
+			Summary:  "Ledger category to bid cost type mappings",
+			Response: []service.CostTypeMapping{},
+			Handler:  handleCostTypeMappings(queries),
+		},
+		{
+			Method:     http.MethodPut,
+			Path:       "/api/cost-type-mappings",
+			Permission: service.PermissionEditAccounting,
+			Summary:    "Replace the cost type mappings",
+			Request:    []service.CostTypeMapping{},
+			Response:   []service.CostTypeMapping{},
+			Handler:    handleCostTypeMappings(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/transaction-types",
+			Summary:  "Transaction type classes",
+			Response: []service.TransactionTypeClass{},
+			Handler:  handleTransactionTypeClasses(queries),
+		},
+		{
+			Method:     http.MethodPut,
+			Path:       "/api/transaction-types",
+			Permission: service.PermissionEditAccounting,
+			Summary:    "Add or reclassify transaction types",
+			Request:    []service.TransactionTypeClass{},
+			Response:   []service.TransactionTypeClass{},
+			Handler:    handleTransactionTypeClasses(queries),
+		},
+		{
+			Method:   http.MethodGet,
+			Path:     "/api/transaction-types/unclassified",
+			Summary:  "Ledger transaction types with no class",
+			Response: []service.UnclassifiedTransactionType{},
+			Report:   true,
+			Handler:  handleGetUnclassifiedTransactionTypes(queries),
+		},
+	}
+}
+
+// registerRoutes adds each route, and its aliases, to the default ServeMux
+// under a method pattern, so other methods get 405 Method Not Allowed.
+// Routes that aren't public are wrapped in requireUser.
+func registerRoutes(queries *database.Queries, routes []route) {
+	for _, rt := range routes {
+		handler := rt.Handler
+		if !rt.Public {
+			handler = requireUser(queries, rt.Permission, handler)
+		}
+		http.HandleFunc(rt.Method+" "+rt.Path, handler)
+		for _, alias := range rt.Aliases {
+			http.HandleFunc(rt.Method+" "+alias, handler)
+		}
+	}
+}
+
+// pathParam returns the named path wildcard, falling back to the query
+// parameter of the same name on the older query-string routes.
+func pathParam(r *http.Request, name string) string {
+	if v := r.PathValue(name); v != "" {
+		return v
+	}
+	return r.URL.Query().Get(name)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+func handleGetStoredMaterials(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobNumber := pathParam(r, "job")
+		if jobNumber == "" {
+			http.Error(w, "job is required", http.StatusBadRequest)
+			return
+		}
+
+		rows, err := service.GetStoredMaterials(context.Background(), queries, jobNumber)
+		if err != nil {
+			http.Error(w, "Failed to fetch stored materials: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "stored-materials-"+jobNumber, rows)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// handleTransactionTypeClasses lists (GET) or adds and reclassifies (PUT)
+// ledger transaction types.
+func handleTransactionTypeClasses(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			var classes []service.TransactionTypeClass
+			if err := json.NewDecoder(r.Body).Decode(&classes); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidateTransactionTypeClasses(classes); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.SetTransactionTypeClasses(context.Background(), queries, classes); err != nil {
+				http.Error(w, "Failed to save transaction type classes: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		classes, err := service.GetTransactionTypeClasses(context.Background(), queries)
+		if err != nil {
+			http.Error(w, "Failed to fetch transaction type classes: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(classes)
+	}
+}
+
+func handleGetUnclassifiedTransactionTypes(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		types, err := service.GetUnclassifiedTransactionTypes(context.Background(), queries)
+		if err != nil {
+			http.Error(w, "Failed to fetch unclassified transaction types: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report.Respond(w, r, "unclassified-transaction-types", types)
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type CreateUserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type UpdateUserRequest struct {
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type PasswordRequest struct {
+	CurrentPassword string `json:"current_password,omitempty"`
+	NewPassword     string `json:"new_password"`
+}
+
+// handleLogin checks a username and password (POST) and starts a session
+// cookie, returning the signed-in user.
+func handleLogin(queries *database.Queries, secureCookies bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req LoginRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		session, err := service.SignIn(context.Background(), queries, req.Username, req.Password)
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to sign in: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		setSessionCookie(w, session, secureCookies)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(session.User)
+	}
+}
+
+// handleLogout ends the caller's session (POST), if any, and clears the
+// cookie.
+func handleLogout(queries *database.Queries, secureCookies bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(sessionCookie); err == nil {
+			if err := service.SignOut(context.Background(), queries, c.Value); err != nil {
+				http.Error(w, "Failed to sign out: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		setSessionCookie(w, nil, secureCookies)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleGetMe returns the signed-in user.
+func handleGetMe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(currentUser(r))
+	}
+}
+
+// handleChangeMyPassword replaces the signed-in user's password (PUT) after
+// checking the current one. Every session, including this one, is ended.
+func handleChangeMyPassword(queries *database.Queries, secureCookies bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := service.ValidatePassword(req.NewPassword); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		err := service.ChangePassword(context.Background(), queries, currentUser(r).Username, req.CurrentPassword, req.NewPassword)
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			http.Error(w, "current_password is incorrect", http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			writeUserError(w, err)
+			return
+		}
+
+		setSessionCookie(w, nil, secureCookies)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleUsers lists (GET) or creates (POST) user accounts.
+func handleUsers(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.Background()
+
+		switch r.Method {
+		case http.MethodGet:
+			users, err := service.ListUsers(ctx, queries)
+			if err != nil {
+				http.Error(w, "Failed to fetch users: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(users)
+
+		case http.MethodPost:
+			var req CreateUserRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidateUsername(service.NormalizeUsername(req.Username)); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !service.ValidRole(req.Role) {
+				http.Error(w, "role must be viewer, project_manager, accounting or admin", http.StatusBadRequest)
+				return
+			}
+			if err := service.ValidatePassword(req.Password); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			user, err := service.CreateUser(ctx, queries, req.Username, req.Password, req.Role)
+			if err != nil {
+				writeUserError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(user)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleUser changes the role or disabled flag of (PATCH), or deletes
+// (DELETE), the user named in the path. PATCH changes only the fields present
+// in the body. The last enabled admin can't be demoted, disabled or deleted.
+func handleUser(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		username := r.PathValue("username")
+		ctx := context.Background()
+
+		switch r.Method {
+		case http.MethodPatch:
+			var req UpdateUserRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.Role == nil && req.Disabled == nil {
+				http.Error(w, "role or disabled is required", http.StatusBadRequest)
+				return
+			}
+			if req.Role != nil && !service.ValidRole(*req.Role) {
+				http.Error(w, "role must be viewer, project_manager, accounting or admin", http.StatusBadRequest)
+				return
+			}
+
+			user, err := service.UpdateUser(ctx, queries, username, req.Role, req.Disabled)
+			if err != nil {
+				writeUserError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(user)
+
+		case http.MethodDelete:
+			if err := service.DeleteUser(ctx, queries, username); err != nil {
+				writeUserError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// handleResetPassword sets the password of the user named in the path (PUT)
+// and signs them out everywhere.
+func handleResetPassword(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req PasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := service.ValidatePassword(req.NewPassword); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := service.SetPassword(context.Background(), queries, r.PathValue("username"), req.NewPassword); err != nil {
+			writeUserError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeUserError maps user account errors to HTTP statuses.
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrUserExists), errors.Is(err, service.ErrLastAdmin):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
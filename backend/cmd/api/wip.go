@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/lostboys08/ksc-go/backend/internal/service"
+)
+
+// handleGetWIPSchedule returns the work-in-progress schedule for all active
+// jobs as of ?month=. Excel downloads use the standard WIP layout; CSV is the
+// job rows followed by the totals row.
+func handleGetWIPSchedule(queries *database.Queries) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		monthStr := r.URL.Query().Get("month")
+		if monthStr == "" {
+			http.Error(w, "month query parameter is required", http.StatusBadRequest)
+			return
+		}
+		asOf, err := parseTargetDate(monthStr)
+		if err != nil {
+			http.Error(w, "Invalid month: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		format, err := report.NegotiateFormat(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filename := "wip-" + asOf.Format("2006-01")
+
+		switch format {
+		case report.FormatXLSX:
+			f, err := service.ExportWIPSchedule(context.Background(), queries, asOf)
+			if err != nil {
+				http.Error(w, "Export failed: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer f.Close()
+
+			w.Header().Set("Content-Type", report.ContentTypeXLSX)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".xlsx"))
+			if err := f.Write(w); err != nil {
+				log.Println("Error writing WIP export:", err)
+			}
+
+		default:
+			wip, err := service.GetWIPSchedule(context.Background(), queries, asOf)
+			if err != nil {
+				http.Error(w, "Failed to build WIP schedule: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if format == report.FormatJSON {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(wip)
+				return
+			}
+			report.Respond(w, r, filename, append(wip.Rows, wip.Totals))
+		}
+	}
+}
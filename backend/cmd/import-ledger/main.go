@@ -66,10 +66,32 @@ func main() {
 	// We keep: Job (0), Phase (1), Cat (2), Transaction Type (3), Transaction Date (4), Amount (6)
 	// We drop: Accounting Date (5), Description (7)
 
+	// Optional Hours and Units columns are kept for PR cost lines when present
+	colHours, colUnits := -1, -1
+	for i, cell := range rows[0] {
+		cellLower := strings.ToLower(strings.TrimSpace(cell))
+		if colHours == -1 && (strings.Contains(cellLower, "hours") || cellLower == "hrs") {
+			colHours = i
+		} else if colUnits == -1 && (strings.Contains(cellLower, "units") || cellLower == "quantity" || cellLower == "qty") {
+			colUnits = i
+		}
+	}
+
 	log.Printf("Found %d rows (including header)", len(rows))
 
+	// Rows dated in a job's closed month are left alone
+	closeRows, err := queries.GetAllPeriodCloses(ctx)
+	if err != nil {
+		log.Fatalf("Failed to fetch closed periods: %v", err)
+	}
+	closed := make(map[string]bool)
+	for _, c := range closeRows {
+		closed[c.JobNumber+"|"+c.PeriodMonth.Format("2006-01")] = true
+	}
+
 	inserted := 0
 	skipped := 0
+	locked := 0
 
 	for i, row := range rows {
 		if i == 0 {
@@ -119,6 +141,12 @@ func main() {
 			continue
 		}
 
+		if transactionDate.Valid && closed[job+"|"+transactionDate.Time.Format("2006-01")] {
+			log.Printf("Row %d: skipping, %s is closed for job %s", i+1, transactionDate.Time.Format("January 2006"), job)
+			locked++
+			continue
+		}
+
 		// Create hash from row content
 		hashInput := fmt.Sprintf("%s|%s|%s|%s|%s|%s", job, phase, cat, transactionType, transactionDateStr, amountStr)
 		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(hashInput)))
@@ -132,6 +160,10 @@ func main() {
 			TransactionDate: transactionDate,
 			Amount:          amount.String(),
 		}
+		if transactionType == "PR cost" {
+			params.Hours = parseQuantity(row, colHours)
+			params.Units = parseQuantity(row, colUnits)
+		}
 
 		err := queries.InsertJobCostLedger(ctx, params)
 		if err != nil {
@@ -142,7 +174,7 @@ func main() {
 		inserted++
 	}
 
-	log.Printf("Import completed: %d inserted, %d skipped", inserted, skipped)
+	log.Printf("Import completed: %d inserted, %d skipped, %d in closed periods", inserted, skipped, locked)
 }
 
 func parseDate(s string) (time.Time, error) {
@@ -172,3 +204,14 @@ func toNullString(s string) sql.NullString {
 	}
 	return sql.NullString{String: s, Valid: true}
 }
+
+func parseQuantity(row []string, idx int) sql.NullString {
+	if idx < 0 || idx >= len(row) {
+		return sql.NullString{}
+	}
+	d, err := decimal.NewFromString(strings.ReplaceAll(strings.TrimSpace(row[idx]), ",", ""))
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: d.String(), Valid: true}
+}
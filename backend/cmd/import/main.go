@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/google/uuid"
@@ -80,12 +81,12 @@ func main() {
 
 	// Parse and import
 	log.Printf("Importing data for %s...", targetDate.Format("January 2006"))
-	err = service.ParsePayApp(ctx, f, queries, jobID, targetDate)
+	revision, err := service.ParsePayApp(ctx, f, queries, jobID, targetDate, filepath.Base(*filePath))
 	if err != nil {
 		log.Fatalf("Failed to parse pay application: %v", err)
 	}
 
-	log.Println("Import completed successfully!")
+	log.Printf("Import completed successfully! Stored as revision %d", revision)
 }
 
 func parseDate(s string) (time.Time, error) {
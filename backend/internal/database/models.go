@@ -11,6 +11,40 @@ import (
 	"github.com/google/uuid"
 )
 
+type ChangeOrder struct {
+	ID          uuid.UUID    `json:"id"`
+	JobID       uuid.UUID    `json:"job_id"`
+	CoNumber    string       `json:"co_number"`
+	Description string       `json:"description"`
+	Status      string       `json:"status"`
+	CoDate      time.Time    `json:"co_date"`
+	Amount      string       `json:"amount"`
+	CreatedAt   sql.NullTime `json:"created_at"`
+	UpdatedAt   sql.NullTime `json:"updated_at"`
+}
+
+type ChangeOrderItem struct {
+	ID             uuid.UUID      `json:"id"`
+	ChangeOrderID  uuid.UUID      `json:"change_order_id"`
+	JobItemID      uuid.NullUUID  `json:"job_item_id"`
+	QtyChange      string         `json:"qty_change"`
+	NewUnitPrice   sql.NullString `json:"new_unit_price"`
+	Amount         string         `json:"amount"`
+	CreatedAt      sql.NullTime   `json:"created_at"`
+	NewItemNumber  sql.NullString `json:"new_item_number"`
+	NewParentID    uuid.NullUUID  `json:"new_parent_id"`
+	NewDescription sql.NullString `json:"new_description"`
+	NewUnit        sql.NullString `json:"new_unit"`
+	NewJobCostID   sql.NullString `json:"new_job_cost_id"`
+	NewBudget      sql.NullString `json:"new_budget"`
+}
+
+type CostTypeMapping struct {
+	Cat       string       `json:"cat"`
+	CostType  string       `json:"cost_type"`
+	CreatedAt sql.NullTime `json:"created_at"`
+}
+
 type Job struct {
 	ID                   uuid.UUID      `json:"id"`
 	JobNumber            string         `json:"job_number"`
@@ -23,6 +57,7 @@ type Job struct {
 	EndDate              sql.NullTime   `json:"end_date"`
 	CreatedAt            sql.NullTime   `json:"created_at"`
 	UpdatedAt            sql.NullTime   `json:"updated_at"`
+	Status               string         `json:"status"`
 }
 
 type JobCostLedger struct {
@@ -34,6 +69,8 @@ type JobCostLedger struct {
 	TransactionDate sql.NullTime   `json:"transaction_date"`
 	Amount          string         `json:"amount"`
 	CreatedAt       sql.NullTime   `json:"created_at"`
+	Hours           sql.NullString `json:"hours"`
+	Units           sql.NullString `json:"units"`
 }
 
 type JobItem struct {
@@ -67,15 +104,87 @@ type JobItem struct {
 	Bond            string         `json:"bond"`
 	Overhead        string         `json:"overhead"`
 	Profit          string         `json:"profit"`
+	ChangeOrderID   uuid.NullUUID  `json:"change_order_id"`
+}
+
+type JobItemRoot struct {
+	ID     uuid.UUID      `json:"id"`
+	JobID  uuid.UUID      `json:"job_id"`
+	RootID uuid.UUID      `json:"root_id"`
+	Phase  sql.NullString `json:"phase"`
+}
+
+type JobItemsRevised struct {
+	ID                     uuid.UUID     `json:"id"`
+	JobID                  uuid.UUID     `json:"job_id"`
+	ParentID               uuid.NullUUID `json:"parent_id"`
+	ChangeOrderID          uuid.NullUUID `json:"change_order_id"`
+	ItemNumber             string        `json:"item_number"`
+	Description            string        `json:"description"`
+	Budget                 string        `json:"budget"`
+	OriginalQty            string        `json:"original_qty"`
+	OriginalUnitPrice      string        `json:"original_unit_price"`
+	OriginalScheduledValue string        `json:"original_scheduled_value"`
+	Qty                    string        `json:"qty"`
+	UnitPrice              string        `json:"unit_price"`
+	ScheduledValue         string        `json:"scheduled_value"`
+}
+
+type JobStatusChange struct {
+	ID        uuid.UUID `json:"id"`
+	JobID     uuid.UUID `json:"job_id"`
+	Status    string    `json:"status"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type PayAppMonthlyTotal struct {
+	JobID           uuid.UUID `json:"job_id"`
+	PayAppMonth     time.Time `json:"pay_app_month"`
+	ScheduledValue  string    `json:"scheduled_value"`
+	WorkThisPeriod  string    `json:"work_this_period"`
+	WorkToDate      string    `json:"work_to_date"`
+	StoredMaterials string    `json:"stored_materials"`
+}
+
+type PayAppEdit struct {
+	ID          uuid.UUID      `json:"id"`
+	JobID       uuid.UUID      `json:"job_id"`
+	JobItemID   uuid.UUID      `json:"job_item_id"`
+	PayAppMonth time.Time      `json:"pay_app_month"`
+	Revision    int32          `json:"revision"`
+	Field       string         `json:"field"`
+	OldValue    string         `json:"old_value"`
+	NewValue    string         `json:"new_value"`
+	EditedBy    string         `json:"edited_by"`
+	Reason      sql.NullString `json:"reason"`
+	CreatedAt   sql.NullTime   `json:"created_at"`
+}
+
+type PayAppRevision struct {
+	ID          uuid.UUID      `json:"id"`
+	JobID       uuid.UUID      `json:"job_id"`
+	PayAppMonth time.Time      `json:"pay_app_month"`
+	Revision    int32          `json:"revision"`
+	Source      sql.NullString `json:"source"`
+	CreatedAt   sql.NullTime   `json:"created_at"`
+}
+
+type PayAppRevisionItem struct {
+	RevisionID      uuid.UUID `json:"revision_id"`
+	JobItemID       uuid.UUID `json:"job_item_id"`
+	Qty             string    `json:"qty"`
+	StoredMaterials string    `json:"stored_materials"`
 }
 
 type PayApplication struct {
-	ID              uuid.UUID    `json:"id"`
-	JobItemID       uuid.UUID    `json:"job_item_id"`
-	PayAppMonth     time.Time    `json:"pay_app_month"`
-	Qty             string       `json:"qty"`
-	StoredMaterials string       `json:"stored_materials"`
-	UpdatedAt       sql.NullTime `json:"updated_at"`
+	ID                 uuid.UUID      `json:"id"`
+	JobItemID          uuid.UUID      `json:"job_item_id"`
+	PayAppMonth        time.Time      `json:"pay_app_month"`
+	Qty                string         `json:"qty"`
+	StoredMaterials    string         `json:"stored_materials"`
+	UpdatedAt          sql.NullTime   `json:"updated_at"`
+	MaterialsAdded     string         `json:"materials_added"`
+	MaterialsInstalled sql.NullString `json:"materials_installed"`
 }
 
 type PayApplicationCumulative struct {
@@ -96,3 +205,88 @@ type PayApplicationCumulative struct {
 	CumulativeAmount         string        `json:"cumulative_amount"`
 	PreviousCumulativeAmount string        `json:"previous_cumulative_amount"`
 }
+
+type PeriodClose struct {
+	JobID       uuid.UUID    `json:"job_id"`
+	PeriodMonth time.Time    `json:"period_month"`
+	ClosedBy    string       `json:"closed_by"`
+	ClosedAt    sql.NullTime `json:"closed_at"`
+}
+
+type PeriodCloseEvent struct {
+	ID          uuid.UUID      `json:"id"`
+	JobID       uuid.UUID      `json:"job_id"`
+	PeriodMonth time.Time      `json:"period_month"`
+	Action      string         `json:"action"`
+	Actor       string         `json:"actor"`
+	Reason      sql.NullString `json:"reason"`
+	CreatedAt   sql.NullTime   `json:"created_at"`
+}
+
+type PvBaseline struct {
+	ID        uuid.UUID      `json:"id"`
+	JobID     uuid.UUID      `json:"job_id"`
+	Version   int32          `json:"version"`
+	Method    string         `json:"method"`
+	Note      sql.NullString `json:"note"`
+	CreatedAt sql.NullTime   `json:"created_at"`
+}
+
+type PvBaselineMonth struct {
+	BaselineID   uuid.UUID `json:"baseline_id"`
+	Month        time.Time `json:"month"`
+	PlannedValue string    `json:"planned_value"`
+}
+
+type RetainageRelease struct {
+	ID           uuid.UUID      `json:"id"`
+	JobID        uuid.UUID      `json:"job_id"`
+	ReleaseMonth time.Time      `json:"release_month"`
+	Amount       string         `json:"amount"`
+	Note         sql.NullString `json:"note"`
+	CreatedAt    sql.NullTime   `json:"created_at"`
+}
+
+type RetainageTier struct {
+	ID               uuid.UUID    `json:"id"`
+	JobID            uuid.UUID    `json:"job_id"`
+	ThresholdPercent string       `json:"threshold_percent"`
+	WorkRate         string       `json:"work_rate"`
+	MaterialsRate    string       `json:"materials_rate"`
+	CreatedAt        sql.NullTime `json:"created_at"`
+}
+
+type Session struct {
+	TokenHash string       `json:"token_hash"`
+	UserID    uuid.UUID    `json:"user_id"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	CreatedAt sql.NullTime `json:"created_at"`
+}
+
+type StoredMaterialsLedger struct {
+	JobItemID          uuid.UUID `json:"job_item_id"`
+	JobID              uuid.UUID `json:"job_id"`
+	ItemNumber         string    `json:"item_number"`
+	PayAppMonth        time.Time `json:"pay_app_month"`
+	PreviousBalance    string    `json:"previous_balance"`
+	MaterialsAdded     string    `json:"materials_added"`
+	MaterialsInstalled string    `json:"materials_installed"`
+	Balance            string    `json:"balance"`
+}
+
+type TransactionTypeClass struct {
+	TransactionType string       `json:"transaction_type"`
+	Class           string       `json:"class"`
+	CreatedAt       sql.NullTime `json:"created_at"`
+	UpdatedAt       sql.NullTime `json:"updated_at"`
+}
+
+type User struct {
+	ID           uuid.UUID    `json:"id"`
+	Username     string       `json:"username"`
+	PasswordHash string       `json:"password_hash"`
+	Role         string       `json:"role"`
+	Disabled     bool         `json:"disabled"`
+	CreatedAt    sql.NullTime `json:"created_at"`
+	UpdatedAt    sql.NullTime `json:"updated_at"`
+}
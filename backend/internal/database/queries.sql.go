@@ -13,6 +13,315 @@ import (
 	"github.com/google/uuid"
 )
 
+const clearChangeOrderNewItems = `-- name: ClearChangeOrderNewItems :exec
+UPDATE change_order_items
+SET job_item_id = NULL
+WHERE change_order_id = $1 AND new_item_number IS NOT NULL
+`
+
+// Unlinks the items a change order added from its lines, which keep their details
+func (q *Queries) ClearChangeOrderNewItems(ctx context.Context, changeOrderID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, clearChangeOrderNewItems, changeOrderID)
+	return err
+}
+
+const countActiveAdmins = `-- name: CountActiveAdmins :one
+SELECT COUNT(*) FROM users WHERE role = 'admin' AND NOT disabled
+`
+
+// Counts the enabled admins, so the last one can't be demoted or removed
+func (q *Queries) CountActiveAdmins(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countActiveAdmins)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countChangeOrderItemsInUse = `-- name: CountChangeOrderItemsInUse :one
+SELECT COUNT(*)
+FROM job_items ji
+WHERE ji.change_order_id = $1
+  AND (
+    EXISTS (SELECT 1 FROM pay_applications pa WHERE pa.job_item_id = ji.id)
+    OR EXISTS (SELECT 1 FROM pay_app_revision_items r WHERE r.job_item_id = ji.id)
+    OR EXISTS (SELECT 1 FROM job_items c WHERE c.parent_id = ji.id)
+    OR EXISTS (
+        SELECT 1 FROM change_order_items coi
+        WHERE coi.job_item_id = ji.id AND coi.change_order_id <> ji.change_order_id
+    )
+  )
+`
+
+// Counts the items a change order added that have been billed, have child
+// items or are touched by another change order
+func (q *Queries) CountChangeOrderItemsInUse(ctx context.Context, changeOrderID uuid.NullUUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChangeOrderItemsInUse, changeOrderID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countJobLedgerRows = `-- name: CountJobLedgerRows :one
+SELECT COUNT(*) FROM job_cost_ledger WHERE job = $1
+`
+
+// Counts a job's ledger rows, which refer to the job by number without a foreign key
+func (q *Queries) CountJobLedgerRows(ctx context.Context, job string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countJobLedgerRows, job)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countUsers = `-- name: CountUsers :one
+SELECT COUNT(*) FROM users
+`
+
+func (q *Queries) CountUsers(ctx context.Context) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsers)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createBaseline = `-- name: CreateBaseline :one
+INSERT INTO pv_baselines (
+    job_id, version, method, note
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING id, job_id, version, method, note, created_at
+`
+
+type CreateBaselineParams struct {
+	JobID   uuid.UUID      `json:"job_id"`
+	Version int32          `json:"version"`
+	Method  string         `json:"method"`
+	Note    sql.NullString `json:"note"`
+}
+
+func (q *Queries) CreateBaseline(ctx context.Context, arg CreateBaselineParams) (PvBaseline, error) {
+	row := q.db.QueryRowContext(ctx, createBaseline,
+		arg.JobID,
+		arg.Version,
+		arg.Method,
+		arg.Note,
+	)
+	var i PvBaseline
+	err := row.Scan(
+		&i.ID,
+		&i.JobID,
+		&i.Version,
+		&i.Method,
+		&i.Note,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createChangeOrder = `-- name: CreateChangeOrder :one
+INSERT INTO change_orders (
+    job_id, co_number, description, status, co_date, amount
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING id, job_id, co_number, description, status, co_date, amount, created_at, updated_at
+`
+
+type CreateChangeOrderParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	CoNumber    string    `json:"co_number"`
+	Description string    `json:"description"`
+	Status      string    `json:"status"`
+	CoDate      time.Time `json:"co_date"`
+	Amount      string    `json:"amount"`
+}
+
+func (q *Queries) CreateChangeOrder(ctx context.Context, arg CreateChangeOrderParams) (ChangeOrder, error) {
+	row := q.db.QueryRowContext(ctx, createChangeOrder,
+		arg.JobID,
+		arg.CoNumber,
+		arg.Description,
+		arg.Status,
+		arg.CoDate,
+		arg.Amount,
+	)
+	var i ChangeOrder
+	err := row.Scan(
+		&i.ID,
+		&i.JobID,
+		&i.CoNumber,
+		&i.Description,
+		&i.Status,
+		&i.CoDate,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const createJob = `-- name: CreateJob :one
+INSERT INTO jobs (
+    job_number, job_name, contract_value, address,
+    scr_number, contract_complete_date, start_date, end_date, status
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING id, job_number, job_name, contract_value, address, scr_number,
+          contract_complete_date, start_date, end_date, created_at, updated_at, status
+`
+
+type CreateJobParams struct {
+	JobNumber            string         `json:"job_number"`
+	JobName              string         `json:"job_name"`
+	ContractValue        sql.NullString `json:"contract_value"`
+	Address              sql.NullString `json:"address"`
+	ScrNumber            sql.NullString `json:"scr_number"`
+	ContractCompleteDate sql.NullTime   `json:"contract_complete_date"`
+	StartDate            sql.NullTime   `json:"start_date"`
+	EndDate              sql.NullTime   `json:"end_date"`
+	Status               string         `json:"status"`
+}
+
+func (q *Queries) CreateJob(ctx context.Context, arg CreateJobParams) (Job, error) {
+	row := q.db.QueryRowContext(ctx, createJob,
+		arg.JobNumber,
+		arg.JobName,
+		arg.ContractValue,
+		arg.Address,
+		arg.ScrNumber,
+		arg.ContractCompleteDate,
+		arg.StartDate,
+		arg.EndDate,
+		arg.Status,
+	)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.JobNumber,
+		&i.JobName,
+		&i.ContractValue,
+		&i.Address,
+		&i.ScrNumber,
+		&i.ContractCompleteDate,
+		&i.StartDate,
+		&i.EndDate,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+	)
+	return i, err
+}
+
+const createPayAppRevision = `-- name: CreatePayAppRevision :one
+INSERT INTO pay_app_revisions (
+    job_id, pay_app_month, revision, source
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING id
+`
+
+type CreatePayAppRevisionParams struct {
+	JobID       uuid.UUID      `json:"job_id"`
+	PayAppMonth time.Time      `json:"pay_app_month"`
+	Revision    int32          `json:"revision"`
+	Source      sql.NullString `json:"source"`
+}
+
+func (q *Queries) CreatePayAppRevision(ctx context.Context, arg CreatePayAppRevisionParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, createPayAppRevision,
+		arg.JobID,
+		arg.PayAppMonth,
+		arg.Revision,
+		arg.Source,
+	)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createSession = `-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, expires_at)
+VALUES ($1, $2, $3)
+`
+
+type CreateSessionParams struct {
+	TokenHash string    `json:"token_hash"`
+	UserID    uuid.UUID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) error {
+	_, err := q.db.ExecContext(ctx, createSession, arg.TokenHash, arg.UserID, arg.ExpiresAt)
+	return err
+}
+
+const createUser = `-- name: CreateUser :one
+INSERT INTO users (username, password_hash, role)
+VALUES ($1, $2, $3)
+RETURNING id, username, password_hash, role, disabled, created_at, updated_at
+`
+
+type CreateUserParams struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, createUser, arg.Username, arg.PasswordHash, arg.Role)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const deleteChangeOrderJobItems = `-- name: DeleteChangeOrderJobItems :exec
+DELETE FROM job_items
+WHERE change_order_id = $1
+`
+
+func (q *Queries) DeleteChangeOrderJobItems(ctx context.Context, changeOrderID uuid.NullUUID) error {
+	_, err := q.db.ExecContext(ctx, deleteChangeOrderJobItems, changeOrderID)
+	return err
+}
+
+const deleteCostTypeMappings = `-- name: DeleteCostTypeMappings :exec
+DELETE FROM cost_type_mappings
+`
+
+func (q *Queries) DeleteCostTypeMappings(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteCostTypeMappings)
+	return err
+}
+
+const deleteExpiredSessions = `-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at <= NOW()
+`
+
+func (q *Queries) DeleteExpiredSessions(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredSessions)
+	return err
+}
+
+const deleteJob = `-- name: DeleteJob :exec
+DELETE FROM jobs WHERE job_number = $1
+`
+
+func (q *Queries) DeleteJob(ctx context.Context, jobNumber string) error {
+	_, err := q.db.ExecContext(ctx, deleteJob, jobNumber)
+	return err
+}
+
 const deleteJobItemsByJob = `-- name: DeleteJobItemsByJob :exec
 DELETE FROM job_items WHERE job_id = $1
 `
@@ -22,6 +331,60 @@ func (q *Queries) DeleteJobItemsByJob(ctx context.Context, jobID uuid.UUID) erro
 	return err
 }
 
+const deletePeriodClose = `-- name: DeletePeriodClose :execrows
+DELETE FROM period_closes
+WHERE job_id = $1 AND period_month = $2
+`
+
+type DeletePeriodCloseParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PeriodMonth time.Time `json:"period_month"`
+}
+
+func (q *Queries) DeletePeriodClose(ctx context.Context, arg DeletePeriodCloseParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deletePeriodClose, arg.JobID, arg.PeriodMonth)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteRetainageTiersByJob = `-- name: DeleteRetainageTiersByJob :exec
+DELETE FROM retainage_tiers WHERE job_id = $1
+`
+
+func (q *Queries) DeleteRetainageTiersByJob(ctx context.Context, jobID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteRetainageTiersByJob, jobID)
+	return err
+}
+
+const deleteSession = `-- name: DeleteSession :exec
+DELETE FROM sessions WHERE token_hash = $1
+`
+
+func (q *Queries) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteSession, tokenHash)
+	return err
+}
+
+const deleteUser = `-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUser, id)
+	return err
+}
+
+const deleteUserSessions = `-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = $1
+`
+
+func (q *Queries) DeleteUserSessions(ctx context.Context, userID uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, deleteUserSessions, userID)
+	return err
+}
+
 const getAllJobs = `-- name: GetAllJobs :many
 SELECT id, job_number, job_name FROM jobs ORDER BY job_number
 `
@@ -55,54 +418,131 @@ func (q *Queries) GetAllJobs(ctx context.Context) ([]GetAllJobsRow, error) {
 	return items, nil
 }
 
-const getChildrenWithPayApps = `-- name: GetChildrenWithPayApps :many
+const getAllPeriodCloses = `-- name: GetAllPeriodCloses :many
+SELECT j.job_number, pc.period_month
+FROM period_closes pc
+JOIN jobs j ON pc.job_id = j.id
+ORDER BY j.job_number, pc.period_month
+`
+
+type GetAllPeriodClosesRow struct {
+	JobNumber   string    `json:"job_number"`
+	PeriodMonth time.Time `json:"period_month"`
+}
+
+// Fetches every closed month keyed by job number, for the cost ledger import
+func (q *Queries) GetAllPeriodCloses(ctx context.Context) ([]GetAllPeriodClosesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getAllPeriodCloses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetAllPeriodClosesRow
+	for rows.Next() {
+		var i GetAllPeriodClosesRow
+		if err := rows.Scan(&i.JobNumber, &i.PeriodMonth); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getBaselineByVersion = `-- name: GetBaselineByVersion :one
+SELECT id, job_id, version, method, note, created_at
+FROM pv_baselines
+WHERE job_id = $1 AND version = $2
+`
+
+type GetBaselineByVersionParams struct {
+	JobID   uuid.UUID `json:"job_id"`
+	Version int32     `json:"version"`
+}
+
+func (q *Queries) GetBaselineByVersion(ctx context.Context, arg GetBaselineByVersionParams) (PvBaseline, error) {
+	row := q.db.QueryRowContext(ctx, getBaselineByVersion, arg.JobID, arg.Version)
+	var i PvBaseline
+	err := row.Scan(
+		&i.ID,
+		&i.JobID,
+		&i.Version,
+		&i.Method,
+		&i.Note,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getBaselineMonths = `-- name: GetBaselineMonths :many
 SELECT
-    ji.id,
-    ji.item_number,
-    ji.qty AS total_qty,
-    ji.unit_price,
-    COALESCE(pa.qty, '0')::TEXT AS pay_app_qty,
-    COALESCE(pac.cumulative_qty, '0')::TEXT AS cumulative_qty,
-    COALESCE(pac.previous_cumulative_qty, '0')::TEXT AS previous_cumulative_qty
-FROM job_items ji
-LEFT JOIN pay_applications pa ON ji.id = pa.job_item_id AND pa.pay_app_month = $2
-LEFT JOIN pay_application_cumulative pac ON ji.id = pac.job_item_id AND pac.pay_app_month = $2
-WHERE ji.parent_id = $1
+    month,
+    planned_value::TEXT AS planned_value,
+    SUM(planned_value) OVER (ORDER BY month)::TEXT AS cumulative_planned_value
+FROM pv_baseline_months
+WHERE baseline_id = $1
+ORDER BY month
 `
 
-type GetChildrenWithPayAppsParams struct {
-	ParentID    uuid.NullUUID `json:"parent_id"`
-	PayAppMonth time.Time     `json:"pay_app_month"`
+type GetBaselineMonthsRow struct {
+	Month                  time.Time `json:"month"`
+	PlannedValue           string    `json:"planned_value"`
+	CumulativePlannedValue string    `json:"cumulative_planned_value"`
 }
 
-type GetChildrenWithPayAppsRow struct {
-	ID                    uuid.UUID `json:"id"`
-	ItemNumber            string    `json:"item_number"`
-	TotalQty              string    `json:"total_qty"`
-	UnitPrice             string    `json:"unit_price"`
-	PayAppQty             string    `json:"pay_app_qty"`
-	CumulativeQty         string    `json:"cumulative_qty"`
-	PreviousCumulativeQty string    `json:"previous_cumulative_qty"`
+// Fetches a baseline's monthly and cumulative planned value
+func (q *Queries) GetBaselineMonths(ctx context.Context, baselineID uuid.UUID) ([]GetBaselineMonthsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getBaselineMonths, baselineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetBaselineMonthsRow
+	for rows.Next() {
+		var i GetBaselineMonthsRow
+		if err := rows.Scan(&i.Month, &i.PlannedValue, &i.CumulativePlannedValue); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-// Fetches children of a parent along with their pay_app data for a specific month
-func (q *Queries) GetChildrenWithPayApps(ctx context.Context, arg GetChildrenWithPayAppsParams) ([]GetChildrenWithPayAppsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getChildrenWithPayApps, arg.ParentID, arg.PayAppMonth)
+const getBaselinesByJob = `-- name: GetBaselinesByJob :many
+SELECT id, job_id, version, method, note, created_at
+FROM pv_baselines
+WHERE job_id = $1
+ORDER BY version DESC
+`
+
+// Fetches every baseline version for a job, newest first
+func (q *Queries) GetBaselinesByJob(ctx context.Context, jobID uuid.UUID) ([]PvBaseline, error) {
+	rows, err := q.db.QueryContext(ctx, getBaselinesByJob, jobID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetChildrenWithPayAppsRow
+	var items []PvBaseline
 	for rows.Next() {
-		var i GetChildrenWithPayAppsRow
+		var i PvBaseline
 		if err := rows.Scan(
 			&i.ID,
-			&i.ItemNumber,
-			&i.TotalQty,
-			&i.UnitPrice,
-			&i.PayAppQty,
-			&i.CumulativeQty,
-			&i.PreviousCumulativeQty,
+			&i.JobID,
+			&i.Version,
+			&i.Method,
+			&i.Note,
+			&i.CreatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -117,26 +557,325 @@ func (q *Queries) GetChildrenWithPayApps(ctx context.Context, arg GetChildrenWit
 	return items, nil
 }
 
-const getCostPerformanceIndex = `-- name: GetCostPerformanceIndex :many
-WITH job_info AS (
-    SELECT id, job_number, contract_value
-    FROM jobs
-    WHERE job_number = $1
-),
-total_scheduled AS (
-    -- Sum of all scheduled quantities and calculated values (only leaf items with qty > 0)
+const getChangeOrderByNumber = `-- name: GetChangeOrderByNumber :one
+SELECT id, job_id, co_number, description, status, co_date, amount, created_at, updated_at
+FROM change_orders
+WHERE job_id = $1 AND co_number = $2
+`
+
+type GetChangeOrderByNumberParams struct {
+	JobID    uuid.UUID `json:"job_id"`
+	CoNumber string    `json:"co_number"`
+}
+
+func (q *Queries) GetChangeOrderByNumber(ctx context.Context, arg GetChangeOrderByNumberParams) (ChangeOrder, error) {
+	row := q.db.QueryRowContext(ctx, getChangeOrderByNumber, arg.JobID, arg.CoNumber)
+	var i ChangeOrder
+	err := row.Scan(
+		&i.ID,
+		&i.JobID,
+		&i.CoNumber,
+		&i.Description,
+		&i.Status,
+		&i.CoDate,
+		&i.Amount,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getChangeOrderClosedRepricedMonths = `-- name: GetChangeOrderClosedRepricedMonths :many
+SELECT DISTINCT pa.pay_app_month
+FROM change_order_items coi
+JOIN change_orders co ON coi.change_order_id = co.id
+JOIN pay_applications pa ON pa.job_item_id = coi.job_item_id
+JOIN period_closes pc ON pc.job_id = co.job_id AND pc.period_month = pa.pay_app_month
+WHERE coi.change_order_id = $1
+  AND coi.new_unit_price IS NOT NULL
+  AND pa.pay_app_month >= DATE_TRUNC('month', co.co_date)
+ORDER BY pa.pay_app_month
+`
+
+// Fetches the closed months, from the change order's month on, in which an
+// item the change order reprices was billed
+func (q *Queries) GetChangeOrderClosedRepricedMonths(ctx context.Context, changeOrderID uuid.UUID) ([]time.Time, error) {
+	rows, err := q.db.QueryContext(ctx, getChangeOrderClosedRepricedMonths, changeOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []time.Time
+	for rows.Next() {
+		var pay_app_month time.Time
+		if err := rows.Scan(&pay_app_month); err != nil {
+			return nil, err
+		}
+		items = append(items, pay_app_month)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChangeOrderItems = `-- name: GetChangeOrderItems :many
+SELECT
+    coi.id,
+    coi.job_item_id,
+    COALESCE(ji.item_number, coi.new_item_number)::TEXT AS item_number,
+    COALESCE(ji.description, coi.new_description, '')::TEXT AS description,
+    (coi.new_item_number IS NOT NULL)::BOOLEAN AS new_item,
+    coi.qty_change::TEXT AS qty_change,
+    coi.new_unit_price,
+    coi.amount::TEXT AS amount
+FROM change_order_items coi
+LEFT JOIN job_items ji ON coi.job_item_id = ji.id
+WHERE coi.change_order_id = $1
+ORDER BY item_number
+`
+
+type GetChangeOrderItemsRow struct {
+	ID           uuid.UUID      `json:"id"`
+	JobItemID    uuid.NullUUID  `json:"job_item_id"`
+	ItemNumber   string         `json:"item_number"`
+	Description  string         `json:"description"`
+	NewItem      bool           `json:"new_item"`
+	QtyChange    string         `json:"qty_change"`
+	NewUnitPrice sql.NullString `json:"new_unit_price"`
+	Amount       string         `json:"amount"`
+}
+
+// Fetches the line items of a change order with the job item they touch, or
+// the item they add
+func (q *Queries) GetChangeOrderItems(ctx context.Context, changeOrderID uuid.UUID) ([]GetChangeOrderItemsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChangeOrderItems, changeOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChangeOrderItemsRow
+	for rows.Next() {
+		var i GetChangeOrderItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.JobItemID,
+			&i.ItemNumber,
+			&i.Description,
+			&i.NewItem,
+			&i.QtyChange,
+			&i.NewUnitPrice,
+			&i.Amount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChangeOrderNewItems = `-- name: GetChangeOrderNewItems :many
+SELECT
+    id,
+    job_item_id,
+    new_item_number::TEXT AS item_number,
+    new_parent_id AS parent_id,
+    COALESCE(new_description, '')::TEXT AS description,
+    new_unit AS unit,
+    new_job_cost_id AS job_cost_id,
+    COALESCE(new_budget, 0)::TEXT AS budget,
+    COALESCE(new_unit_price, 0)::TEXT AS unit_price
+FROM change_order_items
+WHERE change_order_id = $1 AND new_item_number IS NOT NULL
+ORDER BY new_item_number
+`
+
+type GetChangeOrderNewItemsRow struct {
+	ID          uuid.UUID      `json:"id"`
+	JobItemID   uuid.NullUUID  `json:"job_item_id"`
+	ItemNumber  string         `json:"item_number"`
+	ParentID    uuid.NullUUID  `json:"parent_id"`
+	Description string         `json:"description"`
+	Unit        sql.NullString `json:"unit"`
+	JobCostID   sql.NullString `json:"job_cost_id"`
+	Budget      string         `json:"budget"`
+	UnitPrice   string         `json:"unit_price"`
+}
+
+// Fetches the lines of a change order that add an item
+func (q *Queries) GetChangeOrderNewItems(ctx context.Context, changeOrderID uuid.UUID) ([]GetChangeOrderNewItemsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChangeOrderNewItems, changeOrderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChangeOrderNewItemsRow
+	for rows.Next() {
+		var i GetChangeOrderNewItemsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.JobItemID,
+			&i.ItemNumber,
+			&i.ParentID,
+			&i.Description,
+			&i.Unit,
+			&i.JobCostID,
+			&i.Budget,
+			&i.UnitPrice,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChangeOrdersByJob = `-- name: GetChangeOrdersByJob :many
+SELECT id, job_id, co_number, description, status, co_date, amount, created_at, updated_at
+FROM change_orders
+WHERE job_id = $1
+ORDER BY co_date, co_number
+`
+
+// Fetches all change orders for a job in CO date order
+func (q *Queries) GetChangeOrdersByJob(ctx context.Context, jobID uuid.UUID) ([]ChangeOrder, error) {
+	rows, err := q.db.QueryContext(ctx, getChangeOrdersByJob, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChangeOrder
+	for rows.Next() {
+		var i ChangeOrder
+		if err := rows.Scan(
+			&i.ID,
+			&i.JobID,
+			&i.CoNumber,
+			&i.Description,
+			&i.Status,
+			&i.CoDate,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChildrenWithPayApps = `-- name: GetChildrenWithPayApps :many
+SELECT
+    ji.id,
+    ji.item_number,
+    ji.qty AS total_qty,
+    ji.unit_price,
+    COALESCE(pa.qty, '0')::TEXT AS pay_app_qty,
+    COALESCE(pac.cumulative_qty, '0')::TEXT AS cumulative_qty,
+    COALESCE(pac.previous_cumulative_qty, '0')::TEXT AS previous_cumulative_qty
+FROM job_items ji
+LEFT JOIN pay_applications pa ON ji.id = pa.job_item_id AND pa.pay_app_month = $2
+LEFT JOIN pay_application_cumulative pac ON ji.id = pac.job_item_id AND pac.pay_app_month = $2
+WHERE ji.parent_id = $1
+`
+
+type GetChildrenWithPayAppsParams struct {
+	ParentID    uuid.NullUUID `json:"parent_id"`
+	PayAppMonth time.Time     `json:"pay_app_month"`
+}
+
+type GetChildrenWithPayAppsRow struct {
+	ID                    uuid.UUID `json:"id"`
+	ItemNumber            string    `json:"item_number"`
+	TotalQty              string    `json:"total_qty"`
+	UnitPrice             string    `json:"unit_price"`
+	PayAppQty             string    `json:"pay_app_qty"`
+	CumulativeQty         string    `json:"cumulative_qty"`
+	PreviousCumulativeQty string    `json:"previous_cumulative_qty"`
+}
+
+// Fetches children of a parent along with their pay_app data for a specific month
+func (q *Queries) GetChildrenWithPayApps(ctx context.Context, arg GetChildrenWithPayAppsParams) ([]GetChildrenWithPayAppsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getChildrenWithPayApps, arg.ParentID, arg.PayAppMonth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetChildrenWithPayAppsRow
+	for rows.Next() {
+		var i GetChildrenWithPayAppsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ItemNumber,
+			&i.TotalQty,
+			&i.UnitPrice,
+			&i.PayAppQty,
+			&i.CumulativeQty,
+			&i.PreviousCumulativeQty,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCostPerformanceIndex = `-- name: GetCostPerformanceIndex :many
+WITH job_info AS (
+    SELECT id, job_number, contract_value
+    FROM jobs
+    WHERE job_number = $1
+),
+total_scheduled AS (
+    -- Sum of all scheduled quantities and calculated values (only leaf items with qty > 0)
     SELECT
         SUM(ji.qty) AS total_qty,
         SUM(ji.qty * ji.unit_price) AS total_contract_value
-    FROM job_items ji
+    FROM job_items_revised ji
     JOIN job_info j ON ji.job_id = j.id
     WHERE ji.qty > 0
 ),
+approved_change_orders AS (
+    SELECT COALESCE(SUM(co.amount), 0) AS amount
+    FROM change_orders co
+    JOIN job_info j ON co.job_id = j.id
+    WHERE co.status = 'approved'
+      AND ($2::TIMESTAMP IS NULL OR co.updated_at <= $2)
+),
 budget_value AS (
     -- Use contract_value if set, otherwise fall back to sum of qty * unit_price
-    SELECT COALESCE(j.contract_value, ts.total_contract_value, 0) AS budget
+    SELECT COALESCE(j.contract_value + aco.amount, ts.total_contract_value, 0) AS budget
     FROM job_info j
     CROSS JOIN total_scheduled ts
+    CROSS JOIN approved_change_orders aco
 ),
 monthly_cumulative_qty AS (
     -- Cumulative quantity billed per month
@@ -144,7 +883,7 @@ monthly_cumulative_qty AS (
         pa.pay_app_month AS month,
         SUM(pa.qty) AS month_qty,
         SUM(SUM(pa.qty)) OVER (ORDER BY pa.pay_app_month) AS cumulative_qty
-    FROM pay_applications pa
+    FROM pay_applications_as_of($2::TIMESTAMP) pa
     JOIN job_items ji ON pa.job_item_id = ji.id
     JOIN job_info j ON ji.job_id = j.id
     GROUP BY pa.pay_app_month
@@ -155,44 +894,58 @@ monthly_costs AS (
         SUM(amount) AS cost_total
     FROM job_cost_ledger
     WHERE job = $1
-      AND transaction_type IN ('AP cost', 'JC cost', 'PR cost')
+      AND transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
       AND transaction_date IS NOT NULL
+      AND ($2::TIMESTAMP IS NULL OR created_at <= $2)
     GROUP BY DATE_TRUNC('month', transaction_date)
+),
+cpi AS (
+    SELECT
+        mcq.month,
+        COALESCE(bv.budget, 0)::BIGINT AS budget,
+        COALESCE(ts.total_qty, 0)::TEXT AS total_scheduled_qty,
+        mcq.cumulative_qty::TEXT AS cumulative_qty,
+        CASE
+            WHEN COALESCE(ts.total_qty, 0) > 0
+            THEN ROUND((mcq.cumulative_qty / ts.total_qty) * 100, 2)::TEXT
+            ELSE '0'
+        END AS percent_complete,
+        CASE
+            WHEN COALESCE(ts.total_qty, 0) > 0 AND COALESCE(bv.budget, 0) > 0
+            THEN ROUND((mcq.cumulative_qty / ts.total_qty) * bv.budget, 2)::BIGINT
+            ELSE 0::BIGINT
+        END AS earned_value,
+        COALESCE(SUM(mc.cost_total) OVER (ORDER BY mcq.month), 0)::BIGINT AS actual_cost,
+        CASE
+            WHEN COALESCE(SUM(mc.cost_total) OVER (ORDER BY mcq.month), 0) > 0
+                 AND COALESCE(ts.total_qty, 0) > 0
+                 AND COALESCE(bv.budget, 0) > 0
+            THEN ROUND(
+                ((mcq.cumulative_qty / ts.total_qty) * bv.budget) /
+                SUM(mc.cost_total) OVER (ORDER BY mcq.month),
+                2
+            )::TEXT
+            ELSE '0'
+        END AS cpi
+    FROM monthly_cumulative_qty mcq
+    CROSS JOIN budget_value bv
+    CROSS JOIN total_scheduled ts
+    LEFT JOIN monthly_costs mc ON mc.month = mcq.month
 )
-SELECT
-    mcq.month,
-    COALESCE(bv.budget, 0)::BIGINT AS budget,
-    COALESCE(ts.total_qty, 0)::TEXT AS total_scheduled_qty,
-    mcq.cumulative_qty::TEXT AS cumulative_qty,
-    CASE
-        WHEN COALESCE(ts.total_qty, 0) > 0
-        THEN ROUND((mcq.cumulative_qty / ts.total_qty) * 100, 2)::TEXT
-        ELSE '0'
-    END AS percent_complete,
-    CASE
-        WHEN COALESCE(ts.total_qty, 0) > 0 AND COALESCE(bv.budget, 0) > 0
-        THEN ROUND((mcq.cumulative_qty / ts.total_qty) * bv.budget, 2)::BIGINT
-        ELSE 0::BIGINT
-    END AS earned_value,
-    COALESCE(SUM(mc.cost_total) OVER (ORDER BY mcq.month), 0)::BIGINT AS actual_cost,
-    CASE
-        WHEN COALESCE(SUM(mc.cost_total) OVER (ORDER BY mcq.month), 0) > 0
-             AND COALESCE(ts.total_qty, 0) > 0
-             AND COALESCE(bv.budget, 0) > 0
-        THEN ROUND(
-            ((mcq.cumulative_qty / ts.total_qty) * bv.budget) /
-            SUM(mc.cost_total) OVER (ORDER BY mcq.month),
-            2
-        )::TEXT
-        ELSE '0'
-    END AS cpi
-FROM monthly_cumulative_qty mcq
-CROSS JOIN budget_value bv
-CROSS JOIN total_scheduled ts
-LEFT JOIN monthly_costs mc ON mc.month = mcq.month
-ORDER BY mcq.month
+SELECT month, budget, total_scheduled_qty, cumulative_qty, percent_complete, earned_value, actual_cost, cpi
+FROM cpi
+WHERE ($3::DATE IS NULL OR month >= $3)
+  AND ($4::DATE IS NULL OR month <= $4)
+ORDER BY month
 `
 
+type GetCostPerformanceIndexParams struct {
+	JobNumber string       `json:"job_number"`
+	AsOf      sql.NullTime `json:"as_of"`
+	FromMonth sql.NullTime `json:"from_month"`
+	ToMonth   sql.NullTime `json:"to_month"`
+}
+
 type GetCostPerformanceIndexRow struct {
 	Month             time.Time `json:"month"`
 	Budget            int64     `json:"budget"`
@@ -205,10 +958,19 @@ type GetCostPerformanceIndexRow struct {
 }
 
 // Fetches monthly CPI data: Earned Value / Actual Costs
-// Earned Value = (cumulative qty / total qty) * original budget
-// Falls back to sum of scheduled_value if contract_value is not set
-func (q *Queries) GetCostPerformanceIndex(ctx context.Context, jobNumber string) ([]GetCostPerformanceIndexRow, error) {
-	rows, err := q.db.QueryContext(ctx, getCostPerformanceIndex, jobNumber)
+// Earned Value = (cumulative qty / total qty) * revised budget
+// Budget is contract_value plus approved change orders, falling back to the
+// revised sum of qty * unit_price if contract_value is not set
+// as_of leaves out ledger rows imported and change orders changed after that
+// time and reads each month's pay app as the revision submitted by then;
+// from_month and to_month limit the months returned
+func (q *Queries) GetCostPerformanceIndex(ctx context.Context, arg GetCostPerformanceIndexParams) ([]GetCostPerformanceIndexRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCostPerformanceIndex,
+		arg.JobNumber,
+		arg.AsOf,
+		arg.FromMonth,
+		arg.ToMonth,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -239,51 +1001,23 @@ func (q *Queries) GetCostPerformanceIndex(ctx context.Context, jobNumber string)
 	return items, nil
 }
 
-const getDirectChildren = `-- name: GetDirectChildren :many
-SELECT
-    id,
-    parent_id,
-    item_number,
-    description,
-    budget,
-    qty,
-    unit_price,
-    scheduled_value
-FROM job_items
-WHERE parent_id = $1
+const getCostTypeMappings = `-- name: GetCostTypeMappings :many
+SELECT cat, cost_type, created_at
+FROM cost_type_mappings
+ORDER BY cat
 `
 
-type GetDirectChildrenRow struct {
-	ID             uuid.UUID     `json:"id"`
-	ParentID       uuid.NullUUID `json:"parent_id"`
-	ItemNumber     string        `json:"item_number"`
-	Description    string        `json:"description"`
-	Budget         string        `json:"budget"`
-	Qty            string        `json:"qty"`
-	UnitPrice      string        `json:"unit_price"`
-	ScheduledValue string        `json:"scheduled_value"`
-}
-
-// Fetches direct children of a parent item (for validation)
-func (q *Queries) GetDirectChildren(ctx context.Context, parentID uuid.NullUUID) ([]GetDirectChildrenRow, error) {
-	rows, err := q.db.QueryContext(ctx, getDirectChildren, parentID)
+// Fetches the ledger cat to bid cost type mapping
+func (q *Queries) GetCostTypeMappings(ctx context.Context) ([]CostTypeMapping, error) {
+	rows, err := q.db.QueryContext(ctx, getCostTypeMappings)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetDirectChildrenRow
+	var items []CostTypeMapping
 	for rows.Next() {
-		var i GetDirectChildrenRow
-		if err := rows.Scan(
-			&i.ID,
-			&i.ParentID,
-			&i.ItemNumber,
-			&i.Description,
-			&i.Budget,
-			&i.Qty,
-			&i.UnitPrice,
-			&i.ScheduledValue,
-		); err != nil {
+		var i CostTypeMapping
+		if err := rows.Scan(&i.Cat, &i.CostType, &i.CreatedAt); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -297,25 +1031,341 @@ func (q *Queries) GetDirectChildren(ctx context.Context, parentID uuid.NullUUID)
 	return items, nil
 }
 
-const getJobByNumber = `-- name: GetJobByNumber :one
-SELECT id, job_number, job_name FROM jobs WHERE job_number = $1
-`
-
-type GetJobByNumberRow struct {
-	ID        uuid.UUID `json:"id"`
-	JobNumber string    `json:"job_number"`
-	JobName   string    `json:"job_name"`
+const getCostTypeVariance = `-- name: GetCostTypeVariance :many
+WITH item_estimates AS (
+    SELECT
+        ji.job_cost_id AS phase,
+        v.cost_type,
+        SUM(v.amount) AS estimate
+    FROM job_items ji
+    LEFT JOIN job_items parent ON ji.parent_id = parent.id
+    CROSS JOIN LATERAL (
+        VALUES
+            ('labor', ji.labor),
+            ('equip', ji.equip),
+            ('material', ji.material),
+            ('sub', ji.sub),
+            ('trucking', ji.trucking),
+            ('misc', ji.misc),
+            ('plug', ji.plug)
+    ) AS v(cost_type, amount)
+    WHERE ji.job_id = (SELECT j.id FROM jobs j WHERE j.job_number = $1)
+      AND ji.job_cost_id IS NOT NULL
+      AND parent.job_cost_id IS DISTINCT FROM ji.job_cost_id
+    GROUP BY ji.job_cost_id, v.cost_type
+),
+ledger_actuals AS (
+    SELECT
+        COALESCE(jcl.phase, '') AS phase,
+        COALESCE(ctm.cost_type, 'unmapped') AS cost_type,
+        SUM(jcl.amount) AS actual
+    FROM job_cost_ledger jcl
+    LEFT JOIN cost_type_mappings ctm ON ctm.cat = jcl.cat
+    WHERE jcl.job = $1
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+      AND ($2::DATE IS NULL OR jcl.transaction_date >= $2)
+      AND ($3::DATE IS NULL OR jcl.transaction_date < $3 + INTERVAL '1 month')
+      AND ($4::TIMESTAMP IS NULL OR jcl.created_at <= $4)
+    GROUP BY COALESCE(jcl.phase, ''), COALESCE(ctm.cost_type, 'unmapped')
+)
+SELECT
+    COALESCE(ie.phase, la.phase)::TEXT AS phase,
+    COALESCE(ie.cost_type, la.cost_type)::TEXT AS cost_type,
+    COALESCE(ie.estimate, 0)::TEXT AS estimate,
+    COALESCE(la.actual, 0)::TEXT AS actual
+FROM item_estimates ie
+FULL OUTER JOIN ledger_actuals la ON ie.phase = la.phase AND ie.cost_type = la.cost_type
+WHERE COALESCE(ie.estimate, 0) <> 0 OR COALESCE(la.actual, 0) <> 0
+ORDER BY 1, 2
+`
+
+type GetCostTypeVarianceParams struct {
+	JobNumber string       `json:"job_number"`
+	FromMonth sql.NullTime `json:"from_month"`
+	ToMonth   sql.NullTime `json:"to_month"`
+	AsOf      sql.NullTime `json:"as_of"`
+}
+
+type GetCostTypeVarianceRow struct {
+	Phase    string `json:"phase"`
+	CostType string `json:"cost_type"`
+	Estimate string `json:"estimate"`
+	Actual   string `json:"actual"`
+}
+
+// Fetches estimated and actual cost per phase and bid cost type
+// Estimates unpivot the bid's cost type columns, counting only the topmost item
+// carrying each job_cost_id as in GetPhaseBudgets. Actuals map ledger cats
+// through cost_type_mappings; unmapped cats come back as 'unmapped'
+// Actuals can be limited to transactions from from_month through to_month;
+// as_of leaves out rows imported after it
+func (q *Queries) GetCostTypeVariance(ctx context.Context, arg GetCostTypeVarianceParams) ([]GetCostTypeVarianceRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCostTypeVariance,
+		arg.JobNumber,
+		arg.FromMonth,
+		arg.ToMonth,
+		arg.AsOf,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCostTypeVarianceRow
+	for rows.Next() {
+		var i GetCostTypeVarianceRow
+		if err := rows.Scan(
+			&i.Phase,
+			&i.CostType,
+			&i.Estimate,
+			&i.Actual,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getCrewProductivity = `-- name: GetCrewProductivity :many
+WITH job_info AS (
+    SELECT id
+    FROM jobs
+    WHERE job_number = $1
+),
+crew_items AS (
+    SELECT
+        ji.id,
+        ji.sort_order,
+        ji.item_number,
+        ji.description,
+        ir.phase,
+        ir.root_id,
+        ji.production_rate,
+        ji.production_units,
+        ji.qty,
+        ji.unit,
+        ji.man_hours,
+        ji.crew_days,
+        CASE
+            WHEN SUM(ji.man_hours) OVER (PARTITION BY ir.phase) > 0
+            THEN ji.man_hours / SUM(ji.man_hours) OVER (PARTITION BY ir.phase)
+            ELSE 1.0 / COUNT(*) OVER (PARTITION BY ir.phase)
+        END AS share
+    FROM job_items ji
+    JOIN job_item_roots ir ON ir.id = ji.id
+    JOIN job_info j ON ir.job_id = j.id
+    WHERE ji.cost_method = 'Crew'
+),
+phase_labor AS (
+    SELECT
+        jcl.phase,
+        SUM(jcl.hours) AS hours,
+        SUM(jcl.units) AS units,
+        COUNT(DISTINCT jcl.transaction_date) FILTER (WHERE jcl.hours > 0) AS days_worked
+    FROM job_cost_ledger jcl
+    WHERE jcl.job = $1
+      AND jcl.transaction_type = 'PR cost'
+      AND jcl.phase IS NOT NULL
+      AND ($2::DATE IS NULL OR jcl.transaction_date >= $2)
+      AND ($3::DATE IS NULL OR jcl.transaction_date < $3 + INTERVAL '1 month')
+      AND ($4::TIMESTAMP IS NULL OR jcl.created_at <= $4)
+    GROUP BY jcl.phase
+),
+root_billed AS (
+    SELECT pa.job_item_id AS root_id, SUM(pa.qty) / MAX(r.qty) AS fraction
+    FROM pay_applications_as_of($4::TIMESTAMP) pa
+    JOIN job_items_revised r ON pa.job_item_id = r.id
+    JOIN job_info j ON r.job_id = j.id
+    WHERE r.qty > 0
+      AND ($3::DATE IS NULL OR pa.pay_app_month <= $3)
+    GROUP BY pa.job_item_id
+)
+SELECT
+    ci.item_number,
+    ci.description,
+    COALESCE(ci.phase, '')::TEXT AS phase,
+    COALESCE(ci.production_rate, '')::TEXT AS production_rate,
+    COALESCE(ci.production_units, '')::TEXT AS production_units,
+    ci.qty::TEXT AS qty,
+    COALESCE(ci.unit, '')::TEXT AS unit,
+    ci.man_hours::TEXT AS man_hours,
+    ci.crew_days::TEXT AS crew_days,
+    ROUND(COALESCE(pl.hours * ci.share, 0), 2)::TEXT AS actual_hours,
+    ROUND(COALESCE(pl.units * ci.share, 0), 4)::TEXT AS actual_units,
+    COALESCE(pl.days_worked, 0)::INT AS days_worked,
+    COALESCE(rb.fraction, 0)::TEXT AS billed_fraction
+FROM crew_items ci
+LEFT JOIN phase_labor pl ON pl.phase = ci.phase
+LEFT JOIN root_billed rb ON rb.root_id = ci.root_id
+ORDER BY ci.sort_order
+`
+
+type GetCrewProductivityParams struct {
+	JobNumber string       `json:"job_number"`
+	FromMonth sql.NullTime `json:"from_month"`
+	ToMonth   sql.NullTime `json:"to_month"`
+	AsOf      sql.NullTime `json:"as_of"`
+}
+
+type GetCrewProductivityRow struct {
+	ItemNumber      string `json:"item_number"`
+	Description     string `json:"description"`
+	Phase           string `json:"phase"`
+	ProductionRate  string `json:"production_rate"`
+	ProductionUnits string `json:"production_units"`
+	Qty             string `json:"qty"`
+	Unit            string `json:"unit"`
+	ManHours        string `json:"man_hours"`
+	CrewDays        string `json:"crew_days"`
+	ActualHours     string `json:"actual_hours"`
+	ActualUnits     string `json:"actual_units"`
+	DaysWorked      int32  `json:"days_worked"`
+	BilledFraction  string `json:"billed_fraction"`
+}
+
+// Fetches bid production figures and actual payroll hours per Crew item
+// A crew's phase is its own job_cost_id or the nearest ancestor's. PR cost hours
+// and units for a phase shared by several crews are split by bid man hours.
+// Days worked counts distinct dates with payroll hours on the phase. Billed
+// fraction is the pay app percent complete of the crew's pay item
+// Hours, units and days can be limited to transactions from from_month through
+// to_month; billed fraction is to date through to_month. as_of leaves out
+// ledger rows imported after it and reads pay apps as submitted by then
+func (q *Queries) GetCrewProductivity(ctx context.Context, arg GetCrewProductivityParams) ([]GetCrewProductivityRow, error) {
+	rows, err := q.db.QueryContext(ctx, getCrewProductivity,
+		arg.JobNumber,
+		arg.FromMonth,
+		arg.ToMonth,
+		arg.AsOf,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetCrewProductivityRow
+	for rows.Next() {
+		var i GetCrewProductivityRow
+		if err := rows.Scan(
+			&i.ItemNumber,
+			&i.Description,
+			&i.Phase,
+			&i.ProductionRate,
+			&i.ProductionUnits,
+			&i.Qty,
+			&i.Unit,
+			&i.ManHours,
+			&i.CrewDays,
+			&i.ActualHours,
+			&i.ActualUnits,
+			&i.DaysWorked,
+			&i.BilledFraction,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDirectChildren = `-- name: GetDirectChildren :many
+SELECT
+    id,
+    parent_id,
+    item_number,
+    description,
+    budget,
+    qty,
+    unit_price,
+    scheduled_value
+FROM job_items
+WHERE parent_id = $1
+`
+
+type GetDirectChildrenRow struct {
+	ID             uuid.UUID     `json:"id"`
+	ParentID       uuid.NullUUID `json:"parent_id"`
+	ItemNumber     string        `json:"item_number"`
+	Description    string        `json:"description"`
+	Budget         string        `json:"budget"`
+	Qty            string        `json:"qty"`
+	UnitPrice      string        `json:"unit_price"`
+	ScheduledValue string        `json:"scheduled_value"`
+}
+
+// Fetches direct children of a parent item (for validation)
+func (q *Queries) GetDirectChildren(ctx context.Context, parentID uuid.NullUUID) ([]GetDirectChildrenRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDirectChildren, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDirectChildrenRow
+	for rows.Next() {
+		var i GetDirectChildrenRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ParentID,
+			&i.ItemNumber,
+			&i.Description,
+			&i.Budget,
+			&i.Qty,
+			&i.UnitPrice,
+			&i.ScheduledValue,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
 }
 
-func (q *Queries) GetJobByNumber(ctx context.Context, jobNumber string) (GetJobByNumberRow, error) {
+const getJobByNumber = `-- name: GetJobByNumber :one
+SELECT id, job_number, job_name, contract_value, address, scr_number,
+       contract_complete_date, start_date, end_date, created_at, updated_at, status
+FROM jobs
+WHERE job_number = $1
+`
+
+func (q *Queries) GetJobByNumber(ctx context.Context, jobNumber string) (Job, error) {
 	row := q.db.QueryRowContext(ctx, getJobByNumber, jobNumber)
-	var i GetJobByNumberRow
-	err := row.Scan(&i.ID, &i.JobNumber, &i.JobName)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.JobNumber,
+		&i.JobName,
+		&i.ContractValue,
+		&i.Address,
+		&i.ScrNumber,
+		&i.ContractCompleteDate,
+		&i.StartDate,
+		&i.EndDate,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+	)
 	return i, err
 }
 
 const getJobCostLedgerByJob = `-- name: GetJobCostLedgerByJob :many
-SELECT id, job, phase, cat, transaction_type, transaction_date, amount, created_at
+SELECT id, job, phase, cat, transaction_type, transaction_date, amount, created_at, hours, units
 FROM job_cost_ledger
 WHERE job = $1
 ORDER BY transaction_date
@@ -340,6 +1390,8 @@ func (q *Queries) GetJobCostLedgerByJob(ctx context.Context, job string) ([]JobC
 			&i.TransactionDate,
 			&i.Amount,
 			&i.CreatedAt,
+			&i.Hours,
+			&i.Units,
 		); err != nil {
 			return nil, err
 		}
@@ -354,6 +1406,27 @@ func (q *Queries) GetJobCostLedgerByJob(ctx context.Context, job string) ([]JobC
 	return items, nil
 }
 
+const getJobSchedule = `-- name: GetJobSchedule :one
+SELECT
+    start_date,
+    COALESCE(end_date, contract_complete_date) AS end_date
+FROM jobs
+WHERE id = $1
+`
+
+type GetJobScheduleRow struct {
+	StartDate sql.NullTime `json:"start_date"`
+	EndDate   sql.NullTime `json:"end_date"`
+}
+
+// Fetches a job's planned start and finish; finish falls back to the contract completion date
+func (q *Queries) GetJobSchedule(ctx context.Context, id uuid.UUID) (GetJobScheduleRow, error) {
+	row := q.db.QueryRowContext(ctx, getJobSchedule, id)
+	var i GetJobScheduleRow
+	err := row.Scan(&i.StartDate, &i.EndDate)
+	return i, err
+}
+
 const getJobTree = `-- name: GetJobTree :many
 WITH RECURSIVE job_tree AS (
     -- 1. Anchor: Select Roots (Items with no parent)
@@ -525,6 +1598,74 @@ func (q *Queries) GetJobTree(ctx context.Context, jobID uuid.UUID) ([]GetJobTree
 	return items, nil
 }
 
+const getLaterPayAppCumulative = `-- name: GetLaterPayAppCumulative :many
+SELECT
+    job_item_id,
+    MIN(cumulative_qty::NUMERIC)::TEXT AS min_cumulative_qty,
+    MAX(cumulative_qty::NUMERIC)::TEXT AS max_cumulative_qty
+FROM pay_application_cumulative
+WHERE job_id = $1 AND pay_app_month > $2
+GROUP BY job_item_id
+`
+
+type GetLaterPayAppCumulativeParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PayAppMonth time.Time `json:"pay_app_month"`
+}
+
+type GetLaterPayAppCumulativeRow struct {
+	JobItemID        uuid.UUID `json:"job_item_id"`
+	MinCumulativeQty string    `json:"min_cumulative_qty"`
+	MaxCumulativeQty string    `json:"max_cumulative_qty"`
+}
+
+// Fetches the lowest and highest cumulative quantity each of a job's items
+// reaches in months after the given one
+func (q *Queries) GetLaterPayAppCumulative(ctx context.Context, arg GetLaterPayAppCumulativeParams) ([]GetLaterPayAppCumulativeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getLaterPayAppCumulative, arg.JobID, arg.PayAppMonth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetLaterPayAppCumulativeRow
+	for rows.Next() {
+		var i GetLaterPayAppCumulativeRow
+		if err := rows.Scan(&i.JobItemID, &i.MinCumulativeQty, &i.MaxCumulativeQty); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getLatestBaseline = `-- name: GetLatestBaseline :one
+SELECT id, job_id, version, method, note, created_at
+FROM pv_baselines
+WHERE job_id = $1
+ORDER BY version DESC
+LIMIT 1
+`
+
+func (q *Queries) GetLatestBaseline(ctx context.Context, jobID uuid.UUID) (PvBaseline, error) {
+	row := q.db.QueryRowContext(ctx, getLatestBaseline, jobID)
+	var i PvBaseline
+	err := row.Scan(
+		&i.ID,
+		&i.JobID,
+		&i.Version,
+		&i.Method,
+		&i.Note,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
 const getMonthlyPerformance = `-- name: GetMonthlyPerformance :many
 WITH monthly_costs AS (
     SELECT
@@ -532,8 +1673,9 @@ WITH monthly_costs AS (
         SUM(jcl.amount) AS cost_total
     FROM job_cost_ledger jcl
     WHERE jcl.job = $1
-      AND jcl.transaction_type IN ('AP cost', 'JC cost', 'PR cost')
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
       AND jcl.transaction_date IS NOT NULL
+      AND ($2::TIMESTAMP IS NULL OR jcl.created_at <= $2)
     GROUP BY DATE_TRUNC('month', jcl.transaction_date)
 ),
 monthly_billed AS (
@@ -542,29 +1684,43 @@ monthly_billed AS (
         SUM(jcl.amount) AS billed_total
     FROM job_cost_ledger jcl
     WHERE jcl.job = $1
-      AND jcl.transaction_type = 'work billed'
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'billing')
       AND jcl.transaction_date IS NOT NULL
+      AND ($2::TIMESTAMP IS NULL OR jcl.created_at <= $2)
     GROUP BY DATE_TRUNC('month', jcl.transaction_date)
     HAVING SUM(jcl.amount) > 0
+),
+performance AS (
+    SELECT
+        mb.month,
+        COALESCE((
+            SELECT SUM(mc.cost_total)
+            FROM monthly_costs mc
+            WHERE mc.month <= mb.month
+        ), 0)::BIGINT AS cost_total,
+        mb.billed_total::BIGINT AS pay_app_total,
+        COALESCE((
+            SELECT SUM(mc.cost_total)
+            FROM monthly_costs mc
+            WHERE mc.month <= mb.month
+        ), 0)::BIGINT AS cumulative_cost,
+        SUM(mb.billed_total) OVER (ORDER BY mb.month)::BIGINT AS cumulative_pay_app
+    FROM monthly_billed mb
 )
-SELECT
-    mb.month,
-    COALESCE((
-        SELECT SUM(mc.cost_total)
-        FROM monthly_costs mc
-        WHERE mc.month <= mb.month
-    ), 0)::BIGINT AS cost_total,
-    mb.billed_total::BIGINT AS pay_app_total,
-    COALESCE((
-        SELECT SUM(mc.cost_total)
-        FROM monthly_costs mc
-        WHERE mc.month <= mb.month
-    ), 0)::BIGINT AS cumulative_cost,
-    SUM(mb.billed_total) OVER (ORDER BY mb.month)::BIGINT AS cumulative_pay_app
-FROM monthly_billed mb
-ORDER BY mb.month
+SELECT month, cost_total, pay_app_total, cumulative_cost, cumulative_pay_app
+FROM performance
+WHERE ($3::DATE IS NULL OR month >= $3)
+  AND ($4::DATE IS NULL OR month <= $4)
+ORDER BY month
 `
 
+type GetMonthlyPerformanceParams struct {
+	Job       string       `json:"job"`
+	AsOf      sql.NullTime `json:"as_of"`
+	FromMonth sql.NullTime `json:"from_month"`
+	ToMonth   sql.NullTime `json:"to_month"`
+}
+
 type GetMonthlyPerformanceRow struct {
 	Month            time.Time `json:"month"`
 	CostTotal        int64     `json:"cost_total"`
@@ -574,9 +1730,16 @@ type GetMonthlyPerformanceRow struct {
 }
 
 // Fetches monthly cost and billed totals for a job from job_cost_ledger
-// Costs = AP cost, JC cost, PR cost; Billed = work billed
-func (q *Queries) GetMonthlyPerformance(ctx context.Context, job string) ([]GetMonthlyPerformanceRow, error) {
-	rows, err := q.db.QueryContext(ctx, getMonthlyPerformance, job)
+// Costs and billings are the transaction types classed 'cost' and 'billing'
+// as_of leaves out ledger rows imported after that time; from_month and to_month
+// limit the months returned without changing the cumulative totals
+func (q *Queries) GetMonthlyPerformance(ctx context.Context, arg GetMonthlyPerformanceParams) ([]GetMonthlyPerformanceRow, error) {
+	rows, err := q.db.QueryContext(ctx, getMonthlyPerformance,
+		arg.Job,
+		arg.AsOf,
+		arg.FromMonth,
+		arg.ToMonth,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -604,6 +1767,37 @@ func (q *Queries) GetMonthlyPerformance(ctx context.Context, job string) ([]GetM
 	return items, nil
 }
 
+const getNextBaselineVersion = `-- name: GetNextBaselineVersion :one
+SELECT (COALESCE(MAX(version), 0) + 1)::INT AS version
+FROM pv_baselines
+WHERE job_id = $1
+`
+
+func (q *Queries) GetNextBaselineVersion(ctx context.Context, jobID uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getNextBaselineVersion, jobID)
+	var version int32
+	err := row.Scan(&version)
+	return version, err
+}
+
+const getNextPayAppRevision = `-- name: GetNextPayAppRevision :one
+SELECT (COALESCE(MAX(revision), 0) + 1)::INT AS revision
+FROM pay_app_revisions
+WHERE job_id = $1 AND pay_app_month = $2
+`
+
+type GetNextPayAppRevisionParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PayAppMonth time.Time `json:"pay_app_month"`
+}
+
+func (q *Queries) GetNextPayAppRevision(ctx context.Context, arg GetNextPayAppRevisionParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getNextPayAppRevision, arg.JobID, arg.PayAppMonth)
+	var revision int32
+	err := row.Scan(&revision)
+	return revision, err
+}
+
 const getOverBudgetPhases = `-- name: GetOverBudgetPhases :many
 WITH phase_costs AS (
     SELECT
@@ -611,7 +1805,10 @@ WITH phase_costs AS (
         SUM(jcl.amount) AS actual_cost
     FROM job_cost_ledger jcl
     WHERE jcl.job = $1
-      AND jcl.transaction_type IN ('AP cost', 'JC cost', 'PR cost')
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+      AND ($2::DATE IS NULL OR jcl.transaction_date >= $2)
+      AND ($3::DATE IS NULL OR jcl.transaction_date < $3 + INTERVAL '1 month')
+      AND ($4::TIMESTAMP IS NULL OR jcl.created_at <= $4)
     GROUP BY jcl.phase
 ),
 phase_budgets AS (
@@ -620,7 +1817,9 @@ phase_budgets AS (
         SUM(jcl.amount) AS budget
     FROM job_cost_ledger jcl
     WHERE jcl.job = $1
-      AND jcl.transaction_type = 'Original estimate'
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class IN ('original_budget', 'budget_revision'))
+      AND ($3::DATE IS NULL OR jcl.transaction_date IS NULL OR jcl.transaction_date < $3 + INTERVAL '1 month')
+      AND ($4::TIMESTAMP IS NULL OR jcl.created_at <= $4)
     GROUP BY jcl.phase
 ),
 phase_descriptions AS (
@@ -645,6 +1844,13 @@ WHERE COALESCE(pc.actual_cost, 0) > COALESCE(pb.budget, 0)
 ORDER BY (COALESCE(pc.actual_cost, 0) - COALESCE(pb.budget, 0)) DESC
 `
 
+type GetOverBudgetPhasesParams struct {
+	Job       string       `json:"job"`
+	FromMonth sql.NullTime `json:"from_month"`
+	ToMonth   sql.NullTime `json:"to_month"`
+	AsOf      sql.NullTime `json:"as_of"`
+}
+
 type GetOverBudgetPhasesRow struct {
 	Phase       sql.NullString `json:"phase"`
 	Description []byte         `json:"description"`
@@ -654,9 +1860,16 @@ type GetOverBudgetPhasesRow struct {
 }
 
 // Fetches phase codes where actual costs exceed budget
-// Uses "Original estimate" from job_cost_ledger as the budget source
-func (q *Queries) GetOverBudgetPhases(ctx context.Context, job string) ([]GetOverBudgetPhasesRow, error) {
-	rows, err := q.db.QueryContext(ctx, getOverBudgetPhases, job)
+// Uses original budget and budget revision rows from job_cost_ledger as the budget source
+// Costs can be limited to transactions from from_month through to_month; budget
+// rows dated after to_month are left out. as_of leaves out rows imported after it
+func (q *Queries) GetOverBudgetPhases(ctx context.Context, arg GetOverBudgetPhasesParams) ([]GetOverBudgetPhasesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getOverBudgetPhases,
+		arg.Job,
+		arg.FromMonth,
+		arg.ToMonth,
+		arg.AsOf,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -798,33 +2011,2444 @@ type GetPayAppCumulativeParams struct {
 	PayAppMonth time.Time `json:"pay_app_month"`
 }
 
-// Fetches cumulative pay application data for a job and specific month
-func (q *Queries) GetPayAppCumulative(ctx context.Context, arg GetPayAppCumulativeParams) ([]PayApplicationCumulative, error) {
-	rows, err := q.db.QueryContext(ctx, getPayAppCumulative, arg.JobID, arg.PayAppMonth)
+// Fetches cumulative pay application data for a job and specific month
+func (q *Queries) GetPayAppCumulative(ctx context.Context, arg GetPayAppCumulativeParams) ([]PayApplicationCumulative, error) {
+	rows, err := q.db.QueryContext(ctx, getPayAppCumulative, arg.JobID, arg.PayAppMonth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PayApplicationCumulative
+	for rows.Next() {
+		var i PayApplicationCumulative
+		if err := rows.Scan(
+			&i.JobItemID,
+			&i.PayAppMonth,
+			&i.JobID,
+			&i.ParentID,
+			&i.ThisMonthQty,
+			&i.StoredMaterials,
+			&i.TotalQty,
+			&i.UnitPrice,
+			&i.Budget,
+			&i.CumulativeQty,
+			&i.PreviousCumulativeQty,
+			&i.RemainingQty,
+			&i.PercentComplete,
+			&i.ThisMonthAmount,
+			&i.CumulativeAmount,
+			&i.PreviousCumulativeAmount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPayAppEdits = `-- name: GetPayAppEdits :many
+SELECT
+    e.revision,
+    ji.item_number,
+    ji.description,
+    e.field,
+    e.old_value::TEXT AS old_value,
+    e.new_value::TEXT AS new_value,
+    e.edited_by,
+    e.reason,
+    e.created_at
+FROM pay_app_edits e
+JOIN job_items ji ON e.job_item_id = ji.id
+WHERE e.job_id = $1 AND e.pay_app_month = $2
+ORDER BY e.created_at DESC, ji.sort_order
+`
+
+type GetPayAppEditsParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PayAppMonth time.Time `json:"pay_app_month"`
+}
+
+type GetPayAppEditsRow struct {
+	Revision    int32          `json:"revision"`
+	ItemNumber  string         `json:"item_number"`
+	Description string         `json:"description"`
+	Field       string         `json:"field"`
+	OldValue    string         `json:"old_value"`
+	NewValue    string         `json:"new_value"`
+	EditedBy    string         `json:"edited_by"`
+	Reason      sql.NullString `json:"reason"`
+	CreatedAt   sql.NullTime   `json:"created_at"`
+}
+
+// Fetches the edits made to a job's pay application for the month, newest first
+func (q *Queries) GetPayAppEdits(ctx context.Context, arg GetPayAppEditsParams) ([]GetPayAppEditsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPayAppEdits, arg.JobID, arg.PayAppMonth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPayAppEditsRow
+	for rows.Next() {
+		var i GetPayAppEditsRow
+		if err := rows.Scan(
+			&i.Revision,
+			&i.ItemNumber,
+			&i.Description,
+			&i.Field,
+			&i.OldValue,
+			&i.NewValue,
+			&i.EditedBy,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPayAppItems = `-- name: GetPayAppItems :many
+SELECT
+    pac.job_item_id,
+    ji.item_number,
+    ji.description,
+    ji.unit,
+    (pac.parent_id IS NULL)::BOOLEAN AS is_pay_item,
+    pac.this_month_qty::TEXT AS this_month_qty,
+    pac.stored_materials::TEXT AS stored_materials,
+    pac.total_qty::TEXT AS total_qty,
+    pac.unit_price::TEXT AS unit_price,
+    r.scheduled_value::TEXT AS scheduled_value,
+    pac.previous_cumulative_qty
+FROM pay_application_cumulative pac
+JOIN job_items ji ON pac.job_item_id = ji.id
+JOIN job_items_revised r ON pac.job_item_id = r.id
+WHERE pac.job_id = $1 AND pac.pay_app_month = $2
+ORDER BY ji.sort_order
+`
+
+type GetPayAppItemsParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PayAppMonth time.Time `json:"pay_app_month"`
+}
+
+type GetPayAppItemsRow struct {
+	JobItemID             uuid.UUID      `json:"job_item_id"`
+	ItemNumber            string         `json:"item_number"`
+	Description           string         `json:"description"`
+	Unit                  sql.NullString `json:"unit"`
+	IsPayItem             bool           `json:"is_pay_item"`
+	ThisMonthQty          string         `json:"this_month_qty"`
+	StoredMaterials       string         `json:"stored_materials"`
+	TotalQty              string         `json:"total_qty"`
+	UnitPrice             string         `json:"unit_price"`
+	ScheduledValue        string         `json:"scheduled_value"`
+	PreviousCumulativeQty string         `json:"previous_cumulative_qty"`
+}
+
+// Fetches a job's cumulative pay application rows for the month with item
+// details, in bid order. scheduled_value includes approved change orders.
+func (q *Queries) GetPayAppItems(ctx context.Context, arg GetPayAppItemsParams) ([]GetPayAppItemsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPayAppItems, arg.JobID, arg.PayAppMonth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPayAppItemsRow
+	for rows.Next() {
+		var i GetPayAppItemsRow
+		if err := rows.Scan(
+			&i.JobItemID,
+			&i.ItemNumber,
+			&i.Description,
+			&i.Unit,
+			&i.IsPayItem,
+			&i.ThisMonthQty,
+			&i.StoredMaterials,
+			&i.TotalQty,
+			&i.UnitPrice,
+			&i.ScheduledValue,
+			&i.PreviousCumulativeQty,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPayAppMonthlyTotals = `-- name: GetPayAppMonthlyTotals :many
+SELECT
+    job_id,
+    pay_app_month,
+    scheduled_value,
+    work_this_period,
+    work_to_date,
+    stored_materials
+FROM pay_app_monthly_totals
+WHERE job_id = $1
+ORDER BY pay_app_month
+`
+
+// Fetches billed work and stored materials per pay application month for a job
+func (q *Queries) GetPayAppMonthlyTotals(ctx context.Context, jobID uuid.UUID) ([]PayAppMonthlyTotal, error) {
+	rows, err := q.db.QueryContext(ctx, getPayAppMonthlyTotals, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PayAppMonthlyTotal
+	for rows.Next() {
+		var i PayAppMonthlyTotal
+		if err := rows.Scan(
+			&i.JobID,
+			&i.PayAppMonth,
+			&i.ScheduledValue,
+			&i.WorkThisPeriod,
+			&i.WorkToDate,
+			&i.StoredMaterials,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPayAppMonthsForJob = `-- name: GetPayAppMonthsForJob :many
+SELECT DISTINCT pa.pay_app_month
+FROM pay_applications pa
+JOIN job_items ji ON pa.job_item_id = ji.id
+WHERE ji.job_id = $1
+ORDER BY pa.pay_app_month
+`
+
+// Gets all distinct months with pay applications for a job
+func (q *Queries) GetPayAppMonthsForJob(ctx context.Context, jobID uuid.UUID) ([]time.Time, error) {
+	rows, err := q.db.QueryContext(ctx, getPayAppMonthsForJob, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []time.Time
+	for rows.Next() {
+		var pay_app_month time.Time
+		if err := rows.Scan(&pay_app_month); err != nil {
+			return nil, err
+		}
+		items = append(items, pay_app_month)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPayAppRevisionDiff = `-- name: GetPayAppRevisionDiff :many
+WITH from_items AS (
+    SELECT ri.job_item_id, ri.qty, ri.stored_materials
+    FROM pay_app_revision_items ri
+    JOIN pay_app_revisions r ON ri.revision_id = r.id
+    WHERE r.job_id = $1
+      AND r.pay_app_month = $2
+      AND r.revision = $3
+),
+to_items AS (
+    SELECT ri.job_item_id, ri.qty, ri.stored_materials
+    FROM pay_app_revision_items ri
+    JOIN pay_app_revisions r ON ri.revision_id = r.id
+    WHERE r.job_id = $1
+      AND r.pay_app_month = $2
+      AND r.revision = $4
+)
+SELECT
+    ji.id AS job_item_id,
+    ji.item_number,
+    ji.description,
+    (ji.parent_id IS NULL)::BOOLEAN AS is_pay_item,
+    ji.unit_price::TEXT AS unit_price,
+    COALESCE(f.qty, 0)::TEXT AS from_qty,
+    COALESCE(t.qty, 0)::TEXT AS to_qty,
+    COALESCE(f.stored_materials, 0)::TEXT AS from_stored_materials,
+    COALESCE(t.stored_materials, 0)::TEXT AS to_stored_materials
+FROM from_items f
+FULL OUTER JOIN to_items t ON f.job_item_id = t.job_item_id
+JOIN job_items ji ON ji.id = COALESCE(f.job_item_id, t.job_item_id)
+ORDER BY ji.sort_order
+`
+
+type GetPayAppRevisionDiffParams struct {
+	JobID        uuid.UUID `json:"job_id"`
+	PayAppMonth  time.Time `json:"pay_app_month"`
+	FromRevision int32     `json:"from_revision"`
+	ToRevision   int32     `json:"to_revision"`
+}
+
+type GetPayAppRevisionDiffRow struct {
+	JobItemID           uuid.UUID `json:"job_item_id"`
+	ItemNumber          string    `json:"item_number"`
+	Description         string    `json:"description"`
+	IsPayItem           bool      `json:"is_pay_item"`
+	UnitPrice           string    `json:"unit_price"`
+	FromQty             string    `json:"from_qty"`
+	ToQty               string    `json:"to_qty"`
+	FromStoredMaterials string    `json:"from_stored_materials"`
+	ToStoredMaterials   string    `json:"to_stored_materials"`
+}
+
+// Compares two revisions of a job's pay application for the month item by
+// item. Items missing from one side compare as zero.
+func (q *Queries) GetPayAppRevisionDiff(ctx context.Context, arg GetPayAppRevisionDiffParams) ([]GetPayAppRevisionDiffRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPayAppRevisionDiff,
+		arg.JobID,
+		arg.PayAppMonth,
+		arg.FromRevision,
+		arg.ToRevision,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPayAppRevisionDiffRow
+	for rows.Next() {
+		var i GetPayAppRevisionDiffRow
+		if err := rows.Scan(
+			&i.JobItemID,
+			&i.ItemNumber,
+			&i.Description,
+			&i.IsPayItem,
+			&i.UnitPrice,
+			&i.FromQty,
+			&i.ToQty,
+			&i.FromStoredMaterials,
+			&i.ToStoredMaterials,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPayAppRevisions = `-- name: GetPayAppRevisions :many
+SELECT
+    r.revision,
+    r.source,
+    r.created_at,
+    COUNT(ri.job_item_id)::BIGINT AS item_count,
+    COALESCE(SUM(
+        CASE
+            WHEN ji.parent_id IS NOT NULL THEN 0
+            WHEN p.unit_price = 0 THEN ri.qty
+            ELSE ri.qty * p.unit_price
+        END
+    ), 0)::TEXT AS work_this_period,
+    COALESCE(SUM(CASE WHEN ji.parent_id IS NULL THEN ri.stored_materials ELSE 0 END), 0)::TEXT AS stored_materials
+FROM pay_app_revisions r
+LEFT JOIN pay_app_revision_items ri ON ri.revision_id = r.id
+LEFT JOIN job_items ji ON ri.job_item_id = ji.id
+LEFT JOIN job_item_prices p ON p.job_item_id = ri.job_item_id
+ AND r.pay_app_month >= p.from_month
+ AND (p.until_month IS NULL OR r.pay_app_month < p.until_month)
+WHERE r.job_id = $1 AND r.pay_app_month = $2
+GROUP BY r.id, r.revision, r.source, r.created_at
+ORDER BY r.revision DESC
+`
+
+type GetPayAppRevisionsParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PayAppMonth time.Time `json:"pay_app_month"`
+}
+
+type GetPayAppRevisionsRow struct {
+	Revision        int32          `json:"revision"`
+	Source          sql.NullString `json:"source"`
+	CreatedAt       sql.NullTime   `json:"created_at"`
+	ItemCount       int64          `json:"item_count"`
+	WorkThisPeriod  string         `json:"work_this_period"`
+	StoredMaterials string         `json:"stored_materials"`
+}
+
+// Fetches every revision of a job's pay application for the month with its
+// dollar totals from top-level pay items at the month's prices, newest first
+func (q *Queries) GetPayAppRevisions(ctx context.Context, arg GetPayAppRevisionsParams) ([]GetPayAppRevisionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPayAppRevisions, arg.JobID, arg.PayAppMonth)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPayAppRevisionsRow
+	for rows.Next() {
+		var i GetPayAppRevisionsRow
+		if err := rows.Scan(
+			&i.Revision,
+			&i.Source,
+			&i.CreatedAt,
+			&i.ItemCount,
+			&i.WorkThisPeriod,
+			&i.StoredMaterials,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPayItemQuantities = `-- name: GetPayItemQuantities :many
+WITH billed AS (
+    SELECT
+        pa.job_item_id,
+        SUM(pa.qty) AS cumulative_qty,
+        MAX(pa.pay_app_month)::DATE AS last_month
+    FROM pay_applications pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    WHERE ji.job_id = $1
+    GROUP BY pa.job_item_id
+)
+SELECT
+    r.id,
+    r.item_number,
+    r.description,
+    ji.unit,
+    r.original_qty::TEXT AS original_qty,
+    r.qty::TEXT AS contract_qty,
+    r.unit_price::TEXT AS unit_price,
+    b.cumulative_qty::TEXT AS cumulative_qty,
+    b.last_month
+FROM job_items_revised r
+JOIN job_items ji ON ji.id = r.id
+JOIN billed b ON b.job_item_id = r.id
+WHERE r.parent_id IS NULL
+  AND r.unit_price <> 0
+ORDER BY ji.sort_order
+`
+
+type GetPayItemQuantitiesRow struct {
+	ID            uuid.UUID      `json:"id"`
+	ItemNumber    string         `json:"item_number"`
+	Description   string         `json:"description"`
+	Unit          sql.NullString `json:"unit"`
+	OriginalQty   string         `json:"original_qty"`
+	ContractQty   string         `json:"contract_qty"`
+	UnitPrice     string         `json:"unit_price"`
+	CumulativeQty string         `json:"cumulative_qty"`
+	LastMonth     time.Time      `json:"last_month"`
+}
+
+// Fetches each unit-price pay item's quantity billed to date against its
+// contract quantity (bid plus approved change orders). Lump-sum items
+// (unit_price = 0) carry dollars in qty and are left out.
+func (q *Queries) GetPayItemQuantities(ctx context.Context, jobID uuid.UUID) ([]GetPayItemQuantitiesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPayItemQuantities, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPayItemQuantitiesRow
+	for rows.Next() {
+		var i GetPayItemQuantitiesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ItemNumber,
+			&i.Description,
+			&i.Unit,
+			&i.OriginalQty,
+			&i.ContractQty,
+			&i.UnitPrice,
+			&i.CumulativeQty,
+			&i.LastMonth,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPayItemUnitCosts = `-- name: GetPayItemUnitCosts :many
+WITH job_info AS (
+    SELECT id
+    FROM jobs
+    WHERE job_number = $1
+),
+phase_items AS (
+    SELECT
+        ji.job_cost_id AS phase,
+        ir.root_id,
+        SUM(ji.budget) AS budget,
+        COUNT(*) AS items
+    FROM job_items ji
+    JOIN job_item_roots ir ON ir.id = ji.id
+    JOIN job_info j ON ir.job_id = j.id
+    LEFT JOIN job_items parent ON ji.parent_id = parent.id
+    WHERE ji.job_cost_id IS NOT NULL
+      AND parent.job_cost_id IS DISTINCT FROM ji.job_cost_id
+    GROUP BY ji.job_cost_id, ir.root_id
+),
+phase_shares AS (
+    SELECT
+        phase,
+        root_id,
+        CASE
+            WHEN SUM(budget) OVER (PARTITION BY phase) > 0
+            THEN budget / SUM(budget) OVER (PARTITION BY phase)
+            ELSE items::NUMERIC / SUM(items) OVER (PARTITION BY phase)
+        END AS share
+    FROM phase_items
+),
+phase_costs AS (
+    SELECT
+        jcl.phase,
+        DATE_TRUNC('month', jcl.transaction_date)::DATE AS month,
+        SUM(jcl.amount) AS cost
+    FROM job_cost_ledger jcl
+    WHERE jcl.job = $1
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+      AND jcl.transaction_date IS NOT NULL
+      AND jcl.phase IS NOT NULL
+      AND ($2::TIMESTAMP IS NULL OR jcl.created_at <= $2)
+    GROUP BY jcl.phase, DATE_TRUNC('month', jcl.transaction_date)
+),
+pay_items AS (
+    SELECT r.id, ji.sort_order, r.item_number, r.description, ji.unit, r.original_qty, r.budget
+    FROM job_items_revised r
+    JOIN job_items ji ON ji.id = r.id
+    JOIN job_info j ON r.job_id = j.id
+    WHERE r.parent_id IS NULL
+      AND r.qty > 0
+),
+item_costs AS (
+    SELECT ps.root_id, pc.month, SUM(pc.cost * ps.share) AS cost
+    FROM phase_shares ps
+    JOIN phase_costs pc ON pc.phase = ps.phase
+    GROUP BY ps.root_id, pc.month
+),
+item_qty AS (
+    SELECT pa.job_item_id AS root_id, pa.pay_app_month AS month, SUM(pa.qty) AS qty
+    FROM pay_applications_as_of($2::TIMESTAMP) pa
+    JOIN pay_items pi ON pa.job_item_id = pi.id
+    GROUP BY pa.job_item_id, pa.pay_app_month
+),
+months AS (
+    SELECT month FROM item_qty
+    UNION
+    SELECT month FROM item_costs
+),
+item_months AS (
+    SELECT
+        pi.sort_order,
+        pi.item_number,
+        pi.description,
+        COALESCE(pi.unit, '')::TEXT AS unit,
+        pi.original_qty::TEXT AS bid_qty,
+        pi.budget::TEXT AS budget,
+        m.month,
+        COALESCE(SUM(iq.qty) OVER (PARTITION BY pi.id ORDER BY m.month), 0)::TEXT AS installed_qty,
+        ROUND(COALESCE(SUM(ic.cost) OVER (PARTITION BY pi.id ORDER BY m.month), 0), 2)::TEXT AS actual_cost
+    FROM pay_items pi
+    CROSS JOIN months m
+    LEFT JOIN item_qty iq ON iq.root_id = pi.id AND iq.month = m.month
+    LEFT JOIN item_costs ic ON ic.root_id = pi.id AND ic.month = m.month
+)
+SELECT item_number, description, unit, bid_qty, budget, month, installed_qty, actual_cost
+FROM item_months
+WHERE ($3::DATE IS NULL OR month >= $3)
+  AND ($4::DATE IS NULL OR month <= $4)
+ORDER BY sort_order, month
+`
+
+type GetPayItemUnitCostsParams struct {
+	JobNumber string       `json:"job_number"`
+	AsOf      sql.NullTime `json:"as_of"`
+	FromMonth sql.NullTime `json:"from_month"`
+	ToMonth   sql.NullTime `json:"to_month"`
+}
+
+type GetPayItemUnitCostsRow struct {
+	ItemNumber   string    `json:"item_number"`
+	Description  string    `json:"description"`
+	Unit         string    `json:"unit"`
+	BidQty       string    `json:"bid_qty"`
+	Budget       string    `json:"budget"`
+	Month        time.Time `json:"month"`
+	InstalledQty string    `json:"installed_qty"`
+	ActualCost   string    `json:"actual_cost"`
+}
+
+// Fetches cumulative installed quantity and actual cost per pay item by month
+// Ledger cost reaches a pay item through the job_cost_ids of the items beneath
+// it. A phase spread over several pay items is split by each one's share of
+// the phase budget (evenly when the phase has no budget). Bid quantity is the
+// original quantity, since change orders revise quantity but not budget
+// as_of leaves out ledger rows imported after it and reads each month's pay app
+// as the revision submitted by then; from_month and to_month limit the months
+// returned without changing the cumulative totals
+func (q *Queries) GetPayItemUnitCosts(ctx context.Context, arg GetPayItemUnitCostsParams) ([]GetPayItemUnitCostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPayItemUnitCosts,
+		arg.JobNumber,
+		arg.AsOf,
+		arg.FromMonth,
+		arg.ToMonth,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPayItemUnitCostsRow
+	for rows.Next() {
+		var i GetPayItemUnitCostsRow
+		if err := rows.Scan(
+			&i.ItemNumber,
+			&i.Description,
+			&i.Unit,
+			&i.BidQty,
+			&i.Budget,
+			&i.Month,
+			&i.InstalledQty,
+			&i.ActualCost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPeriodClose = `-- name: GetPeriodClose :one
+SELECT job_id, period_month, closed_by, closed_at
+FROM period_closes
+WHERE job_id = $1 AND period_month = $2
+`
+
+type GetPeriodCloseParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PeriodMonth time.Time `json:"period_month"`
+}
+
+func (q *Queries) GetPeriodClose(ctx context.Context, arg GetPeriodCloseParams) (PeriodClose, error) {
+	row := q.db.QueryRowContext(ctx, getPeriodClose, arg.JobID, arg.PeriodMonth)
+	var i PeriodClose
+	err := row.Scan(
+		&i.JobID,
+		&i.PeriodMonth,
+		&i.ClosedBy,
+		&i.ClosedAt,
+	)
+	return i, err
+}
+
+const getPeriodCloseEvents = `-- name: GetPeriodCloseEvents :many
+SELECT id, job_id, period_month, action, actor, reason, created_at
+FROM period_close_events
+WHERE job_id = $1
+ORDER BY created_at DESC
+`
+
+// Fetches a job's close and reopen history, newest first
+func (q *Queries) GetPeriodCloseEvents(ctx context.Context, jobID uuid.UUID) ([]PeriodCloseEvent, error) {
+	rows, err := q.db.QueryContext(ctx, getPeriodCloseEvents, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PeriodCloseEvent
+	for rows.Next() {
+		var i PeriodCloseEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.JobID,
+			&i.PeriodMonth,
+			&i.Action,
+			&i.Actor,
+			&i.Reason,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPeriodCloses = `-- name: GetPeriodCloses :many
+SELECT job_id, period_month, closed_by, closed_at
+FROM period_closes
+WHERE job_id = $1
+ORDER BY period_month
+`
+
+// Fetches a job's closed months, oldest first
+func (q *Queries) GetPeriodCloses(ctx context.Context, jobID uuid.UUID) ([]PeriodClose, error) {
+	rows, err := q.db.QueryContext(ctx, getPeriodCloses, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PeriodClose
+	for rows.Next() {
+		var i PeriodClose
+		if err := rows.Scan(
+			&i.JobID,
+			&i.PeriodMonth,
+			&i.ClosedBy,
+			&i.ClosedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPhaseBudgets = `-- name: GetPhaseBudgets :many
+WITH estimate_budgets AS (
+    SELECT
+        COALESCE(jcl.phase, '') AS phase,
+        SUM(jcl.amount) AS budget
+    FROM job_cost_ledger jcl
+    WHERE jcl.job = $1
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class IN ('original_budget', 'budget_revision'))
+      AND ($2::DATE IS NULL OR jcl.transaction_date IS NULL OR jcl.transaction_date < $2 + INTERVAL '1 month')
+      AND ($3::TIMESTAMP IS NULL OR jcl.created_at <= $3)
+    GROUP BY COALESCE(jcl.phase, '')
+),
+item_budgets AS (
+    SELECT
+        ji.job_cost_id AS phase,
+        SUM(ji.budget) AS budget,
+        STRING_AGG(DISTINCT ji.description, ', ') AS descriptions
+    FROM job_items ji
+    LEFT JOIN job_items parent ON ji.parent_id = parent.id
+    WHERE ji.job_id = (SELECT j.id FROM jobs j WHERE j.job_number = $1)
+      AND ji.job_cost_id IS NOT NULL
+      AND parent.job_cost_id IS DISTINCT FROM ji.job_cost_id
+    GROUP BY ji.job_cost_id
+)
+SELECT
+    COALESCE(eb.phase, ib.phase)::TEXT AS phase,
+    COALESCE(ib.descriptions, '')::TEXT AS description,
+    COALESCE(eb.budget, 0)::TEXT AS estimate_budget,
+    COALESCE(ib.budget, 0)::TEXT AS item_budget
+FROM estimate_budgets eb
+FULL OUTER JOIN item_budgets ib ON eb.phase = ib.phase
+ORDER BY 1
+`
+
+type GetPhaseBudgetsParams struct {
+	Job     string       `json:"job"`
+	ToMonth sql.NullTime `json:"to_month"`
+	AsOf    sql.NullTime `json:"as_of"`
+}
+
+type GetPhaseBudgetsRow struct {
+	Phase          string `json:"phase"`
+	Description    string `json:"description"`
+	EstimateBudget string `json:"estimate_budget"`
+	ItemBudget     string `json:"item_budget"`
+}
+
+// Fetches per-phase budgets from original budget and budget revision ledger rows and from job_items.budget
+// Item budgets only count the topmost item carrying each job_cost_id so parents and children aren't summed twice
+// Budget rows dated after to_month or imported after as_of are left out
+func (q *Queries) GetPhaseBudgets(ctx context.Context, arg GetPhaseBudgetsParams) ([]GetPhaseBudgetsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPhaseBudgets, arg.Job, arg.ToMonth, arg.AsOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPhaseBudgetsRow
+	for rows.Next() {
+		var i GetPhaseBudgetsRow
+		if err := rows.Scan(
+			&i.Phase,
+			&i.Description,
+			&i.EstimateBudget,
+			&i.ItemBudget,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPhaseEarnedValue = `-- name: GetPhaseEarnedValue :many
+WITH job_info AS (
+    SELECT id
+    FROM jobs
+    WHERE job_number = $1
+),
+phase_items AS (
+    SELECT
+        ji.id,
+        ji.job_cost_id AS phase,
+        ji.description,
+        ji.budget,
+        r.qty,
+        ir.root_id
+    FROM job_items ji
+    JOIN job_items_revised r ON r.id = ji.id
+    JOIN job_item_roots ir ON ir.id = ji.id
+    JOIN job_info j ON ir.job_id = j.id
+    LEFT JOIN job_items parent ON ji.parent_id = parent.id
+    WHERE ji.job_cost_id IS NOT NULL
+      AND parent.job_cost_id IS DISTINCT FROM ji.job_cost_id
+),
+item_progress AS (
+    -- Cumulative fraction of each item's revised quantity billed by month
+    SELECT
+        pa.job_item_id,
+        pa.pay_app_month AS month,
+        SUM(pa.qty) OVER (PARTITION BY pa.job_item_id ORDER BY pa.pay_app_month) / r.qty AS fraction
+    FROM pay_applications_as_of($2::TIMESTAMP) pa
+    JOIN job_items_revised r ON pa.job_item_id = r.id
+    JOIN job_info j ON r.job_id = j.id
+    WHERE r.qty > 0
+),
+phase_costs AS (
+    SELECT
+        jcl.phase,
+        DATE_TRUNC('month', jcl.transaction_date)::DATE AS month,
+        SUM(jcl.amount) AS cost
+    FROM job_cost_ledger jcl
+    WHERE jcl.job = $1
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+      AND jcl.transaction_date IS NOT NULL
+      AND jcl.phase IS NOT NULL
+      AND ($2::TIMESTAMP IS NULL OR jcl.created_at <= $2)
+    GROUP BY jcl.phase, DATE_TRUNC('month', jcl.transaction_date)
+),
+phases AS (
+    SELECT phase FROM phase_items
+    UNION
+    SELECT phase FROM phase_costs
+),
+months AS (
+    SELECT month FROM item_progress
+    UNION
+    SELECT month FROM phase_costs
+),
+phase_ev AS (
+    SELECT
+        pi.phase,
+        m.month,
+        STRING_AGG(DISTINCT pi.description, ', ') AS description,
+        SUM(pi.budget) AS budget,
+        SUM(pi.budget * COALESCE(ip.fraction, 0)) AS earned_value
+    FROM phase_items pi
+    CROSS JOIN months m
+    LEFT JOIN LATERAL (
+        -- Carry progress forward through months the item wasn't billed
+        SELECT p.fraction
+        FROM item_progress p
+        WHERE p.job_item_id = CASE WHEN pi.qty > 0 THEN pi.id ELSE pi.root_id END
+          AND p.month <= m.month
+        ORDER BY p.month DESC
+        LIMIT 1
+    ) ip ON true
+    GROUP BY pi.phase, m.month
+),
+phase_months AS (
+    SELECT
+        ph.phase::TEXT AS phase,
+        m.month,
+        COALESCE(pe.description, '')::TEXT AS description,
+        ROUND(COALESCE(pe.budget, 0), 2)::TEXT AS budget,
+        ROUND(COALESCE(pe.earned_value, 0), 2)::TEXT AS earned_value,
+        COALESCE(SUM(pc.cost) OVER (PARTITION BY ph.phase ORDER BY m.month), 0)::TEXT AS actual_cost
+    FROM phases ph
+    CROSS JOIN months m
+    LEFT JOIN phase_ev pe ON pe.phase = ph.phase AND pe.month = m.month
+    LEFT JOIN phase_costs pc ON pc.phase = ph.phase AND pc.month = m.month
+)
+SELECT phase, month, description, budget, earned_value, actual_cost
+FROM phase_months
+WHERE ($3::DATE IS NULL OR month >= $3)
+  AND ($4::DATE IS NULL OR month <= $4)
+ORDER BY phase, month
+`
+
+type GetPhaseEarnedValueParams struct {
+	JobNumber string       `json:"job_number"`
+	AsOf      sql.NullTime `json:"as_of"`
+	FromMonth sql.NullTime `json:"from_month"`
+	ToMonth   sql.NullTime `json:"to_month"`
+}
+
+type GetPhaseEarnedValueRow struct {
+	Phase       string    `json:"phase"`
+	Month       time.Time `json:"month"`
+	Description string    `json:"description"`
+	Budget      string    `json:"budget"`
+	EarnedValue string    `json:"earned_value"`
+	ActualCost  string    `json:"actual_cost"`
+}
+
+// Fetches monthly earned value and cumulative actual cost per cost code
+// Each item carrying a job_cost_id earns its budget in proportion to its own
+// billed quantity, or its top-level pay item's when it has no quantity of its
+// own. Item budgets only count the topmost item carrying each job_cost_id, as
+// in GetPhaseBudgets. Phases with cost but no items have no earned value
+// as_of leaves out ledger rows imported after it and reads each month's pay app
+// as the revision submitted by then; from_month and to_month limit the months
+// returned without changing the cumulative totals
+func (q *Queries) GetPhaseEarnedValue(ctx context.Context, arg GetPhaseEarnedValueParams) ([]GetPhaseEarnedValueRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPhaseEarnedValue,
+		arg.JobNumber,
+		arg.AsOf,
+		arg.FromMonth,
+		arg.ToMonth,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPhaseEarnedValueRow
+	for rows.Next() {
+		var i GetPhaseEarnedValueRow
+		if err := rows.Scan(
+			&i.Phase,
+			&i.Month,
+			&i.Description,
+			&i.Budget,
+			&i.EarnedValue,
+			&i.ActualCost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPhaseMonthlyCosts = `-- name: GetPhaseMonthlyCosts :many
+SELECT
+    COALESCE(jcl.phase, '')::TEXT AS phase,
+    COALESCE(jcl.cat, '')::TEXT AS cat,
+    DATE_TRUNC('month', jcl.transaction_date)::DATE AS month,
+    SUM(jcl.amount)::TEXT AS actual_cost
+FROM job_cost_ledger jcl
+WHERE jcl.job = $1
+  AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+  AND jcl.transaction_date IS NOT NULL
+  AND ($2::DATE IS NULL OR jcl.transaction_date >= $2)
+  AND ($3::DATE IS NULL OR jcl.transaction_date < $3 + INTERVAL '1 month')
+  AND ($4::TIMESTAMP IS NULL OR jcl.created_at <= $4)
+GROUP BY COALESCE(jcl.phase, ''), COALESCE(jcl.cat, ''), DATE_TRUNC('month', jcl.transaction_date)
+ORDER BY phase, cat, month
+`
+
+type GetPhaseMonthlyCostsParams struct {
+	Job       string       `json:"job"`
+	FromMonth sql.NullTime `json:"from_month"`
+	ToMonth   sql.NullTime `json:"to_month"`
+	AsOf      sql.NullTime `json:"as_of"`
+}
+
+type GetPhaseMonthlyCostsRow struct {
+	Phase      string    `json:"phase"`
+	Cat        string    `json:"cat"`
+	Month      time.Time `json:"month"`
+	ActualCost string    `json:"actual_cost"`
+}
+
+// Fetches actual cost per phase, cat and month for a job from job_cost_ledger
+// from_month and to_month limit the months returned; as_of leaves out rows
+// imported after it
+func (q *Queries) GetPhaseMonthlyCosts(ctx context.Context, arg GetPhaseMonthlyCostsParams) ([]GetPhaseMonthlyCostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPhaseMonthlyCosts,
+		arg.Job,
+		arg.FromMonth,
+		arg.ToMonth,
+		arg.AsOf,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPhaseMonthlyCostsRow
+	for rows.Next() {
+		var i GetPhaseMonthlyCostsRow
+		if err := rows.Scan(
+			&i.Phase,
+			&i.Cat,
+			&i.Month,
+			&i.ActualCost,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPortfolio = `-- name: GetPortfolio :many
+WITH job_list AS (
+    SELECT id, job_number, job_name, status, contract_value
+    FROM jobs
+    WHERE (status = $1 OR $1 = '')
+),
+scheduled AS (
+    SELECT
+        ji.job_id,
+        SUM(ji.qty) AS total_qty,
+        SUM(ji.qty * ji.unit_price) AS total_contract_value
+    FROM job_items_revised ji
+    JOIN job_list j ON ji.job_id = j.id
+    WHERE ji.qty > 0
+    GROUP BY ji.job_id
+),
+bid_items AS (
+    SELECT
+        ji.job_id,
+        SUM(ji.budget) FILTER (WHERE ji.parent_id IS NULL) AS bid_cost,
+        MAX(ji.updated_at) AS last_import
+    FROM job_items ji
+    JOIN job_list j ON ji.job_id = j.id
+    GROUP BY ji.job_id
+),
+approved_change_orders AS (
+    SELECT co.job_id, SUM(co.amount) AS amount
+    FROM change_orders co
+    JOIN job_list j ON co.job_id = j.id
+    WHERE co.status = 'approved'
+      AND ($2::DATE IS NULL OR co.co_date < $2 + INTERVAL '1 month')
+      AND ($3::TIMESTAMP IS NULL OR co.updated_at <= $3)
+    GROUP BY co.job_id
+),
+pay_apps AS (
+    SELECT pa.*
+    FROM pay_applications_as_of($3::TIMESTAMP) pa
+    WHERE $2::DATE IS NULL OR pa.pay_app_month <= $2
+),
+pay_app_progress AS (
+    SELECT
+        ji.job_id,
+        SUM(pa.qty) AS cumulative_qty,
+        MAX(pa.updated_at) AS last_import
+    FROM pay_apps pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    JOIN job_list j ON ji.job_id = j.id
+    GROUP BY ji.job_id
+),
+billed AS (
+    -- Work billed on top-level items, priced as in pay_app_monthly_totals
+    SELECT
+        ji.job_id,
+        SUM(
+            CASE
+                WHEN p.unit_price = 0 THEN pa.qty
+                ELSE pa.qty * p.unit_price
+            END
+        ) AS billed
+    FROM pay_apps pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    JOIN job_list j ON ji.job_id = j.id
+    JOIN job_item_prices p ON p.job_item_id = pa.job_item_id
+     AND pa.pay_app_month >= p.from_month
+     AND (p.until_month IS NULL OR pa.pay_app_month < p.until_month)
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id
+),
+costs AS (
+    SELECT
+        j.id AS job_id,
+        SUM(jcl.amount) FILTER (WHERE jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')) AS cost,
+        MAX(jcl.created_at) AS last_import
+    FROM job_cost_ledger jcl
+    JOIN job_list j ON jcl.job = j.job_number
+    WHERE ($2::DATE IS NULL OR jcl.transaction_date < $2 + INTERVAL '1 month')
+      AND ($3::TIMESTAMP IS NULL OR jcl.created_at <= $3)
+    GROUP BY j.id
+),
+metrics AS (
+    SELECT
+        j.id,
+        j.job_number,
+        j.job_name,
+        j.status,
+        COALESCE(j.contract_value + COALESCE(aco.amount, 0), s.total_contract_value, 0) AS contract_value,
+        COALESCE(b.billed, 0) AS billed,
+        COALESCE(c.cost, 0) AS cost,
+        COALESCE(bi.bid_cost, 0) AS bid_cost,
+        CASE
+            WHEN COALESCE(s.total_qty, 0) > 0
+            THEN COALESCE(pp.cumulative_qty, 0) / s.total_qty
+            ELSE 0
+        END AS fraction,
+        GREATEST(c.last_import, pp.last_import, bi.last_import) AS last_import
+    FROM job_list j
+    LEFT JOIN scheduled s ON s.job_id = j.id
+    LEFT JOIN bid_items bi ON bi.job_id = j.id
+    LEFT JOIN approved_change_orders aco ON aco.job_id = j.id
+    LEFT JOIN pay_app_progress pp ON pp.job_id = j.id
+    LEFT JOIN billed b ON b.job_id = j.id
+    LEFT JOIN costs c ON c.job_id = j.id
+),
+projections AS (
+    SELECT
+        m.*,
+        m.fraction * m.contract_value AS earned_value,
+        CASE
+            WHEN m.cost > 0 AND m.fraction > 0
+            THEN m.cost / m.fraction
+            ELSE m.bid_cost
+        END AS projected_cost
+    FROM metrics m
+)
+SELECT
+    p.id,
+    p.job_number,
+    p.job_name,
+    p.status,
+    ROUND(p.contract_value, 2)::TEXT AS contract_value,
+    ROUND(p.billed, 2)::TEXT AS billed,
+    ROUND(p.cost, 2)::TEXT AS cost,
+    ROUND(p.earned_value, 2)::TEXT AS earned_value,
+    CASE
+        WHEN p.cost > 0
+        THEN ROUND(p.earned_value / p.cost, 2)::TEXT
+        ELSE '0'
+    END AS cpi,
+    ROUND(p.fraction * 100, 2)::TEXT AS percent_complete,
+    ROUND(p.projected_cost, 2)::TEXT AS projected_cost,
+    ROUND(p.contract_value - p.projected_cost, 2)::TEXT AS projected_margin,
+    CASE
+        WHEN p.contract_value > 0
+        THEN ROUND((p.contract_value - p.projected_cost) / p.contract_value * 100, 2)::TEXT
+        ELSE '0'
+    END AS projected_margin_percent,
+    p.last_import::TIMESTAMP AS last_import
+FROM projections p
+ORDER BY p.job_number
+`
+
+type GetPortfolioParams struct {
+	Status  string       `json:"status"`
+	ToMonth sql.NullTime `json:"to_month"`
+	AsOf    sql.NullTime `json:"as_of"`
+}
+
+type GetPortfolioRow struct {
+	ID                     uuid.UUID    `json:"id"`
+	JobNumber              string       `json:"job_number"`
+	JobName                string       `json:"job_name"`
+	Status                 string       `json:"status"`
+	ContractValue          string       `json:"contract_value"`
+	Billed                 string       `json:"billed"`
+	Cost                   string       `json:"cost"`
+	EarnedValue            string       `json:"earned_value"`
+	Cpi                    string       `json:"cpi"`
+	PercentComplete        string       `json:"percent_complete"`
+	ProjectedCost          string       `json:"projected_cost"`
+	ProjectedMargin        string       `json:"projected_margin"`
+	ProjectedMarginPercent string       `json:"projected_margin_percent"`
+	LastImport             sql.NullTime `json:"last_import"`
+}
+
+// Fetches summary metrics for every job, optionally filtered by status (” for all)
+// Contract value, earned value and CPI follow GetCostPerformanceIndex. Projected
+// cost is contract value / CPI once there is both cost and earned value, and the
+// bid's direct cost before that. Last import is the latest ledger, pay app or
+// bid row written for the job
+// Figures are to date at the end of to_month when it is set. as_of leaves out
+// ledger rows imported and change orders changed after it and reads each
+// month's pay app as the revision submitted by then
+func (q *Queries) GetPortfolio(ctx context.Context, arg GetPortfolioParams) ([]GetPortfolioRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPortfolio, arg.Status, arg.ToMonth, arg.AsOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPortfolioRow
+	for rows.Next() {
+		var i GetPortfolioRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.JobNumber,
+			&i.JobName,
+			&i.Status,
+			&i.ContractValue,
+			&i.Billed,
+			&i.Cost,
+			&i.EarnedValue,
+			&i.Cpi,
+			&i.PercentComplete,
+			&i.ProjectedCost,
+			&i.ProjectedMargin,
+			&i.ProjectedMarginPercent,
+			&i.LastImport,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRetainageReleases = `-- name: GetRetainageReleases :many
+SELECT id, job_id, release_month, amount, note, created_at
+FROM retainage_releases
+WHERE job_id = $1
+ORDER BY release_month, created_at
+`
+
+// Fetches retainage released to a job, oldest first
+func (q *Queries) GetRetainageReleases(ctx context.Context, jobID uuid.UUID) ([]RetainageRelease, error) {
+	rows, err := q.db.QueryContext(ctx, getRetainageReleases, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RetainageRelease
+	for rows.Next() {
+		var i RetainageRelease
+		if err := rows.Scan(
+			&i.ID,
+			&i.JobID,
+			&i.ReleaseMonth,
+			&i.Amount,
+			&i.Note,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRetainageTiers = `-- name: GetRetainageTiers :many
+SELECT id, job_id, threshold_percent, work_rate, materials_rate, created_at
+FROM retainage_tiers
+WHERE job_id = $1
+ORDER BY threshold_percent
+`
+
+// Fetches a job's retainage tiers, lowest completion threshold first
+func (q *Queries) GetRetainageTiers(ctx context.Context, jobID uuid.UUID) ([]RetainageTier, error) {
+	rows, err := q.db.QueryContext(ctx, getRetainageTiers, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RetainageTier
+	for rows.Next() {
+		var i RetainageTier
+		if err := rows.Scan(
+			&i.ID,
+			&i.JobID,
+			&i.ThresholdPercent,
+			&i.WorkRate,
+			&i.MaterialsRate,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getRevisedContractValue = `-- name: GetRevisedContractValue :one
+WITH original AS (
+    SELECT
+        j.id,
+        COALESCE(
+            j.contract_value,
+            (SELECT SUM(ji.scheduled_value) FROM job_items ji WHERE ji.job_id = j.id AND ji.parent_id IS NULL),
+            0
+        ) AS contract_value
+    FROM jobs j
+    WHERE j.id = $1
+),
+change_order_totals AS (
+    SELECT
+        COALESCE(SUM(amount) FILTER (WHERE status = 'approved'), 0) AS approved,
+        COALESCE(SUM(amount) FILTER (WHERE status = 'pending'), 0) AS pending
+    FROM change_orders
+    WHERE job_id = $1
+)
+SELECT
+    o.contract_value::TEXT AS original_contract_value,
+    cot.approved::TEXT AS approved_change_orders,
+    cot.pending::TEXT AS pending_change_orders,
+    (o.contract_value + cot.approved)::TEXT AS revised_contract_value
+FROM original o
+CROSS JOIN change_order_totals cot
+`
+
+type GetRevisedContractValueRow struct {
+	OriginalContractValue string `json:"original_contract_value"`
+	ApprovedChangeOrders  string `json:"approved_change_orders"`
+	PendingChangeOrders   string `json:"pending_change_orders"`
+	RevisedContractValue  string `json:"revised_contract_value"`
+}
+
+// Original contract value is jobs.contract_value, falling back to the sum of
+// top-level scheduled values from the bid; approved change orders are added on top
+func (q *Queries) GetRevisedContractValue(ctx context.Context, id uuid.UUID) (GetRevisedContractValueRow, error) {
+	row := q.db.QueryRowContext(ctx, getRevisedContractValue, id)
+	var i GetRevisedContractValueRow
+	err := row.Scan(
+		&i.OriginalContractValue,
+		&i.ApprovedChangeOrders,
+		&i.PendingChangeOrders,
+		&i.RevisedContractValue,
+	)
+	return i, err
+}
+
+const getRevisedJobItem = `-- name: GetRevisedJobItem :one
+SELECT
+    id,
+    item_number,
+    qty::TEXT AS qty,
+    unit_price::TEXT AS unit_price,
+    scheduled_value::TEXT AS scheduled_value
+FROM job_items_revised
+WHERE job_id = $1 AND item_number = $2
+`
+
+type GetRevisedJobItemParams struct {
+	JobID      uuid.UUID `json:"job_id"`
+	ItemNumber string    `json:"item_number"`
+}
+
+type GetRevisedJobItemRow struct {
+	ID             uuid.UUID `json:"id"`
+	ItemNumber     string    `json:"item_number"`
+	Qty            string    `json:"qty"`
+	UnitPrice      string    `json:"unit_price"`
+	ScheduledValue string    `json:"scheduled_value"`
+}
+
+// Fetches a job item's quantity, unit price and scheduled value with approved change orders applied
+func (q *Queries) GetRevisedJobItem(ctx context.Context, arg GetRevisedJobItemParams) (GetRevisedJobItemRow, error) {
+	row := q.db.QueryRowContext(ctx, getRevisedJobItem, arg.JobID, arg.ItemNumber)
+	var i GetRevisedJobItemRow
+	err := row.Scan(
+		&i.ID,
+		&i.ItemNumber,
+		&i.Qty,
+		&i.UnitPrice,
+		&i.ScheduledValue,
+	)
+	return i, err
+}
+
+const getSessionUser = `-- name: GetSessionUser :one
+SELECT u.id, u.username, u.password_hash, u.role, u.disabled, u.created_at, u.updated_at
+FROM sessions s
+JOIN users u ON s.user_id = u.id
+WHERE s.token_hash = $1 AND s.expires_at > NOW() AND NOT u.disabled
+`
+
+// Fetches the enabled user signed in with an unexpired session
+func (q *Queries) GetSessionUser(ctx context.Context, tokenHash string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getSessionUser, tokenHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getStoredMaterialsLedger = `-- name: GetStoredMaterialsLedger :many
+SELECT
+    job_item_id,
+    job_id,
+    item_number,
+    pay_app_month,
+    previous_balance,
+    materials_added,
+    materials_installed,
+    balance
+FROM stored_materials_ledger
+WHERE job_id = $1
+ORDER BY item_number, pay_app_month
+`
+
+// Fetches the stored materials running balance for every item and month on a job
+func (q *Queries) GetStoredMaterialsLedger(ctx context.Context, jobID uuid.UUID) ([]StoredMaterialsLedger, error) {
+	rows, err := q.db.QueryContext(ctx, getStoredMaterialsLedger, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []StoredMaterialsLedger
+	for rows.Next() {
+		var i StoredMaterialsLedger
+		if err := rows.Scan(
+			&i.JobItemID,
+			&i.JobID,
+			&i.ItemNumber,
+			&i.PayAppMonth,
+			&i.PreviousBalance,
+			&i.MaterialsAdded,
+			&i.MaterialsInstalled,
+			&i.Balance,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getTransactionTypeClasses = `-- name: GetTransactionTypeClasses :many
+SELECT transaction_type, class, created_at, updated_at
+FROM transaction_type_classes
+ORDER BY class, transaction_type
+`
+
+// Fetches every classified ledger transaction type
+func (q *Queries) GetTransactionTypeClasses(ctx context.Context) ([]TransactionTypeClass, error) {
+	rows, err := q.db.QueryContext(ctx, getTransactionTypeClasses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TransactionTypeClass
+	for rows.Next() {
+		var i TransactionTypeClass
+		if err := rows.Scan(
+			&i.TransactionType,
+			&i.Class,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnbilledPayAppItem = `-- name: GetUnbilledPayAppItem :one
+SELECT
+    ji.id AS job_item_id,
+    ji.item_number,
+    ji.description,
+    ji.unit,
+    (ji.parent_id IS NULL)::BOOLEAN AS is_pay_item,
+    r.qty::TEXT AS total_qty,
+    p.unit_price::TEXT AS unit_price,
+    r.scheduled_value::TEXT AS scheduled_value,
+    COALESCE((
+        SELECT SUM(pa.qty)
+        FROM pay_applications pa
+        WHERE pa.job_item_id = ji.id AND pa.pay_app_month < $2
+    ), 0)::TEXT AS previous_cumulative_qty,
+    COALESCE((
+        SELECT pa.stored_materials
+        FROM pay_applications pa
+        WHERE pa.job_item_id = ji.id AND pa.pay_app_month < $2
+        ORDER BY pa.pay_app_month DESC
+        LIMIT 1
+    ), 0)::TEXT AS previous_stored_materials
+FROM job_items ji
+JOIN job_items_revised r ON r.id = ji.id
+JOIN job_item_prices p ON p.job_item_id = ji.id
+ AND $2 >= p.from_month
+ AND (p.until_month IS NULL OR $2 < p.until_month)
+WHERE ji.job_id = $1
+  AND ji.id = $3
+  AND NOT EXISTS (
+    SELECT 1 FROM pay_applications pa
+    WHERE pa.job_item_id = ji.id AND pa.pay_app_month = $2
+  )
+`
+
+type GetUnbilledPayAppItemParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PayAppMonth time.Time `json:"pay_app_month"`
+	ID          uuid.UUID `json:"id"`
+}
+
+type GetUnbilledPayAppItemRow struct {
+	JobItemID               uuid.UUID      `json:"job_item_id"`
+	ItemNumber              string         `json:"item_number"`
+	Description             string         `json:"description"`
+	Unit                    sql.NullString `json:"unit"`
+	IsPayItem               bool           `json:"is_pay_item"`
+	TotalQty                string         `json:"total_qty"`
+	UnitPrice               string         `json:"unit_price"`
+	ScheduledValue          string         `json:"scheduled_value"`
+	PreviousCumulativeQty   string         `json:"previous_cumulative_qty"`
+	PreviousStoredMaterials string         `json:"previous_stored_materials"`
+}
+
+// Fetches a job item with no row on the month's pay application, with its
+// cumulative quantity and stored materials balance from earlier months and
+// the unit price in effect that month, so an edit can add it
+func (q *Queries) GetUnbilledPayAppItem(ctx context.Context, arg GetUnbilledPayAppItemParams) (GetUnbilledPayAppItemRow, error) {
+	row := q.db.QueryRowContext(ctx, getUnbilledPayAppItem, arg.JobID, arg.PayAppMonth, arg.ID)
+	var i GetUnbilledPayAppItemRow
+	err := row.Scan(
+		&i.JobItemID,
+		&i.ItemNumber,
+		&i.Description,
+		&i.Unit,
+		&i.IsPayItem,
+		&i.TotalQty,
+		&i.UnitPrice,
+		&i.ScheduledValue,
+		&i.PreviousCumulativeQty,
+		&i.PreviousStoredMaterials,
+	)
+	return i, err
+}
+
+const getUnclassifiedTransactionTypes = `-- name: GetUnclassifiedTransactionTypes :many
+SELECT
+    COALESCE(jcl.transaction_type, '')::TEXT AS transaction_type,
+    COUNT(*)::BIGINT AS row_count,
+    SUM(jcl.amount)::TEXT AS total_amount,
+    COUNT(DISTINCT jcl.job)::BIGINT AS job_count,
+    MIN(jcl.transaction_date)::DATE AS first_seen,
+    MAX(jcl.transaction_date)::DATE AS last_seen
+FROM job_cost_ledger jcl
+LEFT JOIN transaction_type_classes ttc ON ttc.transaction_type = jcl.transaction_type
+WHERE ttc.transaction_type IS NULL
+GROUP BY jcl.transaction_type
+ORDER BY COUNT(*) DESC
+`
+
+type GetUnclassifiedTransactionTypesRow struct {
+	TransactionType string       `json:"transaction_type"`
+	RowCount        int64        `json:"row_count"`
+	TotalAmount     string       `json:"total_amount"`
+	JobCount        int64        `json:"job_count"`
+	FirstSeen       sql.NullTime `json:"first_seen"`
+	LastSeen        sql.NullTime `json:"last_seen"`
+}
+
+// Fetches transaction types seen in the ledger that have no classification,
+// with how often and over what dates they appear
+func (q *Queries) GetUnclassifiedTransactionTypes(ctx context.Context) ([]GetUnclassifiedTransactionTypesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUnclassifiedTransactionTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUnclassifiedTransactionTypesRow
+	for rows.Next() {
+		var i GetUnclassifiedTransactionTypesRow
+		if err := rows.Scan(
+			&i.TransactionType,
+			&i.RowCount,
+			&i.TotalAmount,
+			&i.JobCount,
+			&i.FirstSeen,
+			&i.LastSeen,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserByUsername = `-- name: GetUserByUsername :one
+SELECT id, username, password_hash, role, disabled, created_at, updated_at
+FROM users
+WHERE username = $1
+`
+
+func (q *Queries) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUsername, username)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getWIPSchedule = `-- name: GetWIPSchedule :many
+WITH params AS (
+    SELECT (DATE_TRUNC('month', $1::DATE) + INTERVAL '1 month')::DATE AS cutoff
+),
+job_list AS (
+    SELECT j.id, j.job_number, j.job_name, j.contract_value
+    FROM jobs j
+    CROSS JOIN params p
+    WHERE COALESCE((
+        SELECT c.status
+        FROM job_status_changes c
+        WHERE c.job_id = j.id AND c.changed_at < p.cutoff
+        ORDER BY c.changed_at DESC
+        LIMIT 1
+    ), 'active') = 'active'
+),
+approved_change_orders AS (
+    SELECT co.id, co.job_id, co.amount
+    FROM change_orders co
+    JOIN job_list j ON co.job_id = j.id
+    CROSS JOIN params p
+    WHERE co.status = 'approved'
+      AND co.co_date < p.cutoff
+),
+job_items_as_of AS (
+    -- Bid items, and items added by the change orders approved by then
+    SELECT ji.*
+    FROM job_items ji
+    JOIN job_list j ON ji.job_id = j.id
+    WHERE ji.change_order_id IS NULL
+       OR ji.change_order_id IN (SELECT id FROM approved_change_orders)
+),
+scheduled AS (
+    SELECT s.job_id, SUM(s.amount) AS scheduled_value
+    FROM (
+        SELECT ji.job_id, ji.scheduled_value AS amount
+        FROM job_items_as_of ji
+        WHERE ji.parent_id IS NULL
+        UNION ALL
+        SELECT ji.job_id, coi.amount
+        FROM change_order_items coi
+        JOIN approved_change_orders aco ON coi.change_order_id = aco.id
+        JOIN job_items_as_of ji ON coi.job_item_id = ji.id
+        WHERE ji.parent_id IS NULL
+    ) s
+    GROUP BY s.job_id
+),
+change_order_totals AS (
+    SELECT job_id, SUM(amount) AS amount
+    FROM approved_change_orders
+    GROUP BY job_id
+),
+bid_estimates AS (
+    SELECT ji.job_id, SUM(ji.budget) AS budget
+    FROM job_items_as_of ji
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id
+),
+ledger AS (
+    SELECT
+        j.id AS job_id,
+        SUM(jcl.amount) FILTER (
+            WHERE jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+              AND jcl.transaction_date < p.cutoff
+        ) AS cost,
+        SUM(jcl.amount) FILTER (
+            WHERE jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'billing')
+              AND jcl.transaction_date < p.cutoff
+        ) AS billed,
+        -- Undated budget rows are taken as part of the original estimate
+        SUM(jcl.amount) FILTER (
+            WHERE jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class IN ('original_budget', 'budget_revision'))
+              AND COALESCE(jcl.transaction_date < p.cutoff, true)
+        ) AS estimate
+    FROM job_cost_ledger jcl
+    JOIN job_list j ON jcl.job = j.job_number
+    CROSS JOIN params p
+    GROUP BY j.id
+),
+pay_apps AS (
+    SELECT pt.job_id, SUM(pt.work_this_period::NUMERIC) AS billed
+    FROM pay_app_monthly_totals pt
+    JOIN job_list j ON pt.job_id = j.id
+    CROSS JOIN params p
+    WHERE pt.pay_app_month < p.cutoff
+    GROUP BY pt.job_id
+)
+SELECT
+    j.job_number,
+    j.job_name,
+    COALESCE(j.contract_value + COALESCE(cot.amount, 0), s.scheduled_value, 0)::TEXT AS contract_value,
+    COALESCE(NULLIF(be.budget, 0), l.estimate, 0)::TEXT AS estimated_cost,
+    COALESCE(l.cost, 0)::TEXT AS cost_to_date,
+    COALESCE(l.billed, pa.billed, 0)::TEXT AS billed_to_date
+FROM job_list j
+LEFT JOIN scheduled s ON s.job_id = j.id
+LEFT JOIN change_order_totals cot ON cot.job_id = j.id
+LEFT JOIN bid_estimates be ON be.job_id = j.id
+LEFT JOIN ledger l ON l.job_id = j.id
+LEFT JOIN pay_apps pa ON pa.job_id = j.id
+ORDER BY j.job_number
+`
+
+type GetWIPScheduleRow struct {
+	JobNumber     string `json:"job_number"`
+	JobName       string `json:"job_name"`
+	ContractValue string `json:"contract_value"`
+	EstimatedCost string `json:"estimated_cost"`
+	CostToDate    string `json:"cost_to_date"`
+	BilledToDate  string `json:"billed_to_date"`
+}
+
+// Fetches work-in-progress inputs for every job active at the end of a month
+// Everything is as of then: contract value and scheduled value include change
+// orders approved by then, and estimated cost is the bid's direct cost (with
+// items from those change orders), falling back to ledger budget rows dated by
+// then. Billed to date is billings from the ledger, falling back to pay applications
+func (q *Queries) GetWIPSchedule(ctx context.Context, asOf time.Time) ([]GetWIPScheduleRow, error) {
+	rows, err := q.db.QueryContext(ctx, getWIPSchedule, asOf)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetWIPScheduleRow
+	for rows.Next() {
+		var i GetWIPScheduleRow
+		if err := rows.Scan(
+			&i.JobNumber,
+			&i.JobName,
+			&i.ContractValue,
+			&i.EstimatedCost,
+			&i.CostToDate,
+			&i.BilledToDate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const insertBaselineMonth = `-- name: InsertBaselineMonth :exec
+INSERT INTO pv_baseline_months (
+    baseline_id, month, planned_value
+) VALUES (
+    $1, $2, $3
+)
+`
+
+type InsertBaselineMonthParams struct {
+	BaselineID   uuid.UUID `json:"baseline_id"`
+	Month        time.Time `json:"month"`
+	PlannedValue string    `json:"planned_value"`
+}
+
+func (q *Queries) InsertBaselineMonth(ctx context.Context, arg InsertBaselineMonthParams) error {
+	_, err := q.db.ExecContext(ctx, insertBaselineMonth, arg.BaselineID, arg.Month, arg.PlannedValue)
+	return err
+}
+
+const insertBidItem = `-- name: InsertBidItem :exec
+INSERT INTO job_items (
+    id, job_id, parent_id, sort_order, item_number, description,
+    scheduled_value, job_cost_id, budget, qty, unit, unit_price,
+    cost_method, production_rate, production_units,
+    man_hours, production_hours, crew_days, plug,
+    labor, equip, misc, material, sub, trucking,
+    indirect, bond, overhead, profit
+) VALUES (
+    $1, $2, $3, $4, $5, $6,
+    $7, $8, $9, $10, $11, $12,
+    $13, $14, $15,
+    $16, $17, $18, $19,
+    $20, $21, $22, $23, $24, $25,
+    $26, $27, $28, $29
+)
+`
+
+type InsertBidItemParams struct {
+	ID              uuid.UUID      `json:"id"`
+	JobID           uuid.UUID      `json:"job_id"`
+	ParentID        uuid.NullUUID  `json:"parent_id"`
+	SortOrder       int32          `json:"sort_order"`
+	ItemNumber      string         `json:"item_number"`
+	Description     string         `json:"description"`
+	ScheduledValue  string         `json:"scheduled_value"`
+	JobCostID       sql.NullString `json:"job_cost_id"`
+	Budget          string         `json:"budget"`
+	Qty             string         `json:"qty"`
+	Unit            sql.NullString `json:"unit"`
+	UnitPrice       string         `json:"unit_price"`
+	CostMethod      sql.NullString `json:"cost_method"`
+	ProductionRate  sql.NullString `json:"production_rate"`
+	ProductionUnits sql.NullString `json:"production_units"`
+	ManHours        string         `json:"man_hours"`
+	ProductionHours string         `json:"production_hours"`
+	CrewDays        string         `json:"crew_days"`
+	Plug            string         `json:"plug"`
+	Labor           string         `json:"labor"`
+	Equip           string         `json:"equip"`
+	Misc            string         `json:"misc"`
+	Material        string         `json:"material"`
+	Sub             string         `json:"sub"`
+	Trucking        string         `json:"trucking"`
+	Indirect        string         `json:"indirect"`
+	Bond            string         `json:"bond"`
+	Overhead        string         `json:"overhead"`
+	Profit          string         `json:"profit"`
+}
+
+// Inserts a bid item with all cost columns (for batch import)
+func (q *Queries) InsertBidItem(ctx context.Context, arg InsertBidItemParams) error {
+	_, err := q.db.ExecContext(ctx, insertBidItem,
+		arg.ID,
+		arg.JobID,
+		arg.ParentID,
+		arg.SortOrder,
+		arg.ItemNumber,
+		arg.Description,
+		arg.ScheduledValue,
+		arg.JobCostID,
+		arg.Budget,
+		arg.Qty,
+		arg.Unit,
+		arg.UnitPrice,
+		arg.CostMethod,
+		arg.ProductionRate,
+		arg.ProductionUnits,
+		arg.ManHours,
+		arg.ProductionHours,
+		arg.CrewDays,
+		arg.Plug,
+		arg.Labor,
+		arg.Equip,
+		arg.Misc,
+		arg.Material,
+		arg.Sub,
+		arg.Trucking,
+		arg.Indirect,
+		arg.Bond,
+		arg.Overhead,
+		arg.Profit,
+	)
+	return err
+}
+
+const insertChangeOrderItem = `-- name: InsertChangeOrderItem :exec
+INSERT INTO change_order_items (
+    change_order_id, job_item_id, qty_change, new_unit_price, amount,
+    new_item_number, new_parent_id, new_description, new_unit, new_job_cost_id, new_budget
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+)
+`
+
+type InsertChangeOrderItemParams struct {
+	ChangeOrderID  uuid.UUID      `json:"change_order_id"`
+	JobItemID      uuid.NullUUID  `json:"job_item_id"`
+	QtyChange      string         `json:"qty_change"`
+	NewUnitPrice   sql.NullString `json:"new_unit_price"`
+	Amount         string         `json:"amount"`
+	NewItemNumber  sql.NullString `json:"new_item_number"`
+	NewParentID    uuid.NullUUID  `json:"new_parent_id"`
+	NewDescription sql.NullString `json:"new_description"`
+	NewUnit        sql.NullString `json:"new_unit"`
+	NewJobCostID   sql.NullString `json:"new_job_cost_id"`
+	NewBudget      sql.NullString `json:"new_budget"`
+}
+
+// Lines adding an item leave job_item_id null and carry the item's details
+func (q *Queries) InsertChangeOrderItem(ctx context.Context, arg InsertChangeOrderItemParams) error {
+	_, err := q.db.ExecContext(ctx, insertChangeOrderItem,
+		arg.ChangeOrderID,
+		arg.JobItemID,
+		arg.QtyChange,
+		arg.NewUnitPrice,
+		arg.Amount,
+		arg.NewItemNumber,
+		arg.NewParentID,
+		arg.NewDescription,
+		arg.NewUnit,
+		arg.NewJobCostID,
+		arg.NewBudget,
+	)
+	return err
+}
+
+const insertChangeOrderJobItem = `-- name: InsertChangeOrderJobItem :one
+INSERT INTO job_items (
+    job_id, parent_id, sort_order, item_number, description,
+    job_cost_id, budget, unit, unit_price, change_order_id
+) VALUES (
+    $1, $2,
+    (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM job_items WHERE job_id = $1),
+    $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING id
+`
+
+type InsertChangeOrderJobItemParams struct {
+	JobID         uuid.UUID      `json:"job_id"`
+	ParentID      uuid.NullUUID  `json:"parent_id"`
+	ItemNumber    string         `json:"item_number"`
+	Description   string         `json:"description"`
+	JobCostID     sql.NullString `json:"job_cost_id"`
+	Budget        string         `json:"budget"`
+	Unit          sql.NullString `json:"unit"`
+	UnitPrice     string         `json:"unit_price"`
+	ChangeOrderID uuid.NullUUID  `json:"change_order_id"`
+}
+
+// Adds a new job item for a change order. Quantity and scheduled value start at
+// zero and are brought in by the CO line item once the change order is approved.
+func (q *Queries) InsertChangeOrderJobItem(ctx context.Context, arg InsertChangeOrderJobItemParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, insertChangeOrderJobItem,
+		arg.JobID,
+		arg.ParentID,
+		arg.ItemNumber,
+		arg.Description,
+		arg.JobCostID,
+		arg.Budget,
+		arg.Unit,
+		arg.UnitPrice,
+		arg.ChangeOrderID,
+	)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const insertCostTypeMapping = `-- name: InsertCostTypeMapping :exec
+INSERT INTO cost_type_mappings (cat, cost_type) VALUES ($1, $2)
+`
+
+type InsertCostTypeMappingParams struct {
+	Cat      string `json:"cat"`
+	CostType string `json:"cost_type"`
+}
+
+func (q *Queries) InsertCostTypeMapping(ctx context.Context, arg InsertCostTypeMappingParams) error {
+	_, err := q.db.ExecContext(ctx, insertCostTypeMapping, arg.Cat, arg.CostType)
+	return err
+}
+
+const insertJobCostLedger = `-- name: InsertJobCostLedger :exec
+INSERT INTO job_cost_ledger (
+    id, job, phase, cat, transaction_type, transaction_date, amount, hours, units
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+ON CONFLICT (id) DO UPDATE SET
+    hours = COALESCE(job_cost_ledger.hours, EXCLUDED.hours),
+    units = COALESCE(job_cost_ledger.units, EXCLUDED.units)
+`
+
+type InsertJobCostLedgerParams struct {
+	ID              string         `json:"id"`
+	Job             string         `json:"job"`
+	Phase           sql.NullString `json:"phase"`
+	Cat             sql.NullString `json:"cat"`
+	TransactionType sql.NullString `json:"transaction_type"`
+	TransactionDate sql.NullTime   `json:"transaction_date"`
+	Amount          string         `json:"amount"`
+	Hours           sql.NullString `json:"hours"`
+	Units           sql.NullString `json:"units"`
+}
+
+// Inserts a job cost ledger entry. If the hash already exists, hours and units
+// the row doesn't have yet are filled in, so re-importing an export with those
+// columns backfills them; a row's hours and units are never overwritten
+func (q *Queries) InsertJobCostLedger(ctx context.Context, arg InsertJobCostLedgerParams) error {
+	_, err := q.db.ExecContext(ctx, insertJobCostLedger,
+		arg.ID,
+		arg.Job,
+		arg.Phase,
+		arg.Cat,
+		arg.TransactionType,
+		arg.TransactionDate,
+		arg.Amount,
+		arg.Hours,
+		arg.Units,
+	)
+	return err
+}
+
+const insertJobStatusChange = `-- name: InsertJobStatusChange :exec
+INSERT INTO job_status_changes (job_id, status)
+VALUES ($1, $2)
+`
+
+type InsertJobStatusChangeParams struct {
+	JobID  uuid.UUID `json:"job_id"`
+	Status string    `json:"status"`
+}
+
+func (q *Queries) InsertJobStatusChange(ctx context.Context, arg InsertJobStatusChangeParams) error {
+	_, err := q.db.ExecContext(ctx, insertJobStatusChange, arg.JobID, arg.Status)
+	return err
+}
+
+const insertPayAppEdit = `-- name: InsertPayAppEdit :exec
+INSERT INTO pay_app_edits (
+    job_id, job_item_id, pay_app_month, revision, field, old_value, new_value, edited_by, reason
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+`
+
+type InsertPayAppEditParams struct {
+	JobID       uuid.UUID      `json:"job_id"`
+	JobItemID   uuid.UUID      `json:"job_item_id"`
+	PayAppMonth time.Time      `json:"pay_app_month"`
+	Revision    int32          `json:"revision"`
+	Field       string         `json:"field"`
+	OldValue    string         `json:"old_value"`
+	NewValue    string         `json:"new_value"`
+	EditedBy    string         `json:"edited_by"`
+	Reason      sql.NullString `json:"reason"`
+}
+
+func (q *Queries) InsertPayAppEdit(ctx context.Context, arg InsertPayAppEditParams) error {
+	_, err := q.db.ExecContext(ctx, insertPayAppEdit,
+		arg.JobID,
+		arg.JobItemID,
+		arg.PayAppMonth,
+		arg.Revision,
+		arg.Field,
+		arg.OldValue,
+		arg.NewValue,
+		arg.EditedBy,
+		arg.Reason,
+	)
+	return err
+}
+
+const insertPayApplicationIfNotExists = `-- name: InsertPayApplicationIfNotExists :exec
+INSERT INTO pay_applications (
+    job_item_id, pay_app_month, qty, stored_materials
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (job_item_id, pay_app_month) DO NOTHING
+`
+
+type InsertPayApplicationIfNotExistsParams struct {
+	JobItemID       uuid.UUID `json:"job_item_id"`
+	PayAppMonth     time.Time `json:"pay_app_month"`
+	Qty             string    `json:"qty"`
+	StoredMaterials string    `json:"stored_materials"`
+}
+
+// Inserts pay application data only if no record exists for this item/month
+func (q *Queries) InsertPayApplicationIfNotExists(ctx context.Context, arg InsertPayApplicationIfNotExistsParams) error {
+	_, err := q.db.ExecContext(ctx, insertPayApplicationIfNotExists,
+		arg.JobItemID,
+		arg.PayAppMonth,
+		arg.Qty,
+		arg.StoredMaterials,
+	)
+	return err
+}
+
+const insertPeriodClose = `-- name: InsertPeriodClose :exec
+INSERT INTO period_closes (job_id, period_month, closed_by)
+VALUES ($1, $2, $3)
+`
+
+type InsertPeriodCloseParams struct {
+	JobID       uuid.UUID `json:"job_id"`
+	PeriodMonth time.Time `json:"period_month"`
+	ClosedBy    string    `json:"closed_by"`
+}
+
+func (q *Queries) InsertPeriodClose(ctx context.Context, arg InsertPeriodCloseParams) error {
+	_, err := q.db.ExecContext(ctx, insertPeriodClose, arg.JobID, arg.PeriodMonth, arg.ClosedBy)
+	return err
+}
+
+const insertPeriodCloseEvent = `-- name: InsertPeriodCloseEvent :exec
+INSERT INTO period_close_events (job_id, period_month, action, actor, reason)
+VALUES ($1, $2, $3, $4, $5)
+`
+
+type InsertPeriodCloseEventParams struct {
+	JobID       uuid.UUID      `json:"job_id"`
+	PeriodMonth time.Time      `json:"period_month"`
+	Action      string         `json:"action"`
+	Actor       string         `json:"actor"`
+	Reason      sql.NullString `json:"reason"`
+}
+
+func (q *Queries) InsertPeriodCloseEvent(ctx context.Context, arg InsertPeriodCloseEventParams) error {
+	_, err := q.db.ExecContext(ctx, insertPeriodCloseEvent,
+		arg.JobID,
+		arg.PeriodMonth,
+		arg.Action,
+		arg.Actor,
+		arg.Reason,
+	)
+	return err
+}
+
+const insertRetainageRelease = `-- name: InsertRetainageRelease :one
+INSERT INTO retainage_releases (
+    job_id, release_month, amount, note
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING id, job_id, release_month, amount, note, created_at
+`
+
+type InsertRetainageReleaseParams struct {
+	JobID        uuid.UUID      `json:"job_id"`
+	ReleaseMonth time.Time      `json:"release_month"`
+	Amount       string         `json:"amount"`
+	Note         sql.NullString `json:"note"`
+}
+
+func (q *Queries) InsertRetainageRelease(ctx context.Context, arg InsertRetainageReleaseParams) (RetainageRelease, error) {
+	row := q.db.QueryRowContext(ctx, insertRetainageRelease,
+		arg.JobID,
+		arg.ReleaseMonth,
+		arg.Amount,
+		arg.Note,
+	)
+	var i RetainageRelease
+	err := row.Scan(
+		&i.ID,
+		&i.JobID,
+		&i.ReleaseMonth,
+		&i.Amount,
+		&i.Note,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const insertRetainageTier = `-- name: InsertRetainageTier :exec
+INSERT INTO retainage_tiers (
+    job_id, threshold_percent, work_rate, materials_rate
+) VALUES (
+    $1, $2, $3, $4
+)
+`
+
+type InsertRetainageTierParams struct {
+	JobID            uuid.UUID `json:"job_id"`
+	ThresholdPercent string    `json:"threshold_percent"`
+	WorkRate         string    `json:"work_rate"`
+	MaterialsRate    string    `json:"materials_rate"`
+}
+
+func (q *Queries) InsertRetainageTier(ctx context.Context, arg InsertRetainageTierParams) error {
+	_, err := q.db.ExecContext(ctx, insertRetainageTier,
+		arg.JobID,
+		arg.ThresholdPercent,
+		arg.WorkRate,
+		arg.MaterialsRate,
+	)
+	return err
+}
+
+const listUsers = `-- name: ListUsers :many
+SELECT id, username, password_hash, role, disabled, created_at, updated_at
+FROM users
+ORDER BY username
+`
+
+func (q *Queries) ListUsers(ctx context.Context) ([]User, error) {
+	rows, err := q.db.QueryContext(ctx, listUsers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []User
+	for rows.Next() {
+		var i User
+		if err := rows.Scan(
+			&i.ID,
+			&i.Username,
+			&i.PasswordHash,
+			&i.Role,
+			&i.Disabled,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockJob = `-- name: LockJob :exec
+SELECT id FROM jobs WHERE id = $1 FOR UPDATE
+`
+
+// Locks a job's row until the transaction ends, so a period close waits for
+// pay application writes already under way and they wait for it
+func (q *Queries) LockJob(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, lockJob, id)
+	return err
+}
+
+const searchJobCostLedger = `-- name: SearchJobCostLedger :many
+WITH matches AS (
+    SELECT
+        id, job, phase, cat, transaction_type, transaction_date, amount, created_at, hours, units,
+        CASE $1::TEXT
+            WHEN 'transaction_date' THEN COALESCE(transaction_date::TEXT, '')
+            WHEN 'phase' THEN COALESCE(phase, '')
+            WHEN 'cat' THEN COALESCE(cat, '')
+            WHEN 'transaction_type' THEN COALESCE(transaction_type, '')
+            ELSE ''
+        END AS sort_text,
+        CASE $1::TEXT
+            WHEN 'amount' THEN amount
+            WHEN 'hours' THEN COALESCE(hours, 0)
+            WHEN 'units' THEN COALESCE(units, 0)
+            ELSE 0
+        END AS sort_number,
+        COALESCE(transaction_date::TEXT, '') AS date_text
+    FROM job_cost_ledger
+    WHERE job = $2
+      AND ($3::TEXT IS NULL OR phase = $3)
+      AND ($4::TEXT IS NULL OR cat = $4)
+      AND ($5::TEXT IS NULL OR transaction_type = $5)
+      AND ($6::TEXT IS NULL OR transaction_type IN (
+          SELECT transaction_type FROM transaction_type_classes WHERE class = $6
+      ))
+      AND ($7::DATE IS NULL OR transaction_date >= $7)
+      AND ($8::DATE IS NULL OR transaction_date <= $8)
+      AND ($9::NUMERIC IS NULL OR amount >= $9)
+      AND ($10::NUMERIC IS NULL OR amount <= $10)
+)
+SELECT
+    id, job, phase, cat, transaction_type, transaction_date, amount, created_at, hours, units,
+    sort_text::TEXT AS sort_text,
+    sort_number::TEXT AS sort_number,
+    date_text::TEXT AS date_text
+FROM matches
+WHERE $11::TEXT IS NULL
+   OR (NOT $12::BOOLEAN AND (sort_text, sort_number, date_text, id)
+       > ($13::TEXT, $14::NUMERIC, $15::TEXT, $11::TEXT))
+   OR ($12::BOOLEAN AND (sort_text, sort_number, date_text, id)
+       < ($13::TEXT, $14::NUMERIC, $15::TEXT, $11::TEXT))
+ORDER BY
+    CASE WHEN NOT $12::BOOLEAN THEN sort_text END,
+    CASE WHEN NOT $12::BOOLEAN THEN sort_number END,
+    CASE WHEN NOT $12::BOOLEAN THEN date_text END,
+    CASE WHEN NOT $12::BOOLEAN THEN id END,
+    sort_text DESC,
+    sort_number DESC,
+    date_text DESC,
+    id DESC
+LIMIT $16::INT
+`
+
+type SearchJobCostLedgerParams struct {
+	SortBy          string         `json:"sort_by"`
+	Job             string         `json:"job"`
+	Phase           sql.NullString `json:"phase"`
+	Cat             sql.NullString `json:"cat"`
+	TransactionType sql.NullString `json:"transaction_type"`
+	Class           sql.NullString `json:"class"`
+	FromDate        sql.NullTime   `json:"from_date"`
+	ToDate          sql.NullTime   `json:"to_date"`
+	MinAmount       sql.NullString `json:"min_amount"`
+	MaxAmount       sql.NullString `json:"max_amount"`
+	AfterID         sql.NullString `json:"after_id"`
+	Descending      bool           `json:"descending"`
+	AfterText       sql.NullString `json:"after_text"`
+	AfterNumber     sql.NullString `json:"after_number"`
+	AfterDate       sql.NullString `json:"after_date"`
+	RowLimit        int32          `json:"row_limit"`
+}
+
+type SearchJobCostLedgerRow struct {
+	ID              string         `json:"id"`
+	Job             string         `json:"job"`
+	Phase           sql.NullString `json:"phase"`
+	Cat             sql.NullString `json:"cat"`
+	TransactionType sql.NullString `json:"transaction_type"`
+	TransactionDate sql.NullTime   `json:"transaction_date"`
+	Amount          string         `json:"amount"`
+	CreatedAt       sql.NullTime   `json:"created_at"`
+	Hours           sql.NullString `json:"hours"`
+	Units           sql.NullString `json:"units"`
+	SortText        string         `json:"sort_text"`
+	SortNumber      string         `json:"sort_number"`
+	DateText        string         `json:"date_text"`
+}
+
+// Fetches one page of a job's ledger entries matching the optional filters.
+// phase, cat and transaction_type match exactly and class matches the
+// transaction type's class; dates and amounts are inclusive
+// Entries are ordered by sort_by (transaction_date, phase, cat,
+// transaction_type, amount, hours or units), then date, then id, all reversed
+// when descending. Missing values sort as ” or 0. A page starts after the
+// after_* position, taken from the sort_text, sort_number, date_text and id of
+// the previous page's last row; row_limit caps the rows returned
+func (q *Queries) SearchJobCostLedger(ctx context.Context, arg SearchJobCostLedgerParams) ([]SearchJobCostLedgerRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchJobCostLedger,
+		arg.SortBy,
+		arg.Job,
+		arg.Phase,
+		arg.Cat,
+		arg.TransactionType,
+		arg.Class,
+		arg.FromDate,
+		arg.ToDate,
+		arg.MinAmount,
+		arg.MaxAmount,
+		arg.AfterID,
+		arg.Descending,
+		arg.AfterText,
+		arg.AfterNumber,
+		arg.AfterDate,
+		arg.RowLimit,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []PayApplicationCumulative
+	var items []SearchJobCostLedgerRow
 	for rows.Next() {
-		var i PayApplicationCumulative
+		var i SearchJobCostLedgerRow
 		if err := rows.Scan(
-			&i.JobItemID,
-			&i.PayAppMonth,
-			&i.JobID,
-			&i.ParentID,
-			&i.ThisMonthQty,
-			&i.StoredMaterials,
-			&i.TotalQty,
-			&i.UnitPrice,
-			&i.Budget,
-			&i.CumulativeQty,
-			&i.PreviousCumulativeQty,
-			&i.RemainingQty,
-			&i.PercentComplete,
-			&i.ThisMonthAmount,
-			&i.CumulativeAmount,
-			&i.PreviousCumulativeAmount,
+			&i.ID,
+			&i.Job,
+			&i.Phase,
+			&i.Cat,
+			&i.TransactionType,
+			&i.TransactionDate,
+			&i.Amount,
+			&i.CreatedAt,
+			&i.Hours,
+			&i.Units,
+			&i.SortText,
+			&i.SortNumber,
+			&i.DateText,
 		); err != nil {
 			return nil, err
 		}
@@ -839,28 +4463,89 @@ func (q *Queries) GetPayAppCumulative(ctx context.Context, arg GetPayAppCumulati
 	return items, nil
 }
 
-const getPayAppMonthsForJob = `-- name: GetPayAppMonthsForJob :many
-SELECT DISTINCT pa.pay_app_month
-FROM pay_applications pa
-JOIN job_items ji ON pa.job_item_id = ji.id
-WHERE ji.job_id = $1
-ORDER BY pa.pay_app_month
+const searchJobCostLedgerTotals = `-- name: SearchJobCostLedgerTotals :many
+SELECT
+    (CASE $1::TEXT
+        WHEN 'phase' THEN COALESCE(phase, '')
+        WHEN 'cat' THEN COALESCE(cat, '')
+        WHEN 'transaction_type' THEN COALESCE(transaction_type, '')
+        WHEN 'month' THEN COALESCE(TO_CHAR(transaction_date, 'YYYY-MM'), '')
+        ELSE ''
+    END)::TEXT AS group_key,
+    COUNT(*)::INT AS entries,
+    SUM(amount)::TEXT AS amount,
+    COALESCE(SUM(hours), 0)::TEXT AS hours,
+    COALESCE(SUM(units), 0)::TEXT AS units
+FROM job_cost_ledger
+WHERE job = $2
+  AND ($3::TEXT IS NULL OR phase = $3)
+  AND ($4::TEXT IS NULL OR cat = $4)
+  AND ($5::TEXT IS NULL OR transaction_type = $5)
+  AND ($6::TEXT IS NULL OR transaction_type IN (
+      SELECT transaction_type FROM transaction_type_classes WHERE class = $6
+  ))
+  AND ($7::DATE IS NULL OR transaction_date >= $7)
+  AND ($8::DATE IS NULL OR transaction_date <= $8)
+  AND ($9::NUMERIC IS NULL OR amount >= $9)
+  AND ($10::NUMERIC IS NULL OR amount <= $10)
+GROUP BY 1
+ORDER BY 1
 `
 
-// Gets all distinct months with pay applications for a job
-func (q *Queries) GetPayAppMonthsForJob(ctx context.Context, jobID uuid.UUID) ([]time.Time, error) {
-	rows, err := q.db.QueryContext(ctx, getPayAppMonthsForJob, jobID)
+type SearchJobCostLedgerTotalsParams struct {
+	GroupBy         string         `json:"group_by"`
+	Job             string         `json:"job"`
+	Phase           sql.NullString `json:"phase"`
+	Cat             sql.NullString `json:"cat"`
+	TransactionType sql.NullString `json:"transaction_type"`
+	Class           sql.NullString `json:"class"`
+	FromDate        sql.NullTime   `json:"from_date"`
+	ToDate          sql.NullTime   `json:"to_date"`
+	MinAmount       sql.NullString `json:"min_amount"`
+	MaxAmount       sql.NullString `json:"max_amount"`
+}
+
+type SearchJobCostLedgerTotalsRow struct {
+	GroupKey string `json:"group_key"`
+	Entries  int32  `json:"entries"`
+	Amount   string `json:"amount"`
+	Hours    string `json:"hours"`
+	Units    string `json:"units"`
+}
+
+// Totals a job's ledger entries matching the same filters as
+// SearchJobCostLedger, one row per group_by value (phase, cat,
+// transaction_type or month; ” puts every entry in one group)
+func (q *Queries) SearchJobCostLedgerTotals(ctx context.Context, arg SearchJobCostLedgerTotalsParams) ([]SearchJobCostLedgerTotalsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchJobCostLedgerTotals,
+		arg.GroupBy,
+		arg.Job,
+		arg.Phase,
+		arg.Cat,
+		arg.TransactionType,
+		arg.Class,
+		arg.FromDate,
+		arg.ToDate,
+		arg.MinAmount,
+		arg.MaxAmount,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []time.Time
+	var items []SearchJobCostLedgerTotalsRow
 	for rows.Next() {
-		var pay_app_month time.Time
-		if err := rows.Scan(&pay_app_month); err != nil {
+		var i SearchJobCostLedgerTotalsRow
+		if err := rows.Scan(
+			&i.GroupKey,
+			&i.Entries,
+			&i.Amount,
+			&i.Hours,
+			&i.Units,
+		); err != nil {
 			return nil, err
 		}
-		items = append(items, pay_app_month)
+		items = append(items, i)
 	}
 	if err := rows.Close(); err != nil {
 		return nil, err
@@ -871,152 +4556,136 @@ func (q *Queries) GetPayAppMonthsForJob(ctx context.Context, jobID uuid.UUID) ([
 	return items, nil
 }
 
-const insertBidItem = `-- name: InsertBidItem :exec
-INSERT INTO job_items (
-    id, job_id, parent_id, sort_order, item_number, description,
-    scheduled_value, job_cost_id, budget, qty, unit, unit_price,
-    cost_method, production_rate, production_units,
-    man_hours, production_hours, crew_days, plug,
-    labor, equip, misc, material, sub, trucking,
-    indirect, bond, overhead, profit
-) VALUES (
-    $1, $2, $3, $4, $5, $6,
-    $7, $8, $9, $10, $11, $12,
-    $13, $14, $15,
-    $16, $17, $18, $19,
-    $20, $21, $22, $23, $24, $25,
-    $26, $27, $28, $29
-)
+const setChangeOrderItemJobItem = `-- name: SetChangeOrderItemJobItem :exec
+UPDATE change_order_items
+SET job_item_id = $2
+WHERE id = $1
 `
 
-type InsertBidItemParams struct {
-	ID              uuid.UUID      `json:"id"`
-	JobID           uuid.UUID      `json:"job_id"`
-	ParentID        uuid.NullUUID  `json:"parent_id"`
-	SortOrder       int32          `json:"sort_order"`
-	ItemNumber      string         `json:"item_number"`
-	Description     string         `json:"description"`
-	ScheduledValue  string         `json:"scheduled_value"`
-	JobCostID       sql.NullString `json:"job_cost_id"`
-	Budget          string         `json:"budget"`
-	Qty             string         `json:"qty"`
-	Unit            sql.NullString `json:"unit"`
-	UnitPrice       string         `json:"unit_price"`
-	CostMethod      sql.NullString `json:"cost_method"`
-	ProductionRate  sql.NullString `json:"production_rate"`
-	ProductionUnits sql.NullString `json:"production_units"`
-	ManHours        string         `json:"man_hours"`
-	ProductionHours string         `json:"production_hours"`
-	CrewDays        string         `json:"crew_days"`
-	Plug            string         `json:"plug"`
-	Labor           string         `json:"labor"`
-	Equip           string         `json:"equip"`
-	Misc            string         `json:"misc"`
-	Material        string         `json:"material"`
-	Sub             string         `json:"sub"`
-	Trucking        string         `json:"trucking"`
-	Indirect        string         `json:"indirect"`
-	Bond            string         `json:"bond"`
-	Overhead        string         `json:"overhead"`
-	Profit          string         `json:"profit"`
+type SetChangeOrderItemJobItemParams struct {
+	ID        uuid.UUID     `json:"id"`
+	JobItemID uuid.NullUUID `json:"job_item_id"`
 }
 
-// Inserts a bid item with all cost columns (for batch import)
-func (q *Queries) InsertBidItem(ctx context.Context, arg InsertBidItemParams) error {
-	_, err := q.db.ExecContext(ctx, insertBidItem,
-		arg.ID,
-		arg.JobID,
-		arg.ParentID,
-		arg.SortOrder,
-		arg.ItemNumber,
-		arg.Description,
-		arg.ScheduledValue,
-		arg.JobCostID,
-		arg.Budget,
-		arg.Qty,
-		arg.Unit,
-		arg.UnitPrice,
-		arg.CostMethod,
-		arg.ProductionRate,
-		arg.ProductionUnits,
-		arg.ManHours,
-		arg.ProductionHours,
-		arg.CrewDays,
-		arg.Plug,
-		arg.Labor,
-		arg.Equip,
-		arg.Misc,
-		arg.Material,
-		arg.Sub,
-		arg.Trucking,
-		arg.Indirect,
-		arg.Bond,
-		arg.Overhead,
-		arg.Profit,
-	)
+func (q *Queries) SetChangeOrderItemJobItem(ctx context.Context, arg SetChangeOrderItemJobItemParams) error {
+	_, err := q.db.ExecContext(ctx, setChangeOrderItemJobItem, arg.ID, arg.JobItemID)
 	return err
 }
 
-const insertJobCostLedger = `-- name: InsertJobCostLedger :exec
-INSERT INTO job_cost_ledger (
-    id, job, phase, cat, transaction_type, transaction_date, amount
-) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
-)
-ON CONFLICT (id) DO NOTHING
+const setMaterialsInstalled = `-- name: SetMaterialsInstalled :exec
+UPDATE pay_applications
+SET materials_installed = $3, updated_at = NOW()
+WHERE job_item_id = $1 AND pay_app_month = $2
 `
 
-type InsertJobCostLedgerParams struct {
-	ID              string         `json:"id"`
-	Job             string         `json:"job"`
-	Phase           sql.NullString `json:"phase"`
-	Cat             sql.NullString `json:"cat"`
-	TransactionType sql.NullString `json:"transaction_type"`
-	TransactionDate sql.NullTime   `json:"transaction_date"`
-	Amount          string         `json:"amount"`
+type SetMaterialsInstalledParams struct {
+	JobItemID          uuid.UUID      `json:"job_item_id"`
+	PayAppMonth        time.Time      `json:"pay_app_month"`
+	MaterialsInstalled sql.NullString `json:"materials_installed"`
 }
 
-// Inserts a job cost ledger entry, skips if hash already exists
-func (q *Queries) InsertJobCostLedger(ctx context.Context, arg InsertJobCostLedgerParams) error {
-	_, err := q.db.ExecContext(ctx, insertJobCostLedger,
-		arg.ID,
-		arg.Job,
-		arg.Phase,
-		arg.Cat,
-		arg.TransactionType,
-		arg.TransactionDate,
-		arg.Amount,
-	)
+// Records materials installed out of storage for an existing pay application;
+// null leaves stored_materials_ledger to derive it from the balance
+func (q *Queries) SetMaterialsInstalled(ctx context.Context, arg SetMaterialsInstalledParams) error {
+	_, err := q.db.ExecContext(ctx, setMaterialsInstalled, arg.JobItemID, arg.PayAppMonth, arg.MaterialsInstalled)
 	return err
 }
 
-const insertPayApplicationIfNotExists = `-- name: InsertPayApplicationIfNotExists :exec
-INSERT INTO pay_applications (
-    job_item_id, pay_app_month, qty, stored_materials
-) VALUES (
-    $1, $2, $3, $4
-)
-ON CONFLICT (job_item_id, pay_app_month) DO NOTHING
+const snapshotPayAppRevisionItems = `-- name: SnapshotPayAppRevisionItems :exec
+INSERT INTO pay_app_revision_items (revision_id, job_item_id, qty, stored_materials)
+SELECT $1, pa.job_item_id, pa.qty, pa.stored_materials
+FROM pay_applications pa
+JOIN job_items ji ON pa.job_item_id = ji.id
+WHERE ji.job_id = $2 AND pa.pay_app_month = $3
 `
 
-type InsertPayApplicationIfNotExistsParams struct {
-	JobItemID       uuid.UUID `json:"job_item_id"`
-	PayAppMonth     time.Time `json:"pay_app_month"`
-	Qty             string    `json:"qty"`
-	StoredMaterials string    `json:"stored_materials"`
+type SnapshotPayAppRevisionItemsParams struct {
+	RevisionID  uuid.UUID `json:"revision_id"`
+	JobID       uuid.UUID `json:"job_id"`
+	PayAppMonth time.Time `json:"pay_app_month"`
 }
 
-// Inserts pay application data only if no record exists for this item/month
-func (q *Queries) InsertPayApplicationIfNotExists(ctx context.Context, arg InsertPayApplicationIfNotExistsParams) error {
-	_, err := q.db.ExecContext(ctx, insertPayApplicationIfNotExists,
-		arg.JobItemID,
-		arg.PayAppMonth,
-		arg.Qty,
-		arg.StoredMaterials,
-	)
+// Copies a job's effective pay application rows for the month into a revision
+func (q *Queries) SnapshotPayAppRevisionItems(ctx context.Context, arg SnapshotPayAppRevisionItemsParams) error {
+	_, err := q.db.ExecContext(ctx, snapshotPayAppRevisionItems, arg.RevisionID, arg.JobID, arg.PayAppMonth)
+	return err
+}
+
+const updateChangeOrderStatus = `-- name: UpdateChangeOrderStatus :exec
+UPDATE change_orders
+SET status = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateChangeOrderStatusParams struct {
+	ID     uuid.UUID `json:"id"`
+	Status string    `json:"status"`
+}
+
+func (q *Queries) UpdateChangeOrderStatus(ctx context.Context, arg UpdateChangeOrderStatusParams) error {
+	_, err := q.db.ExecContext(ctx, updateChangeOrderStatus, arg.ID, arg.Status)
 	return err
 }
 
+const updateJob = `-- name: UpdateJob :one
+UPDATE jobs
+SET job_name = $2,
+    contract_value = $3,
+    address = $4,
+    scr_number = $5,
+    contract_complete_date = $6,
+    start_date = $7,
+    end_date = $8,
+    status = $9,
+    updated_at = NOW()
+WHERE job_number = $1
+RETURNING id, job_number, job_name, contract_value, address, scr_number,
+          contract_complete_date, start_date, end_date, created_at, updated_at, status
+`
+
+type UpdateJobParams struct {
+	JobNumber            string         `json:"job_number"`
+	JobName              string         `json:"job_name"`
+	ContractValue        sql.NullString `json:"contract_value"`
+	Address              sql.NullString `json:"address"`
+	ScrNumber            sql.NullString `json:"scr_number"`
+	ContractCompleteDate sql.NullTime   `json:"contract_complete_date"`
+	StartDate            sql.NullTime   `json:"start_date"`
+	EndDate              sql.NullTime   `json:"end_date"`
+	Status               string         `json:"status"`
+}
+
+// Replaces a job's editable contract metadata
+func (q *Queries) UpdateJob(ctx context.Context, arg UpdateJobParams) (Job, error) {
+	row := q.db.QueryRowContext(ctx, updateJob,
+		arg.JobNumber,
+		arg.JobName,
+		arg.ContractValue,
+		arg.Address,
+		arg.ScrNumber,
+		arg.ContractCompleteDate,
+		arg.StartDate,
+		arg.EndDate,
+		arg.Status,
+	)
+	var i Job
+	err := row.Scan(
+		&i.ID,
+		&i.JobNumber,
+		&i.JobName,
+		&i.ContractValue,
+		&i.Address,
+		&i.ScrNumber,
+		&i.ContractCompleteDate,
+		&i.StartDate,
+		&i.EndDate,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Status,
+	)
+	return i, err
+}
+
 const updateStoredMaterials = `-- name: UpdateStoredMaterials :exec
 UPDATE pay_applications
 SET stored_materials = $3, updated_at = NOW()
@@ -1035,6 +4704,51 @@ func (q *Queries) UpdateStoredMaterials(ctx context.Context, arg UpdateStoredMat
 	return err
 }
 
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET role = $2, disabled = $3, updated_at = NOW()
+WHERE username = $1
+RETURNING id, username, password_hash, role, disabled, created_at, updated_at
+`
+
+type UpdateUserParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Disabled bool   `json:"disabled"`
+}
+
+// Sets a user's role and disabled flag
+func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUser, arg.Username, arg.Role, arg.Disabled)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.PasswordHash,
+		&i.Role,
+		&i.Disabled,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID           uuid.UUID `json:"id"`
+	PasswordHash string    `json:"password_hash"`
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.PasswordHash)
+	return err
+}
+
 const upsertJob = `-- name: UpsertJob :one
 INSERT INTO jobs (
     job_number, job_name, contract_value, address, 
@@ -1166,3 +4880,20 @@ func (q *Queries) UpsertPayApplication(ctx context.Context, arg UpsertPayApplica
 	)
 	return err
 }
+
+const upsertTransactionTypeClass = `-- name: UpsertTransactionTypeClass :exec
+INSERT INTO transaction_type_classes (transaction_type, class)
+VALUES ($1, $2)
+ON CONFLICT (transaction_type)
+DO UPDATE SET class = EXCLUDED.class, updated_at = NOW()
+`
+
+type UpsertTransactionTypeClassParams struct {
+	TransactionType string `json:"transaction_type"`
+	Class           string `json:"class"`
+}
+
+func (q *Queries) UpsertTransactionTypeClass(ctx context.Context, arg UpsertTransactionTypeClassParams) error {
+	_, err := q.db.ExecContext(ctx, upsertTransactionTypeClass, arg.TransactionType, arg.Class)
+	return err
+}
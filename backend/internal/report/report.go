@@ -0,0 +1,367 @@
+// Package report encodes tabular API responses as JSON, CSV or Excel.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	decimalType = reflect.TypeOf(decimal.Decimal{})
+	timeType    = reflect.TypeOf(time.Time{})
+)
+
+// Format identifies a response encoding.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeCSV  = "text/csv"
+	ContentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// Column describes one column of a Table.
+type Column struct {
+	Header  string
+	Numeric bool // Written as a number cell in Excel
+}
+
+// Table is a header row plus data rows, ready to encode as CSV or Excel.
+// Row values are strings, integers, floats, decimals, times or nil.
+type Table struct {
+	Sheet   string
+	Columns []Column
+	Rows    [][]interface{}
+}
+
+// NegotiateFormat picks the response format for a request. An explicit
+// "format" query parameter wins over the Accept header; JSON is the default.
+func NegotiateFormat(r *http.Request) (Format, error) {
+	if f := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format"))); f != "" {
+		switch Format(f) {
+		case FormatJSON, FormatCSV, FormatXLSX:
+			return Format(f), nil
+		}
+		return "", fmt.Errorf("unsupported format %q - use json, csv or xlsx", f)
+	}
+
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case ContentTypeJSON:
+			return FormatJSON, nil
+		case ContentTypeCSV:
+			return FormatCSV, nil
+		case ContentTypeXLSX:
+			return FormatXLSX, nil
+		}
+	}
+
+	return FormatJSON, nil
+}
+
+// Respond writes v in the negotiated format. JSON encodes v as-is; CSV and
+// Excel require v to be a slice of structs (see FromStructs). filename is used
+// without extension for the download name.
+func Respond(w http.ResponseWriter, r *http.Request, filename string, v interface{}) {
+	format, err := NegotiateFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if format == FormatJSON {
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+
+	table, err := FromStructs(v)
+	if err != nil {
+		http.Error(w, "Failed to build table: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	WriteTable(w, format, filename, table)
+}
+
+// WriteTable writes a table as a CSV or Excel download. JSON callers encode
+// their own response types instead.
+func WriteTable(w http.ResponseWriter, format Format, filename string, t *Table) {
+	switch format {
+	case FormatCSV:
+		w.Header().Set("Content-Type", ContentTypeCSV)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+		WriteCSV(w, t)
+	case FormatXLSX:
+		f, err := NewWorkbook(t)
+		if err != nil {
+			http.Error(w, "Failed to build workbook: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", ContentTypeXLSX)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".xlsx"))
+		f.Write(w)
+	default:
+		http.Error(w, fmt.Sprintf("format %q is not tabular", format), http.StatusNotAcceptable)
+	}
+}
+
+// WriteCSV writes the header row followed by every data row.
+func WriteCSV(w io.Writer, t *Table) error {
+	cw := csv.NewWriter(w)
+
+	header := make([]string, len(t.Columns))
+	for i, col := range t.Columns {
+		header[i] = col.Header
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Columns))
+	for _, row := range t.Rows {
+		for i := range record {
+			record[i] = ""
+			if i < len(row) {
+				record[i] = formatValue(row[i])
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// NewWorkbook builds a single-sheet workbook with a bold, frozen header row.
+// Values in numeric columns are written as number cells.
+func NewWorkbook(t *Table) (*excelize.File, error) {
+	f := excelize.NewFile()
+
+	sheet := t.Sheet
+	if sheet == "" {
+		sheet = "Report"
+	}
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if err := AddSheet(f, sheet, t); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// AddSheet writes a table to an existing sheet of a workbook, starting at A1.
+func AddSheet(f *excelize.File, sheet string, t *Table) error {
+	headerStyle, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+
+	for i, col := range t.Columns {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		if err := f.SetCellValue(sheet, cell, col.Header); err != nil {
+			return err
+		}
+	}
+	if len(t.Columns) > 0 {
+		last, _ := excelize.CoordinatesToCellName(len(t.Columns), 1)
+		if err := f.SetCellStyle(sheet, "A1", last, headerStyle); err != nil {
+			return err
+		}
+	}
+
+	for r, row := range t.Rows {
+		for i, value := range row {
+			if i >= len(t.Columns) || value == nil {
+				continue
+			}
+			if at, ok := value.(time.Time); ok && at.IsZero() {
+				continue
+			}
+			if t.Columns[i].Numeric {
+				value = numericValue(value)
+			}
+			cell, _ := excelize.CoordinatesToCellName(i+1, r+2)
+			if err := f.SetCellValue(sheet, cell, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return f.SetPanes(sheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	})
+}
+
+// FromStructs builds a table from a slice of structs. Each exported field
+// becomes a column headed by its `report` tag, falling back to the json name.
+// Integer, float and decimal fields are numeric; string fields holding numbers are
+// marked with a ",numeric" tag option. Times are written as RFC 3339 in CSV
+// and as dates in Excel. A tag of "-" skips the field.
+//
+//	type Row struct {
+//		Month string `json:"month" report:"Month"`
+//		CPI   string `json:"cpi" report:"CPI,numeric"`
+//	}
+func FromStructs(v interface{}) (*Table, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("expected a slice, got %s", rv.Kind())
+	}
+
+	elemType := rv.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("expected a slice of structs, got slice of %s", elemType.Kind())
+	}
+
+	table := &Table{}
+	var fields []int
+	for i := 0; i < elemType.NumField(); i++ {
+		field := elemType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		header, numeric, ok := columnFromField(field)
+		if !ok {
+			continue
+		}
+		table.Columns = append(table.Columns, Column{Header: header, Numeric: numeric})
+		fields = append(fields, i)
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		elem := rv.Index(i)
+		if elem.Kind() == reflect.Ptr {
+			if elem.IsNil() {
+				continue
+			}
+			elem = elem.Elem()
+		}
+		row := make([]interface{}, len(fields))
+		for j, idx := range fields {
+			row[j] = elem.Field(idx).Interface()
+		}
+		table.Rows = append(table.Rows, row)
+	}
+
+	return table, nil
+}
+
+// columnFromField reads the header and numeric flag for a struct field.
+func columnFromField(field reflect.StructField) (string, bool, bool) {
+	numeric := false
+	switch field.Type {
+	case decimalType:
+		numeric = true
+	case timeType:
+		// A time is one cell, a date in Excel
+	default:
+		switch field.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			numeric = true
+		case reflect.Slice, reflect.Map, reflect.Struct:
+			// Nested values don't flatten into a single cell
+			return "", false, false
+		}
+	}
+
+	tag := field.Tag.Get("report")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	header := ""
+	if tag != "" {
+		parts := strings.Split(tag, ",")
+		header = parts[0]
+		for _, opt := range parts[1:] {
+			if opt == "numeric" {
+				numeric = true
+			}
+		}
+	}
+	if header == "" {
+		header = strings.Split(field.Tag.Get("json"), ",")[0]
+	}
+	if header == "" || header == "-" {
+		header = field.Name
+	}
+
+	return header, numeric, true
+}
+
+// numericValue converts numeric strings and decimals to float64 so Excel
+// stores a number.
+func numericValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case decimal.Decimal:
+		f, _ := val.Float64()
+		return f
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return val
+		}
+		return f
+	}
+	return v
+}
+
+// formatValue renders a cell value for CSV output.
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case time.Time:
+		if val.IsZero() {
+			return ""
+		}
+		return val.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,75 @@
+package report
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+type auditRow struct {
+	Action string          `json:"action" report:"Action"`
+	Amount decimal.Decimal `json:"amount" report:"Amount"`
+	At     time.Time       `json:"at" report:"At"`
+	Nested struct{ A int } `json:"nested"`
+	Hidden string          `json:"hidden" report:"-"`
+}
+
+func TestFromStructsKeepsTimes(t *testing.T) {
+	at := time.Date(2026, 3, 31, 17, 45, 0, 0, time.UTC)
+	table, err := FromStructs([]auditRow{
+		{Action: "close", Amount: decimal.RequireFromString("12.5"), At: at},
+		{Action: "reopen"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Column{{Header: "Action"}, {Header: "Amount", Numeric: true}, {Header: "At"}}
+	if len(table.Columns) != len(want) {
+		t.Fatalf("columns = %+v, want %+v", table.Columns, want)
+	}
+	for i := range want {
+		if table.Columns[i] != want[i] {
+			t.Errorf("column %d = %+v, want %+v", i, table.Columns[i], want[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, table); err != nil {
+		t.Fatal(err)
+	}
+	wantCSV := "Action,Amount,At\nclose,12.5,2026-03-31T17:45:00Z\nreopen,0,\n"
+	if buf.String() != wantCSV {
+		t.Errorf("CSV = %q, want %q", buf.String(), wantCSV)
+	}
+
+	f, err := NewWorkbook(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	raw, err := f.GetCellValue("Report", "C2", excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		t.Fatalf("C2 = %q, want an Excel date serial", raw)
+	}
+	got, err := excelize.ExcelDateToTime(serial, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(at) {
+		t.Errorf("C2 = %s, want %s", got, at)
+	}
+
+	if blank, _ := f.GetCellValue("Report", "C3"); blank != "" {
+		t.Errorf("C3 = %q, want a blank cell for the zero time", blank)
+	}
+}
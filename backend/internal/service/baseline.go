@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+// Baseline methods. Generated baselines spread the contract over the job dates;
+// manual baselines are entered or uploaded month by month.
+const (
+	BaselineLinear = "linear"
+	BaselineSCurve = "s-curve"
+	BaselineManual = "manual"
+)
+
+// ErrInvalidBaseline marks a baseline that can't be saved as given.
+var ErrInvalidBaseline = errors.New("invalid baseline")
+
+// Baseline is one stored planned value baseline version.
+type Baseline struct {
+	Version   int32           `json:"version"`
+	Method    string          `json:"method"`
+	Note      string          `json:"note"`
+	CreatedAt time.Time       `json:"created_at"`
+	Total     decimal.Decimal `json:"total"`
+	Months    []BaselineMonth `json:"months"`
+}
+
+// BaselineMonth is the planned value for one month of a baseline.
+type BaselineMonth struct {
+	Month                  string          `json:"month" report:"Month"`
+	PlannedValue           decimal.Decimal `json:"planned_value" report:"Planned Value"`
+	CumulativePlannedValue decimal.Decimal `json:"cumulative_planned_value" report:"Cumulative Planned Value"`
+}
+
+// baselineActivity is a slice of the contract earned over a window of the job,
+// with start and end as fractions of the job duration.
+type baselineActivity struct {
+	weight     decimal.Decimal
+	start, end decimal.Decimal
+}
+
+// GenerateBaseline builds a new baseline version from the bid. The revised
+// contract value is spread from the job's start to end date, month by month.
+//
+// Crew items are laid end to end in bid order, each taking a share of the job
+// duration proportional to its crew days, and each earns its share of the
+// contract (by budget) across its window. A job without crew days is treated
+// as one activity spanning the whole job. With the s-curve method, progress
+// through the job follows 3t² - 2t³ instead of a straight line, so earning
+// starts slow, peaks mid-job and tapers off.
+func GenerateBaseline(ctx context.Context, q *database.Queries, jobNumber, method, note string) (*Baseline, error) {
+	if method != BaselineLinear && method != BaselineSCurve {
+		return nil, fmt.Errorf("%w: method %q - use linear or s-curve", ErrInvalidBaseline, method)
+	}
+
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	schedule, err := q.GetJobSchedule(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch job schedule: %w", err)
+	}
+	if !schedule.StartDate.Valid || !schedule.EndDate.Valid || !schedule.EndDate.Time.After(schedule.StartDate.Time) {
+		return nil, fmt.Errorf("%w: job %s needs a start date before its end date to generate a baseline", ErrInvalidBaseline, jobNumber)
+	}
+
+	cv, err := GetContractValue(ctx, q, jobNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !cv.Revised.IsPositive() {
+		return nil, fmt.Errorf("%w: job %s has no contract value to spread", ErrInvalidBaseline, jobNumber)
+	}
+
+	items, err := q.GetJobTree(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch job tree: %w", err)
+	}
+	activities := crewActivities(items)
+
+	start := schedule.StartDate.Time
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	end := schedule.EndDate.Time
+	last := time.Date(end.Year(), end.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	var months []BaselineMonth
+	previous := decimal.Zero
+	for m := first; !m.After(last); m = m.AddDate(0, 1, 0) {
+		progress := linearScheduleFraction(schedule, m)
+		if method == BaselineSCurve {
+			progress = sCurve(progress)
+		}
+
+		cumulative := cv.Revised.Mul(earnedFraction(activities, progress)).Round(2)
+		months = append(months, BaselineMonth{
+			Month:        m.Format("2006-01"),
+			PlannedValue: cumulative.Sub(previous),
+		})
+		previous = cumulative
+	}
+
+	return saveBaseline(ctx, q, job.ID, method, note, months)
+}
+
+// SaveManualBaseline stores a baseline entered month by month. Months are
+// formatted 2006-01 and may be given in any order; planned values can't be
+// negative. The caller's slice is left as it was.
+func SaveManualBaseline(ctx context.Context, q *database.Queries, jobNumber, note string, months []BaselineMonth) (*Baseline, error) {
+	if len(months) == 0 {
+		return nil, fmt.Errorf("%w: a manual baseline needs at least one month", ErrInvalidBaseline)
+	}
+
+	sorted := make([]BaselineMonth, len(months))
+	seen := make(map[string]bool)
+	for i, m := range months {
+		t, err := time.Parse("2006-01", m.Month)
+		if err != nil {
+			return nil, fmt.Errorf("%w: month %q - use format like '2006-01'", ErrInvalidBaseline, m.Month)
+		}
+		if seen[m.Month] {
+			return nil, fmt.Errorf("%w: month %s appears more than once", ErrInvalidBaseline, m.Month)
+		}
+		if m.PlannedValue.IsNegative() {
+			return nil, fmt.Errorf("%w: month %s has a negative planned value", ErrInvalidBaseline, m.Month)
+		}
+		seen[m.Month] = true
+		sorted[i] = BaselineMonth{Month: t.Format("2006-01"), PlannedValue: m.PlannedValue}
+	}
+
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Month < sorted[j].Month })
+	return saveBaseline(ctx, q, job.ID, BaselineManual, note, sorted)
+}
+
+// ImportBaseline imports a manual baseline from the first sheet of an Excel
+// file with a month column and a planned value column.
+func ImportBaseline(ctx context.Context, f *excelize.File, q *database.Queries, jobNumber, note string) (*UploadResult, error) {
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("Excel file has no sheets")
+	}
+
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("reading rows: %w", err)
+	}
+
+	headerRow, colMonth, colValue := -1, -1, -1
+	for rowIdx, row := range rows {
+		if rowIdx > 10 {
+			break
+		}
+		for colIdx, cell := range row {
+			cellLower := strings.ToLower(strings.TrimSpace(cell))
+			if (strings.Contains(cellLower, "month") || strings.Contains(cellLower, "period")) && colMonth == -1 {
+				colMonth = colIdx
+			} else if (strings.Contains(cellLower, "planned") || cellLower == "pv" || strings.Contains(cellLower, "amount")) && colValue == -1 {
+				colValue = colIdx
+			}
+		}
+		if colMonth >= 0 && colValue >= 0 {
+			headerRow = rowIdx
+			break
+		}
+		colMonth, colValue = -1, -1
+	}
+	if headerRow < 0 {
+		return nil, fmt.Errorf("could not find Month and Planned Value headers in sheet %s", sheets[0])
+	}
+
+	var months []BaselineMonth
+	for rowIdx := headerRow + 1; rowIdx < len(rows); rowIdx++ {
+		row := rows[rowIdx]
+		monthStr := getColValue(row, colMonth)
+		if monthStr == "" {
+			continue
+		}
+
+		month, err := parseBaselineMonth(monthStr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidBaseline, rowIdx+1, err)
+		}
+
+		value, err := cleanNumeric(getColValue(row, colValue))
+		if err != nil {
+			return nil, fmt.Errorf("row %d planned value: %w", rowIdx+1, err)
+		}
+		pv, err := decimal.NewFromString(value)
+		if err != nil {
+			return nil, fmt.Errorf("row %d planned value: %w", rowIdx+1, err)
+		}
+
+		months = append(months, BaselineMonth{Month: month.Format("2006-01"), PlannedValue: pv})
+	}
+
+	baseline, err := SaveManualBaseline(ctx, q, jobNumber, note, months)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UploadResult{
+		Success:       true,
+		Message:       fmt.Sprintf("Saved baseline version %d for job %s (%d months, $%s)", baseline.Version, jobNumber, len(baseline.Months), baseline.Total.StringFixed(2)),
+		RowsProcessed: len(baseline.Months),
+	}, nil
+}
+
+// GetBaselines returns every baseline version for a job, newest first.
+func GetBaselines(ctx context.Context, q *database.Queries, jobNumber string) ([]Baseline, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	rows, err := q.GetBaselinesByJob(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch baselines: %w", err)
+	}
+
+	baselines := make([]Baseline, 0, len(rows))
+	for _, row := range rows {
+		b, err := baselineWithMonths(ctx, q, row)
+		if err != nil {
+			return nil, err
+		}
+		baselines = append(baselines, *b)
+	}
+	return baselines, nil
+}
+
+// loadBaseline fetches a baseline by version, or the latest when version is 0.
+// It returns nil without error when the job has no baselines yet.
+func loadBaseline(ctx context.Context, q *database.Queries, jobID uuid.UUID, version int32) (*Baseline, error) {
+	var row database.PvBaseline
+	var err error
+	if version == 0 {
+		row, err = q.GetLatestBaseline(ctx, jobID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+	} else {
+		row, err = q.GetBaselineByVersion(ctx, database.GetBaselineByVersionParams{
+			JobID:   jobID,
+			Version: version,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch baseline: %w", err)
+	}
+	return baselineWithMonths(ctx, q, row)
+}
+
+// cumulativeAt returns the baseline's cumulative planned value through the
+// given month.
+func (b *Baseline) cumulativeAt(month time.Time) decimal.Decimal {
+	key := month.Format("2006-01")
+	cumulative := decimal.Zero
+	for _, m := range b.Months {
+		if m.Month > key {
+			break
+		}
+		cumulative = m.CumulativePlannedValue
+	}
+	return cumulative
+}
+
+func saveBaseline(ctx context.Context, q *database.Queries, jobID uuid.UUID, method, note string, months []BaselineMonth) (*Baseline, error) {
+	version, err := q.GetNextBaselineVersion(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to number baseline: %w", err)
+	}
+
+	row, err := q.CreateBaseline(ctx, database.CreateBaselineParams{
+		JobID:   jobID,
+		Version: version,
+		Method:  method,
+		Note:    sql.NullString{String: note, Valid: note != ""},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create baseline: %w", err)
+	}
+
+	for _, m := range months {
+		month, _ := time.Parse("2006-01", m.Month)
+		if err := q.InsertBaselineMonth(ctx, database.InsertBaselineMonthParams{
+			BaselineID:   row.ID,
+			Month:        month,
+			PlannedValue: m.PlannedValue.String(),
+		}); err != nil {
+			return nil, fmt.Errorf("failed to insert baseline month %s: %w", m.Month, err)
+		}
+	}
+
+	return baselineWithMonths(ctx, q, row)
+}
+
+func baselineWithMonths(ctx context.Context, q *database.Queries, row database.PvBaseline) (*Baseline, error) {
+	months, err := q.GetBaselineMonths(ctx, row.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch baseline %d months: %w", row.Version, err)
+	}
+
+	b := &Baseline{
+		Version:   row.Version,
+		Method:    row.Method,
+		Note:      row.Note.String,
+		CreatedAt: row.CreatedAt.Time,
+		Months:    make([]BaselineMonth, 0, len(months)),
+	}
+	for _, m := range months {
+		bm := BaselineMonth{Month: m.Month.Format("2006-01")}
+		if bm.PlannedValue, err = decimal.NewFromString(m.PlannedValue); err != nil {
+			return nil, fmt.Errorf("invalid planned value %q for %s: %w", m.PlannedValue, bm.Month, err)
+		}
+		if bm.CumulativePlannedValue, err = decimal.NewFromString(m.CumulativePlannedValue); err != nil {
+			return nil, fmt.Errorf("invalid planned value %q for %s: %w", m.CumulativePlannedValue, bm.Month, err)
+		}
+		b.Total = bm.CumulativePlannedValue
+		b.Months = append(b.Months, bm)
+	}
+	return b, nil
+}
+
+// crewActivities lays Crew items with crew days end to end in bid order.
+func crewActivities(items []database.GetJobTreeRow) []baselineActivity {
+	type crewItem struct {
+		budget, crewDays decimal.Decimal
+	}
+	var crew []crewItem
+	totalDays, totalBudget := decimal.Zero, decimal.Zero
+
+	for _, item := range items {
+		if item.CostMethod.String != "Crew" {
+			continue
+		}
+		days, err := decimal.NewFromString(item.CrewDays)
+		if err != nil || !days.IsPositive() {
+			continue
+		}
+		budget, _ := decimal.NewFromString(item.Budget)
+		crew = append(crew, crewItem{budget: budget, crewDays: days})
+		totalDays = totalDays.Add(days)
+		totalBudget = totalBudget.Add(budget)
+	}
+
+	if len(crew) == 0 {
+		return []baselineActivity{{weight: decimal.NewFromInt(1), start: decimal.Zero, end: decimal.NewFromInt(1)}}
+	}
+
+	activities := make([]baselineActivity, 0, len(crew))
+	elapsed := decimal.Zero
+	for _, c := range crew {
+		weight := c.crewDays
+		if totalBudget.IsPositive() {
+			weight = c.budget
+		}
+		next := elapsed.Add(c.crewDays.Div(totalDays))
+		activities = append(activities, baselineActivity{weight: weight, start: elapsed, end: next})
+		elapsed = next
+	}
+	// Guard against rounding leaving the last window short of the finish
+	activities[len(activities)-1].end = decimal.NewFromInt(1)
+	return activities
+}
+
+// earnedFraction returns the share of the contract planned to be earned once
+// the job is progress (0 to 1) of the way through.
+func earnedFraction(activities []baselineActivity, progress decimal.Decimal) decimal.Decimal {
+	one := decimal.NewFromInt(1)
+	earned, total := decimal.Zero, decimal.Zero
+	for _, a := range activities {
+		total = total.Add(a.weight)
+
+		var done decimal.Decimal
+		switch {
+		case !progress.GreaterThan(a.start):
+			done = decimal.Zero
+		case !progress.LessThan(a.end):
+			done = one
+		default:
+			done = progress.Sub(a.start).Div(a.end.Sub(a.start))
+		}
+		earned = earned.Add(a.weight.Mul(done))
+	}
+	if total.IsZero() {
+		return decimal.Zero
+	}
+	return earned.Div(total)
+}
+
+// sCurve maps linear progress t onto 3t² - 2t³.
+func sCurve(t decimal.Decimal) decimal.Decimal {
+	t2 := t.Mul(t)
+	return t2.Mul(decimal.NewFromInt(3)).Sub(t2.Mul(t).Mul(decimal.NewFromInt(2)))
+}
+
+// parseBaselineMonth accepts month-only formats and full dates from Excel.
+func parseBaselineMonth(s string) (time.Time, error) {
+	formats := []string{
+		"2006-01",
+		"Jan-06",
+		"Jan 2006",
+		"January 2006",
+		"01/2006",
+		"1/2006",
+		"01-02-06",
+		"1/2/06",
+		"2006-01-02",
+		"01/02/2006",
+		"1/2/2006",
+	}
+
+	for _, format := range formats {
+		if t, err := time.Parse(format, s); err == nil {
+			return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse month '%s' - use format like '2006-01'", s)
+}
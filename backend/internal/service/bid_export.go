@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+// bidExportHeaders are the bid columns written by ExportBid, in the same order as
+// the estimating export. Header text must keep matching bidHeaderPatterns so the
+// exported file re-imports through ImportBid unchanged.
+var bidExportHeaders = []string{
+	"Item #",
+	"Total Direct Cost",
+	"Job Cost ID",
+	"Description",
+	"Quantity",
+	"UM",
+	"Cost Method",
+	"Total Bid Price",
+	"Production Rate",
+	"Production Method",
+	"Total Man Hours",
+	"Total Prod. Hours",
+	"Crew Days",
+	"Total Plug",
+	"Total Labor",
+	"Total Equip.",
+	"Total Misc.",
+	"Total Material",
+	"Total Subcontracted",
+	"Total Trucking",
+	"Total Ind. Cost",
+	"Total Bond",
+	"Total OH",
+	"Total Profit",
+}
+
+// payAppExportHeaders are appended after the bid columns. None of them match a
+// bid header pattern, so the bid importer ignores them on re-import.
+var payAppExportHeaders = []string{
+	"Installed To Date",
+	"Billed To Date",
+	"Pct Complete",
+}
+
+// maxOutlineLevel is the deepest row grouping Excel supports.
+const maxOutlineLevel = 7
+
+// BidExportSheet is the name of the worksheet written by ExportBid.
+const BidExportSheet = "Bid"
+
+// ExportBid writes a job's item tree to a new workbook using Excel row outline
+// levels for the hierarchy. Cumulative quantities from the latest pay application
+// are appended after the bid columns.
+func ExportBid(ctx context.Context, q *database.Queries, jobNumber string) (*excelize.File, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	items, err := q.GetJobTree(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch job tree: %w", err)
+	}
+
+	cumulative, err := latestPayAppCumulative(ctx, q, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	if err := f.SetSheetName(f.GetSheetName(0), BidExportSheet); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to name sheet: %w", err)
+	}
+
+	headers := append(append([]string{}, bidExportHeaders...), payAppExportHeaders...)
+	if err := f.SetSheetRow(BidExportSheet, "A1", &headers); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write header row: %w", err)
+	}
+
+	for i, item := range items {
+		rowNum := i + 2
+		cell, _ := excelize.CoordinatesToCellName(1, rowNum)
+
+		values := bidExportRow(item, cumulative[item.ID])
+		if err := f.SetSheetRow(BidExportSheet, cell, &values); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write item %s: %w", item.ItemNumber, err)
+		}
+
+		level := item.Depth - 1
+		if level > maxOutlineLevel {
+			level = maxOutlineLevel
+		}
+		if level > 0 {
+			if err := f.SetRowOutlineLevel(BidExportSheet, rowNum, uint8(level)); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("failed to set outline level for item %s: %w", item.ItemNumber, err)
+			}
+		}
+	}
+
+	// Parents sit above their children in the bid, so the group toggle belongs on top.
+	summaryBelow := false
+	if err := f.SetSheetProps(BidExportSheet, &excelize.SheetPropsOptions{OutlineSummaryBelow: &summaryBelow}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to set outline properties: %w", err)
+	}
+
+	if err := f.SetPanes(BidExportSheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to freeze header row: %w", err)
+	}
+
+	return f, nil
+}
+
+// latestPayAppCumulative returns the cumulative pay application rows for the most
+// recent month billed on a job, keyed by job item.
+func latestPayAppCumulative(ctx context.Context, q *database.Queries, jobID uuid.UUID) (map[uuid.UUID]database.PayApplicationCumulative, error) {
+	result := make(map[uuid.UUID]database.PayApplicationCumulative)
+
+	months, err := q.GetPayAppMonthsForJob(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pay application months: %w", err)
+	}
+	if len(months) == 0 {
+		return result, nil
+	}
+
+	rows, err := q.GetPayAppCumulative(ctx, database.GetPayAppCumulativeParams{
+		JobID:       jobID,
+		PayAppMonth: months[len(months)-1],
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pay application cumulative data: %w", err)
+	}
+
+	for _, row := range rows {
+		result[row.JobItemID] = row
+	}
+	return result, nil
+}
+
+// bidExportRow builds the cell values for one item. Values are written so that
+// buildBidItems derives the same cost method and hierarchy on re-import.
+func bidExportRow(item database.GetJobTreeRow, payApp database.PayApplicationCumulative) []interface{} {
+	itemNumber := item.ItemNumber
+	if strings.HasPrefix(itemNumber, "AUTO-") {
+		// Generated by the importer for rows without an Item #
+		itemNumber = ""
+	}
+
+	// The importer derives these cost methods from Item # and Production Rate
+	// rather than reading them from the column, so they are written blank.
+	costMethod := item.CostMethod.String
+	switch {
+	case costMethod == "Pay Item":
+		costMethod = ""
+	case costMethod == "Crew" && item.ProductionRate.Valid:
+		costMethod = ""
+	case costMethod == "Cost" && !item.ProductionRate.Valid:
+		costMethod = ""
+	}
+
+	row := []interface{}{
+		itemNumber,
+		numericCell(item.Budget),
+		item.JobCostID.String,
+		item.Description,
+		numericCell(item.Qty),
+		item.Unit.String,
+		costMethod,
+		numericCell(item.ScheduledValue),
+		nullNumericCell(item.ProductionRate),
+		item.ProductionUnits.String,
+		numericCell(item.ManHours),
+		numericCell(item.ProductionHours),
+		numericCell(item.CrewDays),
+		numericCell(item.Plug),
+		numericCell(item.Labor),
+		numericCell(item.Equip),
+		numericCell(item.Misc),
+		numericCell(item.Material),
+		numericCell(item.Sub),
+		numericCell(item.Trucking),
+		numericCell(item.Indirect),
+		numericCell(item.Bond),
+		numericCell(item.Overhead),
+		numericCell(item.Profit),
+	}
+
+	if payApp.JobItemID == uuid.Nil {
+		return append(row, nil, nil, nil)
+	}
+	return append(row,
+		numericCell(payApp.CumulativeQty),
+		numericCell(payApp.CumulativeAmount),
+		numericCell(payApp.PercentComplete),
+	)
+}
+
+// numericCell converts a NUMERIC column value to a number cell. Values that
+// cannot be represented exactly as a float are left as text so re-import is
+// lossless.
+func numericCell(s string) interface{} {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return s
+	}
+	v, exact := d.Float64()
+	if !exact && !decimal.NewFromFloat(v).Equal(d) {
+		return s
+	}
+	return v
+}
+
+// nullNumericCell is numericCell for nullable columns; NULL becomes an empty cell.
+func nullNumericCell(s sql.NullString) interface{} {
+	if !s.Valid {
+		return nil
+	}
+	return numericCell(s.String)
+}
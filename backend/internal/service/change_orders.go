@@ -0,0 +1,494 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// Change order statuses. Only approved change orders revise the contract.
+const (
+	ChangeOrderPending  = "pending"
+	ChangeOrderApproved = "approved"
+	ChangeOrderRejected = "rejected"
+)
+
+var (
+	// ErrInvalidChangeOrder marks a change order that doesn't fit the job.
+	ErrInvalidChangeOrder = errors.New("invalid change order")
+	// ErrChangeOrderInUse marks a status change the job's items won't allow.
+	ErrChangeOrderInUse = errors.New("change order items are in use")
+)
+
+// ChangeOrderInput is a new change order with its line items.
+type ChangeOrderInput struct {
+	Number      string                 `json:"number"`
+	Description string                 `json:"description"`
+	Status      string                 `json:"status"` // defaults to pending
+	Date        string                 `json:"date"`   // 2006-01-02, defaults to today
+	Amount      *decimal.Decimal       `json:"amount"` // required when there are no line items, else must match their total
+	Items       []ChangeOrderInputLine `json:"items"`
+}
+
+// ChangeOrderInputLine adjusts an existing job item or, with New set, adds one.
+type ChangeOrderInputLine struct {
+	ItemNumber string           `json:"item_number"`
+	QtyChange  decimal.Decimal  `json:"qty_change"`
+	UnitPrice  *decimal.Decimal `json:"unit_price"` // new unit price from the change order's month on; required for new items
+	Amount     *decimal.Decimal `json:"amount"`     // contract change; computed from qty and price when omitted
+
+	// New item fields
+	New              bool            `json:"new"`
+	ParentItemNumber string          `json:"parent_item_number"`
+	Description      string          `json:"description"`
+	Unit             string          `json:"unit"`
+	JobCostID        string          `json:"job_cost_id"`
+	Budget           decimal.Decimal `json:"budget"`
+}
+
+// ChangeOrder is a change order as stored, with its line items.
+type ChangeOrder struct {
+	Number      string            `json:"number"`
+	Description string            `json:"description"`
+	Status      string            `json:"status"`
+	Date        string            `json:"date"`
+	Amount      decimal.Decimal   `json:"amount"`
+	Items       []ChangeOrderLine `json:"items"`
+}
+
+// ChangeOrderLine is one stored CO line item.
+type ChangeOrderLine struct {
+	ItemNumber   string           `json:"item_number"`
+	Description  string           `json:"description"`
+	NewItem      bool             `json:"new_item"`
+	QtyChange    decimal.Decimal  `json:"qty_change"`
+	NewUnitPrice *decimal.Decimal `json:"new_unit_price"`
+	Amount       decimal.Decimal  `json:"amount"`
+}
+
+// ContractValue is the original contract plus change orders.
+type ContractValue struct {
+	Original             decimal.Decimal `json:"original_contract_value"`
+	ApprovedChangeOrders decimal.Decimal `json:"approved_change_orders"`
+	PendingChangeOrders  decimal.Decimal `json:"pending_change_orders"`
+	Revised              decimal.Decimal `json:"revised_contract_value"`
+}
+
+// ValidChangeOrderStatus reports whether s is a known change order status.
+func ValidChangeOrderStatus(s string) bool {
+	switch s {
+	case ChangeOrderPending, ChangeOrderApproved, ChangeOrderRejected:
+		return true
+	}
+	return false
+}
+
+// ValidateChangeOrder checks a new change order before anything is written.
+func ValidateChangeOrder(in ChangeOrderInput) error {
+	if in.Number == "" {
+		return fmt.Errorf("number is required")
+	}
+	if in.Status != "" && !ValidChangeOrderStatus(in.Status) {
+		return fmt.Errorf("invalid status %q - use pending, approved or rejected", in.Status)
+	}
+	if in.Date != "" {
+		if _, err := time.Parse("2006-01-02", in.Date); err != nil {
+			return fmt.Errorf("invalid date %q - use format like '2006-01-02'", in.Date)
+		}
+	}
+	if len(in.Items) == 0 && in.Amount == nil {
+		return fmt.Errorf("amount is required when there are no line items")
+	}
+
+	seen := make(map[string]bool)
+	for _, line := range in.Items {
+		if line.ItemNumber == "" {
+			return fmt.Errorf("item_number is required on every line")
+		}
+		if seen[line.ItemNumber] {
+			return fmt.Errorf("item %s appears more than once", line.ItemNumber)
+		}
+		seen[line.ItemNumber] = true
+
+		if line.UnitPrice != nil && line.UnitPrice.IsNegative() {
+			return fmt.Errorf("item %s: unit_price cannot be negative", line.ItemNumber)
+		}
+		if line.New {
+			if line.Description == "" {
+				return fmt.Errorf("item %s: description is required for new items", line.ItemNumber)
+			}
+			if line.UnitPrice == nil && line.Amount == nil {
+				return fmt.Errorf("item %s: unit_price or amount is required for new items", line.ItemNumber)
+			}
+		}
+	}
+	return nil
+}
+
+// CreateChangeOrder stores a change order and its line items in one
+// transaction. Lines adding an item keep its details until the change order
+// is approved, when the item is added to job_items. Line amounts not given are
+// computed against the item's current revised quantity and unit price, and
+// the change order amount is the sum of its lines.
+func CreateChangeOrder(ctx context.Context, q *database.Queries, jobNumber string, in ChangeOrderInput) (*ChangeOrder, error) {
+	if err := ValidateChangeOrder(in); err != nil {
+		return nil, err
+	}
+
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	status := in.Status
+	if status == "" {
+		status = ChangeOrderPending
+	}
+	coDate := time.Now().UTC().Truncate(24 * time.Hour)
+	if in.Date != "" {
+		coDate, _ = time.Parse("2006-01-02", in.Date)
+	}
+
+	// Resolve every line before writing so a bad item number leaves nothing behind.
+	type resolvedLine struct {
+		in       ChangeOrderInputLine
+		itemID   uuid.UUID
+		parentID uuid.NullUUID
+		amount   decimal.Decimal
+	}
+	lines := make([]resolvedLine, 0, len(in.Items))
+	total := decimal.Zero
+
+	for _, line := range in.Items {
+		r := resolvedLine{in: line}
+
+		current, err := q.GetRevisedJobItem(ctx, database.GetRevisedJobItemParams{
+			JobID:      job.ID,
+			ItemNumber: line.ItemNumber,
+		})
+		switch {
+		case line.New && err == nil:
+			return nil, fmt.Errorf("%w: item %s already exists on job %s", ErrInvalidChangeOrder, line.ItemNumber, jobNumber)
+		case line.New && errors.Is(err, sql.ErrNoRows):
+			r.amount = line.QtyChange.Mul(decimalOrZero(line.UnitPrice))
+			if line.ParentItemNumber != "" {
+				parent, err := q.GetRevisedJobItem(ctx, database.GetRevisedJobItemParams{
+					JobID:      job.ID,
+					ItemNumber: line.ParentItemNumber,
+				})
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil, fmt.Errorf("%w: parent item %s not found on job %s", ErrInvalidChangeOrder, line.ParentItemNumber, jobNumber)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("failed to find parent item %s: %w", line.ParentItemNumber, err)
+				}
+				r.parentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+			}
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, fmt.Errorf("%w: item %s not found on job %s - set new to add it", ErrInvalidChangeOrder, line.ItemNumber, jobNumber)
+		case err != nil:
+			return nil, fmt.Errorf("failed to find item %s: %w", line.ItemNumber, err)
+		default:
+			r.itemID = current.ID
+			qty, _ := decimal.NewFromString(current.Qty)
+			price, _ := decimal.NewFromString(current.UnitPrice)
+			newPrice := price
+			if line.UnitPrice != nil {
+				newPrice = *line.UnitPrice
+			}
+			r.amount = qty.Add(line.QtyChange).Mul(newPrice).Sub(qty.Mul(price))
+		}
+
+		if line.Amount != nil {
+			r.amount = *line.Amount
+		}
+		r.amount = r.amount.Round(2)
+		total = total.Add(r.amount)
+		lines = append(lines, r)
+	}
+
+	amount := total
+	if in.Amount != nil {
+		if len(lines) > 0 && !in.Amount.Equal(total) {
+			return nil, fmt.Errorf("%w: amount %s doesn't match the %s total of its lines", ErrInvalidChangeOrder, in.Amount, total)
+		}
+		amount = *in.Amount
+	}
+
+	var co database.ChangeOrder
+	err = q.InTx(ctx, func(q *database.Queries) error {
+		co, err = q.CreateChangeOrder(ctx, database.CreateChangeOrderParams{
+			JobID:       job.ID,
+			CoNumber:    in.Number,
+			Description: in.Description,
+			Status:      status,
+			CoDate:      coDate,
+			Amount:      amount.String(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create change order %s: %w", in.Number, err)
+		}
+
+		for _, r := range lines {
+			params := database.InsertChangeOrderItemParams{
+				ChangeOrderID: co.ID,
+				JobItemID:     uuid.NullUUID{UUID: r.itemID, Valid: !r.in.New},
+				QtyChange:     r.in.QtyChange.String(),
+				Amount:        r.amount.String(),
+			}
+			if r.in.UnitPrice != nil || r.in.New {
+				params.NewUnitPrice = sql.NullString{String: decimalOrZero(r.in.UnitPrice).String(), Valid: true}
+			}
+			if r.in.New {
+				params.NewItemNumber = sql.NullString{String: r.in.ItemNumber, Valid: true}
+				params.NewParentID = r.parentID
+				params.NewDescription = sql.NullString{String: r.in.Description, Valid: true}
+				params.NewUnit = toNullString(r.in.Unit)
+				params.NewJobCostID = toNullString(r.in.JobCostID)
+				params.NewBudget = sql.NullString{String: r.in.Budget.String(), Valid: true}
+			}
+			if err := q.InsertChangeOrderItem(ctx, params); err != nil {
+				return fmt.Errorf("failed to add line for item %s: %w", r.in.ItemNumber, err)
+			}
+		}
+
+		if status == ChangeOrderApproved {
+			if err := checkRepricedPeriodsOpen(ctx, q, co); err != nil {
+				return err
+			}
+			return addChangeOrderJobItems(ctx, q, co)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changeOrderWithItems(ctx, q, co)
+}
+
+// GetChangeOrders returns every change order on a job with its line items.
+func GetChangeOrders(ctx context.Context, q *database.Queries, jobNumber string) ([]ChangeOrder, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	rows, err := q.GetChangeOrdersByJob(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch change orders: %w", err)
+	}
+
+	orders := make([]ChangeOrder, 0, len(rows))
+	for _, row := range rows {
+		co, err := changeOrderWithItems(ctx, q, row)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, *co)
+	}
+	return orders, nil
+}
+
+// SetChangeOrderStatus approves, rejects or reopens a change order. Approving
+// adds the items its lines bring in; moving an approved change order back
+// takes them out again, unless they've been billed or built on since. Either
+// is rejected with ErrPeriodClosed when a new unit price would change a
+// closed month.
+func SetChangeOrderStatus(ctx context.Context, q *database.Queries, jobNumber, coNumber, status string) error {
+	if !ValidChangeOrderStatus(status) {
+		return fmt.Errorf("invalid status %q - use pending, approved or rejected", status)
+	}
+
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	return q.InTx(ctx, func(q *database.Queries) error {
+		co, err := q.GetChangeOrderByNumber(ctx, database.GetChangeOrderByNumberParams{
+			JobID:    job.ID,
+			CoNumber: coNumber,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to find change order %s: %w", coNumber, err)
+		}
+
+		switch {
+		case status == ChangeOrderApproved && co.Status != ChangeOrderApproved:
+			if err := checkRepricedPeriodsOpen(ctx, q, co); err != nil {
+				return err
+			}
+			if err := addChangeOrderJobItems(ctx, q, co); err != nil {
+				return err
+			}
+		case status != ChangeOrderApproved && co.Status == ChangeOrderApproved:
+			if err := checkRepricedPeriodsOpen(ctx, q, co); err != nil {
+				return err
+			}
+			if err := removeChangeOrderJobItems(ctx, q, co); err != nil {
+				return err
+			}
+		}
+
+		if err := q.UpdateChangeOrderStatus(ctx, database.UpdateChangeOrderStatusParams{
+			ID:     co.ID,
+			Status: status,
+		}); err != nil {
+			return fmt.Errorf("failed to update change order %s: %w", coNumber, err)
+		}
+		return nil
+	})
+}
+
+// checkRepricedPeriodsOpen returns ErrPeriodClosed when approving or
+// unapproving the change order would reprice a closed month: its unit prices
+// apply from the month of its date on.
+func checkRepricedPeriodsOpen(ctx context.Context, q *database.Queries, co database.ChangeOrder) error {
+	months, err := q.GetChangeOrderClosedRepricedMonths(ctx, co.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check periods for change order %s: %w", co.CoNumber, err)
+	}
+	if len(months) > 0 {
+		return fmt.Errorf("%w: change order %s reprices items billed in %s, which must be reopened first",
+			ErrPeriodClosed, co.CoNumber, months[0].Format("January 2006"))
+	}
+	return nil
+}
+
+// addChangeOrderJobItems inserts the items an approved change order's lines
+// add, at zero quantity so the lines bring in their quantity and value.
+func addChangeOrderJobItems(ctx context.Context, q *database.Queries, co database.ChangeOrder) error {
+	lines, err := q.GetChangeOrderNewItems(ctx, co.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch new items for change order %s: %w", co.CoNumber, err)
+	}
+
+	for _, line := range lines {
+		if line.JobItemID.Valid {
+			continue
+		}
+		id, err := q.InsertChangeOrderJobItem(ctx, database.InsertChangeOrderJobItemParams{
+			JobID:         co.JobID,
+			ParentID:      line.ParentID,
+			ItemNumber:    line.ItemNumber,
+			Description:   line.Description,
+			JobCostID:     line.JobCostID,
+			Budget:        line.Budget,
+			Unit:          line.Unit,
+			UnitPrice:     line.UnitPrice,
+			ChangeOrderID: uuid.NullUUID{UUID: co.ID, Valid: true},
+		})
+		if isPQError(err, pqUniqueViolation) {
+			return fmt.Errorf("%w: item %s already exists on the job", ErrChangeOrderInUse, line.ItemNumber)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to add item %s: %w", line.ItemNumber, err)
+		}
+		if err := q.SetChangeOrderItemJobItem(ctx, database.SetChangeOrderItemJobItemParams{
+			ID:        line.ID,
+			JobItemID: uuid.NullUUID{UUID: id, Valid: true},
+		}); err != nil {
+			return fmt.Errorf("failed to link item %s: %w", line.ItemNumber, err)
+		}
+	}
+	return nil
+}
+
+// removeChangeOrderJobItems takes out the items a change order added when it
+// is no longer approved. Their details stay on its lines.
+func removeChangeOrderJobItems(ctx context.Context, q *database.Queries, co database.ChangeOrder) error {
+	coID := uuid.NullUUID{UUID: co.ID, Valid: true}
+	inUse, err := q.CountChangeOrderItemsInUse(ctx, coID)
+	if err != nil {
+		return fmt.Errorf("failed to check items of change order %s: %w", co.CoNumber, err)
+	}
+	if inUse > 0 {
+		return fmt.Errorf("%w: %d item(s) added by change order %s have been billed, have child items or are changed by another change order", ErrChangeOrderInUse, inUse, co.CoNumber)
+	}
+
+	if err := q.ClearChangeOrderNewItems(ctx, co.ID); err != nil {
+		return fmt.Errorf("failed to unlink items of change order %s: %w", co.CoNumber, err)
+	}
+	if err := q.DeleteChangeOrderJobItems(ctx, coID); err != nil {
+		return fmt.Errorf("failed to remove items of change order %s: %w", co.CoNumber, err)
+	}
+	return nil
+}
+
+// GetContractValue returns a job's original and revised contract value.
+func GetContractValue(ctx context.Context, q *database.Queries, jobNumber string) (*ContractValue, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	row, err := q.GetRevisedContractValue(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch contract value: %w", err)
+	}
+
+	var cv ContractValue
+	if err := parseDecimals(
+		decimalField{&cv.Original, row.OriginalContractValue},
+		decimalField{&cv.ApprovedChangeOrders, row.ApprovedChangeOrders},
+		decimalField{&cv.PendingChangeOrders, row.PendingChangeOrders},
+		decimalField{&cv.Revised, row.RevisedContractValue},
+	); err != nil {
+		return nil, fmt.Errorf("invalid contract value: %w", err)
+	}
+	return &cv, nil
+}
+
+func changeOrderWithItems(ctx context.Context, q *database.Queries, row database.ChangeOrder) (*ChangeOrder, error) {
+	amount, err := decimal.NewFromString(row.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount %q on change order %s: %w", row.Amount, row.CoNumber, err)
+	}
+
+	items, err := q.GetChangeOrderItems(ctx, row.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch items for change order %s: %w", row.CoNumber, err)
+	}
+
+	co := &ChangeOrder{
+		Number:      row.CoNumber,
+		Description: row.Description,
+		Status:      row.Status,
+		Date:        row.CoDate.Format("2006-01-02"),
+		Amount:      amount,
+		Items:       make([]ChangeOrderLine, 0, len(items)),
+	}
+
+	for _, item := range items {
+		line := ChangeOrderLine{
+			ItemNumber:  item.ItemNumber,
+			Description: item.Description,
+			NewItem:     item.NewItem,
+		}
+		line.QtyChange, _ = decimal.NewFromString(item.QtyChange)
+		line.Amount, _ = decimal.NewFromString(item.Amount)
+		if item.NewUnitPrice.Valid {
+			price, err := decimal.NewFromString(item.NewUnitPrice.String)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unit price %q on item %s: %w", item.NewUnitPrice.String, item.ItemNumber, err)
+			}
+			line.NewUnitPrice = &price
+		}
+		co.Items = append(co.Items, line)
+	}
+	return co, nil
+}
+
+func decimalOrZero(d *decimal.Decimal) decimal.Decimal {
+	if d == nil {
+		return decimal.Zero
+	}
+	return *d
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// CostTypes are the bid's cost breakdown columns, in bid column order.
+// Ledger cost whose cat has no mapping is reported as CostTypeUnmapped.
+var CostTypes = []string{"labor", "equip", "material", "sub", "trucking", "misc", "plug"}
+
+const CostTypeUnmapped = "unmapped"
+
+// CostTypeMapping assigns a ledger cat code to a bid cost type.
+type CostTypeMapping struct {
+	Cat      string `json:"cat"`
+	CostType string `json:"cost_type"`
+}
+
+// CostTypeVarianceRow compares estimated and actual cost for one cost type,
+// either for the whole job (Phase empty) or for one phase. Variance is actual
+// minus estimate, so overruns are positive.
+type CostTypeVarianceRow struct {
+	Phase           string          `json:"phase,omitempty" report:"Phase"`
+	CostType        string          `json:"cost_type" report:"Cost Type"`
+	Estimate        decimal.Decimal `json:"estimate" report:"Estimate"`
+	Actual          decimal.Decimal `json:"actual" report:"Actual"`
+	Variance        decimal.Decimal `json:"variance" report:"Variance"`
+	VariancePercent decimal.Decimal `json:"variance_percent" report:"Variance %"`
+}
+
+// ValidateCostTypeMappings checks every cost type is known and no cat is
+// mapped twice.
+func ValidateCostTypeMappings(mappings []CostTypeMapping) error {
+	seen := make(map[string]bool)
+	for _, m := range mappings {
+		if strings.TrimSpace(m.Cat) == "" {
+			return fmt.Errorf("cat is required")
+		}
+		if costTypeIndex(m.CostType) < 0 {
+			return fmt.Errorf("cost_type %q for cat %s must be one of %s", m.CostType, m.Cat, strings.Join(CostTypes, ", "))
+		}
+		if seen[m.Cat] {
+			return fmt.Errorf("cat %s is mapped more than once", m.Cat)
+		}
+		seen[m.Cat] = true
+	}
+	return nil
+}
+
+// GetCostTypeMappings returns the ledger cat to cost type mapping.
+func GetCostTypeMappings(ctx context.Context, q *database.Queries) ([]CostTypeMapping, error) {
+	rows, err := q.GetCostTypeMappings(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch cost type mappings: %w", err)
+	}
+
+	mappings := make([]CostTypeMapping, 0, len(rows))
+	for _, row := range rows {
+		mappings = append(mappings, CostTypeMapping{Cat: row.Cat, CostType: row.CostType})
+	}
+	return mappings, nil
+}
+
+// SetCostTypeMappings replaces the ledger cat to cost type mapping.
+func SetCostTypeMappings(ctx context.Context, q *database.Queries, mappings []CostTypeMapping) error {
+	if err := q.DeleteCostTypeMappings(ctx); err != nil {
+		return fmt.Errorf("failed to clear cost type mappings: %w", err)
+	}
+
+	for _, m := range mappings {
+		if err := q.InsertCostTypeMapping(ctx, database.InsertCostTypeMappingParams{
+			Cat:      strings.TrimSpace(m.Cat),
+			CostType: m.CostType,
+		}); err != nil {
+			return fmt.Errorf("failed to insert mapping for cat %s: %w", m.Cat, err)
+		}
+	}
+	return nil
+}
+
+// GetCostTypeVariance compares the bid's cost type breakdown with ledger actuals
+// for a job. With byPhase set, each phase gets its own rows; otherwise phases
+// are summed into one row per cost type. The period limits the actuals to its
+// months and the data seen; see Period.
+func GetCostTypeVariance(ctx context.Context, q *database.Queries, jobNumber string, byPhase bool, period Period) ([]CostTypeVarianceRow, error) {
+	rows, err := q.GetCostTypeVariance(ctx, database.GetCostTypeVarianceParams{
+		JobNumber: jobNumber,
+		FromMonth: period.From,
+		ToMonth:   period.To,
+		AsOf:      period.AsOf,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching cost type variance: %w", err)
+	}
+
+	var result []CostTypeVarianceRow
+	index := make(map[[2]string]int)
+	for _, row := range rows {
+		estimate, err := decimal.NewFromString(row.Estimate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid estimate %q for phase %s: %w", row.Estimate, row.Phase, err)
+		}
+		actual, err := decimal.NewFromString(row.Actual)
+		if err != nil {
+			return nil, fmt.Errorf("invalid actual %q for phase %s: %w", row.Actual, row.Phase, err)
+		}
+
+		phase := ""
+		if byPhase {
+			phase = row.Phase
+		}
+		key := [2]string{phase, row.CostType}
+		idx, ok := index[key]
+		if !ok {
+			idx = len(result)
+			index[key] = idx
+			result = append(result, CostTypeVarianceRow{Phase: phase, CostType: row.CostType})
+		}
+		result[idx].Estimate = result[idx].Estimate.Add(estimate)
+		result[idx].Actual = result[idx].Actual.Add(actual)
+	}
+
+	for i := range result {
+		r := &result[i]
+		r.Variance = r.Actual.Sub(r.Estimate)
+		if !r.Estimate.IsZero() {
+			r.VariancePercent = r.Variance.Div(r.Estimate).Mul(hundred).Round(2)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Phase != result[j].Phase {
+			return result[i].Phase < result[j].Phase
+		}
+		return costTypeOrder(result[i].CostType) < costTypeOrder(result[j].CostType)
+	})
+
+	return result, nil
+}
+
+// costTypeIndex returns the position of t in CostTypes, or -1.
+func costTypeIndex(t string) int {
+	for i, ct := range CostTypes {
+		if ct == t {
+			return i
+		}
+	}
+	return -1
+}
+
+// costTypeOrder sorts cost types in bid column order with unmapped last.
+func costTypeOrder(t string) int {
+	if i := costTypeIndex(t); i >= 0 {
+		return i
+	}
+	return len(CostTypes)
+}
@@ -0,0 +1,35 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// decimalField is a decimal to parse from its database text.
+type decimalField struct {
+	dst *decimal.Decimal
+	src string
+}
+
+// parseDecimals parses each field's text into its destination, stopping at
+// the first one that isn't a number.
+func parseDecimals(fields ...decimalField) error {
+	for _, f := range fields {
+		v, err := decimal.NewFromString(f.src)
+		if err != nil {
+			return fmt.Errorf("%q: %w", f.src, err)
+		}
+		*f.dst = v
+	}
+	return nil
+}
+
+// nullDecimalText is the text of a nullable numeric column, "0" when null.
+func nullDecimalText(s sql.NullString) string {
+	if !s.Valid {
+		return "0"
+	}
+	return s.String
+}
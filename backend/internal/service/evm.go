@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// EVMMonth holds the earned value metrics for one pay application month.
+// Index values (CPI, SPI, TCPI) are zero when undefined, matching the CPI query.
+type EVMMonth struct {
+	Month             time.Time
+	Budget            decimal.Decimal // BAC: contract value plus approved change orders
+	TotalScheduledQty string
+	CumulativeQty     string
+	PercentComplete   decimal.Decimal
+	BaselineVersion   int32 // 0 when PV is the straight line over the job dates
+
+	PlannedValue decimal.Decimal
+	EarnedValue  decimal.Decimal
+	ActualCost   decimal.Decimal
+
+	ScheduleVariance decimal.Decimal // EV - PV
+	CostVariance     decimal.Decimal // EV - AC
+	SPI              decimal.Decimal // EV / PV
+	CPI              decimal.Decimal // EV / AC
+
+	EAC               decimal.Decimal // BAC / CPI
+	EACBudgetRate     decimal.Decimal // AC + (BAC - EV)
+	EACCompositeIndex decimal.Decimal // AC + (BAC - EV) / (CPI * SPI)
+	ETC               decimal.Decimal // EAC - AC
+	VAC               decimal.Decimal // BAC - EAC
+	TCPI              decimal.Decimal // (BAC - EV) / (BAC - AC)
+	TCPIEAC           decimal.Decimal // (BAC - EV) / (EAC - AC)
+}
+
+// GetEarnedValueMetrics computes the full set of earned value metrics per month
+// for a job. EV, AC and budget come from GetCostPerformanceIndex; PV comes
+// from the given baseline version, or the latest when baselineVersion is 0.
+// Jobs without a baseline fall back to a straight line over the job dates.
+// The period limits the months returned and the data seen; see Period.
+func GetEarnedValueMetrics(ctx context.Context, q *database.Queries, jobNumber string, baselineVersion int32, period Period) ([]EVMMonth, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	rows, err := q.GetCostPerformanceIndex(ctx, database.GetCostPerformanceIndexParams{
+		JobNumber: jobNumber,
+		AsOf:      period.AsOf,
+		FromMonth: period.From,
+		ToMonth:   period.To,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch CPI data: %w", err)
+	}
+
+	schedule, err := q.GetJobSchedule(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch job schedule: %w", err)
+	}
+
+	baseline, err := loadBaseline(ctx, q, job.ID, baselineVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	months := make([]EVMMonth, 0, len(rows))
+	for _, row := range rows {
+		m := EVMMonth{
+			Month:             row.Month,
+			Budget:            decimal.NewFromInt(row.Budget),
+			TotalScheduledQty: row.TotalScheduledQty,
+			CumulativeQty:     row.CumulativeQty,
+			EarnedValue:       decimal.NewFromInt(row.EarnedValue),
+			ActualCost:        decimal.NewFromInt(row.ActualCost),
+		}
+		m.PercentComplete, _ = decimal.NewFromString(row.PercentComplete)
+		if baseline != nil {
+			m.BaselineVersion = baseline.Version
+			m.PlannedValue = baseline.cumulativeAt(row.Month).Round(0)
+		} else {
+			m.PlannedValue = m.Budget.Mul(linearScheduleFraction(schedule, row.Month)).Round(0)
+		}
+
+		computeEVM(&m)
+		months = append(months, m)
+	}
+
+	return months, nil
+}
+
+// computeEVM fills in the derived metrics from BAC, PV, EV and AC.
+func computeEVM(m *EVMMonth) {
+	bac, ev, ac, pv := m.Budget, m.EarnedValue, m.ActualCost, m.PlannedValue
+
+	m.ScheduleVariance = ev.Sub(pv)
+	m.CostVariance = ev.Sub(ac)
+	m.SPI = ratio(ev, pv)
+	m.CPI = ratio(ev, ac)
+
+	remaining := bac.Sub(ev)
+	m.EACBudgetRate = ac.Add(remaining)
+
+	// EACs use the unrounded indices; only the reported CPI and SPI are rounded
+	m.EAC = m.EACBudgetRate
+	if ev.IsPositive() && ac.IsPositive() {
+		m.EAC = bac.Mul(ac).Div(ev).Round(0)
+	}
+
+	m.EACCompositeIndex = m.EACBudgetRate
+	if ev.IsPositive() && ac.IsPositive() && pv.IsPositive() {
+		// remaining / (CPI * SPI) = remaining * AC * PV / EV²
+		m.EACCompositeIndex = ac.Add(remaining.Mul(ac).Mul(pv).Div(ev.Mul(ev))).Round(0)
+	}
+
+	m.ETC = m.EAC.Sub(ac)
+	m.VAC = bac.Sub(m.EAC)
+	if bac.Sub(ac).IsPositive() {
+		m.TCPI = ratio(remaining, bac.Sub(ac))
+	}
+	if m.ETC.IsPositive() {
+		m.TCPIEAC = ratio(remaining, m.ETC)
+	}
+}
+
+// linearScheduleFraction returns how much of the job's duration has elapsed by
+// the end of the given month, from 0 to 1. Jobs without both dates plan nothing.
+func linearScheduleFraction(schedule database.GetJobScheduleRow, month time.Time) decimal.Decimal {
+	if !schedule.StartDate.Valid || !schedule.EndDate.Valid {
+		return decimal.Zero
+	}
+	start, end := schedule.StartDate.Time, schedule.EndDate.Time
+	if !end.After(start) {
+		return decimal.Zero
+	}
+
+	monthEnd := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	switch {
+	case !monthEnd.After(start):
+		return decimal.Zero
+	case !monthEnd.Before(end):
+		return decimal.NewFromInt(1)
+	}
+
+	elapsed := decimal.NewFromFloat(monthEnd.Sub(start).Hours())
+	total := decimal.NewFromFloat(end.Sub(start).Hours())
+	return elapsed.Div(total)
+}
+
+// ratio returns a / b rounded to two places, or zero when b is zero.
+func ratio(a, b decimal.Decimal) decimal.Decimal {
+	if b.IsZero() {
+		return decimal.Zero
+	}
+	return a.Div(b).Round(2)
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// ItemTotals are the dollar columns of a bid item.
+type ItemTotals struct {
+	Budget         decimal.Decimal `json:"budget"`
+	ScheduledValue decimal.Decimal `json:"scheduled_value"`
+	Labor          decimal.Decimal `json:"labor"`
+	Equip          decimal.Decimal `json:"equip"`
+	Material       decimal.Decimal `json:"material"`
+	Sub            decimal.Decimal `json:"sub"`
+	Trucking       decimal.Decimal `json:"trucking"`
+	Misc           decimal.Decimal `json:"misc"`
+	Plug           decimal.Decimal `json:"plug"`
+	Indirect       decimal.Decimal `json:"indirect"`
+	Bond           decimal.Decimal `json:"bond"`
+	Overhead       decimal.Decimal `json:"overhead"`
+	Profit         decimal.Decimal `json:"profit"`
+}
+
+func (t *ItemTotals) add(o ItemTotals) {
+	for _, f := range []struct{ dst, src *decimal.Decimal }{
+		{&t.Budget, &o.Budget},
+		{&t.ScheduledValue, &o.ScheduledValue},
+		{&t.Labor, &o.Labor},
+		{&t.Equip, &o.Equip},
+		{&t.Material, &o.Material},
+		{&t.Sub, &o.Sub},
+		{&t.Trucking, &o.Trucking},
+		{&t.Misc, &o.Misc},
+		{&t.Plug, &o.Plug},
+		{&t.Indirect, &o.Indirect},
+		{&t.Bond, &o.Bond},
+		{&t.Overhead, &o.Overhead},
+		{&t.Profit, &o.Profit},
+	} {
+		*f.dst = f.dst.Add(*f.src)
+	}
+}
+
+// JobItemNode is one bid item with its children. Own holds the values stored
+// on the item; Rollup sums the leaf items beneath it (its own values for a
+// leaf), so a parent whose Own and Rollup differ doesn't match its detail.
+// Rollups always cover the full tree, even when children are collapsed or
+// filtered out. ChildCount is the number of children before either.
+type JobItemNode struct {
+	ID          uuid.UUID       `json:"id"`
+	ItemNumber  string          `json:"item_number"`
+	Description string          `json:"description"`
+	CostMethod  string          `json:"cost_method"`
+	JobCostID   string          `json:"job_cost_id,omitempty"`
+	Depth       int32           `json:"depth"`
+	Qty         decimal.Decimal `json:"qty"`
+	Unit        string          `json:"unit,omitempty"`
+	UnitPrice   decimal.Decimal `json:"unit_price"`
+	Own         ItemTotals      `json:"own"`
+	Rollup      ItemTotals      `json:"rollup"`
+	ChildCount  int             `json:"child_count"`
+	Children    []*JobItemNode  `json:"children"`
+
+	all []*JobItemNode // every child, before collapsing and filtering
+}
+
+// GetJobItemTree returns a job's bid items as a nested tree in bid order.
+// maxDepth > 0 drops items below that depth (pay items are depth 1). A
+// non-empty methods keeps only items with one of those cost methods (matched
+// without regard to case); kept items move up to their nearest kept ancestor.
+func GetJobItemTree(ctx context.Context, q *database.Queries, jobNumber string, maxDepth int32, methods []string) ([]*JobItemNode, error) {
+	job, err := GetJob(ctx, q, jobNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := q.GetJobTree(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch job tree: %w", err)
+	}
+
+	nodes := make(map[uuid.UUID]*JobItemNode, len(items))
+	var roots []*JobItemNode
+	for _, item := range items {
+		node, err := jobItemNode(item)
+		if err != nil {
+			return nil, err
+		}
+		nodes[item.ID] = node
+
+		parent, ok := nodes[item.ParentID.UUID]
+		if !item.ParentID.Valid || !ok {
+			roots = append(roots, node)
+			continue
+		}
+		parent.all = append(parent.all, node)
+	}
+
+	for _, root := range roots {
+		rollUp(root)
+	}
+
+	keep := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		if m = strings.TrimSpace(m); m != "" {
+			keep[strings.ToLower(m)] = true
+		}
+	}
+
+	return pruneItemTree(roots, maxDepth, keep), nil
+}
+
+func jobItemNode(item database.GetJobTreeRow) (*JobItemNode, error) {
+	node := &JobItemNode{
+		ID:          item.ID,
+		ItemNumber:  item.ItemNumber,
+		Description: item.Description,
+		CostMethod:  item.CostMethod.String,
+		JobCostID:   item.JobCostID.String,
+		Depth:       item.Depth,
+		Unit:        item.Unit.String,
+	}
+
+	if err := parseDecimals(
+		decimalField{&node.Qty, item.Qty},
+		decimalField{&node.UnitPrice, item.UnitPrice},
+		decimalField{&node.Own.Budget, item.Budget},
+		decimalField{&node.Own.ScheduledValue, item.ScheduledValue},
+		decimalField{&node.Own.Labor, item.Labor},
+		decimalField{&node.Own.Equip, item.Equip},
+		decimalField{&node.Own.Material, item.Material},
+		decimalField{&node.Own.Sub, item.Sub},
+		decimalField{&node.Own.Trucking, item.Trucking},
+		decimalField{&node.Own.Misc, item.Misc},
+		decimalField{&node.Own.Plug, item.Plug},
+		decimalField{&node.Own.Indirect, item.Indirect},
+		decimalField{&node.Own.Bond, item.Bond},
+		decimalField{&node.Own.Overhead, item.Overhead},
+		decimalField{&node.Own.Profit, item.Profit},
+	); err != nil {
+		return nil, fmt.Errorf("invalid value on item %s: %w", item.ItemNumber, err)
+	}
+	return node, nil
+}
+
+// rollUp fills in Rollup and ChildCount for node and everything below it.
+func rollUp(node *JobItemNode) {
+	node.ChildCount = len(node.all)
+	if len(node.all) == 0 {
+		node.Rollup = node.Own
+		return
+	}
+	for _, child := range node.all {
+		rollUp(child)
+		node.Rollup.add(child.Rollup)
+	}
+}
+
+// pruneItemTree builds the visible children lists, dropping items deeper than
+// maxDepth and lifting the kept descendants of filtered-out items.
+func pruneItemTree(nodes []*JobItemNode, maxDepth int32, keep map[string]bool) []*JobItemNode {
+	visible := make([]*JobItemNode, 0, len(nodes))
+	for _, node := range nodes {
+		if maxDepth > 0 && node.Depth > maxDepth {
+			continue
+		}
+		children := pruneItemTree(node.all, maxDepth, keep)
+		if len(keep) > 0 && !keep[strings.ToLower(node.CostMethod)] {
+			visible = append(visible, children...)
+			continue
+		}
+		node.Children = children
+		visible = append(visible, node)
+	}
+	return visible
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// Job errors, wrapped with the job number.
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobExists   = errors.New("job already exists")
+	ErrJobInUse    = errors.New("job has imported data")
+)
+
+// Postgres error codes for constraint violations.
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+)
+
+// JobFields is a job's editable contract metadata. Dates are YYYY-MM-DD and
+// empty when unset; a null contract_value falls back to the summed SOV.
+type JobFields struct {
+	JobName              string              `json:"job_name"`
+	Status               string              `json:"status"`
+	ContractValue        decimal.NullDecimal `json:"contract_value"`
+	Address              string              `json:"address"`
+	ScrNumber            string              `json:"scr_number"`
+	ContractCompleteDate string              `json:"contract_complete_date"`
+	StartDate            string              `json:"start_date"`
+	EndDate              string              `json:"end_date"`
+}
+
+// JobDetails is a job with all of its contract metadata.
+type JobDetails struct {
+	ID        uuid.UUID `json:"id"`
+	JobNumber string    `json:"job_number"`
+	JobFields
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ValidateJobNumber checks a job number fits the jobs table.
+func ValidateJobNumber(jobNumber string) error {
+	if strings.TrimSpace(jobNumber) == "" {
+		return fmt.Errorf("job number is required")
+	}
+	if len(jobNumber) > 50 {
+		return fmt.Errorf("job number must be 50 characters or fewer")
+	}
+	return nil
+}
+
+// ValidateJobFields checks the name, status, amount and dates of a job.
+func ValidateJobFields(f JobFields) error {
+	if strings.TrimSpace(f.JobName) == "" {
+		return fmt.Errorf("job_name is required")
+	}
+	if !ValidJobStatus(f.Status) {
+		return fmt.Errorf("status %q must be active, complete or closed", f.Status)
+	}
+	if f.ContractValue.Valid && f.ContractValue.Decimal.IsNegative() {
+		return fmt.Errorf("contract_value must not be negative")
+	}
+	if len(f.ScrNumber) > 50 {
+		return fmt.Errorf("scr_number must be 50 characters or fewer")
+	}
+
+	dates := make(map[string]sql.NullTime)
+	for _, d := range []struct{ name, value string }{
+		{"start_date", f.StartDate},
+		{"end_date", f.EndDate},
+		{"contract_complete_date", f.ContractCompleteDate},
+	} {
+		t, err := parseJobDate(d.value)
+		if err != nil {
+			return fmt.Errorf("%s %q must be a date like 2006-01-02", d.name, d.value)
+		}
+		dates[d.name] = t
+	}
+
+	start := dates["start_date"]
+	for _, name := range []string{"end_date", "contract_complete_date"} {
+		if d := dates[name]; start.Valid && d.Valid && d.Time.Before(start.Time) {
+			return fmt.Errorf("%s must not be before start_date", name)
+		}
+	}
+	return nil
+}
+
+// GetJob returns a job with all of its contract metadata.
+func GetJob(ctx context.Context, q *database.Queries, jobNumber string) (*JobDetails, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, jobNumber)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+	return jobDetails(job), nil
+}
+
+// CreateJob adds a job. Fields must already pass ValidateJobFields.
+func CreateJob(ctx context.Context, q *database.Queries, jobNumber string, f JobFields) (*JobDetails, error) {
+	params, err := jobParams(jobNumber, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var job database.Job
+	err = q.InTx(ctx, func(q *database.Queries) error {
+		job, err = q.CreateJob(ctx, database.CreateJobParams(params))
+		if isPQError(err, pqUniqueViolation) {
+			return fmt.Errorf("%w: %s", ErrJobExists, jobNumber)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create job %s: %w", jobNumber, err)
+		}
+		if job.Status != JobActive {
+			return recordJobStatus(ctx, q, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobDetails(job), nil
+}
+
+// UpdateJob replaces a job's contract metadata. Fields must already pass
+// ValidateJobFields. A change of status is recorded for as-of reports.
+func UpdateJob(ctx context.Context, q *database.Queries, jobNumber string, f JobFields) (*JobDetails, error) {
+	params, err := jobParams(jobNumber, f)
+	if err != nil {
+		return nil, err
+	}
+
+	var job database.Job
+	err = q.InTx(ctx, func(q *database.Queries) error {
+		current, err := q.GetJobByNumber(ctx, jobNumber)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%w: %s", ErrJobNotFound, jobNumber)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+		}
+
+		job, err = q.UpdateJob(ctx, params)
+		if err != nil {
+			return fmt.Errorf("failed to update job %s: %w", jobNumber, err)
+		}
+		if job.Status != current.Status {
+			return recordJobStatus(ctx, q, job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobDetails(job), nil
+}
+
+// recordJobStatus adds the job's new status to its history.
+func recordJobStatus(ctx context.Context, q *database.Queries, job database.Job) error {
+	if err := q.InsertJobStatusChange(ctx, database.InsertJobStatusChangeParams{
+		JobID:  job.ID,
+		Status: job.Status,
+	}); err != nil {
+		return fmt.Errorf("failed to record status of job %s: %w", job.JobNumber, err)
+	}
+	return nil
+}
+
+// DeleteJob removes a job that has nothing imported against it. Jobs with
+// bid items, pay applications, ledger rows or other data are kept; close them
+// instead.
+func DeleteJob(ctx context.Context, q *database.Queries, jobNumber string) error {
+	if _, err := GetJob(ctx, q, jobNumber); err != nil {
+		return err
+	}
+
+	// Ledger rows aren't held by a foreign key, so check for them first
+	ledgerRows, err := q.CountJobLedgerRows(ctx, jobNumber)
+	if err != nil {
+		return fmt.Errorf("failed to check ledger for job %s: %w", jobNumber, err)
+	}
+	if ledgerRows > 0 {
+		return fmt.Errorf("%w: %s has %d ledger rows - set its status to closed instead", ErrJobInUse, jobNumber, ledgerRows)
+	}
+
+	err = q.DeleteJob(ctx, jobNumber)
+	if isPQError(err, pqForeignKeyViolation) {
+		return fmt.Errorf("%w: %s - set its status to closed instead", ErrJobInUse, jobNumber)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete job %s: %w", jobNumber, err)
+	}
+	return nil
+}
+
+func jobParams(jobNumber string, f JobFields) (database.UpdateJobParams, error) {
+	params := database.UpdateJobParams{
+		JobNumber: jobNumber,
+		JobName:   strings.TrimSpace(f.JobName),
+		Address:   toNullString(strings.TrimSpace(f.Address)),
+		ScrNumber: toNullString(strings.TrimSpace(f.ScrNumber)),
+		Status:    f.Status,
+	}
+	if f.ContractValue.Valid {
+		params.ContractValue = sql.NullString{String: f.ContractValue.Decimal.String(), Valid: true}
+	}
+
+	for _, d := range []struct {
+		dst   *sql.NullTime
+		name  string
+		value string
+	}{
+		{&params.StartDate, "start_date", f.StartDate},
+		{&params.EndDate, "end_date", f.EndDate},
+		{&params.ContractCompleteDate, "contract_complete_date", f.ContractCompleteDate},
+	} {
+		t, err := parseJobDate(d.value)
+		if err != nil {
+			return params, fmt.Errorf("invalid %s: %w", d.name, err)
+		}
+		*d.dst = t
+	}
+	return params, nil
+}
+
+func jobDetails(job database.Job) *JobDetails {
+	d := &JobDetails{
+		ID:        job.ID,
+		JobNumber: job.JobNumber,
+		JobFields: JobFields{
+			JobName:              job.JobName,
+			Status:               job.Status,
+			Address:              job.Address.String,
+			ScrNumber:            job.ScrNumber.String,
+			ContractCompleteDate: formatJobDate(job.ContractCompleteDate),
+			StartDate:            formatJobDate(job.StartDate),
+			EndDate:              formatJobDate(job.EndDate),
+		},
+		CreatedAt: job.CreatedAt.Time,
+		UpdatedAt: job.UpdatedAt.Time,
+	}
+	if job.ContractValue.Valid {
+		if v, err := decimal.NewFromString(job.ContractValue.String); err == nil {
+			d.ContractValue = decimal.NewNullDecimal(v)
+		}
+	}
+	return d
+}
+
+func parseJobDate(s string) (sql.NullTime, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+func formatJobDate(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format("2006-01-02")
+}
+
+func isPQError(err error, code pq.ErrorCode) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == code
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// Ledger search defaults and page sizes.
+const (
+	DefaultLedgerSort  = "transaction_date"
+	DefaultLedgerLimit = 100
+	MaxLedgerLimit     = 1000
+)
+
+// ErrInvalidLedgerSearch is returned, wrapped with the reason, for a sort,
+// grouping or cursor the ledger search can't use.
+var ErrInvalidLedgerSearch = errors.New("invalid ledger search")
+
+// LedgerEntry is one job cost ledger transaction.
+type LedgerEntry struct {
+	ID              string          `json:"id" report:"-"`
+	TransactionDate string          `json:"transaction_date" report:"Date"`
+	Phase           string          `json:"phase" report:"Phase"`
+	Cat             string          `json:"cat" report:"Cat"`
+	TransactionType string          `json:"transaction_type" report:"Type"`
+	Amount          decimal.Decimal `json:"amount" report:"Amount"`
+	Hours           decimal.Decimal `json:"hours" report:"Hours"`
+	Units           decimal.Decimal `json:"units" report:"Units"`
+}
+
+// LedgerSubtotal totals the entries in one group of a ledger search.
+type LedgerSubtotal struct {
+	Group   string          `json:"group" report:"Group"`
+	Entries int             `json:"entries" report:"Entries"`
+	Amount  decimal.Decimal `json:"amount" report:"Amount"`
+	Hours   decimal.Decimal `json:"hours" report:"Hours"`
+	Units   decimal.Decimal `json:"units" report:"Units"`
+}
+
+// LedgerFilter limits a ledger search. Empty fields match everything; dates
+// and amounts are inclusive. Class matches the transaction type's class, so
+// class=cost with a phase lists the rows behind that phase's actual cost.
+type LedgerFilter struct {
+	Phase           string
+	Cat             string
+	TransactionType string
+	Class           string
+	From            sql.NullTime
+	To              sql.NullTime
+	MinAmount       decimal.NullDecimal
+	MaxAmount       decimal.NullDecimal
+}
+
+// LedgerSearch is one page request of a job's ledger. SortBy names an entry's
+// JSON field ("" for DefaultLedgerSort); ties fall back to date, then ID.
+// GroupBy is phase, cat, transaction_type or month, or "" for no subtotals.
+// After continues from a previous page's cursor.
+type LedgerSearch struct {
+	LedgerFilter
+	SortBy     string
+	Descending bool
+	GroupBy    string
+	After      *LedgerCursor
+	Limit      int
+}
+
+// LedgerPage is one page of a ledger search. Matched, Total and Subtotals
+// cover every entry the filter matches, not just this page. NextCursor is
+// empty on the last page.
+type LedgerPage struct {
+	Entries    []LedgerEntry    `json:"entries"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+	Matched    int              `json:"matched"`
+	Total      LedgerSubtotal   `json:"total"`
+	GroupBy    string           `json:"group_by,omitempty"`
+	Subtotals  []LedgerSubtotal `json:"subtotals,omitempty"`
+}
+
+// LedgerCursor marks the position of a page's last entry in the sort order:
+// its sort value, date and ID as SearchJobCostLedger reports them. It carries
+// the sort it was issued for so a page can't be continued under a different
+// order.
+type LedgerCursor struct {
+	SortBy     string `json:"s"`
+	Descending bool   `json:"d"`
+	Text       string `json:"t"`
+	Number     string `json:"n"`
+	Date       string `json:"a"`
+	ID         string `json:"i"`
+}
+
+// ledgerSortKeys are the sortable fields, named by their JSON field. The
+// query orders by the same names.
+var ledgerSortKeys = map[string]bool{
+	"transaction_date": true,
+	"phase":            true,
+	"cat":              true,
+	"transaction_type": true,
+	"amount":           true,
+	"hours":            true,
+	"units":            true,
+}
+
+// ledgerGroupKeys are the groupings the totals query can subtotal by.
+var ledgerGroupKeys = map[string]bool{
+	"phase":            true,
+	"cat":              true,
+	"transaction_type": true,
+	"month":            true,
+}
+
+// ValidLedgerSort reports whether key names a sortable ledger field.
+func ValidLedgerSort(key string) bool {
+	return ledgerSortKeys[key]
+}
+
+// ValidLedgerGroup reports whether key names a ledger subtotal grouping.
+func ValidLedgerGroup(key string) bool {
+	return ledgerGroupKeys[key]
+}
+
+// ParseLedgerCursor decodes a cursor returned as a page's next_cursor.
+func ParseLedgerCursor(s string) (*LedgerCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	var c LedgerCursor
+	if err := json.Unmarshal(b, &c); err != nil || !ValidLedgerSort(c.SortBy) || c.ID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &c, nil
+}
+
+func (c LedgerCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// SearchLedger returns one page of a job's cost ledger entries matching the
+// filter, in the requested order, with totals over every match. Paging and
+// totals both run in the database, so only the page's entries are loaded.
+func SearchLedger(ctx context.Context, q *database.Queries, jobNumber string, s LedgerSearch) (*LedgerPage, error) {
+	if s.SortBy == "" {
+		s.SortBy = DefaultLedgerSort
+	}
+	if !ValidLedgerSort(s.SortBy) {
+		return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalidLedgerSearch, s.SortBy)
+	}
+	if s.GroupBy != "" && !ValidLedgerGroup(s.GroupBy) {
+		return nil, fmt.Errorf("%w: cannot group by %q", ErrInvalidLedgerSearch, s.GroupBy)
+	}
+	if s.After != nil && (s.After.SortBy != s.SortBy || s.After.Descending != s.Descending) {
+		return nil, fmt.Errorf("%w: cursor was issued for a different sort or order", ErrInvalidLedgerSearch)
+	}
+	if s.Limit <= 0 {
+		s.Limit = DefaultLedgerLimit
+	}
+	if s.Limit > MaxLedgerLimit {
+		s.Limit = MaxLedgerLimit
+	}
+
+	totalsParams := database.SearchJobCostLedgerTotalsParams{
+		GroupBy:         s.GroupBy,
+		Job:             jobNumber,
+		Phase:           toNullString(s.Phase),
+		Cat:             toNullString(s.Cat),
+		TransactionType: toNullString(s.TransactionType),
+		Class:           toNullString(s.Class),
+		FromDate:        s.From,
+		ToDate:          s.To,
+	}
+	if s.MinAmount.Valid {
+		totalsParams.MinAmount = sql.NullString{String: s.MinAmount.Decimal.String(), Valid: true}
+	}
+	if s.MaxAmount.Valid {
+		totalsParams.MaxAmount = sql.NullString{String: s.MaxAmount.Decimal.String(), Valid: true}
+	}
+
+	// One row past the page tells whether another page follows
+	params := database.SearchJobCostLedgerParams{
+		SortBy:          s.SortBy,
+		Job:             totalsParams.Job,
+		Phase:           totalsParams.Phase,
+		Cat:             totalsParams.Cat,
+		TransactionType: totalsParams.TransactionType,
+		Class:           totalsParams.Class,
+		FromDate:        totalsParams.FromDate,
+		ToDate:          totalsParams.ToDate,
+		MinAmount:       totalsParams.MinAmount,
+		MaxAmount:       totalsParams.MaxAmount,
+		Descending:      s.Descending,
+		RowLimit:        int32(s.Limit + 1),
+	}
+	if s.After != nil {
+		params.AfterID = sql.NullString{String: s.After.ID, Valid: true}
+		params.AfterText = sql.NullString{String: s.After.Text, Valid: true}
+		params.AfterNumber = sql.NullString{String: s.After.Number, Valid: true}
+		params.AfterDate = sql.NullString{String: s.After.Date, Valid: true}
+	}
+
+	rows, err := q.SearchJobCostLedger(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search ledger: %w", err)
+	}
+
+	page := &LedgerPage{
+		Entries: make([]LedgerEntry, 0, min(len(rows), s.Limit)),
+		Total:   LedgerSubtotal{Group: "Total"},
+		GroupBy: s.GroupBy,
+	}
+	for _, row := range rows[:min(len(rows), s.Limit)] {
+		e := LedgerEntry{
+			ID:              row.ID,
+			Phase:           row.Phase.String,
+			Cat:             row.Cat.String,
+			TransactionType: row.TransactionType.String,
+		}
+		if row.TransactionDate.Valid {
+			e.TransactionDate = row.TransactionDate.Time.Format("2006-01-02")
+		}
+		if err := parseDecimals(
+			decimalField{&e.Amount, row.Amount},
+			decimalField{&e.Hours, nullDecimalText(row.Hours)},
+			decimalField{&e.Units, nullDecimalText(row.Units)},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value on ledger entry %s: %w", row.ID, err)
+		}
+		page.Entries = append(page.Entries, e)
+	}
+	if len(rows) > s.Limit {
+		last := rows[s.Limit-1]
+		page.NextCursor = LedgerCursor{
+			SortBy:     s.SortBy,
+			Descending: s.Descending,
+			Text:       last.SortText,
+			Number:     last.SortNumber,
+			Date:       last.DateText,
+			ID:         last.ID,
+		}.String()
+	}
+
+	totals, err := q.SearchJobCostLedgerTotals(ctx, totalsParams)
+	if err != nil {
+		return nil, fmt.Errorf("failed to total ledger: %w", err)
+	}
+	for _, row := range totals {
+		st := LedgerSubtotal{Group: row.GroupKey, Entries: int(row.Entries)}
+		if err := parseDecimals(
+			decimalField{&st.Amount, row.Amount},
+			decimalField{&st.Hours, row.Hours},
+			decimalField{&st.Units, row.Units},
+		); err != nil {
+			return nil, fmt.Errorf("invalid ledger total: %w", err)
+		}
+		page.Total.add(st)
+		if s.GroupBy != "" {
+			page.Subtotals = append(page.Subtotals, st)
+		}
+	}
+	page.Matched = page.Total.Entries
+	return page, nil
+}
+
+func (t *LedgerSubtotal) add(o LedgerSubtotal) {
+	t.Entries += o.Entries
+	t.Amount = t.Amount.Add(o.Amount)
+	t.Hours = t.Hours.Add(o.Hours)
+	t.Units = t.Units.Add(o.Units)
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// Quantity overrun statuses.
+const (
+	OverrunExceeded    = "overrun"
+	OverrunApproaching = "approaching"
+)
+
+// DefaultOverrunThreshold is the percent of contract quantity at which a pay
+// item is flagged as approaching an overrun.
+var DefaultOverrunThreshold = decimal.NewFromInt(90)
+
+// QuantityOverrun is a unit-price pay item billed past, or close to, its
+// contract quantity. Contract quantity includes approved change orders, so an
+// overrun is quantity no change order covers yet.
+type QuantityOverrun struct {
+	ItemNumber      string          `json:"item_number" report:"Item"`
+	Description     string          `json:"description" report:"Description"`
+	Unit            string          `json:"unit" report:"Unit"`
+	Status          string          `json:"status" report:"Status"`
+	OriginalQty     decimal.Decimal `json:"original_qty" report:"Bid Qty"`
+	ContractQty     decimal.Decimal `json:"contract_qty" report:"Contract Qty"`
+	CumulativeQty   decimal.Decimal `json:"cumulative_qty" report:"Billed To Date"`
+	PercentComplete decimal.Decimal `json:"percent_complete" report:"Pct Complete"`
+	OverrunQty      decimal.Decimal `json:"overrun_qty" report:"Overrun Qty"`
+	OverrunAmount   decimal.Decimal `json:"overrun_amount" report:"Overrun Amount"`
+	LastMonth       string          `json:"last_month" report:"Last Billed"`
+}
+
+// ValidateOverrunThreshold checks a threshold is a percentage.
+func ValidateOverrunThreshold(threshold decimal.Decimal) error {
+	if threshold.IsNegative() || threshold.GreaterThan(hundred) {
+		return fmt.Errorf("overrun threshold %s must be between 0 and 100", threshold)
+	}
+	return nil
+}
+
+// GetQuantityOverruns lists a job's unit-price pay items billed past their
+// contract quantity, or to at least threshold percent of it.
+func GetQuantityOverruns(ctx context.Context, q *database.Queries, jobNumber string, threshold decimal.Decimal) ([]QuantityOverrun, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+	return detectQuantityOverruns(ctx, q, job.ID, threshold)
+}
+
+// detectQuantityOverruns flags pay items whose quantity billed to date exceeds
+// contract quantity (including billing against a zero quantity) or reaches
+// threshold percent of it. Overruns are listed before items approaching one.
+func detectQuantityOverruns(ctx context.Context, q *database.Queries, jobID uuid.UUID, threshold decimal.Decimal) ([]QuantityOverrun, error) {
+	rows, err := q.GetPayItemQuantities(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pay item quantities: %w", err)
+	}
+
+	var exceeded, approaching []QuantityOverrun
+	for _, row := range rows {
+		var unitPrice decimal.Decimal
+		o := QuantityOverrun{
+			ItemNumber:  row.ItemNumber,
+			Description: row.Description,
+			Unit:        row.Unit.String,
+			LastMonth:   row.LastMonth.Format("2006-01"),
+		}
+		if err := parseDecimals(
+			decimalField{&o.OriginalQty, row.OriginalQty},
+			decimalField{&o.ContractQty, row.ContractQty},
+			decimalField{&o.CumulativeQty, row.CumulativeQty},
+			decimalField{&unitPrice, row.UnitPrice},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for item %s: %w", row.ItemNumber, err)
+		}
+
+		if !o.CumulativeQty.IsPositive() {
+			continue
+		}
+		if o.ContractQty.IsPositive() {
+			o.PercentComplete = o.CumulativeQty.Div(o.ContractQty).Mul(hundred).Round(2)
+		}
+
+		switch {
+		case o.CumulativeQty.GreaterThan(o.ContractQty):
+			o.Status = OverrunExceeded
+			o.OverrunQty = o.CumulativeQty.Sub(o.ContractQty)
+			o.OverrunAmount = o.OverrunQty.Mul(unitPrice).Round(2)
+			exceeded = append(exceeded, o)
+		case o.PercentComplete.GreaterThanOrEqual(threshold):
+			o.Status = OverrunApproaching
+			approaching = append(approaching, o)
+		}
+	}
+
+	overruns := make([]QuantityOverrun, 0, len(exceeded)+len(approaching))
+	overruns = append(overruns, exceeded...)
+	return append(overruns, approaching...), nil
+}
@@ -63,20 +63,44 @@ type parentItemInfo struct {
 }
 
 // ParsePayApp parses an Excel file and populates the database.
-// It reads the SOV sheet (first page) for job items and pay application data.
-func ParsePayApp(ctx context.Context, f *excelize.File, q *database.Queries, jobID uuid.UUID, targetDate time.Time) error {
+// It reads the SOV sheet (first page) for job items and pay application data,
+// then stores the month's result as a new revision, which becomes the
+// effective one. source names the submission, usually the file name.
+// A closed target month is rejected with ErrPeriodClosed. The import runs in
+// one transaction, holding the job's lock so the month can't close mid-way.
+func ParsePayApp(ctx context.Context, f *excelize.File, q *database.Queries, jobID uuid.UUID, targetDate time.Time, source string) (int32, error) {
 	targetMonth := time.Date(targetDate.Year(), targetDate.Month(), 1, 0, 0, 0, 0, time.UTC)
 
-	// Parse SOV sheet only
-	err := parseSOVSheet(ctx, f, q, jobID, targetMonth)
+	var revision int32
+	err := q.InTx(ctx, func(q *database.Queries) error {
+		if err := q.LockJob(ctx, jobID); err != nil {
+			return fmt.Errorf("locking job: %w", err)
+		}
+		if err := checkPeriodOpen(ctx, q, jobID, targetMonth); err != nil {
+			return err
+		}
+
+		// Parse SOV sheet only
+		if err := parseSOVSheet(ctx, f, q, jobID, targetMonth); err != nil {
+			return fmt.Errorf("parsing SOV sheet: %w", err)
+		}
+
+		var err error
+		revision, err = recordPayAppRevision(ctx, q, jobID, targetMonth, source)
+		if err != nil {
+			return fmt.Errorf("recording revision: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("parsing SOV sheet: %w", err)
+		return 0, err
 	}
 
-	return nil
+	return revision, nil
 }
 
 // parseDetailSheet parses the Detail sheet, imports job items and time series data.
+// Month columns for closed months other than the target are skipped.
 // Returns parent item info for matching with SOV sheet.
 func parseDetailSheet(ctx context.Context, f *excelize.File, q *database.Queries, jobID uuid.UUID, targetMonth time.Time) ([]parentItemInfo, error) {
 	sheetName := findDetailSheet(f)
@@ -99,6 +123,11 @@ func parseDetailSheet(ctx context.Context, f *excelize.File, q *database.Queries
 		return nil, fmt.Errorf("building month columns: %w", err)
 	}
 
+	closed, err := jobClosedMonths(ctx, q, jobID)
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := f.GetRows(sheetName)
 	if err != nil {
 		return nil, fmt.Errorf("reading rows: %w", err)
@@ -194,6 +223,11 @@ func parseDetailSheet(ctx context.Context, f *excelize.File, q *database.Queries
 
 		// Import time series data for ALL months
 		for _, mc := range monthCols {
+			// The target month was checked up front; closed history stays as reported
+			if !mc.Date.Equal(targetMonth) && closed[mc.Date.Format("2006-01")] {
+				continue
+			}
+
 			qtyVal := getColValue(row, mc.Qty)
 			if qtyVal == "" {
 				continue
@@ -258,6 +292,7 @@ func parseSOVSheet(ctx context.Context, f *excelize.File, q *database.Queries, j
 	colScheduledValue := -1
 	colThisPeriod := -1
 	colMaterials := -1
+	colInstalled := -1
 
 	for rowIdx, row := range rows {
 		if rowIdx > 10 {
@@ -277,6 +312,8 @@ func parseSOVSheet(ctx context.Context, f *excelize.File, q *database.Queries, j
 				colDesc = colIdx
 			} else if strings.Contains(cellLower, "scheduled") && colScheduledValue == -1 {
 				colScheduledValue = colIdx
+			} else if strings.Contains(cellLower, "installed") && colInstalled == -1 {
+				colInstalled = colIdx
 			} else if strings.Contains(cellLower, "this period") && colThisPeriod == -1 {
 				colThisPeriod = colIdx
 			} else if strings.Contains(cellLower, "materials") && colMaterials == -1 {
@@ -377,6 +414,20 @@ func parseSOVSheet(ctx context.Context, f *excelize.File, q *database.Queries, j
 		if err != nil {
 			return fmt.Errorf("upserting pay application for item %s: %w", itemNum, err)
 		}
+
+		installed := ""
+		if colInstalled >= 0 {
+			val, err := cleanNumeric(getColValue(row, colInstalled))
+			if err != nil {
+				return fmt.Errorf("SOV row %d materials installed: %w", rowIdx+1, err)
+			}
+			installed = val
+		}
+
+		err = recordMaterialsInstalled(ctx, q, jobItemID, targetMonth, installed)
+		if err != nil {
+			return fmt.Errorf("recording materials installed for item %s: %w", itemNum, err)
+		}
 	}
 
 	return nil
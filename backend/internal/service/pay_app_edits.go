@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// Pay application fields that can be edited.
+const (
+	PayAppFieldQty             = "qty"
+	PayAppFieldStoredMaterials = "stored_materials"
+)
+
+// ErrInvalidPayAppEdit is returned, wrapped with the reason, when an edit
+// would leave a pay application with impossible cumulative values.
+var ErrInvalidPayAppEdit = errors.New("invalid pay app edit")
+
+// PayAppItem is one item's line on a month's pay application. Amounts are
+// qty * unit price, or qty itself for lump-sum items (unit price 0). Contract
+// is the revised quantity, or the revised scheduled value for lump-sum items.
+type PayAppItem struct {
+	JobItemID          uuid.UUID       `json:"job_item_id" report:"-"`
+	ItemNumber         string          `json:"item_number" report:"Item"`
+	Description        string          `json:"description" report:"Description"`
+	Unit               string          `json:"unit" report:"Unit"`
+	PayItem            bool            `json:"pay_item" report:"Pay Item"`
+	UnitPrice          decimal.Decimal `json:"unit_price" report:"Unit Price"`
+	Contract           decimal.Decimal `json:"contract" report:"Contract"`
+	PreviousQty        decimal.Decimal `json:"previous_qty" report:"Previous Qty"`
+	ThisPeriodQty      decimal.Decimal `json:"this_period_qty" report:"This Period Qty"`
+	CumulativeQty      decimal.Decimal `json:"cumulative_qty" report:"Cumulative Qty"`
+	RemainingQty       decimal.Decimal `json:"remaining_qty" report:"Remaining Qty"`
+	PercentComplete    decimal.Decimal `json:"percent_complete" report:"Pct Complete"`
+	PreviousAmount     decimal.Decimal `json:"previous_amount" report:"Previous Amount"`
+	ThisPeriodAmount   decimal.Decimal `json:"this_period_amount" report:"This Period Amount"`
+	CumulativeAmount   decimal.Decimal `json:"cumulative_amount" report:"Cumulative Amount"`
+	StoredMaterials    decimal.Decimal `json:"stored_materials" report:"Stored Materials"`
+	CompletedAndStored decimal.Decimal `json:"completed_and_stored" report:"Completed And Stored"`
+}
+
+// PayApp is a job's pay application for a month as of its effective revision.
+type PayApp struct {
+	Month    string       `json:"month"`
+	Revision int32        `json:"revision"`
+	Items    []PayAppItem `json:"items"`
+}
+
+// PayAppItemEdit sets an item's this-period quantity and stored materials
+// balance. Fields left null keep their current value.
+type PayAppItemEdit struct {
+	JobItemID       uuid.UUID           `json:"job_item_id"`
+	Qty             decimal.NullDecimal `json:"qty"`
+	StoredMaterials decimal.NullDecimal `json:"stored_materials"`
+}
+
+// PayAppEdit is one changed value in a pay application's edit history.
+type PayAppEdit struct {
+	Revision    int32           `json:"revision" report:"Revision"`
+	ItemNumber  string          `json:"item_number" report:"Item"`
+	Description string          `json:"description" report:"Description"`
+	Field       string          `json:"field" report:"Field"`
+	OldValue    decimal.Decimal `json:"old_value" report:"Old Value"`
+	NewValue    decimal.Decimal `json:"new_value" report:"New Value"`
+	EditedBy    string          `json:"edited_by" report:"By"`
+	Reason      string          `json:"reason" report:"Reason"`
+	At          time.Time       `json:"at" report:"At"`
+}
+
+// GetPayApp returns a job's pay application for the month, item by item in
+// bid order. A month with nothing billed has no items.
+func GetPayApp(ctx context.Context, q *database.Queries, jobNumber string, month time.Time) (*PayApp, error) {
+	job, err := GetJob(ctx, q, jobNumber)
+	if err != nil {
+		return nil, err
+	}
+	return loadPayApp(ctx, q, job.ID, monthStart(month))
+}
+
+// EditPayApp changes this-period quantities and stored materials on a job's
+// pay application for the month, then stores the result as a new revision
+// and records each changed value against actor. An item of the job not yet
+// on the month's pay application is added, starting from nothing billed this
+// period and the stored materials balance it carried in. Parent items are
+// rejected: their quantities come from their children.
+//
+// Cumulative quantities are checked in this month and every later one: none
+// may go below zero, and lump-sum items may not bill past their scheduled
+// value. Unit-price items may run past contract quantity; those show up as
+// quantity overruns. A closed month is rejected with ErrPeriodClosed.
+// The checks and writes run in one transaction.
+func EditPayApp(ctx context.Context, q *database.Queries, jobNumber string, month time.Time, edits []PayAppItemEdit, actor, reason string) (*PayApp, error) {
+	if strings.TrimSpace(actor) == "" {
+		return nil, fmt.Errorf("actor is required to edit a pay application")
+	}
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("%w: no items to change", ErrInvalidPayAppEdit)
+	}
+
+	var result *PayApp
+	err := q.InTx(ctx, func(q *database.Queries) error {
+		var err error
+		result, err = editPayApp(ctx, q, jobNumber, month, edits, actor, reason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// editPayApp is EditPayApp within its transaction.
+func editPayApp(ctx context.Context, q *database.Queries, jobNumber string, month time.Time, edits []PayAppItemEdit, actor, reason string) (*PayApp, error) {
+	job, err := GetJob(ctx, q, jobNumber)
+	if err != nil {
+		return nil, err
+	}
+	month = monthStart(month)
+	if err := q.LockJob(ctx, job.ID); err != nil {
+		return nil, fmt.Errorf("failed to lock job %s: %w", jobNumber, err)
+	}
+	if err := checkPeriodOpen(ctx, q, job.ID, month); err != nil {
+		return nil, err
+	}
+
+	parentRows, err := q.GetParentItems(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch parent items: %w", err)
+	}
+	parents := make(map[uuid.UUID]bool, len(parentRows))
+	for _, p := range parentRows {
+		parents[p.ID] = true
+	}
+
+	current, err := loadPayApp(ctx, q, job.ID, month)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[uuid.UUID]PayAppItem, len(current.Items))
+	for _, item := range current.Items {
+		items[item.JobItemID] = item
+	}
+
+	later, err := q.GetLaterPayAppCumulative(ctx, database.GetLaterPayAppCumulativeParams{
+		JobID:       job.ID,
+		PayAppMonth: month,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch later pay applications: %w", err)
+	}
+	laterRanges := make(map[uuid.UUID]*cumulativeRange, len(later))
+	for _, row := range later {
+		var r cumulativeRange
+		if err := parseDecimals(
+			decimalField{&r.min, row.MinCumulativeQty},
+			decimalField{&r.max, row.MaxCumulativeQty},
+		); err != nil {
+			return nil, fmt.Errorf("invalid cumulative quantity: %w", err)
+		}
+		laterRanges[row.JobItemID] = &r
+	}
+
+	type change struct {
+		item        PayAppItem
+		qty, stored decimal.Decimal
+		fields      []database.InsertPayAppEditParams
+	}
+	var changes []change
+	seen := make(map[uuid.UUID]bool, len(edits))
+	for _, e := range edits {
+		item, ok := items[e.JobItemID]
+		if !ok {
+			item, err = unbilledPayAppItem(ctx, q, job.ID, month, e.JobItemID)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if parents[e.JobItemID] {
+			return nil, fmt.Errorf("%w: item %s has child items; edit those instead", ErrInvalidPayAppEdit, item.ItemNumber)
+		}
+		if seen[e.JobItemID] {
+			return nil, fmt.Errorf("%w: item %s is listed more than once", ErrInvalidPayAppEdit, item.ItemNumber)
+		}
+		seen[e.JobItemID] = true
+		if !e.Qty.Valid && !e.StoredMaterials.Valid {
+			return nil, fmt.Errorf("%w: item %s has no qty or stored_materials", ErrInvalidPayAppEdit, item.ItemNumber)
+		}
+
+		c := change{item: item, qty: item.ThisPeriodQty, stored: item.StoredMaterials}
+		if e.Qty.Valid {
+			c.qty = e.Qty.Decimal
+		}
+		if e.StoredMaterials.Valid {
+			c.stored = e.StoredMaterials.Decimal
+		}
+		if err := validatePayAppItemEdit(item, c.qty, c.stored, laterRanges[item.JobItemID]); err != nil {
+			return nil, err
+		}
+
+		for _, f := range []struct {
+			field    string
+			old, new decimal.Decimal
+		}{
+			{PayAppFieldQty, item.ThisPeriodQty, c.qty},
+			{PayAppFieldStoredMaterials, item.StoredMaterials, c.stored},
+		} {
+			if f.old.Equal(f.new) {
+				continue
+			}
+			c.fields = append(c.fields, database.InsertPayAppEditParams{
+				JobID:       job.ID,
+				JobItemID:   item.JobItemID,
+				PayAppMonth: month,
+				Field:       f.field,
+				OldValue:    f.old.String(),
+				NewValue:    f.new.String(),
+				EditedBy:    actor,
+				Reason:      toNullString(strings.TrimSpace(reason)),
+			})
+		}
+		if len(c.fields) > 0 {
+			changes = append(changes, c)
+		}
+	}
+
+	if len(changes) == 0 {
+		return current, nil
+	}
+
+	for _, c := range changes {
+		err := q.UpsertPayApplication(ctx, database.UpsertPayApplicationParams{
+			JobItemID:       c.item.JobItemID,
+			PayAppMonth:     month,
+			Qty:             c.qty.String(),
+			StoredMaterials: c.stored.String(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to update item %s: %w", c.item.ItemNumber, err)
+		}
+	}
+
+	revision, err := recordPayAppRevision(ctx, q, job.ID, month, "edited by "+actor)
+	if err != nil {
+		return nil, fmt.Errorf("recording revision: %w", err)
+	}
+
+	for _, c := range changes {
+		for _, params := range c.fields {
+			params.Revision = revision
+			if err := q.InsertPayAppEdit(ctx, params); err != nil {
+				return nil, fmt.Errorf("failed to record edit to item %s: %w", c.item.ItemNumber, err)
+			}
+		}
+	}
+
+	return loadPayApp(ctx, q, job.ID, month)
+}
+
+// GetPayAppEdits returns the edits made to a job's pay application for the
+// month, newest first.
+func GetPayAppEdits(ctx context.Context, q *database.Queries, jobNumber string, month time.Time) ([]PayAppEdit, error) {
+	job, err := GetJob(ctx, q, jobNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.GetPayAppEdits(ctx, database.GetPayAppEditsParams{
+		JobID:       job.ID,
+		PayAppMonth: monthStart(month),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pay app edits: %w", err)
+	}
+
+	edits := make([]PayAppEdit, 0, len(rows))
+	for _, row := range rows {
+		e := PayAppEdit{
+			Revision:    row.Revision,
+			ItemNumber:  row.ItemNumber,
+			Description: row.Description,
+			Field:       row.Field,
+			EditedBy:    row.EditedBy,
+			Reason:      row.Reason.String,
+			At:          row.CreatedAt.Time,
+		}
+		if err := parseDecimals(
+			decimalField{&e.OldValue, row.OldValue},
+			decimalField{&e.NewValue, row.NewValue},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for item %s: %w", row.ItemNumber, err)
+		}
+		edits = append(edits, e)
+	}
+	return edits, nil
+}
+
+// unbilledPayAppItem returns a job item missing from the month's pay
+// application as it would stand with a row of its own: nothing billed this
+// period and the stored materials balance carried in from earlier months.
+func unbilledPayAppItem(ctx context.Context, q *database.Queries, jobID uuid.UUID, month time.Time, jobItemID uuid.UUID) (PayAppItem, error) {
+	row, err := q.GetUnbilledPayAppItem(ctx, database.GetUnbilledPayAppItemParams{
+		JobID:       jobID,
+		PayAppMonth: month,
+		ID:          jobItemID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return PayAppItem{}, fmt.Errorf("%w: item %s is not on the job", ErrInvalidPayAppEdit, jobItemID)
+	}
+	if err != nil {
+		return PayAppItem{}, fmt.Errorf("failed to find item %s: %w", jobItemID, err)
+	}
+
+	var totalQty, scheduledValue decimal.Decimal
+	item := PayAppItem{
+		JobItemID:   row.JobItemID,
+		ItemNumber:  row.ItemNumber,
+		Description: row.Description,
+		Unit:        row.Unit.String,
+		PayItem:     row.IsPayItem,
+	}
+	if err := parseDecimals(
+		decimalField{&item.StoredMaterials, row.PreviousStoredMaterials},
+		decimalField{&item.PreviousQty, row.PreviousCumulativeQty},
+		decimalField{&item.UnitPrice, row.UnitPrice},
+		decimalField{&totalQty, row.TotalQty},
+		decimalField{&scheduledValue, row.ScheduledValue},
+	); err != nil {
+		return PayAppItem{}, fmt.Errorf("invalid value for item %s: %w", row.ItemNumber, err)
+	}
+	item.setTotals(totalQty, scheduledValue)
+	return item, nil
+}
+
+// cumulativeRange is the lowest and highest cumulative quantity an item
+// reaches across a run of months.
+type cumulativeRange struct {
+	min, max decimal.Decimal
+}
+
+// validatePayAppItemEdit checks an item's new this-period quantity and stored
+// materials against its previous cumulative quantity and, when it is billed
+// in later months, the range its cumulative quantity covers there.
+func validatePayAppItemEdit(item PayAppItem, qty, stored decimal.Decimal, later *cumulativeRange) error {
+	if stored.IsNegative() {
+		return fmt.Errorf("%w: item %s stored materials must not be negative", ErrInvalidPayAppEdit, item.ItemNumber)
+	}
+
+	delta := qty.Sub(item.ThisPeriodQty)
+	cumulative := item.PreviousQty.Add(qty)
+	lowest, highest := cumulative, cumulative
+	if later != nil {
+		lowest = decimal.Min(lowest, later.min.Add(delta))
+		highest = decimal.Max(highest, later.max.Add(delta))
+	}
+
+	if lowest.IsNegative() {
+		return fmt.Errorf("%w: item %s cumulative quantity would fall to %s", ErrInvalidPayAppEdit, item.ItemNumber, lowest)
+	}
+	if item.UnitPrice.IsZero() && highest.GreaterThan(item.Contract) {
+		return fmt.Errorf("%w: item %s would bill %s of its %s scheduled value", ErrInvalidPayAppEdit, item.ItemNumber, highest, item.Contract)
+	}
+	return nil
+}
+
+// loadPayApp reads a job's pay application rows for the month and works out
+// each item's cumulative amounts.
+func loadPayApp(ctx context.Context, q *database.Queries, jobID uuid.UUID, month time.Time) (*PayApp, error) {
+	next, err := q.GetNextPayAppRevision(ctx, database.GetNextPayAppRevisionParams{
+		JobID:       jobID,
+		PayAppMonth: month,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find effective revision: %w", err)
+	}
+
+	rows, err := q.GetPayAppItems(ctx, database.GetPayAppItemsParams{
+		JobID:       jobID,
+		PayAppMonth: month,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pay application: %w", err)
+	}
+
+	app := &PayApp{
+		Month:    month.Format("2006-01"),
+		Revision: next - 1,
+		Items:    make([]PayAppItem, 0, len(rows)),
+	}
+	for _, row := range rows {
+		var totalQty, scheduledValue decimal.Decimal
+		item := PayAppItem{
+			JobItemID:   row.JobItemID,
+			ItemNumber:  row.ItemNumber,
+			Description: row.Description,
+			Unit:        row.Unit.String,
+			PayItem:     row.IsPayItem,
+		}
+		if err := parseDecimals(
+			decimalField{&item.ThisPeriodQty, row.ThisMonthQty},
+			decimalField{&item.StoredMaterials, row.StoredMaterials},
+			decimalField{&item.PreviousQty, row.PreviousCumulativeQty},
+			decimalField{&item.UnitPrice, row.UnitPrice},
+			decimalField{&totalQty, row.TotalQty},
+			decimalField{&scheduledValue, row.ScheduledValue},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for item %s: %w", row.ItemNumber, err)
+		}
+
+		item.setTotals(totalQty, scheduledValue)
+		app.Items = append(app.Items, item)
+	}
+	return app, nil
+}
+
+// setTotals fills in the contract and cumulative figures from the item's
+// quantities, unit price and stored materials.
+func (item *PayAppItem) setTotals(totalQty, scheduledValue decimal.Decimal) {
+	item.Contract = totalQty
+	if item.UnitPrice.IsZero() {
+		item.Contract = scheduledValue
+	}
+	item.CumulativeQty = item.PreviousQty.Add(item.ThisPeriodQty)
+	item.RemainingQty = item.Contract.Sub(item.CumulativeQty)
+	if item.Contract.IsPositive() {
+		item.PercentComplete = item.CumulativeQty.Div(item.Contract).Mul(hundred).Round(2)
+	}
+	item.PreviousAmount = payAppAmount(item.PreviousQty, item.UnitPrice)
+	item.ThisPeriodAmount = payAppAmount(item.ThisPeriodQty, item.UnitPrice)
+	item.CumulativeAmount = payAppAmount(item.CumulativeQty, item.UnitPrice)
+	item.CompletedAndStored = item.CumulativeAmount.Add(item.StoredMaterials)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// PayAppRevision is one submission of a job's pay application for a month.
+// Dollar totals come from top-level pay items only.
+type PayAppRevision struct {
+	Revision        int32           `json:"revision" report:"Revision"`
+	Source          string          `json:"source" report:"Source"`
+	CreatedAt       time.Time       `json:"created_at" report:"Uploaded"`
+	Effective       bool            `json:"effective" report:"Effective"`
+	Items           int64           `json:"items" report:"Items"`
+	WorkThisPeriod  decimal.Decimal `json:"work_this_period" report:"Work This Period"`
+	StoredMaterials decimal.Decimal `json:"stored_materials" report:"Stored Materials"`
+}
+
+// PayAppRevisionChange is one item's values in two revisions of a pay
+// application. Amount is qty * unit price, or qty itself for lump-sum items
+// (unit price 0), which carry the SOV "THIS PERIOD" dollars in qty.
+type PayAppRevisionChange struct {
+	ItemNumber            string          `json:"item_number" report:"Item"`
+	Description           string          `json:"description" report:"Description"`
+	PayItem               bool            `json:"pay_item" report:"Pay Item"`
+	FromQty               decimal.Decimal `json:"from_qty" report:"From Qty"`
+	ToQty                 decimal.Decimal `json:"to_qty" report:"To Qty"`
+	QtyChange             decimal.Decimal `json:"qty_change" report:"Qty Change"`
+	FromAmount            decimal.Decimal `json:"from_amount" report:"From Amount"`
+	ToAmount              decimal.Decimal `json:"to_amount" report:"To Amount"`
+	AmountChange          decimal.Decimal `json:"amount_change" report:"Amount Change"`
+	FromStoredMaterials   decimal.Decimal `json:"from_stored_materials" report:"From Stored Materials"`
+	ToStoredMaterials     decimal.Decimal `json:"to_stored_materials" report:"To Stored Materials"`
+	StoredMaterialsChange decimal.Decimal `json:"stored_materials_change" report:"Stored Materials Change"`
+}
+
+// PayAppRevisionDiff compares two revisions of a month's pay application.
+type PayAppRevisionDiff struct {
+	Month        string                 `json:"month"`
+	FromRevision int32                  `json:"from_revision"`
+	ToRevision   int32                  `json:"to_revision"`
+	Changes      []PayAppRevisionChange `json:"changes"`
+}
+
+// recordPayAppRevision snapshots the job's effective pay application rows for
+// the month as the next revision and returns its number.
+func recordPayAppRevision(ctx context.Context, q *database.Queries, jobID uuid.UUID, month time.Time, source string) (int32, error) {
+	revision, err := q.GetNextPayAppRevision(ctx, database.GetNextPayAppRevisionParams{
+		JobID:       jobID,
+		PayAppMonth: month,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next revision: %w", err)
+	}
+
+	revisionID, err := q.CreatePayAppRevision(ctx, database.CreatePayAppRevisionParams{
+		JobID:       jobID,
+		PayAppMonth: month,
+		Revision:    revision,
+		Source:      toNullString(source),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to create revision: %w", err)
+	}
+
+	err = q.SnapshotPayAppRevisionItems(ctx, database.SnapshotPayAppRevisionItemsParams{
+		RevisionID:  revisionID,
+		JobID:       jobID,
+		PayAppMonth: month,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to snapshot revision items: %w", err)
+	}
+	return revision, nil
+}
+
+// GetPayAppRevisions lists every revision of a job's pay application for the
+// month, newest (effective) first.
+func GetPayAppRevisions(ctx context.Context, q *database.Queries, jobNumber string, month time.Time) ([]PayAppRevision, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	rows, err := q.GetPayAppRevisions(ctx, database.GetPayAppRevisionsParams{
+		JobID:       job.ID,
+		PayAppMonth: monthStart(month),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pay app revisions: %w", err)
+	}
+
+	revisions := make([]PayAppRevision, 0, len(rows))
+	for i, row := range rows {
+		rev := PayAppRevision{
+			Revision:  row.Revision,
+			Source:    row.Source.String,
+			CreatedAt: row.CreatedAt.Time,
+			Effective: i == 0,
+			Items:     row.ItemCount,
+		}
+		if err := parseDecimals(
+			decimalField{&rev.WorkThisPeriod, row.WorkThisPeriod},
+			decimalField{&rev.StoredMaterials, row.StoredMaterials},
+		); err != nil {
+			return nil, fmt.Errorf("invalid revision %d total: %w", row.Revision, err)
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// DiffPayAppRevisions compares two revisions of a job's pay application for
+// the month item by item. toRevision 0 means the effective revision and
+// fromRevision 0 the one before it. Unchanged items are left out unless all
+// is set.
+func DiffPayAppRevisions(ctx context.Context, q *database.Queries, jobNumber string, month time.Time, fromRevision, toRevision int32, all bool) (*PayAppRevisionDiff, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+	month = monthStart(month)
+
+	next, err := q.GetNextPayAppRevision(ctx, database.GetNextPayAppRevisionParams{
+		JobID:       job.ID,
+		PayAppMonth: month,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find effective revision: %w", err)
+	}
+	latest := next - 1
+	if toRevision == 0 {
+		toRevision = latest
+	}
+	if fromRevision == 0 {
+		fromRevision = toRevision - 1
+	}
+	for _, r := range []int32{fromRevision, toRevision} {
+		if r < 1 || r > latest {
+			return nil, fmt.Errorf("revision %d does not exist for %s; job %s has %d", r, month.Format("January 2006"), jobNumber, latest)
+		}
+	}
+
+	rows, err := q.GetPayAppRevisionDiff(ctx, database.GetPayAppRevisionDiffParams{
+		JobID:        job.ID,
+		PayAppMonth:  month,
+		FromRevision: fromRevision,
+		ToRevision:   toRevision,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revision diff: %w", err)
+	}
+
+	diff := &PayAppRevisionDiff{
+		Month:        month.Format("2006-01"),
+		FromRevision: fromRevision,
+		ToRevision:   toRevision,
+		Changes:      make([]PayAppRevisionChange, 0),
+	}
+	for _, row := range rows {
+		var unitPrice decimal.Decimal
+		c := PayAppRevisionChange{
+			ItemNumber:  row.ItemNumber,
+			Description: row.Description,
+			PayItem:     row.IsPayItem,
+		}
+		if err := parseDecimals(
+			decimalField{&unitPrice, row.UnitPrice},
+			decimalField{&c.FromQty, row.FromQty},
+			decimalField{&c.ToQty, row.ToQty},
+			decimalField{&c.FromStoredMaterials, row.FromStoredMaterials},
+			decimalField{&c.ToStoredMaterials, row.ToStoredMaterials},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for item %s: %w", row.ItemNumber, err)
+		}
+
+		c.QtyChange = c.ToQty.Sub(c.FromQty)
+		c.StoredMaterialsChange = c.ToStoredMaterials.Sub(c.FromStoredMaterials)
+		if !all && c.QtyChange.IsZero() && c.StoredMaterialsChange.IsZero() {
+			continue
+		}
+
+		c.FromAmount = payAppAmount(c.FromQty, unitPrice)
+		c.ToAmount = payAppAmount(c.ToQty, unitPrice)
+		c.AmountChange = c.ToAmount.Sub(c.FromAmount)
+		diff.Changes = append(diff.Changes, c)
+	}
+	return diff, nil
+}
+
+// payAppAmount is the dollars billed for qty of an item; lump-sum items
+// (unit price 0) carry dollars in qty.
+func payAppAmount(qty, unitPrice decimal.Decimal) decimal.Decimal {
+	if unitPrice.IsZero() {
+		return qty
+	}
+	return qty.Mul(unitPrice).Round(2)
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Period limits a job report to the months From through To, computed from the
+// data as it stood at AsOf. Ledger rows imported after AsOf are left out and
+// each pay app month is read as the revision submitted by then, so an old
+// report can be regenerated exactly. Unset fields leave that side unbounded.
+type Period struct {
+	From sql.NullTime
+	To   sql.NullTime
+	AsOf sql.NullTime
+}
+
+// Validate rejects a range that ends before it starts.
+func (p Period) Validate() error {
+	if p.From.Valid && p.To.Valid && p.To.Time.Before(p.From.Time) {
+		return fmt.Errorf("to (%s) is before from (%s)", p.To.Time.Format("2006-01"), p.From.Time.Format("2006-01"))
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+)
+
+// Period close audit actions.
+const (
+	PeriodActionClose  = "close"
+	PeriodActionReopen = "reopen"
+)
+
+// ErrPeriodClosed is returned, wrapped with the job and month, when an import
+// or edit would change a closed month.
+var ErrPeriodClosed = errors.New("period is closed")
+
+// ErrPeriodOpen is returned when reopening a month that isn't closed.
+var ErrPeriodOpen = errors.New("period is not closed")
+
+// ClosedPeriod is a month closed on a job.
+type ClosedPeriod struct {
+	Month    string    `json:"month"`
+	ClosedBy string    `json:"closed_by"`
+	ClosedAt time.Time `json:"closed_at"`
+}
+
+// PeriodCloseEvent is one close or reopen in a job's audit trail.
+type PeriodCloseEvent struct {
+	Month  string    `json:"month" report:"Month"`
+	Action string    `json:"action" report:"Action"`
+	Actor  string    `json:"actor" report:"By"`
+	Reason string    `json:"reason" report:"Reason"`
+	At     time.Time `json:"at" report:"At"`
+}
+
+// ClosePeriod closes a job's month so pay applications, stored materials,
+// retainage releases and ledger costs dated in it can no longer change. The
+// close and its audit event are written in one transaction.
+func ClosePeriod(ctx context.Context, q *database.Queries, jobNumber string, month time.Time, actor, reason string) error {
+	if strings.TrimSpace(actor) == "" {
+		return fmt.Errorf("actor is required to close a period")
+	}
+
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	month = monthStart(month)
+	return q.InTx(ctx, func(q *database.Queries) error {
+		if err := q.LockJob(ctx, job.ID); err != nil {
+			return fmt.Errorf("failed to lock job %s: %w", jobNumber, err)
+		}
+		if err := checkPeriodOpen(ctx, q, job.ID, month); err != nil {
+			return err
+		}
+
+		err := q.InsertPeriodClose(ctx, database.InsertPeriodCloseParams{
+			JobID:       job.ID,
+			PeriodMonth: month,
+			ClosedBy:    actor,
+		})
+		if isPQError(err, pqUniqueViolation) {
+			// Closed by someone else since the check
+			return fmt.Errorf("%w: %s is already closed", ErrPeriodClosed, month.Format("January 2006"))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to close period: %w", err)
+		}
+
+		return recordPeriodEvent(ctx, q, job.ID, month, PeriodActionClose, actor, reason)
+	})
+}
+
+// ReopenPeriod reopens a closed month. A reason is required so the audit
+// trail explains why a billed period changed; the reopen and its audit event
+// are written in one transaction.
+func ReopenPeriod(ctx context.Context, q *database.Queries, jobNumber string, month time.Time, actor, reason string) error {
+	if strings.TrimSpace(actor) == "" {
+		return fmt.Errorf("actor is required to reopen a period")
+	}
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("reason is required to reopen a period")
+	}
+
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	month = monthStart(month)
+	return q.InTx(ctx, func(q *database.Queries) error {
+		// The delete locks the close row, so of two reopens only one finds it
+		deleted, err := q.DeletePeriodClose(ctx, database.DeletePeriodCloseParams{
+			JobID:       job.ID,
+			PeriodMonth: month,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to reopen period: %w", err)
+		}
+		if deleted == 0 {
+			return fmt.Errorf("%w: %s on job %s", ErrPeriodOpen, month.Format("January 2006"), jobNumber)
+		}
+
+		return recordPeriodEvent(ctx, q, job.ID, month, PeriodActionReopen, actor, reason)
+	})
+}
+
+// GetClosedPeriods lists a job's closed months, oldest first.
+func GetClosedPeriods(ctx context.Context, q *database.Queries, jobNumber string) ([]ClosedPeriod, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	rows, err := q.GetPeriodCloses(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch closed periods: %w", err)
+	}
+
+	periods := make([]ClosedPeriod, 0, len(rows))
+	for _, row := range rows {
+		periods = append(periods, ClosedPeriod{
+			Month:    row.PeriodMonth.Format("2006-01"),
+			ClosedBy: row.ClosedBy,
+			ClosedAt: row.ClosedAt.Time,
+		})
+	}
+	return periods, nil
+}
+
+// GetPeriodCloseEvents returns a job's close and reopen history, newest first.
+func GetPeriodCloseEvents(ctx context.Context, q *database.Queries, jobNumber string) ([]PeriodCloseEvent, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	rows, err := q.GetPeriodCloseEvents(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch period history: %w", err)
+	}
+
+	events := make([]PeriodCloseEvent, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, PeriodCloseEvent{
+			Month:  row.PeriodMonth.Format("2006-01"),
+			Action: row.Action,
+			Actor:  row.Actor,
+			Reason: row.Reason.String,
+			At:     row.CreatedAt.Time,
+		})
+	}
+	return events, nil
+}
+
+func recordPeriodEvent(ctx context.Context, q *database.Queries, jobID uuid.UUID, month time.Time, action, actor, reason string) error {
+	err := q.InsertPeriodCloseEvent(ctx, database.InsertPeriodCloseEventParams{
+		JobID:       jobID,
+		PeriodMonth: month,
+		Action:      action,
+		Actor:       actor,
+		Reason:      toNullString(strings.TrimSpace(reason)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record period %s: %w", action, err)
+	}
+	return nil
+}
+
+// checkPeriodOpen returns ErrPeriodClosed when the job's month is closed.
+func checkPeriodOpen(ctx context.Context, q *database.Queries, jobID uuid.UUID, month time.Time) error {
+	month = monthStart(month)
+	_, err := q.GetPeriodClose(ctx, database.GetPeriodCloseParams{JobID: jobID, PeriodMonth: month})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check period: %w", err)
+	}
+	return fmt.Errorf("%w: %s must be reopened before it can change", ErrPeriodClosed, month.Format("January 2006"))
+}
+
+// closedPeriods holds every closed month by job number, for imports that
+// span jobs.
+type closedPeriods map[string]map[string]bool
+
+func loadClosedPeriods(ctx context.Context, q *database.Queries) (closedPeriods, error) {
+	rows, err := q.GetAllPeriodCloses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch closed periods: %w", err)
+	}
+
+	closed := make(closedPeriods)
+	for _, row := range rows {
+		if closed[row.JobNumber] == nil {
+			closed[row.JobNumber] = make(map[string]bool)
+		}
+		closed[row.JobNumber][row.PeriodMonth.Format("2006-01")] = true
+	}
+	return closed, nil
+}
+
+func (c closedPeriods) has(jobNumber string, date time.Time) bool {
+	return c[jobNumber][date.Format("2006-01")]
+}
+
+// jobClosedMonths returns one job's closed months, keyed by "2006-01".
+func jobClosedMonths(ctx context.Context, q *database.Queries, jobID uuid.UUID) (map[string]bool, error) {
+	rows, err := q.GetPeriodCloses(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch closed periods: %w", err)
+	}
+
+	closed := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		closed[row.PeriodMonth.Format("2006-01")] = true
+	}
+	return closed, nil
+}
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
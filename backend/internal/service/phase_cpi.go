@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// PhaseCPI is the cost performance of one cost code to date, with its
+// cumulative monthly history.
+type PhaseCPI struct {
+	Phase           string          `json:"phase" report:"Phase"`
+	Description     string          `json:"description" report:"Description"`
+	Budget          decimal.Decimal `json:"budget" report:"Budget"`
+	PercentComplete decimal.Decimal `json:"percent_complete" report:"Percent Complete"`
+	EarnedValue     decimal.Decimal `json:"earned_value" report:"Earned Value"`
+	ActualCost      decimal.Decimal `json:"actual_cost" report:"Actual Cost"`
+	CostVariance    decimal.Decimal `json:"cost_variance" report:"Cost Variance"`
+	CPI             decimal.Decimal `json:"cpi" report:"CPI"`
+	Months          []PhaseCPIMonth `json:"months" report:"-"`
+}
+
+// PhaseCPIMonth holds a cost code's cumulative EV, AC and CPI through a month.
+type PhaseCPIMonth struct {
+	Month       string          `json:"month"`
+	EarnedValue decimal.Decimal `json:"earned_value"`
+	ActualCost  decimal.Decimal `json:"actual_cost"`
+	CPI         decimal.Decimal `json:"cpi"`
+}
+
+// GetPhaseCPI computes monthly CPI per cost code and ranks the phases worst
+// first. Earned value for a phase is the budget of the items under its
+// job_cost_id times their billed percent complete; actual cost is the ledger
+// cost booked to the same phase, including phases with no items. Phases with
+// no cost yet have no CPI and sort last. The period limits the months returned
+// and the data seen; to date figures are as of its last month.
+func GetPhaseCPI(ctx context.Context, q *database.Queries, jobNumber string, period Period) ([]PhaseCPI, error) {
+	rows, err := q.GetPhaseEarnedValue(ctx, database.GetPhaseEarnedValueParams{
+		JobNumber: jobNumber,
+		AsOf:      period.AsOf,
+		FromMonth: period.From,
+		ToMonth:   period.To,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching phase earned value: %w", err)
+	}
+
+	phases := make([]PhaseCPI, 0)
+	index := make(map[string]int)
+	for _, row := range rows {
+		idx, ok := index[row.Phase]
+		if !ok {
+			idx = len(phases)
+			index[row.Phase] = idx
+			phases = append(phases, PhaseCPI{Phase: row.Phase, Description: row.Description})
+		}
+		p := &phases[idx]
+
+		var m PhaseCPIMonth
+		m.Month = row.Month.Format("2006-01")
+		if err := parseDecimals(
+			decimalField{&p.Budget, row.Budget},
+			decimalField{&m.EarnedValue, row.EarnedValue},
+			decimalField{&m.ActualCost, row.ActualCost},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for phase %s: %w", row.Phase, err)
+		}
+		m.CPI = ratio(m.EarnedValue, m.ActualCost)
+		p.Months = append(p.Months, m)
+
+		// Rows arrive in month order, so the last one is to date
+		p.EarnedValue = m.EarnedValue
+		p.ActualCost = m.ActualCost
+	}
+
+	for i := range phases {
+		p := &phases[i]
+		p.CostVariance = p.EarnedValue.Sub(p.ActualCost)
+		p.CPI = ratio(p.EarnedValue, p.ActualCost)
+		if p.Budget.IsPositive() {
+			p.PercentComplete = p.EarnedValue.Div(p.Budget).Mul(hundred).Round(2)
+		}
+	}
+
+	sort.SliceStable(phases, func(i, j int) bool {
+		a, b := phases[i], phases[j]
+		aCost, bCost := a.ActualCost.IsPositive(), b.ActualCost.IsPositive()
+		if aCost != bCost {
+			return aCost
+		}
+		if !aCost {
+			return a.Phase < b.Phase
+		}
+		if !a.CPI.Equal(b.CPI) {
+			return a.CPI.LessThan(b.CPI)
+		}
+		return a.CostVariance.LessThan(b.CostVariance)
+	})
+
+	return phases, nil
+}
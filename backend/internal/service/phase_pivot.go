@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/lostboys08/ksc-go/backend/internal/report"
+	"github.com/shopspring/decimal"
+)
+
+// PhaseCostPivot is a phase-by-month matrix of actual cost for one job.
+// Costs[i] on every row lines up with Months[i].
+type PhaseCostPivot struct {
+	JobNumber string               `json:"job_number"`
+	Months    []string             `json:"months"`
+	Rows      []PhaseCostPivotRow  `json:"rows"`
+	Totals    PhaseCostPivotTotals `json:"totals"`
+}
+
+// PhaseCostPivotRow holds one phase's budgets and monthly actual cost.
+type PhaseCostPivotRow struct {
+	Phase          string              `json:"phase"`
+	Description    string              `json:"description"`
+	EstimateBudget decimal.Decimal     `json:"estimate_budget"` // original budget and budget revision ledger rows
+	ItemBudget     decimal.Decimal     `json:"item_budget"`     // job_items.budget by job_cost_id
+	Costs          []decimal.Decimal   `json:"costs"`
+	Total          decimal.Decimal     `json:"total"`
+	Cats           []PhaseCostPivotCat `json:"cats,omitempty"`
+}
+
+// PhaseCostPivotCat breaks a phase row down by ledger cat.
+type PhaseCostPivotCat struct {
+	Cat   string            `json:"cat"`
+	Costs []decimal.Decimal `json:"costs"`
+	Total decimal.Decimal   `json:"total"`
+}
+
+// PhaseCostPivotTotals is the column totals row.
+type PhaseCostPivotTotals struct {
+	EstimateBudget decimal.Decimal   `json:"estimate_budget"`
+	ItemBudget     decimal.Decimal   `json:"item_budget"`
+	Costs          []decimal.Decimal `json:"costs"`
+	Total          decimal.Decimal   `json:"total"`
+}
+
+// GetPhaseCostPivot builds the phase-by-month cost pivot for a job. Months run
+// continuously from the first to the last month with cost. When byCat is set,
+// each phase row carries a breakdown by ledger cat. The period limits the
+// months and the data seen; estimate budgets are those dated by its last month.
+func GetPhaseCostPivot(ctx context.Context, q *database.Queries, jobNumber string, byCat bool, period Period) (*PhaseCostPivot, error) {
+	costs, err := q.GetPhaseMonthlyCosts(ctx, database.GetPhaseMonthlyCostsParams{
+		Job:       jobNumber,
+		FromMonth: period.From,
+		ToMonth:   period.To,
+		AsOf:      period.AsOf,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching phase costs: %w", err)
+	}
+
+	budgets, err := q.GetPhaseBudgets(ctx, database.GetPhaseBudgetsParams{
+		Job:     jobNumber,
+		ToMonth: period.To,
+		AsOf:    period.AsOf,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching phase budgets: %w", err)
+	}
+
+	months := monthRange(costs)
+	monthIndex := make(map[string]int, len(months))
+	for i, m := range months {
+		monthIndex[m] = i
+	}
+
+	pivot := &PhaseCostPivot{
+		JobNumber: jobNumber,
+		Months:    months,
+		Totals:    PhaseCostPivotTotals{Costs: make([]decimal.Decimal, len(months))},
+	}
+
+	rowIndex := make(map[string]int)
+	rowFor := func(phase string) *PhaseCostPivotRow {
+		if idx, ok := rowIndex[phase]; ok {
+			return &pivot.Rows[idx]
+		}
+		rowIndex[phase] = len(pivot.Rows)
+		pivot.Rows = append(pivot.Rows, PhaseCostPivotRow{
+			Phase: phase,
+			Costs: make([]decimal.Decimal, len(months)),
+		})
+		return &pivot.Rows[len(pivot.Rows)-1]
+	}
+
+	for _, b := range budgets {
+		row := rowFor(b.Phase)
+		row.Description = b.Description
+		row.EstimateBudget, _ = decimal.NewFromString(b.EstimateBudget)
+		row.ItemBudget, _ = decimal.NewFromString(b.ItemBudget)
+		pivot.Totals.EstimateBudget = pivot.Totals.EstimateBudget.Add(row.EstimateBudget)
+		pivot.Totals.ItemBudget = pivot.Totals.ItemBudget.Add(row.ItemBudget)
+	}
+
+	for _, c := range costs {
+		amount, err := decimal.NewFromString(c.ActualCost)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cost %q for phase %s: %w", c.ActualCost, c.Phase, err)
+		}
+		col := monthIndex[c.Month.Format("2006-01")]
+
+		row := rowFor(c.Phase)
+		row.Costs[col] = row.Costs[col].Add(amount)
+		row.Total = row.Total.Add(amount)
+		pivot.Totals.Costs[col] = pivot.Totals.Costs[col].Add(amount)
+		pivot.Totals.Total = pivot.Totals.Total.Add(amount)
+
+		if byCat {
+			cat := catFor(row, c.Cat, len(months))
+			cat.Costs[col] = cat.Costs[col].Add(amount)
+			cat.Total = cat.Total.Add(amount)
+		}
+	}
+
+	sort.SliceStable(pivot.Rows, func(i, j int) bool {
+		return pivot.Rows[i].Phase < pivot.Rows[j].Phase
+	})
+
+	return pivot, nil
+}
+
+// catFor finds or appends a cat breakdown on a phase row.
+func catFor(row *PhaseCostPivotRow, cat string, months int) *PhaseCostPivotCat {
+	for i := range row.Cats {
+		if row.Cats[i].Cat == cat {
+			return &row.Cats[i]
+		}
+	}
+	row.Cats = append(row.Cats, PhaseCostPivotCat{Cat: cat, Costs: make([]decimal.Decimal, months)})
+	return &row.Cats[len(row.Cats)-1]
+}
+
+// Table flattens the pivot for CSV and Excel output. Cat rows follow their
+// phase row with the budget columns left blank, and a totals row closes the table.
+func (p *PhaseCostPivot) Table() *report.Table {
+	table := &report.Table{
+		Sheet: "Phase Costs",
+		Columns: []report.Column{
+			{Header: "Phase"},
+			{Header: "Cat"},
+			{Header: "Description"},
+			{Header: "Estimate Budget", Numeric: true},
+			{Header: "Item Budget", Numeric: true},
+		},
+	}
+	for _, m := range p.Months {
+		table.Columns = append(table.Columns, report.Column{Header: m, Numeric: true})
+	}
+	table.Columns = append(table.Columns, report.Column{Header: "Total", Numeric: true})
+
+	for _, row := range p.Rows {
+		cells := []interface{}{row.Phase, nil, row.Description, row.EstimateBudget, row.ItemBudget}
+		table.Rows = append(table.Rows, appendCosts(cells, row.Costs, row.Total))
+
+		for _, cat := range row.Cats {
+			cells := []interface{}{row.Phase, cat.Cat, nil, nil, nil}
+			table.Rows = append(table.Rows, appendCosts(cells, cat.Costs, cat.Total))
+		}
+	}
+
+	cells := []interface{}{"Total", nil, nil, p.Totals.EstimateBudget, p.Totals.ItemBudget}
+	table.Rows = append(table.Rows, appendCosts(cells, p.Totals.Costs, p.Totals.Total))
+
+	return table
+}
+
+func appendCosts(cells []interface{}, costs []decimal.Decimal, total decimal.Decimal) []interface{} {
+	for _, c := range costs {
+		cells = append(cells, c)
+	}
+	return append(cells, total)
+}
+
+// monthRange returns every month from the earliest to the latest cost row,
+// formatted as 2006-01, so months without cost still get a column.
+func monthRange(costs []database.GetPhaseMonthlyCostsRow) []string {
+	if len(costs) == 0 {
+		return []string{}
+	}
+
+	first, last := costs[0].Month, costs[0].Month
+	for _, c := range costs {
+		if c.Month.Before(first) {
+			first = c.Month
+		}
+		if c.Month.After(last) {
+			last = c.Month
+		}
+	}
+
+	var months []string
+	for m := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(last); m = m.AddDate(0, 1, 0) {
+		months = append(months, m.Format("2006-01"))
+	}
+	return months
+}
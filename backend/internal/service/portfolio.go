@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// Job statuses.
+const (
+	JobActive   = "active"
+	JobComplete = "complete"
+	JobClosed   = "closed"
+)
+
+// ValidJobStatus reports whether s is a known job status.
+func ValidJobStatus(s string) bool {
+	return s == JobActive || s == JobComplete || s == JobClosed
+}
+
+// PortfolioJob is one job's headline numbers for the portfolio view.
+type PortfolioJob struct {
+	ID                     uuid.UUID       `json:"id" report:"-"`
+	JobNumber              string          `json:"job_number" report:"Job"`
+	JobName                string          `json:"job_name" report:"Name"`
+	Status                 string          `json:"status" report:"Status"`
+	ContractValue          decimal.Decimal `json:"contract_value" report:"Contract Value"`
+	Billed                 decimal.Decimal `json:"billed" report:"Billed To Date"`
+	Cost                   decimal.Decimal `json:"cost" report:"Cost To Date"`
+	EarnedValue            decimal.Decimal `json:"earned_value" report:"Earned Value"`
+	CPI                    decimal.Decimal `json:"cpi" report:"CPI"`
+	PercentComplete        decimal.Decimal `json:"percent_complete" report:"Percent Complete"`
+	ProjectedCost          decimal.Decimal `json:"projected_cost" report:"Projected Cost"`
+	ProjectedMargin        decimal.Decimal `json:"projected_margin" report:"Projected Margin"`
+	ProjectedMarginPercent decimal.Decimal `json:"projected_margin_percent" report:"Projected Margin %"`
+	LastImport             string          `json:"last_import" report:"Last Import"`
+}
+
+// portfolioSortKeys compares two jobs by each sortable metric, named by its
+// JSON field.
+var portfolioSortKeys = map[string]func(a, b *PortfolioJob) int{
+	"job_number":               func(a, b *PortfolioJob) int { return strings.Compare(a.JobNumber, b.JobNumber) },
+	"job_name":                 func(a, b *PortfolioJob) int { return strings.Compare(a.JobName, b.JobName) },
+	"status":                   func(a, b *PortfolioJob) int { return strings.Compare(a.Status, b.Status) },
+	"contract_value":           func(a, b *PortfolioJob) int { return a.ContractValue.Cmp(b.ContractValue) },
+	"billed":                   func(a, b *PortfolioJob) int { return a.Billed.Cmp(b.Billed) },
+	"cost":                     func(a, b *PortfolioJob) int { return a.Cost.Cmp(b.Cost) },
+	"earned_value":             func(a, b *PortfolioJob) int { return a.EarnedValue.Cmp(b.EarnedValue) },
+	"cpi":                      func(a, b *PortfolioJob) int { return a.CPI.Cmp(b.CPI) },
+	"percent_complete":         func(a, b *PortfolioJob) int { return a.PercentComplete.Cmp(b.PercentComplete) },
+	"projected_cost":           func(a, b *PortfolioJob) int { return a.ProjectedCost.Cmp(b.ProjectedCost) },
+	"projected_margin":         func(a, b *PortfolioJob) int { return a.ProjectedMargin.Cmp(b.ProjectedMargin) },
+	"projected_margin_percent": func(a, b *PortfolioJob) int { return a.ProjectedMarginPercent.Cmp(b.ProjectedMarginPercent) },
+	"last_import":              func(a, b *PortfolioJob) int { return strings.Compare(a.LastImport, b.LastImport) },
+}
+
+// ValidPortfolioSort reports whether key names a sortable portfolio field.
+func ValidPortfolioSort(key string) bool {
+	_, ok := portfolioSortKeys[key]
+	return ok
+}
+
+// GetPortfolio returns headline metrics for every job with the given status
+// ("" for all), sorted by the named metric. Ties keep job number order.
+// Figures are to date, so the period's To and AsOf apply but From does not.
+func GetPortfolio(ctx context.Context, q *database.Queries, status, sortBy string, descending bool, period Period) ([]PortfolioJob, error) {
+	if status != "" && !ValidJobStatus(status) {
+		return nil, fmt.Errorf("invalid status %q", status)
+	}
+	if period.From.Valid {
+		return nil, fmt.Errorf("portfolio figures are to date; use to instead of from")
+	}
+	if sortBy == "" {
+		sortBy = "job_number"
+	}
+	cmp, ok := portfolioSortKeys[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("cannot sort by %q", sortBy)
+	}
+
+	rows, err := q.GetPortfolio(ctx, database.GetPortfolioParams{
+		Status:  status,
+		ToMonth: period.To,
+		AsOf:    period.AsOf,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch portfolio: %w", err)
+	}
+
+	jobs := make([]PortfolioJob, 0, len(rows))
+	for _, row := range rows {
+		j := PortfolioJob{
+			ID:        row.ID,
+			JobNumber: row.JobNumber,
+			JobName:   row.JobName,
+			Status:    row.Status,
+		}
+		if row.LastImport.Valid {
+			j.LastImport = row.LastImport.Time.Format("2006-01-02 15:04")
+		}
+		if err := parseDecimals(
+			decimalField{&j.ContractValue, row.ContractValue},
+			decimalField{&j.Billed, row.Billed},
+			decimalField{&j.Cost, row.Cost},
+			decimalField{&j.EarnedValue, row.EarnedValue},
+			decimalField{&j.CPI, row.Cpi},
+			decimalField{&j.PercentComplete, row.PercentComplete},
+			decimalField{&j.ProjectedCost, row.ProjectedCost},
+			decimalField{&j.ProjectedMargin, row.ProjectedMargin},
+			decimalField{&j.ProjectedMarginPercent, row.ProjectedMarginPercent},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for job %s: %w", row.JobNumber, err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	sort.SliceStable(jobs, func(i, k int) bool {
+		c := cmp(&jobs[i], &jobs[k])
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	return jobs, nil
+}
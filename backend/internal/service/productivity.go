@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// Installed unit sources for ProductivityRow.UnitsSource.
+const (
+	UnitsFromLedger = "ledger"
+	UnitsFromPayApp = "pay app"
+)
+
+// ProductivityRow compares a Crew item's actual production with the bid.
+// Rates are units per man-hour and per crew-day. Projected hours assume the
+// rest of the work goes at the rate achieved so far; a positive overrun means
+// the crew will need more hours than bid.
+type ProductivityRow struct {
+	ItemNumber      string `json:"item_number" report:"Item"`
+	Description     string `json:"description" report:"Description"`
+	Phase           string `json:"phase" report:"Phase"`
+	Unit            string `json:"unit" report:"Unit"`
+	ProductionRate  string `json:"production_rate" report:"Production Rate"`
+	ProductionUnits string `json:"production_units" report:"Production Method"`
+
+	BidQty             decimal.Decimal `json:"bid_qty" report:"Bid Qty"`
+	BidManHours        decimal.Decimal `json:"bid_man_hours" report:"Bid Man Hours"`
+	BidCrewDays        decimal.Decimal `json:"bid_crew_days" report:"Bid Crew Days"`
+	BidUnitsPerManHour decimal.Decimal `json:"bid_units_per_man_hour" report:"Bid Units/MH"`
+	BidUnitsPerCrewDay decimal.Decimal `json:"bid_units_per_crew_day" report:"Bid Units/Day"`
+
+	InstalledUnits        decimal.Decimal `json:"installed_units" report:"Installed Units"`
+	UnitsSource           string          `json:"units_source" report:"Units Source"`
+	PercentComplete       decimal.Decimal `json:"percent_complete" report:"Percent Complete"`
+	ActualHours           decimal.Decimal `json:"actual_hours" report:"Actual Hours"`
+	DaysWorked            int32           `json:"days_worked" report:"Days Worked"`
+	ActualUnitsPerManHour decimal.Decimal `json:"actual_units_per_man_hour" report:"Actual Units/MH"`
+	ActualUnitsPerCrewDay decimal.Decimal `json:"actual_units_per_crew_day" report:"Actual Units/Day"`
+	PerformanceFactor     decimal.Decimal `json:"performance_factor" report:"Performance Factor"`
+
+	ProjectedHours       decimal.Decimal `json:"projected_hours" report:"Projected Hours"`
+	ProjectedHourOverrun decimal.Decimal `json:"projected_hour_overrun" report:"Projected Hour Overrun"`
+}
+
+// GetProductivity reports actual against bid production for every Crew item on
+// a job. Hours come from PR cost lines in the ledger for the crew's phase.
+// Installed units come from the ledger when the payroll export reports units,
+// otherwise from the pay app percent complete of the crew's pay item.
+// The period limits hours and ledger units to its months and the data seen;
+// percent complete is to date through its last month.
+func GetProductivity(ctx context.Context, q *database.Queries, jobNumber string, period Period) ([]ProductivityRow, error) {
+	rows, err := q.GetCrewProductivity(ctx, database.GetCrewProductivityParams{
+		JobNumber: jobNumber,
+		FromMonth: period.From,
+		ToMonth:   period.To,
+		AsOf:      period.AsOf,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching crew productivity: %w", err)
+	}
+
+	result := make([]ProductivityRow, 0, len(rows))
+	for _, row := range rows {
+		p := ProductivityRow{
+			ItemNumber:      row.ItemNumber,
+			Description:     row.Description,
+			Phase:           row.Phase,
+			Unit:            row.Unit,
+			ProductionRate:  row.ProductionRate,
+			ProductionUnits: row.ProductionUnits,
+			DaysWorked:      row.DaysWorked,
+		}
+
+		var ledgerUnits, billedFraction decimal.Decimal
+		if err := parseDecimals(
+			decimalField{&p.BidQty, row.Qty},
+			decimalField{&p.BidManHours, row.ManHours},
+			decimalField{&p.BidCrewDays, row.CrewDays},
+			decimalField{&p.ActualHours, row.ActualHours},
+			decimalField{&ledgerUnits, row.ActualUnits},
+			decimalField{&billedFraction, row.BilledFraction},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for crew %s: %w", row.Description, err)
+		}
+
+		if ledgerUnits.IsPositive() {
+			p.InstalledUnits = ledgerUnits
+			p.UnitsSource = UnitsFromLedger
+		} else {
+			p.InstalledUnits = p.BidQty.Mul(billedFraction).Round(4)
+			p.UnitsSource = UnitsFromPayApp
+		}
+
+		p.BidUnitsPerManHour = productionRate(p.BidQty, p.BidManHours)
+		p.BidUnitsPerCrewDay = productionRate(p.BidQty, p.BidCrewDays)
+		p.ActualUnitsPerManHour = productionRate(p.InstalledUnits, p.ActualHours)
+		p.ActualUnitsPerCrewDay = productionRate(p.InstalledUnits, decimal.NewFromInt32(p.DaysWorked))
+		p.PerformanceFactor = ratio(p.ActualUnitsPerManHour, p.BidUnitsPerManHour)
+
+		if p.BidQty.IsPositive() {
+			p.PercentComplete = p.InstalledUnits.Div(p.BidQty).Mul(hundred).Round(2)
+		}
+		if p.InstalledUnits.IsPositive() && p.BidQty.IsPositive() {
+			p.ProjectedHours = p.ActualHours.Mul(p.BidQty).Div(p.InstalledUnits).Round(2)
+			p.ProjectedHourOverrun = p.ProjectedHours.Sub(p.BidManHours)
+		}
+
+		result = append(result, p)
+	}
+
+	return result, nil
+}
+
+// productionRate returns units per hour or day to four places, or zero when
+// no time has been spent.
+func productionRate(units, time decimal.Decimal) decimal.Decimal {
+	if !time.IsPositive() {
+		return decimal.Zero
+	}
+	return units.Div(time).Round(4)
+}
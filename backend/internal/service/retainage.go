@@ -0,0 +1,379 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// ErrInvalidRetainageRelease is returned, wrapped with the reason, for a
+// release that isn't positive or is more than the retainage held.
+var ErrInvalidRetainageRelease = errors.New("invalid retainage release")
+
+// RetainageTier is one step of a job's retainage schedule. The tier with the
+// highest threshold at or below the job's percent complete applies. Rates and
+// thresholds are percentages (10 means 10%).
+type RetainageTier struct {
+	ThresholdPercent decimal.Decimal `json:"threshold_percent"`
+	WorkRate         decimal.Decimal `json:"work_rate"`
+	MaterialsRate    decimal.Decimal `json:"materials_rate"`
+}
+
+// RetainageRelease is retainage paid back to us by the owner.
+type RetainageRelease struct {
+	Month  string          `json:"month"`
+	Amount decimal.Decimal `json:"amount"`
+	Note   string          `json:"note"`
+}
+
+// PayAppSummary is the G702-style money summary for one pay application month.
+type PayAppSummary struct {
+	Month                   string          `json:"month" report:"Month"`
+	ScheduledValue          decimal.Decimal `json:"scheduled_value" report:"Scheduled Value"`
+	WorkThisPeriod          decimal.Decimal `json:"work_this_period" report:"Work This Period"`
+	WorkToDate              decimal.Decimal `json:"work_to_date" report:"Work To Date"`
+	StoredMaterials         decimal.Decimal `json:"stored_materials" report:"Stored Materials"`
+	CompletedAndStored      decimal.Decimal `json:"completed_and_stored" report:"Completed And Stored"`
+	PercentComplete         decimal.Decimal `json:"percent_complete" report:"Pct Complete"`
+	WorkRetainageRate       decimal.Decimal `json:"work_retainage_rate" report:"Work Retainage Rate"`
+	MaterialsRetainageRate  decimal.Decimal `json:"materials_retainage_rate" report:"Materials Retainage Rate"`
+	WorkRetainage           decimal.Decimal `json:"work_retainage" report:"Work Retainage"`
+	MaterialsRetainage      decimal.Decimal `json:"materials_retainage" report:"Materials Retainage"`
+	RetainageReleased       decimal.Decimal `json:"retainage_released" report:"Retainage Released"`
+	RetainageReleasedToDate decimal.Decimal `json:"retainage_released_to_date" report:"Retainage Released To Date"`
+	RetainageHeld           decimal.Decimal `json:"retainage_held" report:"Retainage Held"`
+	EarnedLessRetainage     decimal.Decimal `json:"earned_less_retainage" report:"Earned Less Retainage"`
+	PreviousPayments        decimal.Decimal `json:"previous_payments" report:"Previous Payments"`
+	CurrentPaymentDue       decimal.Decimal `json:"current_payment_due" report:"Current Payment Due"`
+}
+
+// ValidateRetainageTiers checks that rates and thresholds are percentages and
+// that no threshold is repeated.
+func ValidateRetainageTiers(tiers []RetainageTier) error {
+	seen := make(map[string]bool)
+	for _, t := range tiers {
+		if t.ThresholdPercent.IsNegative() || t.ThresholdPercent.GreaterThan(hundred) {
+			return fmt.Errorf("threshold_percent %s must be between 0 and 100", t.ThresholdPercent)
+		}
+		if t.WorkRate.IsNegative() || t.WorkRate.GreaterThan(hundred) {
+			return fmt.Errorf("work_rate %s must be between 0 and 100", t.WorkRate)
+		}
+		if t.MaterialsRate.IsNegative() || t.MaterialsRate.GreaterThan(hundred) {
+			return fmt.Errorf("materials_rate %s must be between 0 and 100", t.MaterialsRate)
+		}
+		key := t.ThresholdPercent.String()
+		if seen[key] {
+			return fmt.Errorf("duplicate threshold_percent %s", key)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// GetRetainageTiers returns a job's retainage schedule, lowest threshold first.
+func GetRetainageTiers(ctx context.Context, q *database.Queries, jobNumber string) ([]RetainageTier, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+	return retainageTiers(ctx, q, job.ID)
+}
+
+// SetRetainageTiers replaces a job's retainage schedule. The schedule applies
+// to every pay application month, so it is rejected with ErrPeriodClosed
+// while any billed month is closed.
+func SetRetainageTiers(ctx context.Context, q *database.Queries, jobNumber string, tiers []RetainageTier) error {
+	if err := ValidateRetainageTiers(tiers); err != nil {
+		return err
+	}
+
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	return q.InTx(ctx, func(q *database.Queries) error {
+		if err := checkBilledPeriodsOpen(ctx, q, job.ID); err != nil {
+			return err
+		}
+
+		if err := q.DeleteRetainageTiersByJob(ctx, job.ID); err != nil {
+			return fmt.Errorf("failed to clear retainage tiers: %w", err)
+		}
+
+		for _, t := range tiers {
+			if err := q.InsertRetainageTier(ctx, database.InsertRetainageTierParams{
+				JobID:            job.ID,
+				ThresholdPercent: t.ThresholdPercent.String(),
+				WorkRate:         t.WorkRate.String(),
+				MaterialsRate:    t.MaterialsRate.String(),
+			}); err != nil {
+				return fmt.Errorf("failed to insert retainage tier at %s%%: %w", t.ThresholdPercent, err)
+			}
+		}
+		return nil
+	})
+}
+
+// checkBilledPeriodsOpen returns ErrPeriodClosed when any month billed on the
+// job is closed, so its retainage and payment due can't be recomputed.
+func checkBilledPeriodsOpen(ctx context.Context, q *database.Queries, jobID uuid.UUID) error {
+	closed, err := jobClosedMonths(ctx, q, jobID)
+	if err != nil || len(closed) == 0 {
+		return err
+	}
+
+	totals, err := q.GetPayAppMonthlyTotals(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch pay application totals: %w", err)
+	}
+	for _, t := range totals {
+		if closed[t.PayAppMonth.Format("2006-01")] {
+			return fmt.Errorf("%w: %s is billed and must be reopened before the retainage schedule can change",
+				ErrPeriodClosed, t.PayAppMonth.Format("January 2006"))
+		}
+	}
+	return nil
+}
+
+// GetRetainageReleases returns the retainage released on a job, oldest first.
+func GetRetainageReleases(ctx context.Context, q *database.Queries, jobNumber string) ([]RetainageRelease, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	rows, err := q.GetRetainageReleases(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch retainage releases: %w", err)
+	}
+
+	releases := make([]RetainageRelease, 0, len(rows))
+	for _, row := range rows {
+		amount, err := decimal.NewFromString(row.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("invalid release amount %q: %w", row.Amount, err)
+		}
+		releases = append(releases, RetainageRelease{
+			Month:  row.ReleaseMonth.Format("2006-01"),
+			Amount: amount,
+			Note:   row.Note.String,
+		})
+	}
+	return releases, nil
+}
+
+// AddRetainageRelease records retainage released in the given month. A closed
+// month is rejected with ErrPeriodClosed; a release of more than is held, in
+// that month or any later one, with ErrInvalidRetainageRelease.
+func AddRetainageRelease(ctx context.Context, q *database.Queries, jobNumber string, month time.Time, amount decimal.Decimal, note string) error {
+	if !amount.IsPositive() {
+		return fmt.Errorf("%w: amount must be greater than zero", ErrInvalidRetainageRelease)
+	}
+
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	if err := checkPeriodOpen(ctx, q, job.ID, month); err != nil {
+		return err
+	}
+
+	summaries, err := GetPayAppSummaries(ctx, q, jobNumber)
+	if err != nil {
+		return err
+	}
+	held := releasableRetainage(summaries, month)
+	if amount.GreaterThan(held) {
+		return fmt.Errorf("%w: %s is more than the %s retainage held in %s",
+			ErrInvalidRetainageRelease, amount, held, month.Format("2006-01"))
+	}
+
+	_, err = q.InsertRetainageRelease(ctx, database.InsertRetainageReleaseParams{
+		JobID:        job.ID,
+		ReleaseMonth: month,
+		Amount:       amount.String(),
+		Note:         sql.NullString{String: note, Valid: note != ""},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to insert retainage release: %w", err)
+	}
+	return nil
+}
+
+// GetPayAppSummaries computes retainage and payment due for every pay
+// application month on a job.
+//
+// Work retainage accrues per period at the work rate in effect once that
+// period's work is counted toward percent complete, so a lower tier only
+// applies going forward. Materials retainage is the materials rate applied to
+// the stored balance that month. Releases dated between two pay applications
+// are credited on the later one; releases after the last pay application
+// get a summary of their own, carrying that pay application's totals forward.
+func GetPayAppSummaries(ctx context.Context, q *database.Queries, jobNumber string) ([]PayAppSummary, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	totals, err := q.GetPayAppMonthlyTotals(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch pay application totals: %w", err)
+	}
+
+	tiers, err := retainageTiers(ctx, q, job.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.GetRetainageReleases(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch retainage releases: %w", err)
+	}
+	releases := make([]decimal.Decimal, len(rows))
+	for i, row := range rows {
+		if releases[i], err = decimal.NewFromString(row.Amount); err != nil {
+			return nil, fmt.Errorf("invalid release amount %q: %w", row.Amount, err)
+		}
+	}
+
+	summaries := make([]PayAppSummary, 0, len(totals))
+	var workRetainage, releasedToDate, previousPayments decimal.Decimal
+	nextRelease := 0
+
+	// settle credits the releases dated up to month and works out what is
+	// held and due.
+	settle := func(s *PayAppSummary, month time.Time) {
+		for nextRelease < len(rows) && !rows[nextRelease].ReleaseMonth.After(month) {
+			s.RetainageReleased = s.RetainageReleased.Add(releases[nextRelease])
+			nextRelease++
+		}
+		releasedToDate = releasedToDate.Add(s.RetainageReleased)
+		s.RetainageReleasedToDate = releasedToDate
+
+		s.RetainageHeld = s.WorkRetainage.Add(s.MaterialsRetainage).Sub(releasedToDate)
+		s.EarnedLessRetainage = s.CompletedAndStored.Sub(s.RetainageHeld)
+		s.PreviousPayments = previousPayments
+		s.CurrentPaymentDue = s.EarnedLessRetainage.Sub(previousPayments)
+		previousPayments = s.EarnedLessRetainage
+	}
+
+	for _, t := range totals {
+		s := PayAppSummary{Month: t.PayAppMonth.Format("2006-01")}
+		if err := parseDecimals(
+			decimalField{&s.ScheduledValue, t.ScheduledValue},
+			decimalField{&s.WorkThisPeriod, t.WorkThisPeriod},
+			decimalField{&s.WorkToDate, t.WorkToDate},
+			decimalField{&s.StoredMaterials, t.StoredMaterials},
+		); err != nil {
+			return nil, fmt.Errorf("invalid pay application total for %s: %w", s.Month, err)
+		}
+
+		s.CompletedAndStored = s.WorkToDate.Add(s.StoredMaterials)
+		if s.ScheduledValue.IsPositive() {
+			s.PercentComplete = s.WorkToDate.Div(s.ScheduledValue).Mul(hundred).Round(2)
+		}
+
+		tier := tierFor(tiers, s.PercentComplete)
+		s.WorkRetainageRate = tier.WorkRate
+		s.MaterialsRetainageRate = tier.MaterialsRate
+
+		workRetainage = workRetainage.Add(s.WorkThisPeriod.Mul(tier.WorkRate).Div(hundred).Round(2))
+		s.WorkRetainage = workRetainage
+		s.MaterialsRetainage = s.StoredMaterials.Mul(tier.MaterialsRate).Div(hundred).Round(2)
+
+		settle(&s, t.PayAppMonth)
+		summaries = append(summaries, s)
+	}
+
+	for nextRelease < len(rows) {
+		month := rows[nextRelease].ReleaseMonth
+		var s PayAppSummary
+		if len(summaries) > 0 {
+			last := summaries[len(summaries)-1]
+			s = PayAppSummary{
+				ScheduledValue:         last.ScheduledValue,
+				WorkToDate:             last.WorkToDate,
+				StoredMaterials:        last.StoredMaterials,
+				CompletedAndStored:     last.CompletedAndStored,
+				PercentComplete:        last.PercentComplete,
+				WorkRetainageRate:      last.WorkRetainageRate,
+				MaterialsRetainageRate: last.MaterialsRetainageRate,
+				WorkRetainage:          last.WorkRetainage,
+				MaterialsRetainage:     last.MaterialsRetainage,
+			}
+		}
+		s.Month = month.Format("2006-01")
+		settle(&s, month)
+		summaries = append(summaries, s)
+	}
+
+	return summaries, nil
+}
+
+// releasableRetainage returns how much could be released in month without
+// the retainage held going negative. The release is credited on the first
+// summary on or after month, so every summary from there on must cover it;
+// with none, the held balance carried forward from the last one must.
+func releasableRetainage(summaries []PayAppSummary, month time.Time) decimal.Decimal {
+	key := month.Format("2006-01")
+	var held decimal.Decimal
+	later := false
+	for _, s := range summaries {
+		switch {
+		case s.Month < key:
+			held = s.RetainageHeld
+		case !later:
+			held, later = s.RetainageHeld, true
+		default:
+			held = decimal.Min(held, s.RetainageHeld)
+		}
+	}
+	if held.IsNegative() {
+		return decimal.Zero
+	}
+	return held
+}
+
+func retainageTiers(ctx context.Context, q *database.Queries, jobID uuid.UUID) ([]RetainageTier, error) {
+	rows, err := q.GetRetainageTiers(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch retainage tiers: %w", err)
+	}
+
+	tiers := make([]RetainageTier, 0, len(rows))
+	for _, row := range rows {
+		var t RetainageTier
+		if err := parseDecimals(
+			decimalField{&t.ThresholdPercent, row.ThresholdPercent},
+			decimalField{&t.WorkRate, row.WorkRate},
+			decimalField{&t.MaterialsRate, row.MaterialsRate},
+		); err != nil {
+			return nil, fmt.Errorf("invalid retainage tier value: %w", err)
+		}
+		tiers = append(tiers, t)
+	}
+	return tiers, nil
+}
+
+// tierFor returns the tier in effect at the given percent complete. Tiers must
+// be sorted by threshold. A job without a tier at or below pct holds nothing.
+func tierFor(tiers []RetainageTier, pct decimal.Decimal) RetainageTier {
+	var current RetainageTier
+	for _, t := range tiers {
+		if t.ThresholdPercent.GreaterThan(pct) {
+			break
+		}
+		current = t
+	}
+	return current
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// StoredMaterialsRow is one item's stored materials movement for a month.
+type StoredMaterialsRow struct {
+	ItemNumber      string          `json:"item_number" report:"Item"`
+	Month           string          `json:"month" report:"Month"`
+	PreviousBalance decimal.Decimal `json:"previous_balance" report:"Previous Balance"`
+	Added           decimal.Decimal `json:"added" report:"Added"`
+	Installed       decimal.Decimal `json:"installed" report:"Installed"`
+	Balance         decimal.Decimal `json:"balance" report:"Balance"`
+}
+
+// recordMaterialsInstalled stores the materials installed out of storage as
+// reported on a pay application. installed is "" when the pay app has no
+// installed column, leaving stored_materials_ledger to take a drop in balance
+// as installed. Materials added is always the rise in balance.
+func recordMaterialsInstalled(ctx context.Context, q *database.Queries, jobItemID uuid.UUID, month time.Time, installed string) error {
+	var used sql.NullString
+	if installed != "" {
+		if _, err := decimal.NewFromString(installed); err != nil {
+			return fmt.Errorf("invalid materials installed %q: %w", installed, err)
+		}
+		used = sql.NullString{String: installed, Valid: true}
+	}
+
+	return q.SetMaterialsInstalled(ctx, database.SetMaterialsInstalledParams{
+		JobItemID:          jobItemID,
+		PayAppMonth:        month,
+		MaterialsInstalled: used,
+	})
+}
+
+// GetStoredMaterials returns the stored materials ledger for a job by item and month.
+func GetStoredMaterials(ctx context.Context, q *database.Queries, jobNumber string) ([]StoredMaterialsRow, error) {
+	job, err := q.GetJobByNumber(ctx, jobNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find job %s: %w", jobNumber, err)
+	}
+
+	rows, err := q.GetStoredMaterialsLedger(ctx, job.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stored materials: %w", err)
+	}
+
+	result := make([]StoredMaterialsRow, 0, len(rows))
+	for _, row := range rows {
+		r := StoredMaterialsRow{
+			ItemNumber: row.ItemNumber,
+			Month:      row.PayAppMonth.Format("2006-01"),
+		}
+		if err := parseDecimals(
+			decimalField{&r.PreviousBalance, row.PreviousBalance},
+			decimalField{&r.Added, row.MaterialsAdded},
+			decimalField{&r.Installed, row.MaterialsInstalled},
+			decimalField{&r.Balance, row.Balance},
+		); err != nil {
+			return nil, fmt.Errorf("invalid stored materials value for item %s: %w", row.ItemNumber, err)
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// Transaction type classes. Every report picks ledger rows by class rather
+// than by raw transaction type; unclassified types are left out.
+const (
+	ClassCost           = "cost"
+	ClassBilling        = "billing"
+	ClassOriginalBudget = "original_budget"
+	ClassBudgetRevision = "budget_revision"
+	ClassIgnore         = "ignore"
+)
+
+// ValidTransactionClass reports whether c is a known transaction type class.
+func ValidTransactionClass(c string) bool {
+	switch c {
+	case ClassCost, ClassBilling, ClassOriginalBudget, ClassBudgetRevision, ClassIgnore:
+		return true
+	}
+	return false
+}
+
+// TransactionTypeClass assigns a raw ledger transaction type to a class.
+type TransactionTypeClass struct {
+	TransactionType string `json:"transaction_type"`
+	Class           string `json:"class"`
+}
+
+// UnclassifiedTransactionType is a transaction type found in the ledger with no
+// class, so none of its rows reach any report.
+type UnclassifiedTransactionType struct {
+	TransactionType string          `json:"transaction_type" report:"Transaction Type"`
+	Rows            int64           `json:"rows" report:"Rows"`
+	Jobs            int64           `json:"jobs" report:"Jobs"`
+	TotalAmount     decimal.Decimal `json:"total_amount" report:"Total Amount"`
+	FirstSeen       string          `json:"first_seen" report:"First Seen"`
+	LastSeen        string          `json:"last_seen" report:"Last Seen"`
+}
+
+// ValidateTransactionTypeClasses checks every class is known and no type is
+// listed twice.
+func ValidateTransactionTypeClasses(classes []TransactionTypeClass) error {
+	seen := make(map[string]bool)
+	for _, c := range classes {
+		if strings.TrimSpace(c.TransactionType) == "" {
+			return fmt.Errorf("transaction_type is required")
+		}
+		if !ValidTransactionClass(c.Class) {
+			return fmt.Errorf("class %q for %s must be cost, billing, original_budget, budget_revision or ignore", c.Class, c.TransactionType)
+		}
+		if seen[c.TransactionType] {
+			return fmt.Errorf("transaction_type %s is listed more than once", c.TransactionType)
+		}
+		seen[c.TransactionType] = true
+	}
+	return nil
+}
+
+// GetTransactionTypeClasses returns every classified transaction type.
+func GetTransactionTypeClasses(ctx context.Context, q *database.Queries) ([]TransactionTypeClass, error) {
+	rows, err := q.GetTransactionTypeClasses(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch transaction type classes: %w", err)
+	}
+
+	classes := make([]TransactionTypeClass, 0, len(rows))
+	for _, row := range rows {
+		classes = append(classes, TransactionTypeClass{TransactionType: row.TransactionType, Class: row.Class})
+	}
+	return classes, nil
+}
+
+// SetTransactionTypeClasses adds or reclassifies the given transaction types.
+// Types not listed keep their current class.
+func SetTransactionTypeClasses(ctx context.Context, q *database.Queries, classes []TransactionTypeClass) error {
+	for _, c := range classes {
+		if err := q.UpsertTransactionTypeClass(ctx, database.UpsertTransactionTypeClassParams{
+			TransactionType: c.TransactionType,
+			Class:           c.Class,
+		}); err != nil {
+			return fmt.Errorf("failed to classify %s: %w", c.TransactionType, err)
+		}
+	}
+	return nil
+}
+
+// GetUnclassifiedTransactionTypes lists ledger transaction types with no class,
+// most frequent first.
+func GetUnclassifiedTransactionTypes(ctx context.Context, q *database.Queries) ([]UnclassifiedTransactionType, error) {
+	rows, err := q.GetUnclassifiedTransactionTypes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch unclassified transaction types: %w", err)
+	}
+
+	result := make([]UnclassifiedTransactionType, 0, len(rows))
+	for _, row := range rows {
+		t := UnclassifiedTransactionType{
+			TransactionType: row.TransactionType,
+			Rows:            row.RowCount,
+			Jobs:            row.JobCount,
+		}
+		if t.TotalAmount, err = decimal.NewFromString(row.TotalAmount); err != nil {
+			return nil, fmt.Errorf("invalid total %q for %s: %w", row.TotalAmount, row.TransactionType, err)
+		}
+		if row.FirstSeen.Valid {
+			t.FirstSeen = row.FirstSeen.Time.Format("2006-01-02")
+		}
+		if row.LastSeen.Valid {
+			t.LastSeen = row.LastSeen.Time.Format("2006-01-02")
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+)
+
+// UnitCostRow compares a pay item's actual cost per unit installed with the
+// bid's budget per bid unit, to date. Variance is actual minus bid, so overruns
+// are positive. Actual unit cost is zero until quantity has been installed.
+type UnitCostRow struct {
+	ItemNumber      string          `json:"item_number" report:"Item"`
+	Description     string          `json:"description" report:"Description"`
+	Unit            string          `json:"unit" report:"Unit"`
+	BidQty          decimal.Decimal `json:"bid_qty" report:"Bid Qty"`
+	Budget          decimal.Decimal `json:"budget" report:"Budget"`
+	BidUnitCost     decimal.Decimal `json:"bid_unit_cost" report:"Bid Unit Cost"`
+	InstalledQty    decimal.Decimal `json:"installed_qty" report:"Installed Qty"`
+	ActualCost      decimal.Decimal `json:"actual_cost" report:"Actual Cost"`
+	ActualUnitCost  decimal.Decimal `json:"actual_unit_cost" report:"Actual Unit Cost"`
+	Variance        decimal.Decimal `json:"unit_cost_variance" report:"Unit Cost Variance"`
+	VariancePercent decimal.Decimal `json:"variance_percent" report:"Variance %"`
+	Months          []UnitCostMonth `json:"months" report:"-"`
+}
+
+// UnitCostMonth is a pay item's cumulative quantity, cost and unit cost
+// through one month.
+type UnitCostMonth struct {
+	Month          string          `json:"month"`
+	InstalledQty   decimal.Decimal `json:"installed_qty"`
+	ActualCost     decimal.Decimal `json:"actual_cost"`
+	ActualUnitCost decimal.Decimal `json:"actual_unit_cost"`
+}
+
+// GetUnitCosts returns actual against bid unit cost for each pay item on a job,
+// with the monthly trend of cumulative unit cost. The period limits the months
+// returned and the data seen; to date figures are as of its last month.
+func GetUnitCosts(ctx context.Context, q *database.Queries, jobNumber string, period Period) ([]UnitCostRow, error) {
+	rows, err := q.GetPayItemUnitCosts(ctx, database.GetPayItemUnitCostsParams{
+		JobNumber: jobNumber,
+		AsOf:      period.AsOf,
+		FromMonth: period.From,
+		ToMonth:   period.To,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching pay item unit costs: %w", err)
+	}
+
+	result := make([]UnitCostRow, 0)
+	index := make(map[string]int)
+	for _, row := range rows {
+		idx, ok := index[row.ItemNumber]
+		if !ok {
+			idx = len(result)
+			index[row.ItemNumber] = idx
+			result = append(result, UnitCostRow{
+				ItemNumber:  row.ItemNumber,
+				Description: row.Description,
+				Unit:        row.Unit,
+			})
+		}
+		r := &result[idx]
+
+		m := UnitCostMonth{Month: row.Month.Format("2006-01")}
+		if err := parseDecimals(
+			decimalField{&r.BidQty, row.BidQty},
+			decimalField{&r.Budget, row.Budget},
+			decimalField{&m.InstalledQty, row.InstalledQty},
+			decimalField{&m.ActualCost, row.ActualCost},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for item %s: %w", row.ItemNumber, err)
+		}
+		m.ActualUnitCost = unitCost(m.ActualCost, m.InstalledQty)
+		r.Months = append(r.Months, m)
+
+		// The latest month carries the item's to-date totals
+		r.InstalledQty = m.InstalledQty
+		r.ActualCost = m.ActualCost
+		r.ActualUnitCost = m.ActualUnitCost
+	}
+
+	for i := range result {
+		r := &result[i]
+		r.BidUnitCost = unitCost(r.Budget, r.BidQty)
+		if r.InstalledQty.IsPositive() {
+			r.Variance = r.ActualUnitCost.Sub(r.BidUnitCost)
+			if r.BidUnitCost.IsPositive() {
+				r.VariancePercent = r.Variance.Div(r.BidUnitCost).Mul(hundred).Round(2)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// unitCost returns cost / qty rounded to cents, or zero when nothing is installed.
+func unitCost(cost, qty decimal.Decimal) decimal.Decimal {
+	if !qty.IsPositive() {
+		return decimal.Zero
+	}
+	return cost.Div(qty).Round(2)
+}
@@ -20,20 +20,26 @@ type SheetResult struct {
 	RowsProcessed int    `json:"rowsProcessed"`
 	RowsInserted  int    `json:"rowsInserted"`
 	RowsSkipped   int    `json:"rowsSkipped"`
+	RowsLocked    int    `json:"rowsLocked,omitempty"`
 	Error         string `json:"error,omitempty"`
 }
 
 // UploadResult contains the result of a file upload operation.
 type UploadResult struct {
-	Success       bool          `json:"success"`
-	Message       string        `json:"message"`
-	Filename      string        `json:"filename,omitempty"`
-	RowsProcessed int           `json:"rowsProcessed,omitempty"`
-	SheetResults  []SheetResult `json:"sheetResults,omitempty"`
+	Success       bool              `json:"success"`
+	Message       string            `json:"message"`
+	Filename      string            `json:"filename,omitempty"`
+	RowsProcessed int               `json:"rowsProcessed,omitempty"`
+	SheetResults  []SheetResult     `json:"sheetResults,omitempty"`
+	Revision      int32             `json:"revision,omitempty"`
+	Warnings      []string          `json:"warnings,omitempty"`
+	Overruns      []QuantityOverrun `json:"overruns,omitempty"`
 }
 
-// ImportPayApplication imports a pay application Excel file for a specific job and month.
-func ImportPayApplication(ctx context.Context, f *excelize.File, q *database.Queries, jobNumber, jobName string, targetDate time.Time) (*UploadResult, error) {
+// ImportPayApplication imports a pay application Excel file for a specific job
+// and month as the month's next revision, then flags pay items billed past or
+// within overrunThreshold percent of their contract quantity.
+func ImportPayApplication(ctx context.Context, f *excelize.File, q *database.Queries, jobNumber, jobName string, targetDate time.Time, source string, overrunThreshold decimal.Decimal) (*UploadResult, error) {
 	// Get or create job
 	jobID, err := getOrCreateJob(ctx, q, jobNumber, jobName)
 	if err != nil {
@@ -41,33 +47,56 @@ func ImportPayApplication(ctx context.Context, f *excelize.File, q *database.Que
 	}
 
 	// Parse and import
-	err = ParsePayApp(ctx, f, q, jobID, targetDate)
+	revision, err := ParsePayApp(ctx, f, q, jobID, targetDate, source)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse pay application: %w", err)
 	}
 
+	materials, err := ValidateStoredMaterials(ctx, q, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate stored materials: %w", err)
+	}
+
+	overruns, err := detectQuantityOverruns(ctx, q, jobID, overrunThreshold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check quantity overruns: %w", err)
+	}
+
 	return &UploadResult{
-		Success: true,
-		Message: fmt.Sprintf("Successfully imported pay application for job %s, %s", jobNumber, targetDate.Format("January 2006")),
+		Success:  true,
+		Message:  fmt.Sprintf("Successfully imported pay application for job %s, %s (revision %d)", jobNumber, targetDate.Format("January 2006"), revision),
+		Revision: revision,
+		Warnings: materials.Warnings,
+		Overruns: overruns,
 	}, nil
 }
 
 // ImportCostLedger imports a cost ledger Excel file, processing all sheets.
+// Rows dated in a job's closed month are not imported and are reported as locked.
 func ImportCostLedger(ctx context.Context, f *excelize.File, q *database.Queries) (*UploadResult, error) {
 	sheets := f.GetSheetList()
 	if len(sheets) == 0 {
 		return nil, fmt.Errorf("Excel file has no sheets")
 	}
 
+	closed, err := loadClosedPeriods(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+
 	var sheetResults []SheetResult
+	var warnings []string
 	totalInserted := 0
 	totalSkipped := 0
 
 	for _, sheetName := range sheets {
-		result := processSheet(ctx, f, q, sheetName)
+		result := processSheet(ctx, f, q, sheetName, closed)
 		sheetResults = append(sheetResults, result)
 		totalInserted += result.RowsInserted
 		totalSkipped += result.RowsSkipped
+		if result.RowsLocked > 0 {
+			warnings = append(warnings, fmt.Sprintf("%s: %d rows dated in closed periods were not imported", sheetName, result.RowsLocked))
+		}
 	}
 
 	return &UploadResult{
@@ -75,11 +104,12 @@ func ImportCostLedger(ctx context.Context, f *excelize.File, q *database.Queries
 		Message:       fmt.Sprintf("Processed %d sheets: %d inserted, %d skipped", len(sheets), totalInserted, totalSkipped),
 		RowsProcessed: totalInserted,
 		SheetResults:  sheetResults,
+		Warnings:      warnings,
 	}, nil
 }
 
 // processSheet processes a single sheet from the cost ledger workbook.
-func processSheet(ctx context.Context, f *excelize.File, q *database.Queries, sheetName string) SheetResult {
+func processSheet(ctx context.Context, f *excelize.File, q *database.Queries, sheetName string, closed closedPeriods) SheetResult {
 	result := SheetResult{SheetName: sheetName}
 
 	rows, err := f.GetRows(sheetName)
@@ -94,6 +124,7 @@ func processSheet(ctx context.Context, f *excelize.File, q *database.Queries, sh
 	}
 
 	result.RowsProcessed = len(rows) - 1 // exclude header
+	colHours, colUnits := ledgerQuantityColumns(rows[0])
 
 	for i, row := range rows {
 		if i == 0 {
@@ -134,6 +165,11 @@ func processSheet(ctx context.Context, f *excelize.File, q *database.Queries, sh
 			continue
 		}
 
+		if transactionDate.Valid && closed.has(job, transactionDate.Time) {
+			result.RowsLocked++
+			continue
+		}
+
 		hashInput := fmt.Sprintf("%s|%s|%s|%s|%s|%s", job, phase, cat, transactionType, transactionDateStr, amountStr)
 		hash := fmt.Sprintf("%x", sha256.Sum256([]byte(hashInput)))
 
@@ -146,6 +182,10 @@ func processSheet(ctx context.Context, f *excelize.File, q *database.Queries, sh
 			TransactionDate: transactionDate,
 			Amount:          amount.String(),
 		}
+		if transactionType == "PR cost" {
+			params.Hours = ledgerQuantity(row, colHours)
+			params.Units = ledgerQuantity(row, colUnits)
+		}
 
 		err := q.InsertJobCostLedger(ctx, params)
 		if err != nil {
@@ -158,6 +198,38 @@ func processSheet(ctx context.Context, f *excelize.File, q *database.Queries, sh
 	return result
 }
 
+// ledgerQuantityColumns finds the optional hours and units columns in a cost
+// ledger header row. Either index is -1 when the export doesn't include it.
+func ledgerQuantityColumns(header []string) (hours, units int) {
+	hours, units = -1, -1
+	for i, cell := range header {
+		cellLower := strings.ToLower(strings.TrimSpace(cell))
+		switch {
+		case hours == -1 && (strings.Contains(cellLower, "hours") || cellLower == "hrs"):
+			hours = i
+		case units == -1 && (strings.Contains(cellLower, "units") || cellLower == "quantity" || cellLower == "qty"):
+			units = i
+		}
+	}
+	return hours, units
+}
+
+// ledgerQuantity reads an optional hours or units cell, NULL when blank or unreadable.
+func ledgerQuantity(row []string, idx int) sql.NullString {
+	raw := getColValue(row, idx)
+	if raw == "" {
+		return sql.NullString{}
+	}
+	val, err := cleanNumeric(raw)
+	if err != nil {
+		return sql.NullString{}
+	}
+	if _, err := decimal.NewFromString(val); err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: val, Valid: true}
+}
+
 func getOrCreateJob(ctx context.Context, q *database.Queries, jobNumber, jobName string) (uuid.UUID, error) {
 	job, err := q.GetJobByNumber(ctx, jobNumber)
 	if err == nil {
@@ -0,0 +1,432 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User roles, from least to most access.
+const (
+	RoleViewer         = "viewer"
+	RoleProjectManager = "project_manager"
+	RoleAccounting     = "accounting"
+	RoleAdmin          = "admin"
+)
+
+// SessionTTL is how long a sign-in lasts.
+const SessionTTL = 12 * time.Hour
+
+// MinPasswordLength is the shortest password accepted for an account.
+const MinPasswordLength = 10
+
+// User errors, wrapped with the username.
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserExists         = errors.New("user already exists")
+	ErrLastAdmin          = errors.New("at least one enabled admin is required")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrNoSession          = errors.New("not signed in")
+)
+
+// Permission names a change a user may be allowed to make. Every signed-in
+// user may read.
+type Permission string
+
+const (
+	PermissionImportPayApps   Permission = "import_pay_apps"
+	PermissionImportLedger    Permission = "import_ledger"
+	PermissionImportBids      Permission = "import_bids"
+	PermissionImportBaselines Permission = "import_baselines"
+	PermissionEditJobs        Permission = "edit_jobs"       // jobs, change orders, baselines, retainage schedules
+	PermissionEditPayApps     Permission = "edit_pay_apps"   // pay application quantities and stored materials
+	PermissionEditAccounting  Permission = "edit_accounting" // retainage releases, cost type and transaction type setup
+	PermissionClosePeriods    Permission = "close_periods"
+	PermissionManageUsers     Permission = "manage_users"
+)
+
+// rolePermissions lists what each role may change. Admins may do everything.
+var rolePermissions = map[string][]Permission{
+	RoleViewer: nil,
+	RoleProjectManager: {
+		PermissionImportPayApps,
+		PermissionImportBids,
+		PermissionImportBaselines,
+		PermissionEditJobs,
+		PermissionEditPayApps,
+	},
+	RoleAccounting: {
+		PermissionImportPayApps,
+		PermissionImportLedger,
+		PermissionEditPayApps,
+		PermissionEditAccounting,
+		PermissionClosePeriods,
+	},
+	RoleAdmin: {
+		PermissionImportPayApps,
+		PermissionImportLedger,
+		PermissionImportBids,
+		PermissionImportBaselines,
+		PermissionEditJobs,
+		PermissionEditPayApps,
+		PermissionEditAccounting,
+		PermissionClosePeriods,
+		PermissionManageUsers,
+	},
+}
+
+// importPermissions maps each /api/upload type to the permission it needs.
+var importPermissions = map[string]Permission{
+	"pay-application": PermissionImportPayApps,
+	"cost-ledger":     PermissionImportLedger,
+	"bid":             PermissionImportBids,
+	"baseline":        PermissionImportBaselines,
+}
+
+// ImportPermission returns the permission needed to upload importType.
+func ImportPermission(importType string) (Permission, bool) {
+	p, ok := importPermissions[importType]
+	return p, ok
+}
+
+// ValidRole reports whether role is a known user role.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// User is an account without its password hash.
+type User struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Can reports whether the user's role grants p. An empty permission is
+// granted to every user.
+func (u *User) Can(p Permission) bool {
+	if p == "" {
+		return true
+	}
+	for _, granted := range rolePermissions[u.Role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Session is a sign-in. Token goes in the session cookie; only its hash is
+// stored.
+type Session struct {
+	Token     string
+	ExpiresAt time.Time
+	User      *User
+}
+
+// NormalizeUsername trims and lowercases a username, so sign-in is case
+// insensitive.
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// ValidateUsername checks a normalized username fits the users table.
+func ValidateUsername(username string) error {
+	if username == "" {
+		return fmt.Errorf("username is required")
+	}
+	if len(username) > 100 {
+		return fmt.Errorf("username must be 100 characters or fewer")
+	}
+	return nil
+}
+
+// ValidatePassword checks a new password's length. bcrypt ignores bytes past
+// 72, so longer passwords are refused rather than silently truncated.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	if len(password) > 72 {
+		return fmt.Errorf("password must be 72 bytes or fewer")
+	}
+	return nil
+}
+
+// ListUsers returns every account, by username.
+func ListUsers(ctx context.Context, q *database.Queries) ([]User, error) {
+	rows, err := q.ListUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	users := make([]User, 0, len(rows))
+	for _, row := range rows {
+		users = append(users, *userFromRow(row))
+	}
+	return users, nil
+}
+
+// CreateUser adds an account. The username is normalized; the role and
+// password must already be valid.
+func CreateUser(ctx context.Context, q *database.Queries, username, password, role string) (*User, error) {
+	username = NormalizeUsername(username)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	row, err := q.CreateUser(ctx, database.CreateUserParams{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+	})
+	if isPQError(err, pqUniqueViolation) {
+		return nil, fmt.Errorf("%w: %s", ErrUserExists, username)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user %s: %w", username, err)
+	}
+	return userFromRow(row), nil
+}
+
+// UpdateUser sets an account's role and disabled flag; nil leaves that one
+// as it is. Disabling a user signs them out everywhere.
+func UpdateUser(ctx context.Context, q *database.Queries, username string, newRole *string, newDisabled *bool) (*User, error) {
+	current, err := getUser(ctx, q, username)
+	if err != nil {
+		return nil, err
+	}
+	role, disabled := current.Role, current.Disabled
+	if newRole != nil {
+		role = *newRole
+	}
+	if newDisabled != nil {
+		disabled = *newDisabled
+	}
+	if role != RoleAdmin || disabled {
+		if err := checkNotLastAdmin(ctx, q, current); err != nil {
+			return nil, err
+		}
+	}
+
+	row, err := q.UpdateUser(ctx, database.UpdateUserParams{
+		Username: current.Username,
+		Role:     role,
+		Disabled: disabled,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user %s: %w", current.Username, err)
+	}
+	if disabled {
+		if err := q.DeleteUserSessions(ctx, row.ID); err != nil {
+			return nil, fmt.Errorf("failed to sign out user %s: %w", row.Username, err)
+		}
+	}
+	return userFromRow(row), nil
+}
+
+// SetPassword replaces an account's password and signs it out everywhere.
+// The password must already be valid.
+func SetPassword(ctx context.Context, q *database.Queries, username, password string) error {
+	user, err := getUser(ctx, q, username)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	err = q.UpdateUserPassword(ctx, database.UpdateUserPasswordParams{
+		ID:           user.ID,
+		PasswordHash: string(hash),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set password for %s: %w", user.Username, err)
+	}
+	if err := q.DeleteUserSessions(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to sign out user %s: %w", user.Username, err)
+	}
+	return nil
+}
+
+// ChangePassword replaces an account's password after checking its current
+// one, returning ErrInvalidCredentials if that is wrong. The new password
+// must already be valid.
+func ChangePassword(ctx context.Context, q *database.Queries, username, current, password string) error {
+	user, err := getUser(ctx, q, username)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		return ErrInvalidCredentials
+	}
+	return SetPassword(ctx, q, user.Username, password)
+}
+
+// DeleteUser removes an account and its sessions.
+func DeleteUser(ctx context.Context, q *database.Queries, username string) error {
+	user, err := getUser(ctx, q, username)
+	if err != nil {
+		return err
+	}
+	if err := checkNotLastAdmin(ctx, q, user); err != nil {
+		return err
+	}
+	if err := q.DeleteUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("failed to delete user %s: %w", user.Username, err)
+	}
+	return nil
+}
+
+// EnsureAdmin creates an admin account when there are no accounts at all, so
+// a new install can be signed in to. It does nothing once any user exists.
+func EnsureAdmin(ctx context.Context, q *database.Queries, username, password string) (bool, error) {
+	count, err := q.CountUsers(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to count users: %w", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if err := ValidateUsername(NormalizeUsername(username)); err != nil {
+		return false, err
+	}
+	if err := ValidatePassword(password); err != nil {
+		return false, err
+	}
+	if _, err := CreateUser(ctx, q, username, password, RoleAdmin); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SignIn checks a username and password and starts a session. Unknown users,
+// wrong passwords and disabled accounts all return ErrInvalidCredentials.
+func SignIn(ctx context.Context, q *database.Queries, username, password string) (*Session, error) {
+	row, err := q.GetUserByUsername(ctx, NormalizeUsername(username))
+	if errors.Is(err, sql.ErrNoRows) {
+		// Spend the same time as a real check so response timing doesn't
+		// reveal which usernames exist.
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user: %w", err)
+	}
+	if bcrypt.CompareHashAndPassword([]byte(row.PasswordHash), []byte(password)) != nil || row.Disabled {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Opportunistic cleanup; a failure here shouldn't block the sign-in.
+	q.DeleteExpiredSessions(ctx)
+
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	s := &Session{
+		Token:     base64.RawURLEncoding.EncodeToString(token),
+		ExpiresAt: time.Now().Add(SessionTTL),
+		User:      userFromRow(row),
+	}
+	err = q.CreateSession(ctx, database.CreateSessionParams{
+		TokenHash: hashSessionToken(s.Token),
+		UserID:    row.ID,
+		ExpiresAt: s.ExpiresAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+	return s, nil
+}
+
+// SessionUser returns the user signed in with token, or ErrNoSession if the
+// session is unknown, expired or belongs to a disabled account.
+func SessionUser(ctx context.Context, q *database.Queries, token string) (*User, error) {
+	if token == "" {
+		return nil, ErrNoSession
+	}
+	row, err := q.GetSessionUser(ctx, hashSessionToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSession
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check session: %w", err)
+	}
+	return userFromRow(row), nil
+}
+
+// SignOut ends the session for token.
+func SignOut(ctx context.Context, q *database.Queries, token string) error {
+	if err := q.DeleteSession(ctx, hashSessionToken(token)); err != nil {
+		return fmt.Errorf("failed to end session: %w", err)
+	}
+	return nil
+}
+
+// dummyPasswordHash is compared against when signing in as an unknown user.
+// It is made on first use so programs that never sign in don't pay for it.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("not a real password"), bcrypt.DefaultCost)
+	return hash
+})
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func getUser(ctx context.Context, q *database.Queries, username string) (*database.User, error) {
+	username = NormalizeUsername(username)
+	row, err := q.GetUserByUsername(ctx, username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find user %s: %w", username, err)
+	}
+	return &row, nil
+}
+
+// checkNotLastAdmin refuses to demote, disable or delete u if it is the only
+// enabled admin.
+func checkNotLastAdmin(ctx context.Context, q *database.Queries, u *database.User) error {
+	if u.Role != RoleAdmin || u.Disabled {
+		return nil
+	}
+	count, err := q.CountActiveAdmins(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to count admins: %w", err)
+	}
+	if count <= 1 {
+		return fmt.Errorf("%w: %s is the last one", ErrLastAdmin, u.Username)
+	}
+	return nil
+}
+
+func userFromRow(row database.User) *User {
+	return &User{
+		ID:        row.ID,
+		Username:  row.Username,
+		Role:      row.Role,
+		Disabled:  row.Disabled,
+		CreatedAt: row.CreatedAt.Time,
+		UpdatedAt: row.UpdatedAt.Time,
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import "testing"
+
+func TestUserCan(t *testing.T) {
+	tests := []struct {
+		role string
+		perm Permission
+		want bool
+	}{
+		{RoleViewer, "", true},
+		{RoleViewer, PermissionImportPayApps, false},
+		{RoleViewer, PermissionEditJobs, false},
+		{RoleProjectManager, PermissionImportPayApps, true},
+		{RoleProjectManager, PermissionImportBids, true},
+		{RoleProjectManager, PermissionEditJobs, true},
+		{RoleProjectManager, PermissionImportLedger, false},
+		{RoleProjectManager, PermissionClosePeriods, false},
+		{RoleProjectManager, PermissionManageUsers, false},
+		{RoleAccounting, PermissionImportLedger, true},
+		{RoleAccounting, PermissionEditAccounting, true},
+		{RoleAccounting, PermissionClosePeriods, true},
+		{RoleAccounting, PermissionImportBids, false},
+		{RoleAccounting, PermissionEditJobs, false},
+		{RoleAccounting, PermissionManageUsers, false},
+		{RoleAdmin, PermissionManageUsers, true},
+		{RoleAdmin, PermissionClosePeriods, true},
+		{RoleAdmin, PermissionImportBaselines, true},
+		{"unknown", "", true},
+		{"unknown", PermissionImportPayApps, false},
+	}
+
+	for _, tt := range tests {
+		u := &User{Role: tt.role}
+		if got := u.Can(tt.perm); got != tt.want {
+			t.Errorf("%s.Can(%q) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestAdminHasEveryPermission(t *testing.T) {
+	admin := &User{Role: RoleAdmin}
+	for role, perms := range rolePermissions {
+		for _, p := range perms {
+			if !admin.Can(p) {
+				t.Errorf("admin lacks %q, which %s has", p, role)
+			}
+		}
+	}
+}
+
+func TestImportPermission(t *testing.T) {
+	tests := []struct {
+		importType string
+		want       Permission
+		wantOK     bool
+	}{
+		{"pay-application", PermissionImportPayApps, true},
+		{"cost-ledger", PermissionImportLedger, true},
+		{"bid", PermissionImportBids, true},
+		{"baseline", PermissionImportBaselines, true},
+		{"", "", false},
+		{"payroll", "", false},
+	}
+
+	for _, tt := range tests {
+		got, ok := ImportPermission(tt.importType)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ImportPermission(%q) = %q, %v, want %q, %v", tt.importType, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
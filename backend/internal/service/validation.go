@@ -224,7 +224,43 @@ func ValidateMonthlyAmounts(ctx context.Context, q *database.Queries, jobID uuid
 	return result, nil
 }
 
-// ValidateAll runs both budget and monthly amount validations.
+// ValidateStoredMaterials warns when more materials were reported installed out
+// of storage than the previous balance plus the month's rise in balance.
+func ValidateStoredMaterials(ctx context.Context, q *database.Queries, jobID uuid.UUID) (*ValidationResult, error) {
+	result := &ValidationResult{IsValid: true}
+
+	rows, err := q.GetStoredMaterialsLedger(ctx, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("fetching stored materials: %w", err)
+	}
+
+	for _, row := range rows {
+		previous, err := decimal.NewFromString(row.PreviousBalance)
+		if err != nil {
+			continue
+		}
+		added, err := decimal.NewFromString(row.MaterialsAdded)
+		if err != nil {
+			continue
+		}
+		installed, err := decimal.NewFromString(row.MaterialsInstalled)
+		if err != nil {
+			continue
+		}
+
+		available := previous.Add(added)
+		if installed.Sub(available).GreaterThan(tolerance) {
+			result.Warnings = append(result.Warnings,
+				fmt.Sprintf("Item %s, Month %s - installed $%s from storage but only $%s was stored",
+					row.ItemNumber, row.PayAppMonth.Format("Jan-2006"),
+					installed.StringFixed(2), available.StringFixed(2)))
+		}
+	}
+
+	return result, nil
+}
+
+// ValidateAll runs the budget, monthly amount and stored materials validations.
 func ValidateAll(ctx context.Context, q *database.Queries, jobID uuid.UUID) (*ValidationResult, error) {
 	combined := &ValidationResult{IsValid: true}
 
@@ -250,6 +286,13 @@ func ValidateAll(ctx context.Context, q *database.Queries, jobID uuid.UUID) (*Va
 		combined.IsValid = false
 	}
 
+	materialsResult, err := ValidateStoredMaterials(ctx, q, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("stored materials validation: %w", err)
+	}
+
+	combined.Warnings = append(combined.Warnings, materialsResult.Warnings...)
+
 	return combined, nil
 }
 
@@ -260,7 +303,14 @@ func ValidateAll(ctx context.Context, q *database.Queries, jobID uuid.UUID) (*Va
 // 1. Calculate parent's percent complete: cumulative_qty_to_date / total_qty
 // 2. For each child: new_qty = (child.total_qty * parent_pct) - child.previous_cumulative
 // 3. Upsert child pay_applications
+// 4. Record the month as a new pay app revision
+//
+// A closed target month is rejected with ErrPeriodClosed.
 func DistributeParentQty(ctx context.Context, q *database.Queries, jobID uuid.UUID, targetMonth time.Time) (*DistributionResult, error) {
+	if err := checkPeriodOpen(ctx, q, jobID, targetMonth); err != nil {
+		return nil, err
+	}
+
 	result := &DistributionResult{
 		MonthsProcessed: []time.Time{targetMonth},
 	}
@@ -378,5 +428,12 @@ func DistributeParentQty(ctx context.Context, q *database.Queries, jobID uuid.UU
 		}
 	}
 
+	// The distributed quantities become the month's effective revision
+	if result.ItemsUpdated > 0 {
+		if _, err := recordPayAppRevision(ctx, q, jobID, targetMonth, "distributed from parent items"); err != nil {
+			return nil, fmt.Errorf("recording revision: %w", err)
+		}
+	}
+
 	return result, nil
 }
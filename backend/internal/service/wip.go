@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lostboys08/ksc-go/backend/internal/database"
+	"github.com/shopspring/decimal"
+	"github.com/xuri/excelize/v2"
+)
+
+// WIPRow is one job's line on the work-in-progress schedule. Percent complete
+// is cost-to-cost: cost to date over estimated total cost. Over billings are
+// billed in excess of earned revenue; under billings are earned revenue not
+// yet billed. Only one of the two is non-zero on a row.
+type WIPRow struct {
+	JobNumber            string          `json:"job_number" report:"Job"`
+	JobName              string          `json:"job_name" report:"Job Name"`
+	ContractValue        decimal.Decimal `json:"contract_value" report:"Contract Value"`
+	EstimatedCost        decimal.Decimal `json:"estimated_cost" report:"Estimated Total Cost"`
+	EstimatedGrossProfit decimal.Decimal `json:"estimated_gross_profit" report:"Estimated Gross Profit"`
+	CostToDate           decimal.Decimal `json:"cost_to_date" report:"Cost To Date"`
+	CostToComplete       decimal.Decimal `json:"cost_to_complete" report:"Cost To Complete"`
+	PercentComplete      decimal.Decimal `json:"percent_complete" report:"Percent Complete"`
+	EarnedRevenue        decimal.Decimal `json:"earned_revenue" report:"Earned Revenue"`
+	GrossProfitToDate    decimal.Decimal `json:"gross_profit_to_date" report:"Gross Profit To Date"`
+	BilledToDate         decimal.Decimal `json:"billed_to_date" report:"Billed To Date"`
+	OverBillings         decimal.Decimal `json:"over_billings" report:"Over Billings"`
+	UnderBillings        decimal.Decimal `json:"under_billings" report:"Under Billings"`
+}
+
+// WIPSchedule is the work-in-progress schedule for every active job as of the
+// end of a month, with a totals row.
+type WIPSchedule struct {
+	AsOf   string   `json:"as_of"`
+	Rows   []WIPRow `json:"rows"`
+	Totals WIPRow   `json:"totals"`
+}
+
+// WIPExportSheet is the name of the worksheet written by ExportWIPSchedule.
+const WIPExportSheet = "WIP"
+
+// GetWIPSchedule builds the work-in-progress schedule as of the end of the
+// given month. When cost to date has already passed the estimate, the estimate
+// is raised to cost to date so the job shows as complete rather than over 100%.
+func GetWIPSchedule(ctx context.Context, q *database.Queries, asOf time.Time) (*WIPSchedule, error) {
+	rows, err := q.GetWIPSchedule(ctx, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch WIP data: %w", err)
+	}
+
+	wip := &WIPSchedule{
+		AsOf:   asOf.Format("2006-01"),
+		Rows:   make([]WIPRow, 0, len(rows)),
+		Totals: WIPRow{JobName: "Total"},
+	}
+	for _, row := range rows {
+		w := WIPRow{JobNumber: row.JobNumber, JobName: row.JobName}
+		if err := parseDecimals(
+			decimalField{&w.ContractValue, row.ContractValue},
+			decimalField{&w.EstimatedCost, row.EstimatedCost},
+			decimalField{&w.CostToDate, row.CostToDate},
+			decimalField{&w.BilledToDate, row.BilledToDate},
+		); err != nil {
+			return nil, fmt.Errorf("invalid value for job %s: %w", row.JobNumber, err)
+		}
+
+		if w.CostToDate.GreaterThan(w.EstimatedCost) {
+			w.EstimatedCost = w.CostToDate
+		}
+		w.EstimatedGrossProfit = w.ContractValue.Sub(w.EstimatedCost)
+		w.CostToComplete = w.EstimatedCost.Sub(w.CostToDate)
+
+		if w.EstimatedCost.IsPositive() {
+			fraction := w.CostToDate.Div(w.EstimatedCost)
+			w.PercentComplete = fraction.Mul(hundred).Round(2)
+			w.EarnedRevenue = w.ContractValue.Mul(fraction).Round(2)
+		}
+		w.GrossProfitToDate = w.EarnedRevenue.Sub(w.CostToDate)
+
+		if diff := w.BilledToDate.Sub(w.EarnedRevenue); diff.IsPositive() {
+			w.OverBillings = diff
+		} else {
+			w.UnderBillings = diff.Neg()
+		}
+
+		addWIPTotals(&wip.Totals, w)
+		wip.Rows = append(wip.Rows, w)
+	}
+
+	if wip.Totals.EstimatedCost.IsPositive() {
+		wip.Totals.PercentComplete = wip.Totals.CostToDate.Div(wip.Totals.EstimatedCost).Mul(hundred).Round(2)
+	}
+
+	return wip, nil
+}
+
+// addWIPTotals adds a job's dollar columns into the totals row.
+func addWIPTotals(t *WIPRow, w WIPRow) {
+	t.ContractValue = t.ContractValue.Add(w.ContractValue)
+	t.EstimatedCost = t.EstimatedCost.Add(w.EstimatedCost)
+	t.EstimatedGrossProfit = t.EstimatedGrossProfit.Add(w.EstimatedGrossProfit)
+	t.CostToDate = t.CostToDate.Add(w.CostToDate)
+	t.CostToComplete = t.CostToComplete.Add(w.CostToComplete)
+	t.EarnedRevenue = t.EarnedRevenue.Add(w.EarnedRevenue)
+	t.GrossProfitToDate = t.GrossProfitToDate.Add(w.GrossProfitToDate)
+	t.BilledToDate = t.BilledToDate.Add(w.BilledToDate)
+	t.OverBillings = t.OverBillings.Add(w.OverBillings)
+	t.UnderBillings = t.UnderBillings.Add(w.UnderBillings)
+}
+
+// wipExportHeaders are the WIP schedule columns in the order bonding companies
+// and CPAs expect them.
+var wipExportHeaders = []string{
+	"Job #",
+	"Job Name",
+	"Contract Amount",
+	"Est. Total Cost",
+	"Est. Gross Profit",
+	"Cost To Date",
+	"Cost To Complete",
+	"% Complete",
+	"Earned Revenue",
+	"Gross Profit To Date",
+	"Billed To Date",
+	"Over Billings",
+	"Under Billings",
+}
+
+// ExportWIPSchedule writes the WIP schedule to a new workbook: a title and
+// as-of line, the column headers, one row per job and a bold totals row.
+func ExportWIPSchedule(ctx context.Context, q *database.Queries, asOf time.Time) (*excelize.File, error) {
+	wip, err := GetWIPSchedule(ctx, q, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	f := excelize.NewFile()
+	if err := f.SetSheetName(f.GetSheetName(0), WIPExportSheet); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to name sheet: %w", err)
+	}
+
+	title := []interface{}{"Work In Progress Schedule"}
+	subtitle := []interface{}{"As of " + asOf.AddDate(0, 1, -1).Format("January 2, 2006")}
+	headers := wipExportHeaders
+	for cell, values := range map[string]interface{}{"A1": &title, "A2": &subtitle, "A4": &headers} {
+		if err := f.SetSheetRow(WIPExportSheet, cell, values); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write heading: %w", err)
+		}
+	}
+
+	for i, w := range wip.Rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+5)
+		values := wipExportRow(w)
+		if err := f.SetSheetRow(WIPExportSheet, cell, &values); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write job %s: %w", w.JobNumber, err)
+		}
+	}
+
+	totalRow := len(wip.Rows) + 5
+	totalCell, _ := excelize.CoordinatesToCellName(1, totalRow)
+	totals := wipExportRow(wip.Totals)
+	if err := f.SetSheetRow(WIPExportSheet, totalCell, &totals); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to write totals: %w", err)
+	}
+
+	if err := styleWIPSheet(f, totalRow); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to format sheet: %w", err)
+	}
+
+	return f, nil
+}
+
+// wipExportRow builds the cell values for one WIP row. Percent complete is
+// written as a fraction so the percent number format displays it.
+func wipExportRow(w WIPRow) []interface{} {
+	money := func(d decimal.Decimal) interface{} {
+		v, _ := d.Float64()
+		return v
+	}
+	return []interface{}{
+		w.JobNumber,
+		w.JobName,
+		money(w.ContractValue),
+		money(w.EstimatedCost),
+		money(w.EstimatedGrossProfit),
+		money(w.CostToDate),
+		money(w.CostToComplete),
+		money(w.PercentComplete.Div(hundred)),
+		money(w.EarnedRevenue),
+		money(w.GrossProfitToDate),
+		money(w.BilledToDate),
+		money(w.OverBillings),
+		money(w.UnderBillings),
+	}
+}
+
+// styleWIPSheet applies the title, header, number and totals formatting.
+func styleWIPSheet(f *excelize.File, totalRow int) error {
+	lastCol, _ := excelize.ColumnNumberToName(len(wipExportHeaders))
+
+	bold, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return err
+	}
+	title, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}})
+	if err != nil {
+		return err
+	}
+	header, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: true},
+		Alignment: &excelize.Alignment{WrapText: true, Horizontal: "center"},
+		Border:    []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}},
+	})
+	if err != nil {
+		return err
+	}
+	money, err := f.NewStyle(&excelize.Style{NumFmt: 3}) // #,##0
+	if err != nil {
+		return err
+	}
+	percent, err := f.NewStyle(&excelize.Style{NumFmt: 10}) // 0.00%
+	if err != nil {
+		return err
+	}
+	totalMoney, err := f.NewStyle(&excelize.Style{
+		NumFmt: 3,
+		Font:   &excelize.Font{Bold: true},
+		Border: []excelize.Border{{Type: "top", Color: "000000", Style: 1}, {Type: "bottom", Color: "000000", Style: 6}},
+	})
+	if err != nil {
+		return err
+	}
+	totalPercent, err := f.NewStyle(&excelize.Style{
+		NumFmt: 10,
+		Font:   &excelize.Font{Bold: true},
+		Border: []excelize.Border{{Type: "top", Color: "000000", Style: 1}, {Type: "bottom", Color: "000000", Style: 6}},
+	})
+	if err != nil {
+		return err
+	}
+
+	cells := func(col string, row int) string { return fmt.Sprintf("%s%d", col, row) }
+	for _, s := range []struct {
+		from, to string
+		style    int
+	}{
+		{"A1", "A1", title},
+		{"A2", "A2", bold},
+		{"A4", cells(lastCol, 4), header},
+		{"C5", cells(lastCol, totalRow), money},
+		{"H5", cells("H", totalRow), percent},
+		{cells("A", totalRow), cells("B", totalRow), bold},
+		{cells("C", totalRow), cells(lastCol, totalRow), totalMoney},
+		{cells("H", totalRow), cells("H", totalRow), totalPercent},
+	} {
+		if err := f.SetCellStyle(WIPExportSheet, s.from, s.to, s.style); err != nil {
+			return err
+		}
+	}
+
+	if err := f.SetColWidth(WIPExportSheet, "A", "A", 10); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(WIPExportSheet, "B", "B", 32); err != nil {
+		return err
+	}
+	if err := f.SetColWidth(WIPExportSheet, "C", lastCol, 15); err != nil {
+		return err
+	}
+
+	return f.SetPanes(WIPExportSheet, &excelize.Panes{
+		Freeze:      true,
+		XSplit:      2,
+		YSplit:      4,
+		TopLeftCell: "C5",
+		ActivePane:  "bottomRight",
+	})
+}
@@ -21,7 +21,52 @@ RETURNING id;
 SELECT id, job_number, job_name FROM jobs ORDER BY job_number;
 
 -- name: GetJobByNumber :one
-SELECT id, job_number, job_name FROM jobs WHERE job_number = $1;
+SELECT id, job_number, job_name, contract_value, address, scr_number,
+       contract_complete_date, start_date, end_date, created_at, updated_at, status
+FROM jobs
+WHERE job_number = $1;
+
+-- name: LockJob :exec
+-- Locks a job's row until the transaction ends, so a period close waits for
+-- pay application writes already under way and they wait for it
+SELECT id FROM jobs WHERE id = $1 FOR UPDATE;
+
+-- name: CreateJob :one
+INSERT INTO jobs (
+    job_number, job_name, contract_value, address,
+    scr_number, contract_complete_date, start_date, end_date, status
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING id, job_number, job_name, contract_value, address, scr_number,
+          contract_complete_date, start_date, end_date, created_at, updated_at, status;
+
+-- name: UpdateJob :one
+-- Replaces a job's editable contract metadata
+UPDATE jobs
+SET job_name = $2,
+    contract_value = $3,
+    address = $4,
+    scr_number = $5,
+    contract_complete_date = $6,
+    start_date = $7,
+    end_date = $8,
+    status = $9,
+    updated_at = NOW()
+WHERE job_number = $1
+RETURNING id, job_number, job_name, contract_value, address, scr_number,
+          contract_complete_date, start_date, end_date, created_at, updated_at, status;
+
+-- name: CountJobLedgerRows :one
+-- Counts a job's ledger rows, which refer to the job by number without a foreign key
+SELECT COUNT(*) FROM job_cost_ledger WHERE job = $1;
+
+-- name: DeleteJob :exec
+DELETE FROM jobs WHERE job_number = $1;
+
+-- name: InsertJobStatusChange :exec
+INSERT INTO job_status_changes (job_id, status)
+VALUES ($1, $2);
 
 -- name: UpsertJobItem :one
 INSERT INTO job_items (
@@ -235,32 +280,131 @@ LEFT JOIN pay_application_cumulative pac ON pa.job_item_id = pac.job_item_id AND
 WHERE pa.job_item_id = $1 AND pa.pay_app_month = $2;
 
 -- name: InsertJobCostLedger :exec
--- Inserts a job cost ledger entry, skips if hash already exists
+-- Inserts a job cost ledger entry. If the hash already exists, hours and units
+-- the row doesn't have yet are filled in, so re-importing an export with those
+-- columns backfills them; a row's hours and units are never overwritten
 INSERT INTO job_cost_ledger (
-    id, job, phase, cat, transaction_type, transaction_date, amount
+    id, job, phase, cat, transaction_type, transaction_date, amount, hours, units
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
 )
-ON CONFLICT (id) DO NOTHING;
+ON CONFLICT (id) DO UPDATE SET
+    hours = COALESCE(job_cost_ledger.hours, EXCLUDED.hours),
+    units = COALESCE(job_cost_ledger.units, EXCLUDED.units);
 
 -- name: GetJobCostLedgerByJob :many
 -- Fetches all job cost ledger entries for a specific job
-SELECT id, job, phase, cat, transaction_type, transaction_date, amount, created_at
+SELECT id, job, phase, cat, transaction_type, transaction_date, amount, created_at, hours, units
 FROM job_cost_ledger
 WHERE job = $1
 ORDER BY transaction_date;
 
+-- name: SearchJobCostLedger :many
+-- Fetches one page of a job's ledger entries matching the optional filters.
+-- phase, cat and transaction_type match exactly and class matches the
+-- transaction type's class; dates and amounts are inclusive
+-- Entries are ordered by sort_by (transaction_date, phase, cat,
+-- transaction_type, amount, hours or units), then date, then id, all reversed
+-- when descending. Missing values sort as '' or 0. A page starts after the
+-- after_* position, taken from the sort_text, sort_number, date_text and id of
+-- the previous page's last row; row_limit caps the rows returned
+WITH matches AS (
+    SELECT
+        id, job, phase, cat, transaction_type, transaction_date, amount, created_at, hours, units,
+        CASE sqlc.arg(sort_by)::TEXT
+            WHEN 'transaction_date' THEN COALESCE(transaction_date::TEXT, '')
+            WHEN 'phase' THEN COALESCE(phase, '')
+            WHEN 'cat' THEN COALESCE(cat, '')
+            WHEN 'transaction_type' THEN COALESCE(transaction_type, '')
+            ELSE ''
+        END AS sort_text,
+        CASE sqlc.arg(sort_by)::TEXT
+            WHEN 'amount' THEN amount
+            WHEN 'hours' THEN COALESCE(hours, 0)
+            WHEN 'units' THEN COALESCE(units, 0)
+            ELSE 0
+        END AS sort_number,
+        COALESCE(transaction_date::TEXT, '') AS date_text
+    FROM job_cost_ledger
+    WHERE job = sqlc.arg(job)
+      AND (sqlc.narg(phase)::TEXT IS NULL OR phase = sqlc.narg(phase))
+      AND (sqlc.narg(cat)::TEXT IS NULL OR cat = sqlc.narg(cat))
+      AND (sqlc.narg(transaction_type)::TEXT IS NULL OR transaction_type = sqlc.narg(transaction_type))
+      AND (sqlc.narg(class)::TEXT IS NULL OR transaction_type IN (
+          SELECT transaction_type FROM transaction_type_classes WHERE class = sqlc.narg(class)
+      ))
+      AND (sqlc.narg(from_date)::DATE IS NULL OR transaction_date >= sqlc.narg(from_date))
+      AND (sqlc.narg(to_date)::DATE IS NULL OR transaction_date <= sqlc.narg(to_date))
+      AND (sqlc.narg(min_amount)::NUMERIC IS NULL OR amount >= sqlc.narg(min_amount))
+      AND (sqlc.narg(max_amount)::NUMERIC IS NULL OR amount <= sqlc.narg(max_amount))
+)
+SELECT
+    id, job, phase, cat, transaction_type, transaction_date, amount, created_at, hours, units,
+    sort_text::TEXT AS sort_text,
+    sort_number::TEXT AS sort_number,
+    date_text::TEXT AS date_text
+FROM matches
+WHERE sqlc.narg(after_id)::TEXT IS NULL
+   OR (NOT sqlc.arg(descending)::BOOLEAN AND (sort_text, sort_number, date_text, id)
+       > (sqlc.narg(after_text)::TEXT, sqlc.narg(after_number)::NUMERIC, sqlc.narg(after_date)::TEXT, sqlc.narg(after_id)::TEXT))
+   OR (sqlc.arg(descending)::BOOLEAN AND (sort_text, sort_number, date_text, id)
+       < (sqlc.narg(after_text)::TEXT, sqlc.narg(after_number)::NUMERIC, sqlc.narg(after_date)::TEXT, sqlc.narg(after_id)::TEXT))
+ORDER BY
+    CASE WHEN NOT sqlc.arg(descending)::BOOLEAN THEN sort_text END,
+    CASE WHEN NOT sqlc.arg(descending)::BOOLEAN THEN sort_number END,
+    CASE WHEN NOT sqlc.arg(descending)::BOOLEAN THEN date_text END,
+    CASE WHEN NOT sqlc.arg(descending)::BOOLEAN THEN id END,
+    sort_text DESC,
+    sort_number DESC,
+    date_text DESC,
+    id DESC
+LIMIT sqlc.arg(row_limit)::INT;
+
+-- name: SearchJobCostLedgerTotals :many
+-- Totals a job's ledger entries matching the same filters as
+-- SearchJobCostLedger, one row per group_by value (phase, cat,
+-- transaction_type or month; '' puts every entry in one group)
+SELECT
+    (CASE sqlc.arg(group_by)::TEXT
+        WHEN 'phase' THEN COALESCE(phase, '')
+        WHEN 'cat' THEN COALESCE(cat, '')
+        WHEN 'transaction_type' THEN COALESCE(transaction_type, '')
+        WHEN 'month' THEN COALESCE(TO_CHAR(transaction_date, 'YYYY-MM'), '')
+        ELSE ''
+    END)::TEXT AS group_key,
+    COUNT(*)::INT AS entries,
+    SUM(amount)::TEXT AS amount,
+    COALESCE(SUM(hours), 0)::TEXT AS hours,
+    COALESCE(SUM(units), 0)::TEXT AS units
+FROM job_cost_ledger
+WHERE job = sqlc.arg(job)
+  AND (sqlc.narg(phase)::TEXT IS NULL OR phase = sqlc.narg(phase))
+  AND (sqlc.narg(cat)::TEXT IS NULL OR cat = sqlc.narg(cat))
+  AND (sqlc.narg(transaction_type)::TEXT IS NULL OR transaction_type = sqlc.narg(transaction_type))
+  AND (sqlc.narg(class)::TEXT IS NULL OR transaction_type IN (
+      SELECT transaction_type FROM transaction_type_classes WHERE class = sqlc.narg(class)
+  ))
+  AND (sqlc.narg(from_date)::DATE IS NULL OR transaction_date >= sqlc.narg(from_date))
+  AND (sqlc.narg(to_date)::DATE IS NULL OR transaction_date <= sqlc.narg(to_date))
+  AND (sqlc.narg(min_amount)::NUMERIC IS NULL OR amount >= sqlc.narg(min_amount))
+  AND (sqlc.narg(max_amount)::NUMERIC IS NULL OR amount <= sqlc.narg(max_amount))
+GROUP BY 1
+ORDER BY 1;
+
 -- name: GetMonthlyPerformance :many
 -- Fetches monthly cost and billed totals for a job from job_cost_ledger
--- Costs = AP cost, JC cost, PR cost; Billed = work billed
+-- Costs and billings are the transaction types classed 'cost' and 'billing'
+-- as_of leaves out ledger rows imported after that time; from_month and to_month
+-- limit the months returned without changing the cumulative totals
 WITH monthly_costs AS (
     SELECT
         DATE_TRUNC('month', jcl.transaction_date)::DATE AS month,
         SUM(jcl.amount) AS cost_total
     FROM job_cost_ledger jcl
     WHERE jcl.job = $1
-      AND jcl.transaction_type IN ('AP cost', 'JC cost', 'PR cost')
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
       AND jcl.transaction_date IS NOT NULL
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
     GROUP BY DATE_TRUNC('month', jcl.transaction_date)
 ),
 monthly_billed AS (
@@ -269,32 +413,43 @@ monthly_billed AS (
         SUM(jcl.amount) AS billed_total
     FROM job_cost_ledger jcl
     WHERE jcl.job = $1
-      AND jcl.transaction_type = 'work billed'
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'billing')
       AND jcl.transaction_date IS NOT NULL
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
     GROUP BY DATE_TRUNC('month', jcl.transaction_date)
     HAVING SUM(jcl.amount) > 0
+),
+performance AS (
+    SELECT
+        mb.month,
+        COALESCE((
+            SELECT SUM(mc.cost_total)
+            FROM monthly_costs mc
+            WHERE mc.month <= mb.month
+        ), 0)::BIGINT AS cost_total,
+        mb.billed_total::BIGINT AS pay_app_total,
+        COALESCE((
+            SELECT SUM(mc.cost_total)
+            FROM monthly_costs mc
+            WHERE mc.month <= mb.month
+        ), 0)::BIGINT AS cumulative_cost,
+        SUM(mb.billed_total) OVER (ORDER BY mb.month)::BIGINT AS cumulative_pay_app
+    FROM monthly_billed mb
 )
-SELECT
-    mb.month,
-    COALESCE((
-        SELECT SUM(mc.cost_total)
-        FROM monthly_costs mc
-        WHERE mc.month <= mb.month
-    ), 0)::BIGINT AS cost_total,
-    mb.billed_total::BIGINT AS pay_app_total,
-    COALESCE((
-        SELECT SUM(mc.cost_total)
-        FROM monthly_costs mc
-        WHERE mc.month <= mb.month
-    ), 0)::BIGINT AS cumulative_cost,
-    SUM(mb.billed_total) OVER (ORDER BY mb.month)::BIGINT AS cumulative_pay_app
-FROM monthly_billed mb
-ORDER BY mb.month;
+SELECT month, cost_total, pay_app_total, cumulative_cost, cumulative_pay_app
+FROM performance
+WHERE (sqlc.narg(from_month)::DATE IS NULL OR month >= sqlc.narg(from_month))
+  AND (sqlc.narg(to_month)::DATE IS NULL OR month <= sqlc.narg(to_month))
+ORDER BY month;
 
 -- name: GetCostPerformanceIndex :many
 -- Fetches monthly CPI data: Earned Value / Actual Costs
--- Earned Value = (cumulative qty / total qty) * original budget
--- Falls back to sum of scheduled_value if contract_value is not set
+-- Earned Value = (cumulative qty / total qty) * revised budget
+-- Budget is contract_value plus approved change orders, falling back to the
+-- revised sum of qty * unit_price if contract_value is not set
+-- as_of leaves out ledger rows imported and change orders changed after that
+-- time and reads each month's pay app as the revision submitted by then;
+-- from_month and to_month limit the months returned
 WITH job_info AS (
     SELECT id, job_number, contract_value
     FROM jobs
@@ -305,15 +460,23 @@ total_scheduled AS (
     SELECT
         SUM(ji.qty) AS total_qty,
         SUM(ji.qty * ji.unit_price) AS total_contract_value
-    FROM job_items ji
+    FROM job_items_revised ji
     JOIN job_info j ON ji.job_id = j.id
     WHERE ji.qty > 0
 ),
+approved_change_orders AS (
+    SELECT COALESCE(SUM(co.amount), 0) AS amount
+    FROM change_orders co
+    JOIN job_info j ON co.job_id = j.id
+    WHERE co.status = 'approved'
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR co.updated_at <= sqlc.narg(as_of))
+),
 budget_value AS (
     -- Use contract_value if set, otherwise fall back to sum of qty * unit_price
-    SELECT COALESCE(j.contract_value, ts.total_contract_value, 0) AS budget
+    SELECT COALESCE(j.contract_value + aco.amount, ts.total_contract_value, 0) AS budget
     FROM job_info j
     CROSS JOIN total_scheduled ts
+    CROSS JOIN approved_change_orders aco
 ),
 monthly_cumulative_qty AS (
     -- Cumulative quantity billed per month
@@ -321,7 +484,7 @@ monthly_cumulative_qty AS (
         pa.pay_app_month AS month,
         SUM(pa.qty) AS month_qty,
         SUM(SUM(pa.qty)) OVER (ORDER BY pa.pay_app_month) AS cumulative_qty
-    FROM pay_applications pa
+    FROM pay_applications_as_of(sqlc.narg(as_of)::TIMESTAMP) pa
     JOIN job_items ji ON pa.job_item_id = ji.id
     JOIN job_info j ON ji.job_id = j.id
     GROUP BY pa.pay_app_month
@@ -332,42 +495,49 @@ monthly_costs AS (
         SUM(amount) AS cost_total
     FROM job_cost_ledger
     WHERE job = $1
-      AND transaction_type IN ('AP cost', 'JC cost', 'PR cost')
+      AND transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
       AND transaction_date IS NOT NULL
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR created_at <= sqlc.narg(as_of))
     GROUP BY DATE_TRUNC('month', transaction_date)
+),
+cpi AS (
+    SELECT
+        mcq.month,
+        COALESCE(bv.budget, 0)::BIGINT AS budget,
+        COALESCE(ts.total_qty, 0)::TEXT AS total_scheduled_qty,
+        mcq.cumulative_qty::TEXT AS cumulative_qty,
+        CASE
+            WHEN COALESCE(ts.total_qty, 0) > 0
+            THEN ROUND((mcq.cumulative_qty / ts.total_qty) * 100, 2)::TEXT
+            ELSE '0'
+        END AS percent_complete,
+        CASE
+            WHEN COALESCE(ts.total_qty, 0) > 0 AND COALESCE(bv.budget, 0) > 0
+            THEN ROUND((mcq.cumulative_qty / ts.total_qty) * bv.budget, 2)::BIGINT
+            ELSE 0::BIGINT
+        END AS earned_value,
+        COALESCE(SUM(mc.cost_total) OVER (ORDER BY mcq.month), 0)::BIGINT AS actual_cost,
+        CASE
+            WHEN COALESCE(SUM(mc.cost_total) OVER (ORDER BY mcq.month), 0) > 0
+                 AND COALESCE(ts.total_qty, 0) > 0
+                 AND COALESCE(bv.budget, 0) > 0
+            THEN ROUND(
+                ((mcq.cumulative_qty / ts.total_qty) * bv.budget) /
+                SUM(mc.cost_total) OVER (ORDER BY mcq.month),
+                2
+            )::TEXT
+            ELSE '0'
+        END AS cpi
+    FROM monthly_cumulative_qty mcq
+    CROSS JOIN budget_value bv
+    CROSS JOIN total_scheduled ts
+    LEFT JOIN monthly_costs mc ON mc.month = mcq.month
 )
-SELECT
-    mcq.month,
-    COALESCE(bv.budget, 0)::BIGINT AS budget,
-    COALESCE(ts.total_qty, 0)::TEXT AS total_scheduled_qty,
-    mcq.cumulative_qty::TEXT AS cumulative_qty,
-    CASE
-        WHEN COALESCE(ts.total_qty, 0) > 0
-        THEN ROUND((mcq.cumulative_qty / ts.total_qty) * 100, 2)::TEXT
-        ELSE '0'
-    END AS percent_complete,
-    CASE
-        WHEN COALESCE(ts.total_qty, 0) > 0 AND COALESCE(bv.budget, 0) > 0
-        THEN ROUND((mcq.cumulative_qty / ts.total_qty) * bv.budget, 2)::BIGINT
-        ELSE 0::BIGINT
-    END AS earned_value,
-    COALESCE(SUM(mc.cost_total) OVER (ORDER BY mcq.month), 0)::BIGINT AS actual_cost,
-    CASE
-        WHEN COALESCE(SUM(mc.cost_total) OVER (ORDER BY mcq.month), 0) > 0
-             AND COALESCE(ts.total_qty, 0) > 0
-             AND COALESCE(bv.budget, 0) > 0
-        THEN ROUND(
-            ((mcq.cumulative_qty / ts.total_qty) * bv.budget) /
-            SUM(mc.cost_total) OVER (ORDER BY mcq.month),
-            2
-        )::TEXT
-        ELSE '0'
-    END AS cpi
-FROM monthly_cumulative_qty mcq
-CROSS JOIN budget_value bv
-CROSS JOIN total_scheduled ts
-LEFT JOIN monthly_costs mc ON mc.month = mcq.month
-ORDER BY mcq.month;
+SELECT month, budget, total_scheduled_qty, cumulative_qty, percent_complete, earned_value, actual_cost, cpi
+FROM cpi
+WHERE (sqlc.narg(from_month)::DATE IS NULL OR month >= sqlc.narg(from_month))
+  AND (sqlc.narg(to_month)::DATE IS NULL OR month <= sqlc.narg(to_month))
+ORDER BY month;
 
 -- name: InsertBidItem :exec
 -- Inserts a bid item with all cost columns (for batch import)
@@ -389,14 +559,19 @@ INSERT INTO job_items (
 
 -- name: GetOverBudgetPhases :many
 -- Fetches phase codes where actual costs exceed budget
--- Uses "Original estimate" from job_cost_ledger as the budget source
+-- Uses original budget and budget revision rows from job_cost_ledger as the budget source
+-- Costs can be limited to transactions from from_month through to_month; budget
+-- rows dated after to_month are left out. as_of leaves out rows imported after it
 WITH phase_costs AS (
     SELECT
         jcl.phase,
         SUM(jcl.amount) AS actual_cost
     FROM job_cost_ledger jcl
     WHERE jcl.job = $1
-      AND jcl.transaction_type IN ('AP cost', 'JC cost', 'PR cost')
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+      AND (sqlc.narg(from_month)::DATE IS NULL OR jcl.transaction_date >= sqlc.narg(from_month))
+      AND (sqlc.narg(to_month)::DATE IS NULL OR jcl.transaction_date < sqlc.narg(to_month) + INTERVAL '1 month')
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
     GROUP BY jcl.phase
 ),
 phase_budgets AS (
@@ -405,7 +580,9 @@ phase_budgets AS (
         SUM(jcl.amount) AS budget
     FROM job_cost_ledger jcl
     WHERE jcl.job = $1
-      AND jcl.transaction_type = 'Original estimate'
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class IN ('original_budget', 'budget_revision'))
+      AND (sqlc.narg(to_month)::DATE IS NULL OR jcl.transaction_date IS NULL OR jcl.transaction_date < sqlc.narg(to_month) + INTERVAL '1 month')
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
     GROUP BY jcl.phase
 ),
 phase_descriptions AS (
@@ -428,3 +605,1277 @@ FULL OUTER JOIN phase_costs pc ON pb.phase = pc.phase
 LEFT JOIN phase_descriptions pd ON COALESCE(pb.phase, pc.phase) = pd.phase
 WHERE COALESCE(pc.actual_cost, 0) > COALESCE(pb.budget, 0)
 ORDER BY (COALESCE(pc.actual_cost, 0) - COALESCE(pb.budget, 0)) DESC;
+
+-- name: GetPhaseMonthlyCosts :many
+-- Fetches actual cost per phase, cat and month for a job from job_cost_ledger
+-- from_month and to_month limit the months returned; as_of leaves out rows
+-- imported after it
+SELECT
+    COALESCE(jcl.phase, '')::TEXT AS phase,
+    COALESCE(jcl.cat, '')::TEXT AS cat,
+    DATE_TRUNC('month', jcl.transaction_date)::DATE AS month,
+    SUM(jcl.amount)::TEXT AS actual_cost
+FROM job_cost_ledger jcl
+WHERE jcl.job = $1
+  AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+  AND jcl.transaction_date IS NOT NULL
+  AND (sqlc.narg(from_month)::DATE IS NULL OR jcl.transaction_date >= sqlc.narg(from_month))
+  AND (sqlc.narg(to_month)::DATE IS NULL OR jcl.transaction_date < sqlc.narg(to_month) + INTERVAL '1 month')
+  AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
+GROUP BY COALESCE(jcl.phase, ''), COALESCE(jcl.cat, ''), DATE_TRUNC('month', jcl.transaction_date)
+ORDER BY phase, cat, month;
+
+-- name: GetPhaseBudgets :many
+-- Fetches per-phase budgets from original budget and budget revision ledger rows and from job_items.budget
+-- Item budgets only count the topmost item carrying each job_cost_id so parents and children aren't summed twice
+-- Budget rows dated after to_month or imported after as_of are left out
+WITH estimate_budgets AS (
+    SELECT
+        COALESCE(jcl.phase, '') AS phase,
+        SUM(jcl.amount) AS budget
+    FROM job_cost_ledger jcl
+    WHERE jcl.job = $1
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class IN ('original_budget', 'budget_revision'))
+      AND (sqlc.narg(to_month)::DATE IS NULL OR jcl.transaction_date IS NULL OR jcl.transaction_date < sqlc.narg(to_month) + INTERVAL '1 month')
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
+    GROUP BY COALESCE(jcl.phase, '')
+),
+item_budgets AS (
+    SELECT
+        ji.job_cost_id AS phase,
+        SUM(ji.budget) AS budget,
+        STRING_AGG(DISTINCT ji.description, ', ') AS descriptions
+    FROM job_items ji
+    LEFT JOIN job_items parent ON ji.parent_id = parent.id
+    WHERE ji.job_id = (SELECT j.id FROM jobs j WHERE j.job_number = $1)
+      AND ji.job_cost_id IS NOT NULL
+      AND parent.job_cost_id IS DISTINCT FROM ji.job_cost_id
+    GROUP BY ji.job_cost_id
+)
+SELECT
+    COALESCE(eb.phase, ib.phase)::TEXT AS phase,
+    COALESCE(ib.descriptions, '')::TEXT AS description,
+    COALESCE(eb.budget, 0)::TEXT AS estimate_budget,
+    COALESCE(ib.budget, 0)::TEXT AS item_budget
+FROM estimate_budgets eb
+FULL OUTER JOIN item_budgets ib ON eb.phase = ib.phase
+ORDER BY 1;
+
+-- name: GetPhaseEarnedValue :many
+-- Fetches monthly earned value and cumulative actual cost per cost code
+-- Each item carrying a job_cost_id earns its budget in proportion to its own
+-- billed quantity, or its top-level pay item's when it has no quantity of its
+-- own. Item budgets only count the topmost item carrying each job_cost_id, as
+-- in GetPhaseBudgets. Phases with cost but no items have no earned value
+-- as_of leaves out ledger rows imported after it and reads each month's pay app
+-- as the revision submitted by then; from_month and to_month limit the months
+-- returned without changing the cumulative totals
+WITH job_info AS (
+    SELECT id
+    FROM jobs
+    WHERE job_number = $1
+),
+phase_items AS (
+    SELECT
+        ji.id,
+        ji.job_cost_id AS phase,
+        ji.description,
+        ji.budget,
+        r.qty,
+        ir.root_id
+    FROM job_items ji
+    JOIN job_items_revised r ON r.id = ji.id
+    JOIN job_item_roots ir ON ir.id = ji.id
+    JOIN job_info j ON ir.job_id = j.id
+    LEFT JOIN job_items parent ON ji.parent_id = parent.id
+    WHERE ji.job_cost_id IS NOT NULL
+      AND parent.job_cost_id IS DISTINCT FROM ji.job_cost_id
+),
+item_progress AS (
+    -- Cumulative fraction of each item's revised quantity billed by month
+    SELECT
+        pa.job_item_id,
+        pa.pay_app_month AS month,
+        SUM(pa.qty) OVER (PARTITION BY pa.job_item_id ORDER BY pa.pay_app_month) / r.qty AS fraction
+    FROM pay_applications_as_of(sqlc.narg(as_of)::TIMESTAMP) pa
+    JOIN job_items_revised r ON pa.job_item_id = r.id
+    JOIN job_info j ON r.job_id = j.id
+    WHERE r.qty > 0
+),
+phase_costs AS (
+    SELECT
+        jcl.phase,
+        DATE_TRUNC('month', jcl.transaction_date)::DATE AS month,
+        SUM(jcl.amount) AS cost
+    FROM job_cost_ledger jcl
+    WHERE jcl.job = $1
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+      AND jcl.transaction_date IS NOT NULL
+      AND jcl.phase IS NOT NULL
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
+    GROUP BY jcl.phase, DATE_TRUNC('month', jcl.transaction_date)
+),
+phases AS (
+    SELECT phase FROM phase_items
+    UNION
+    SELECT phase FROM phase_costs
+),
+months AS (
+    SELECT month FROM item_progress
+    UNION
+    SELECT month FROM phase_costs
+),
+phase_ev AS (
+    SELECT
+        pi.phase,
+        m.month,
+        STRING_AGG(DISTINCT pi.description, ', ') AS description,
+        SUM(pi.budget) AS budget,
+        SUM(pi.budget * COALESCE(ip.fraction, 0)) AS earned_value
+    FROM phase_items pi
+    CROSS JOIN months m
+    LEFT JOIN LATERAL (
+        -- Carry progress forward through months the item wasn't billed
+        SELECT p.fraction
+        FROM item_progress p
+        WHERE p.job_item_id = CASE WHEN pi.qty > 0 THEN pi.id ELSE pi.root_id END
+          AND p.month <= m.month
+        ORDER BY p.month DESC
+        LIMIT 1
+    ) ip ON true
+    GROUP BY pi.phase, m.month
+),
+phase_months AS (
+    SELECT
+        ph.phase::TEXT AS phase,
+        m.month,
+        COALESCE(pe.description, '')::TEXT AS description,
+        ROUND(COALESCE(pe.budget, 0), 2)::TEXT AS budget,
+        ROUND(COALESCE(pe.earned_value, 0), 2)::TEXT AS earned_value,
+        COALESCE(SUM(pc.cost) OVER (PARTITION BY ph.phase ORDER BY m.month), 0)::TEXT AS actual_cost
+    FROM phases ph
+    CROSS JOIN months m
+    LEFT JOIN phase_ev pe ON pe.phase = ph.phase AND pe.month = m.month
+    LEFT JOIN phase_costs pc ON pc.phase = ph.phase AND pc.month = m.month
+)
+SELECT phase, month, description, budget, earned_value, actual_cost
+FROM phase_months
+WHERE (sqlc.narg(from_month)::DATE IS NULL OR month >= sqlc.narg(from_month))
+  AND (sqlc.narg(to_month)::DATE IS NULL OR month <= sqlc.narg(to_month))
+ORDER BY phase, month;
+
+-- name: GetPayAppMonthlyTotals :many
+-- Fetches billed work and stored materials per pay application month for a job
+SELECT
+    job_id,
+    pay_app_month,
+    scheduled_value,
+    work_this_period,
+    work_to_date,
+    stored_materials
+FROM pay_app_monthly_totals
+WHERE job_id = $1
+ORDER BY pay_app_month;
+
+-- name: GetRetainageTiers :many
+-- Fetches a job's retainage tiers, lowest completion threshold first
+SELECT id, job_id, threshold_percent, work_rate, materials_rate, created_at
+FROM retainage_tiers
+WHERE job_id = $1
+ORDER BY threshold_percent;
+
+-- name: DeleteRetainageTiersByJob :exec
+DELETE FROM retainage_tiers WHERE job_id = $1;
+
+-- name: InsertRetainageTier :exec
+INSERT INTO retainage_tiers (
+    job_id, threshold_percent, work_rate, materials_rate
+) VALUES (
+    $1, $2, $3, $4
+);
+
+-- name: GetRetainageReleases :many
+-- Fetches retainage released to a job, oldest first
+SELECT id, job_id, release_month, amount, note, created_at
+FROM retainage_releases
+WHERE job_id = $1
+ORDER BY release_month, created_at;
+
+-- name: InsertRetainageRelease :one
+INSERT INTO retainage_releases (
+    job_id, release_month, amount, note
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING id, job_id, release_month, amount, note, created_at;
+
+-- name: CreateChangeOrder :one
+INSERT INTO change_orders (
+    job_id, co_number, description, status, co_date, amount
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+)
+RETURNING id, job_id, co_number, description, status, co_date, amount, created_at, updated_at;
+
+-- name: GetChangeOrdersByJob :many
+-- Fetches all change orders for a job in CO date order
+SELECT id, job_id, co_number, description, status, co_date, amount, created_at, updated_at
+FROM change_orders
+WHERE job_id = $1
+ORDER BY co_date, co_number;
+
+-- name: GetChangeOrderByNumber :one
+SELECT id, job_id, co_number, description, status, co_date, amount, created_at, updated_at
+FROM change_orders
+WHERE job_id = $1 AND co_number = $2;
+
+-- name: UpdateChangeOrderStatus :exec
+UPDATE change_orders
+SET status = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: InsertChangeOrderJobItem :one
+-- Adds the job item an approved change order's line brings in. Quantity and
+-- scheduled value start at zero; job_items_revised adds them from the line.
+INSERT INTO job_items (
+    job_id, parent_id, sort_order, item_number, description,
+    job_cost_id, budget, unit, unit_price, change_order_id
+) VALUES (
+    $1, $2,
+    (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM job_items WHERE job_id = $1),
+    $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING id;
+
+-- name: InsertChangeOrderItem :exec
+-- Lines adding an item leave job_item_id null and carry the item's details
+INSERT INTO change_order_items (
+    change_order_id, job_item_id, qty_change, new_unit_price, amount,
+    new_item_number, new_parent_id, new_description, new_unit, new_job_cost_id, new_budget
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+);
+
+-- name: GetChangeOrderItems :many
+-- Fetches the line items of a change order with the job item they touch, or
+-- the item they add
+SELECT
+    coi.id,
+    coi.job_item_id,
+    COALESCE(ji.item_number, coi.new_item_number)::TEXT AS item_number,
+    COALESCE(ji.description, coi.new_description, '')::TEXT AS description,
+    (coi.new_item_number IS NOT NULL)::BOOLEAN AS new_item,
+    coi.qty_change::TEXT AS qty_change,
+    coi.new_unit_price,
+    coi.amount::TEXT AS amount
+FROM change_order_items coi
+LEFT JOIN job_items ji ON coi.job_item_id = ji.id
+WHERE coi.change_order_id = $1
+ORDER BY item_number;
+
+-- name: GetChangeOrderNewItems :many
+-- Fetches the lines of a change order that add an item
+SELECT
+    id,
+    job_item_id,
+    new_item_number::TEXT AS item_number,
+    new_parent_id AS parent_id,
+    COALESCE(new_description, '')::TEXT AS description,
+    new_unit AS unit,
+    new_job_cost_id AS job_cost_id,
+    COALESCE(new_budget, 0)::TEXT AS budget,
+    COALESCE(new_unit_price, 0)::TEXT AS unit_price
+FROM change_order_items
+WHERE change_order_id = $1 AND new_item_number IS NOT NULL
+ORDER BY new_item_number;
+
+-- name: SetChangeOrderItemJobItem :exec
+UPDATE change_order_items
+SET job_item_id = $2
+WHERE id = $1;
+
+-- name: GetChangeOrderClosedRepricedMonths :many
+-- Fetches the closed months, from the change order's month on, in which an
+-- item the change order reprices was billed
+SELECT DISTINCT pa.pay_app_month
+FROM change_order_items coi
+JOIN change_orders co ON coi.change_order_id = co.id
+JOIN pay_applications pa ON pa.job_item_id = coi.job_item_id
+JOIN period_closes pc ON pc.job_id = co.job_id AND pc.period_month = pa.pay_app_month
+WHERE coi.change_order_id = $1
+  AND coi.new_unit_price IS NOT NULL
+  AND pa.pay_app_month >= DATE_TRUNC('month', co.co_date)
+ORDER BY pa.pay_app_month;
+
+-- name: CountChangeOrderItemsInUse :one
+-- Counts the items a change order added that have been billed, have child
+-- items or are touched by another change order
+SELECT COUNT(*)
+FROM job_items ji
+WHERE ji.change_order_id = $1
+  AND (
+    EXISTS (SELECT 1 FROM pay_applications pa WHERE pa.job_item_id = ji.id)
+    OR EXISTS (SELECT 1 FROM pay_app_revision_items r WHERE r.job_item_id = ji.id)
+    OR EXISTS (SELECT 1 FROM job_items c WHERE c.parent_id = ji.id)
+    OR EXISTS (
+        SELECT 1 FROM change_order_items coi
+        WHERE coi.job_item_id = ji.id AND coi.change_order_id <> ji.change_order_id
+    )
+  );
+
+-- name: ClearChangeOrderNewItems :exec
+-- Unlinks the items a change order added from its lines, which keep their details
+UPDATE change_order_items
+SET job_item_id = NULL
+WHERE change_order_id = $1 AND new_item_number IS NOT NULL;
+
+-- name: DeleteChangeOrderJobItems :exec
+DELETE FROM job_items
+WHERE change_order_id = $1;
+
+-- name: GetRevisedJobItem :one
+-- Fetches a job item's quantity, unit price and scheduled value with approved change orders applied
+SELECT
+    id,
+    item_number,
+    qty::TEXT AS qty,
+    unit_price::TEXT AS unit_price,
+    scheduled_value::TEXT AS scheduled_value
+FROM job_items_revised
+WHERE job_id = $1 AND item_number = $2;
+
+-- name: GetRevisedContractValue :one
+-- Original contract value is jobs.contract_value, falling back to the sum of
+-- top-level scheduled values from the bid; approved change orders are added on top
+WITH original AS (
+    SELECT
+        j.id,
+        COALESCE(
+            j.contract_value,
+            (SELECT SUM(ji.scheduled_value) FROM job_items ji WHERE ji.job_id = j.id AND ji.parent_id IS NULL),
+            0
+        ) AS contract_value
+    FROM jobs j
+    WHERE j.id = $1
+),
+change_order_totals AS (
+    SELECT
+        COALESCE(SUM(amount) FILTER (WHERE status = 'approved'), 0) AS approved,
+        COALESCE(SUM(amount) FILTER (WHERE status = 'pending'), 0) AS pending
+    FROM change_orders
+    WHERE job_id = $1
+)
+SELECT
+    o.contract_value::TEXT AS original_contract_value,
+    cot.approved::TEXT AS approved_change_orders,
+    cot.pending::TEXT AS pending_change_orders,
+    (o.contract_value + cot.approved)::TEXT AS revised_contract_value
+FROM original o
+CROSS JOIN change_order_totals cot;
+
+-- name: SetMaterialsInstalled :exec
+-- Records materials installed out of storage for an existing pay application;
+-- null leaves stored_materials_ledger to derive it from the balance
+UPDATE pay_applications
+SET materials_installed = $3, updated_at = NOW()
+WHERE job_item_id = $1 AND pay_app_month = $2;
+
+-- name: GetStoredMaterialsLedger :many
+-- Fetches the stored materials running balance for every item and month on a job
+SELECT
+    job_item_id,
+    job_id,
+    item_number,
+    pay_app_month,
+    previous_balance,
+    materials_added,
+    materials_installed,
+    balance
+FROM stored_materials_ledger
+WHERE job_id = $1
+ORDER BY item_number, pay_app_month;
+
+-- name: GetJobSchedule :one
+-- Fetches a job's planned start and finish; finish falls back to the contract completion date
+SELECT
+    start_date,
+    COALESCE(end_date, contract_complete_date) AS end_date
+FROM jobs
+WHERE id = $1;
+
+-- name: GetNextBaselineVersion :one
+SELECT (COALESCE(MAX(version), 0) + 1)::INT AS version
+FROM pv_baselines
+WHERE job_id = $1;
+
+-- name: CreateBaseline :one
+INSERT INTO pv_baselines (
+    job_id, version, method, note
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING id, job_id, version, method, note, created_at;
+
+-- name: InsertBaselineMonth :exec
+INSERT INTO pv_baseline_months (
+    baseline_id, month, planned_value
+) VALUES (
+    $1, $2, $3
+);
+
+-- name: GetBaselinesByJob :many
+-- Fetches every baseline version for a job, newest first
+SELECT id, job_id, version, method, note, created_at
+FROM pv_baselines
+WHERE job_id = $1
+ORDER BY version DESC;
+
+-- name: GetBaselineByVersion :one
+SELECT id, job_id, version, method, note, created_at
+FROM pv_baselines
+WHERE job_id = $1 AND version = $2;
+
+-- name: GetLatestBaseline :one
+SELECT id, job_id, version, method, note, created_at
+FROM pv_baselines
+WHERE job_id = $1
+ORDER BY version DESC
+LIMIT 1;
+
+-- name: GetBaselineMonths :many
+-- Fetches a baseline's monthly and cumulative planned value
+SELECT
+    month,
+    planned_value::TEXT AS planned_value,
+    SUM(planned_value) OVER (ORDER BY month)::TEXT AS cumulative_planned_value
+FROM pv_baseline_months
+WHERE baseline_id = $1
+ORDER BY month;
+
+-- name: GetCostTypeMappings :many
+-- Fetches the ledger cat to bid cost type mapping
+SELECT cat, cost_type, created_at
+FROM cost_type_mappings
+ORDER BY cat;
+
+-- name: DeleteCostTypeMappings :exec
+DELETE FROM cost_type_mappings;
+
+-- name: InsertCostTypeMapping :exec
+INSERT INTO cost_type_mappings (cat, cost_type) VALUES ($1, $2);
+
+-- name: GetCostTypeVariance :many
+-- Fetches estimated and actual cost per phase and bid cost type
+-- Estimates unpivot the bid's cost type columns, counting only the topmost item
+-- carrying each job_cost_id as in GetPhaseBudgets. Actuals map ledger cats
+-- through cost_type_mappings; unmapped cats come back as 'unmapped'
+-- Actuals can be limited to transactions from from_month through to_month;
+-- as_of leaves out rows imported after it
+WITH item_estimates AS (
+    SELECT
+        ji.job_cost_id AS phase,
+        v.cost_type,
+        SUM(v.amount) AS estimate
+    FROM job_items ji
+    LEFT JOIN job_items parent ON ji.parent_id = parent.id
+    CROSS JOIN LATERAL (
+        VALUES
+            ('labor', ji.labor),
+            ('equip', ji.equip),
+            ('material', ji.material),
+            ('sub', ji.sub),
+            ('trucking', ji.trucking),
+            ('misc', ji.misc),
+            ('plug', ji.plug)
+    ) AS v(cost_type, amount)
+    WHERE ji.job_id = (SELECT j.id FROM jobs j WHERE j.job_number = $1)
+      AND ji.job_cost_id IS NOT NULL
+      AND parent.job_cost_id IS DISTINCT FROM ji.job_cost_id
+    GROUP BY ji.job_cost_id, v.cost_type
+),
+ledger_actuals AS (
+    SELECT
+        COALESCE(jcl.phase, '') AS phase,
+        COALESCE(ctm.cost_type, 'unmapped') AS cost_type,
+        SUM(jcl.amount) AS actual
+    FROM job_cost_ledger jcl
+    LEFT JOIN cost_type_mappings ctm ON ctm.cat = jcl.cat
+    WHERE jcl.job = $1
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+      AND (sqlc.narg(from_month)::DATE IS NULL OR jcl.transaction_date >= sqlc.narg(from_month))
+      AND (sqlc.narg(to_month)::DATE IS NULL OR jcl.transaction_date < sqlc.narg(to_month) + INTERVAL '1 month')
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
+    GROUP BY COALESCE(jcl.phase, ''), COALESCE(ctm.cost_type, 'unmapped')
+)
+SELECT
+    COALESCE(ie.phase, la.phase)::TEXT AS phase,
+    COALESCE(ie.cost_type, la.cost_type)::TEXT AS cost_type,
+    COALESCE(ie.estimate, 0)::TEXT AS estimate,
+    COALESCE(la.actual, 0)::TEXT AS actual
+FROM item_estimates ie
+FULL OUTER JOIN ledger_actuals la ON ie.phase = la.phase AND ie.cost_type = la.cost_type
+WHERE COALESCE(ie.estimate, 0) <> 0 OR COALESCE(la.actual, 0) <> 0
+ORDER BY 1, 2;
+
+-- name: GetPayItemUnitCosts :many
+-- Fetches cumulative installed quantity and actual cost per pay item by month
+-- Ledger cost reaches a pay item through the job_cost_ids of the items beneath
+-- it. A phase spread over several pay items is split by each one's share of
+-- the phase budget (evenly when the phase has no budget). Bid quantity is the
+-- original quantity, since change orders revise quantity but not budget
+-- as_of leaves out ledger rows imported after it and reads each month's pay app
+-- as the revision submitted by then; from_month and to_month limit the months
+-- returned without changing the cumulative totals
+WITH job_info AS (
+    SELECT id
+    FROM jobs
+    WHERE job_number = $1
+),
+phase_items AS (
+    SELECT
+        ji.job_cost_id AS phase,
+        ir.root_id,
+        SUM(ji.budget) AS budget,
+        COUNT(*) AS items
+    FROM job_items ji
+    JOIN job_item_roots ir ON ir.id = ji.id
+    JOIN job_info j ON ir.job_id = j.id
+    LEFT JOIN job_items parent ON ji.parent_id = parent.id
+    WHERE ji.job_cost_id IS NOT NULL
+      AND parent.job_cost_id IS DISTINCT FROM ji.job_cost_id
+    GROUP BY ji.job_cost_id, ir.root_id
+),
+phase_shares AS (
+    SELECT
+        phase,
+        root_id,
+        CASE
+            WHEN SUM(budget) OVER (PARTITION BY phase) > 0
+            THEN budget / SUM(budget) OVER (PARTITION BY phase)
+            ELSE items::NUMERIC / SUM(items) OVER (PARTITION BY phase)
+        END AS share
+    FROM phase_items
+),
+phase_costs AS (
+    SELECT
+        jcl.phase,
+        DATE_TRUNC('month', jcl.transaction_date)::DATE AS month,
+        SUM(jcl.amount) AS cost
+    FROM job_cost_ledger jcl
+    WHERE jcl.job = $1
+      AND jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+      AND jcl.transaction_date IS NOT NULL
+      AND jcl.phase IS NOT NULL
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
+    GROUP BY jcl.phase, DATE_TRUNC('month', jcl.transaction_date)
+),
+pay_items AS (
+    SELECT r.id, ji.sort_order, r.item_number, r.description, ji.unit, r.original_qty, r.budget
+    FROM job_items_revised r
+    JOIN job_items ji ON ji.id = r.id
+    JOIN job_info j ON r.job_id = j.id
+    WHERE r.parent_id IS NULL
+      AND r.qty > 0
+),
+item_costs AS (
+    SELECT ps.root_id, pc.month, SUM(pc.cost * ps.share) AS cost
+    FROM phase_shares ps
+    JOIN phase_costs pc ON pc.phase = ps.phase
+    GROUP BY ps.root_id, pc.month
+),
+item_qty AS (
+    SELECT pa.job_item_id AS root_id, pa.pay_app_month AS month, SUM(pa.qty) AS qty
+    FROM pay_applications_as_of(sqlc.narg(as_of)::TIMESTAMP) pa
+    JOIN pay_items pi ON pa.job_item_id = pi.id
+    GROUP BY pa.job_item_id, pa.pay_app_month
+),
+months AS (
+    SELECT month FROM item_qty
+    UNION
+    SELECT month FROM item_costs
+),
+item_months AS (
+    SELECT
+        pi.sort_order,
+        pi.item_number,
+        pi.description,
+        COALESCE(pi.unit, '')::TEXT AS unit,
+        pi.original_qty::TEXT AS bid_qty,
+        pi.budget::TEXT AS budget,
+        m.month,
+        COALESCE(SUM(iq.qty) OVER (PARTITION BY pi.id ORDER BY m.month), 0)::TEXT AS installed_qty,
+        ROUND(COALESCE(SUM(ic.cost) OVER (PARTITION BY pi.id ORDER BY m.month), 0), 2)::TEXT AS actual_cost
+    FROM pay_items pi
+    CROSS JOIN months m
+    LEFT JOIN item_qty iq ON iq.root_id = pi.id AND iq.month = m.month
+    LEFT JOIN item_costs ic ON ic.root_id = pi.id AND ic.month = m.month
+)
+SELECT item_number, description, unit, bid_qty, budget, month, installed_qty, actual_cost
+FROM item_months
+WHERE (sqlc.narg(from_month)::DATE IS NULL OR month >= sqlc.narg(from_month))
+  AND (sqlc.narg(to_month)::DATE IS NULL OR month <= sqlc.narg(to_month))
+ORDER BY sort_order, month;
+
+-- name: GetCrewProductivity :many
+-- Fetches bid production figures and actual payroll hours per Crew item
+-- A crew's phase is its own job_cost_id or the nearest ancestor's. PR cost hours
+-- and units for a phase shared by several crews are split by bid man hours.
+-- Days worked counts distinct dates with payroll hours on the phase. Billed
+-- fraction is the pay app percent complete of the crew's pay item
+-- Hours, units and days can be limited to transactions from from_month through
+-- to_month; billed fraction is to date through to_month. as_of leaves out
+-- ledger rows imported after it and reads pay apps as submitted by then
+WITH job_info AS (
+    SELECT id
+    FROM jobs
+    WHERE job_number = $1
+),
+crew_items AS (
+    SELECT
+        ji.id,
+        ji.sort_order,
+        ji.item_number,
+        ji.description,
+        ir.phase,
+        ir.root_id,
+        ji.production_rate,
+        ji.production_units,
+        ji.qty,
+        ji.unit,
+        ji.man_hours,
+        ji.crew_days,
+        CASE
+            WHEN SUM(ji.man_hours) OVER (PARTITION BY ir.phase) > 0
+            THEN ji.man_hours / SUM(ji.man_hours) OVER (PARTITION BY ir.phase)
+            ELSE 1.0 / COUNT(*) OVER (PARTITION BY ir.phase)
+        END AS share
+    FROM job_items ji
+    JOIN job_item_roots ir ON ir.id = ji.id
+    JOIN job_info j ON ir.job_id = j.id
+    WHERE ji.cost_method = 'Crew'
+),
+phase_labor AS (
+    SELECT
+        jcl.phase,
+        SUM(jcl.hours) AS hours,
+        SUM(jcl.units) AS units,
+        COUNT(DISTINCT jcl.transaction_date) FILTER (WHERE jcl.hours > 0) AS days_worked
+    FROM job_cost_ledger jcl
+    WHERE jcl.job = $1
+      AND jcl.transaction_type = 'PR cost'
+      AND jcl.phase IS NOT NULL
+      AND (sqlc.narg(from_month)::DATE IS NULL OR jcl.transaction_date >= sqlc.narg(from_month))
+      AND (sqlc.narg(to_month)::DATE IS NULL OR jcl.transaction_date < sqlc.narg(to_month) + INTERVAL '1 month')
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
+    GROUP BY jcl.phase
+),
+root_billed AS (
+    SELECT pa.job_item_id AS root_id, SUM(pa.qty) / MAX(r.qty) AS fraction
+    FROM pay_applications_as_of(sqlc.narg(as_of)::TIMESTAMP) pa
+    JOIN job_items_revised r ON pa.job_item_id = r.id
+    JOIN job_info j ON r.job_id = j.id
+    WHERE r.qty > 0
+      AND (sqlc.narg(to_month)::DATE IS NULL OR pa.pay_app_month <= sqlc.narg(to_month))
+    GROUP BY pa.job_item_id
+)
+SELECT
+    ci.item_number,
+    ci.description,
+    COALESCE(ci.phase, '')::TEXT AS phase,
+    COALESCE(ci.production_rate, '')::TEXT AS production_rate,
+    COALESCE(ci.production_units, '')::TEXT AS production_units,
+    ci.qty::TEXT AS qty,
+    COALESCE(ci.unit, '')::TEXT AS unit,
+    ci.man_hours::TEXT AS man_hours,
+    ci.crew_days::TEXT AS crew_days,
+    ROUND(COALESCE(pl.hours * ci.share, 0), 2)::TEXT AS actual_hours,
+    ROUND(COALESCE(pl.units * ci.share, 0), 4)::TEXT AS actual_units,
+    COALESCE(pl.days_worked, 0)::INT AS days_worked,
+    COALESCE(rb.fraction, 0)::TEXT AS billed_fraction
+FROM crew_items ci
+LEFT JOIN phase_labor pl ON pl.phase = ci.phase
+LEFT JOIN root_billed rb ON rb.root_id = ci.root_id
+ORDER BY ci.sort_order;
+
+-- name: GetPortfolio :many
+-- Fetches summary metrics for every job, optionally filtered by status ('' for all)
+-- Contract value, earned value and CPI follow GetCostPerformanceIndex. Projected
+-- cost is contract value / CPI once there is both cost and earned value, and the
+-- bid's direct cost before that. Last import is the latest ledger, pay app or
+-- bid row written for the job
+-- Figures are to date at the end of to_month when it is set. as_of leaves out
+-- ledger rows imported and change orders changed after it and reads each
+-- month's pay app as the revision submitted by then
+WITH job_list AS (
+    SELECT id, job_number, job_name, status, contract_value
+    FROM jobs
+    WHERE (status = $1 OR $1 = '')
+),
+scheduled AS (
+    SELECT
+        ji.job_id,
+        SUM(ji.qty) AS total_qty,
+        SUM(ji.qty * ji.unit_price) AS total_contract_value
+    FROM job_items_revised ji
+    JOIN job_list j ON ji.job_id = j.id
+    WHERE ji.qty > 0
+    GROUP BY ji.job_id
+),
+bid_items AS (
+    SELECT
+        ji.job_id,
+        SUM(ji.budget) FILTER (WHERE ji.parent_id IS NULL) AS bid_cost,
+        MAX(ji.updated_at) AS last_import
+    FROM job_items ji
+    JOIN job_list j ON ji.job_id = j.id
+    GROUP BY ji.job_id
+),
+approved_change_orders AS (
+    SELECT co.job_id, SUM(co.amount) AS amount
+    FROM change_orders co
+    JOIN job_list j ON co.job_id = j.id
+    WHERE co.status = 'approved'
+      AND (sqlc.narg(to_month)::DATE IS NULL OR co.co_date < sqlc.narg(to_month) + INTERVAL '1 month')
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR co.updated_at <= sqlc.narg(as_of))
+    GROUP BY co.job_id
+),
+pay_apps AS (
+    SELECT pa.*
+    FROM pay_applications_as_of(sqlc.narg(as_of)::TIMESTAMP) pa
+    WHERE sqlc.narg(to_month)::DATE IS NULL OR pa.pay_app_month <= sqlc.narg(to_month)
+),
+pay_app_progress AS (
+    SELECT
+        ji.job_id,
+        SUM(pa.qty) AS cumulative_qty,
+        MAX(pa.updated_at) AS last_import
+    FROM pay_apps pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    JOIN job_list j ON ji.job_id = j.id
+    GROUP BY ji.job_id
+),
+billed AS (
+    -- Work billed on top-level items, priced as in pay_app_monthly_totals
+    SELECT
+        ji.job_id,
+        SUM(
+            CASE
+                WHEN p.unit_price = 0 THEN pa.qty
+                ELSE pa.qty * p.unit_price
+            END
+        ) AS billed
+    FROM pay_apps pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    JOIN job_list j ON ji.job_id = j.id
+    JOIN job_item_prices p ON p.job_item_id = pa.job_item_id
+     AND pa.pay_app_month >= p.from_month
+     AND (p.until_month IS NULL OR pa.pay_app_month < p.until_month)
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id
+),
+costs AS (
+    SELECT
+        j.id AS job_id,
+        SUM(jcl.amount) FILTER (WHERE jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')) AS cost,
+        MAX(jcl.created_at) AS last_import
+    FROM job_cost_ledger jcl
+    JOIN job_list j ON jcl.job = j.job_number
+    WHERE (sqlc.narg(to_month)::DATE IS NULL OR jcl.transaction_date < sqlc.narg(to_month) + INTERVAL '1 month')
+      AND (sqlc.narg(as_of)::TIMESTAMP IS NULL OR jcl.created_at <= sqlc.narg(as_of))
+    GROUP BY j.id
+),
+metrics AS (
+    SELECT
+        j.id,
+        j.job_number,
+        j.job_name,
+        j.status,
+        COALESCE(j.contract_value + COALESCE(aco.amount, 0), s.total_contract_value, 0) AS contract_value,
+        COALESCE(b.billed, 0) AS billed,
+        COALESCE(c.cost, 0) AS cost,
+        COALESCE(bi.bid_cost, 0) AS bid_cost,
+        CASE
+            WHEN COALESCE(s.total_qty, 0) > 0
+            THEN COALESCE(pp.cumulative_qty, 0) / s.total_qty
+            ELSE 0
+        END AS fraction,
+        GREATEST(c.last_import, pp.last_import, bi.last_import) AS last_import
+    FROM job_list j
+    LEFT JOIN scheduled s ON s.job_id = j.id
+    LEFT JOIN bid_items bi ON bi.job_id = j.id
+    LEFT JOIN approved_change_orders aco ON aco.job_id = j.id
+    LEFT JOIN pay_app_progress pp ON pp.job_id = j.id
+    LEFT JOIN billed b ON b.job_id = j.id
+    LEFT JOIN costs c ON c.job_id = j.id
+),
+projections AS (
+    SELECT
+        m.*,
+        m.fraction * m.contract_value AS earned_value,
+        CASE
+            WHEN m.cost > 0 AND m.fraction > 0
+            THEN m.cost / m.fraction
+            ELSE m.bid_cost
+        END AS projected_cost
+    FROM metrics m
+)
+SELECT
+    p.id,
+    p.job_number,
+    p.job_name,
+    p.status,
+    ROUND(p.contract_value, 2)::TEXT AS contract_value,
+    ROUND(p.billed, 2)::TEXT AS billed,
+    ROUND(p.cost, 2)::TEXT AS cost,
+    ROUND(p.earned_value, 2)::TEXT AS earned_value,
+    CASE
+        WHEN p.cost > 0
+        THEN ROUND(p.earned_value / p.cost, 2)::TEXT
+        ELSE '0'
+    END AS cpi,
+    ROUND(p.fraction * 100, 2)::TEXT AS percent_complete,
+    ROUND(p.projected_cost, 2)::TEXT AS projected_cost,
+    ROUND(p.contract_value - p.projected_cost, 2)::TEXT AS projected_margin,
+    CASE
+        WHEN p.contract_value > 0
+        THEN ROUND((p.contract_value - p.projected_cost) / p.contract_value * 100, 2)::TEXT
+        ELSE '0'
+    END AS projected_margin_percent,
+    p.last_import::TIMESTAMP AS last_import
+FROM projections p
+ORDER BY p.job_number;
+
+-- name: GetWIPSchedule :many
+-- Fetches work-in-progress inputs for every job active at the end of a month
+-- Everything is as of then: contract value and scheduled value include change
+-- orders approved by then, and estimated cost is the bid's direct cost (with
+-- items from those change orders), falling back to ledger budget rows dated by
+-- then. Billed to date is billings from the ledger, falling back to pay applications
+WITH params AS (
+    SELECT (DATE_TRUNC('month', sqlc.arg(as_of)::DATE) + INTERVAL '1 month')::DATE AS cutoff
+),
+job_list AS (
+    SELECT j.id, j.job_number, j.job_name, j.contract_value
+    FROM jobs j
+    CROSS JOIN params p
+    WHERE COALESCE((
+        SELECT c.status
+        FROM job_status_changes c
+        WHERE c.job_id = j.id AND c.changed_at < p.cutoff
+        ORDER BY c.changed_at DESC
+        LIMIT 1
+    ), 'active') = 'active'
+),
+approved_change_orders AS (
+    SELECT co.id, co.job_id, co.amount
+    FROM change_orders co
+    JOIN job_list j ON co.job_id = j.id
+    CROSS JOIN params p
+    WHERE co.status = 'approved'
+      AND co.co_date < p.cutoff
+),
+job_items_as_of AS (
+    -- Bid items, and items added by the change orders approved by then
+    SELECT ji.*
+    FROM job_items ji
+    JOIN job_list j ON ji.job_id = j.id
+    WHERE ji.change_order_id IS NULL
+       OR ji.change_order_id IN (SELECT id FROM approved_change_orders)
+),
+scheduled AS (
+    SELECT s.job_id, SUM(s.amount) AS scheduled_value
+    FROM (
+        SELECT ji.job_id, ji.scheduled_value AS amount
+        FROM job_items_as_of ji
+        WHERE ji.parent_id IS NULL
+        UNION ALL
+        SELECT ji.job_id, coi.amount
+        FROM change_order_items coi
+        JOIN approved_change_orders aco ON coi.change_order_id = aco.id
+        JOIN job_items_as_of ji ON coi.job_item_id = ji.id
+        WHERE ji.parent_id IS NULL
+    ) s
+    GROUP BY s.job_id
+),
+change_order_totals AS (
+    SELECT job_id, SUM(amount) AS amount
+    FROM approved_change_orders
+    GROUP BY job_id
+),
+bid_estimates AS (
+    SELECT ji.job_id, SUM(ji.budget) AS budget
+    FROM job_items_as_of ji
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id
+),
+ledger AS (
+    SELECT
+        j.id AS job_id,
+        SUM(jcl.amount) FILTER (
+            WHERE jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'cost')
+              AND jcl.transaction_date < p.cutoff
+        ) AS cost,
+        SUM(jcl.amount) FILTER (
+            WHERE jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class = 'billing')
+              AND jcl.transaction_date < p.cutoff
+        ) AS billed,
+        -- Undated budget rows are taken as part of the original estimate
+        SUM(jcl.amount) FILTER (
+            WHERE jcl.transaction_type IN (SELECT transaction_type FROM transaction_type_classes WHERE class IN ('original_budget', 'budget_revision'))
+              AND COALESCE(jcl.transaction_date < p.cutoff, true)
+        ) AS estimate
+    FROM job_cost_ledger jcl
+    JOIN job_list j ON jcl.job = j.job_number
+    CROSS JOIN params p
+    GROUP BY j.id
+),
+pay_apps AS (
+    SELECT pt.job_id, SUM(pt.work_this_period::NUMERIC) AS billed
+    FROM pay_app_monthly_totals pt
+    JOIN job_list j ON pt.job_id = j.id
+    CROSS JOIN params p
+    WHERE pt.pay_app_month < p.cutoff
+    GROUP BY pt.job_id
+)
+SELECT
+    j.job_number,
+    j.job_name,
+    COALESCE(j.contract_value + COALESCE(cot.amount, 0), s.scheduled_value, 0)::TEXT AS contract_value,
+    COALESCE(NULLIF(be.budget, 0), l.estimate, 0)::TEXT AS estimated_cost,
+    COALESCE(l.cost, 0)::TEXT AS cost_to_date,
+    COALESCE(l.billed, pa.billed, 0)::TEXT AS billed_to_date
+FROM job_list j
+LEFT JOIN scheduled s ON s.job_id = j.id
+LEFT JOIN change_order_totals cot ON cot.job_id = j.id
+LEFT JOIN bid_estimates be ON be.job_id = j.id
+LEFT JOIN ledger l ON l.job_id = j.id
+LEFT JOIN pay_apps pa ON pa.job_id = j.id
+ORDER BY j.job_number;
+
+-- name: GetTransactionTypeClasses :many
+-- Fetches every classified ledger transaction type
+SELECT transaction_type, class, created_at, updated_at
+FROM transaction_type_classes
+ORDER BY class, transaction_type;
+
+-- name: UpsertTransactionTypeClass :exec
+INSERT INTO transaction_type_classes (transaction_type, class)
+VALUES ($1, $2)
+ON CONFLICT (transaction_type)
+DO UPDATE SET class = EXCLUDED.class, updated_at = NOW();
+
+-- name: GetUnclassifiedTransactionTypes :many
+-- Fetches transaction types seen in the ledger that have no classification,
+-- with how often and over what dates they appear
+SELECT
+    COALESCE(jcl.transaction_type, '')::TEXT AS transaction_type,
+    COUNT(*)::BIGINT AS row_count,
+    SUM(jcl.amount)::TEXT AS total_amount,
+    COUNT(DISTINCT jcl.job)::BIGINT AS job_count,
+    MIN(jcl.transaction_date)::DATE AS first_seen,
+    MAX(jcl.transaction_date)::DATE AS last_seen
+FROM job_cost_ledger jcl
+LEFT JOIN transaction_type_classes ttc ON ttc.transaction_type = jcl.transaction_type
+WHERE ttc.transaction_type IS NULL
+GROUP BY jcl.transaction_type
+ORDER BY COUNT(*) DESC;
+
+-- name: GetPeriodCloses :many
+-- Fetches a job's closed months, oldest first
+SELECT job_id, period_month, closed_by, closed_at
+FROM period_closes
+WHERE job_id = $1
+ORDER BY period_month;
+
+-- name: GetPeriodClose :one
+SELECT job_id, period_month, closed_by, closed_at
+FROM period_closes
+WHERE job_id = $1 AND period_month = $2;
+
+-- name: GetAllPeriodCloses :many
+-- Fetches every closed month keyed by job number, for the cost ledger import
+SELECT j.job_number, pc.period_month
+FROM period_closes pc
+JOIN jobs j ON pc.job_id = j.id
+ORDER BY j.job_number, pc.period_month;
+
+-- name: InsertPeriodClose :exec
+INSERT INTO period_closes (job_id, period_month, closed_by)
+VALUES ($1, $2, $3);
+
+-- name: DeletePeriodClose :execrows
+DELETE FROM period_closes
+WHERE job_id = $1 AND period_month = $2;
+
+-- name: InsertPeriodCloseEvent :exec
+INSERT INTO period_close_events (job_id, period_month, action, actor, reason)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetPeriodCloseEvents :many
+-- Fetches a job's close and reopen history, newest first
+SELECT id, job_id, period_month, action, actor, reason, created_at
+FROM period_close_events
+WHERE job_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetNextPayAppRevision :one
+SELECT (COALESCE(MAX(revision), 0) + 1)::INT AS revision
+FROM pay_app_revisions
+WHERE job_id = $1 AND pay_app_month = $2;
+
+-- name: CreatePayAppRevision :one
+INSERT INTO pay_app_revisions (
+    job_id, pay_app_month, revision, source
+) VALUES (
+    $1, $2, $3, $4
+)
+RETURNING id;
+
+-- name: SnapshotPayAppRevisionItems :exec
+-- Copies a job's effective pay application rows for the month into a revision
+INSERT INTO pay_app_revision_items (revision_id, job_item_id, qty, stored_materials)
+SELECT sqlc.arg(revision_id), pa.job_item_id, pa.qty, pa.stored_materials
+FROM pay_applications pa
+JOIN job_items ji ON pa.job_item_id = ji.id
+WHERE ji.job_id = sqlc.arg(job_id) AND pa.pay_app_month = sqlc.arg(pay_app_month);
+
+-- name: GetPayAppRevisions :many
+-- Fetches every revision of a job's pay application for the month with its
+-- dollar totals from top-level pay items at the month's prices, newest first
+SELECT
+    r.revision,
+    r.source,
+    r.created_at,
+    COUNT(ri.job_item_id)::BIGINT AS item_count,
+    COALESCE(SUM(
+        CASE
+            WHEN ji.parent_id IS NOT NULL THEN 0
+            WHEN p.unit_price = 0 THEN ri.qty
+            ELSE ri.qty * p.unit_price
+        END
+    ), 0)::TEXT AS work_this_period,
+    COALESCE(SUM(CASE WHEN ji.parent_id IS NULL THEN ri.stored_materials ELSE 0 END), 0)::TEXT AS stored_materials
+FROM pay_app_revisions r
+LEFT JOIN pay_app_revision_items ri ON ri.revision_id = r.id
+LEFT JOIN job_items ji ON ri.job_item_id = ji.id
+LEFT JOIN job_item_prices p ON p.job_item_id = ri.job_item_id
+ AND r.pay_app_month >= p.from_month
+ AND (p.until_month IS NULL OR r.pay_app_month < p.until_month)
+WHERE r.job_id = $1 AND r.pay_app_month = $2
+GROUP BY r.id, r.revision, r.source, r.created_at
+ORDER BY r.revision DESC;
+
+-- name: GetPayAppRevisionDiff :many
+-- Compares two revisions of a job's pay application for the month item by
+-- item. Items missing from one side compare as zero.
+WITH from_items AS (
+    SELECT ri.job_item_id, ri.qty, ri.stored_materials
+    FROM pay_app_revision_items ri
+    JOIN pay_app_revisions r ON ri.revision_id = r.id
+    WHERE r.job_id = sqlc.arg(job_id)
+      AND r.pay_app_month = sqlc.arg(pay_app_month)
+      AND r.revision = sqlc.arg(from_revision)
+),
+to_items AS (
+    SELECT ri.job_item_id, ri.qty, ri.stored_materials
+    FROM pay_app_revision_items ri
+    JOIN pay_app_revisions r ON ri.revision_id = r.id
+    WHERE r.job_id = sqlc.arg(job_id)
+      AND r.pay_app_month = sqlc.arg(pay_app_month)
+      AND r.revision = sqlc.arg(to_revision)
+)
+SELECT
+    ji.id AS job_item_id,
+    ji.item_number,
+    ji.description,
+    (ji.parent_id IS NULL)::BOOLEAN AS is_pay_item,
+    ji.unit_price::TEXT AS unit_price,
+    COALESCE(f.qty, 0)::TEXT AS from_qty,
+    COALESCE(t.qty, 0)::TEXT AS to_qty,
+    COALESCE(f.stored_materials, 0)::TEXT AS from_stored_materials,
+    COALESCE(t.stored_materials, 0)::TEXT AS to_stored_materials
+FROM from_items f
+FULL OUTER JOIN to_items t ON f.job_item_id = t.job_item_id
+JOIN job_items ji ON ji.id = COALESCE(f.job_item_id, t.job_item_id)
+ORDER BY ji.sort_order;
+
+-- name: GetPayItemQuantities :many
+-- Fetches each unit-price pay item's quantity billed to date against its
+-- contract quantity (bid plus approved change orders). Lump-sum items
+-- (unit_price = 0) carry dollars in qty and are left out.
+WITH billed AS (
+    SELECT
+        pa.job_item_id,
+        SUM(pa.qty) AS cumulative_qty,
+        MAX(pa.pay_app_month)::DATE AS last_month
+    FROM pay_applications pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    WHERE ji.job_id = $1
+    GROUP BY pa.job_item_id
+)
+SELECT
+    r.id,
+    r.item_number,
+    r.description,
+    ji.unit,
+    r.original_qty::TEXT AS original_qty,
+    r.qty::TEXT AS contract_qty,
+    r.unit_price::TEXT AS unit_price,
+    b.cumulative_qty::TEXT AS cumulative_qty,
+    b.last_month
+FROM job_items_revised r
+JOIN job_items ji ON ji.id = r.id
+JOIN billed b ON b.job_item_id = r.id
+WHERE r.parent_id IS NULL
+  AND r.unit_price <> 0
+ORDER BY ji.sort_order;
+
+-- name: GetPayAppItems :many
+-- Fetches a job's cumulative pay application rows for the month with item
+-- details, in bid order. scheduled_value includes approved change orders.
+SELECT
+    pac.job_item_id,
+    ji.item_number,
+    ji.description,
+    ji.unit,
+    (pac.parent_id IS NULL)::BOOLEAN AS is_pay_item,
+    pac.this_month_qty::TEXT AS this_month_qty,
+    pac.stored_materials::TEXT AS stored_materials,
+    pac.total_qty::TEXT AS total_qty,
+    pac.unit_price::TEXT AS unit_price,
+    r.scheduled_value::TEXT AS scheduled_value,
+    pac.previous_cumulative_qty
+FROM pay_application_cumulative pac
+JOIN job_items ji ON pac.job_item_id = ji.id
+JOIN job_items_revised r ON pac.job_item_id = r.id
+WHERE pac.job_id = $1 AND pac.pay_app_month = $2
+ORDER BY ji.sort_order;
+
+-- name: GetUnbilledPayAppItem :one
+-- Fetches a job item with no row on the month's pay application, with its
+-- cumulative quantity and stored materials balance from earlier months and
+-- the unit price in effect that month, so an edit can add it
+SELECT
+    ji.id AS job_item_id,
+    ji.item_number,
+    ji.description,
+    ji.unit,
+    (ji.parent_id IS NULL)::BOOLEAN AS is_pay_item,
+    r.qty::TEXT AS total_qty,
+    p.unit_price::TEXT AS unit_price,
+    r.scheduled_value::TEXT AS scheduled_value,
+    COALESCE((
+        SELECT SUM(pa.qty)
+        FROM pay_applications pa
+        WHERE pa.job_item_id = ji.id AND pa.pay_app_month < $2
+    ), 0)::TEXT AS previous_cumulative_qty,
+    COALESCE((
+        SELECT pa.stored_materials
+        FROM pay_applications pa
+        WHERE pa.job_item_id = ji.id AND pa.pay_app_month < $2
+        ORDER BY pa.pay_app_month DESC
+        LIMIT 1
+    ), 0)::TEXT AS previous_stored_materials
+FROM job_items ji
+JOIN job_items_revised r ON r.id = ji.id
+JOIN job_item_prices p ON p.job_item_id = ji.id
+ AND $2 >= p.from_month
+ AND (p.until_month IS NULL OR $2 < p.until_month)
+WHERE ji.job_id = $1
+  AND ji.id = $3
+  AND NOT EXISTS (
+    SELECT 1 FROM pay_applications pa
+    WHERE pa.job_item_id = ji.id AND pa.pay_app_month = $2
+  );
+
+-- name: GetLaterPayAppCumulative :many
+-- Fetches the lowest and highest cumulative quantity each of a job's items
+-- reaches in months after the given one
+SELECT
+    job_item_id,
+    MIN(cumulative_qty::NUMERIC)::TEXT AS min_cumulative_qty,
+    MAX(cumulative_qty::NUMERIC)::TEXT AS max_cumulative_qty
+FROM pay_application_cumulative
+WHERE job_id = $1 AND pay_app_month > $2
+GROUP BY job_item_id;
+
+-- name: InsertPayAppEdit :exec
+INSERT INTO pay_app_edits (
+    job_id, job_item_id, pay_app_month, revision, field, old_value, new_value, edited_by, reason
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+);
+
+-- name: GetPayAppEdits :many
+-- Fetches the edits made to a job's pay application for the month, newest first
+SELECT
+    e.revision,
+    ji.item_number,
+    ji.description,
+    e.field,
+    e.old_value::TEXT AS old_value,
+    e.new_value::TEXT AS new_value,
+    e.edited_by,
+    e.reason,
+    e.created_at
+FROM pay_app_edits e
+JOIN job_items ji ON e.job_item_id = ji.id
+WHERE e.job_id = $1 AND e.pay_app_month = $2
+ORDER BY e.created_at DESC, ji.sort_order;
+
+-- name: CountUsers :one
+SELECT COUNT(*) FROM users;
+
+-- name: CountActiveAdmins :one
+-- Counts the enabled admins, so the last one can't be demoted or removed
+SELECT COUNT(*) FROM users WHERE role = 'admin' AND NOT disabled;
+
+-- name: CreateUser :one
+INSERT INTO users (username, password_hash, role)
+VALUES ($1, $2, $3)
+RETURNING id, username, password_hash, role, disabled, created_at, updated_at;
+
+-- name: GetUserByUsername :one
+SELECT id, username, password_hash, role, disabled, created_at, updated_at
+FROM users
+WHERE username = $1;
+
+-- name: ListUsers :many
+SELECT id, username, password_hash, role, disabled, created_at, updated_at
+FROM users
+ORDER BY username;
+
+-- name: UpdateUser :one
+-- Sets a user's role and disabled flag
+UPDATE users
+SET role = $2, disabled = $3, updated_at = NOW()
+WHERE username = $1
+RETURNING id, username, password_hash, role, disabled, created_at, updated_at;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET password_hash = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteUser :exec
+DELETE FROM users WHERE id = $1;
+
+-- name: CreateSession :exec
+INSERT INTO sessions (token_hash, user_id, expires_at)
+VALUES ($1, $2, $3);
+
+-- name: GetSessionUser :one
+-- Fetches the enabled user signed in with an unexpired session
+SELECT u.id, u.username, u.password_hash, u.role, u.disabled, u.created_at, u.updated_at
+FROM sessions s
+JOIN users u ON s.user_id = u.id
+WHERE s.token_hash = $1 AND s.expires_at > NOW() AND NOT u.disabled;
+
+-- name: DeleteSession :exec
+DELETE FROM sessions WHERE token_hash = $1;
+
+-- name: DeleteUserSessions :exec
+DELETE FROM sessions WHERE user_id = $1;
+
+-- name: DeleteExpiredSessions :exec
+DELETE FROM sessions WHERE expires_at <= NOW();
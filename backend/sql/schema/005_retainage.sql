@@ -0,0 +1,72 @@
+-- +goose Up
+
+-- Retainage rates per job. The tier with the highest threshold_percent at or
+-- below the job's percent complete applies to work billed that month.
+CREATE TABLE retainage_tiers (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  threshold_percent NUMERIC NOT NULL DEFAULT 0,
+  work_rate NUMERIC NOT NULL DEFAULT 0,
+  materials_rate NUMERIC NOT NULL DEFAULT 0,
+
+  created_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(job_id, threshold_percent)
+);
+
+-- Retainage paid back to us by the owner
+CREATE TABLE retainage_releases (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  release_month DATE NOT NULL,
+  amount NUMERIC NOT NULL,
+  note TEXT,
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_retainage_releases_job ON retainage_releases(job_id);
+
+-- Pay application dollar totals per job and month, from top-level pay items only
+-- so parents and their children aren't billed twice.
+-- Lump-sum items (unit_price = 0) carry the SOV "THIS PERIOD" dollars in qty.
+CREATE VIEW pay_app_monthly_totals AS
+WITH contract AS (
+    SELECT job_id, SUM(scheduled_value) AS scheduled_value
+    FROM job_items
+    WHERE parent_id IS NULL
+    GROUP BY job_id
+),
+monthly AS (
+    SELECT
+        ji.job_id,
+        pa.pay_app_month,
+        SUM(
+            CASE
+                WHEN ji.unit_price = 0 THEN pa.qty
+                ELSE pa.qty * ji.unit_price
+            END
+        ) AS work_this_period,
+        SUM(pa.stored_materials) AS stored_materials
+    FROM pay_applications pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id, pa.pay_app_month
+)
+SELECT
+    m.job_id,
+    m.pay_app_month,
+    COALESCE(c.scheduled_value, 0)::TEXT AS scheduled_value,
+    m.work_this_period::TEXT AS work_this_period,
+    SUM(m.work_this_period) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS work_to_date,
+    m.stored_materials::TEXT AS stored_materials
+FROM monthly m
+LEFT JOIN contract c ON c.job_id = m.job_id;
+
+-- +goose Down
+DROP VIEW IF EXISTS pay_app_monthly_totals;
+DROP INDEX IF EXISTS idx_retainage_releases_job;
+DROP TABLE IF EXISTS retainage_releases;
+DROP TABLE IF EXISTS retainage_tiers;
@@ -0,0 +1,290 @@
+-- +goose Up
+
+-- Change orders against a job's contract
+CREATE TABLE change_orders (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  co_number VARCHAR(50) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  status VARCHAR(20) NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'approved', 'rejected')),
+  co_date DATE NOT NULL DEFAULT CURRENT_DATE,
+  amount NUMERIC NOT NULL DEFAULT 0,
+
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(job_id, co_number)
+);
+
+-- Items added by a change order point back at it. Original contract items have no change order.
+ALTER TABLE job_items ADD COLUMN change_order_id UUID REFERENCES change_orders(id);
+
+-- CO line items. Each line adjusts one job item's quantity, unit price and/or
+-- scheduled value; new items are inserted at zero quantity and brought in by their line.
+CREATE TABLE change_order_items (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  change_order_id UUID NOT NULL REFERENCES change_orders(id) ON DELETE CASCADE,
+  job_item_id UUID NOT NULL REFERENCES job_items(id),
+  qty_change NUMERIC NOT NULL DEFAULT 0,
+  new_unit_price NUMERIC,
+  amount NUMERIC NOT NULL DEFAULT 0,
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_change_order_items_item ON change_order_items(job_item_id);
+
+-- Job items with approved change orders applied. original_* columns keep the bid values.
+CREATE VIEW job_items_revised AS
+WITH approved_lines AS (
+    SELECT
+        coi.job_item_id,
+        coi.qty_change,
+        coi.new_unit_price,
+        coi.amount,
+        co.co_date,
+        co.created_at
+    FROM change_order_items coi
+    JOIN change_orders co ON coi.change_order_id = co.id
+    WHERE co.status = 'approved'
+),
+adjustments AS (
+    SELECT
+        job_item_id,
+        SUM(qty_change) AS qty_change,
+        SUM(amount) AS amount
+    FROM approved_lines
+    GROUP BY job_item_id
+),
+latest_prices AS (
+    -- The most recent approved CO that sets a unit price wins
+    SELECT DISTINCT ON (job_item_id)
+        job_item_id,
+        new_unit_price AS unit_price
+    FROM approved_lines
+    WHERE new_unit_price IS NOT NULL
+    ORDER BY job_item_id, co_date DESC, created_at DESC
+)
+SELECT
+    ji.id,
+    ji.job_id,
+    ji.parent_id,
+    ji.change_order_id,
+    ji.item_number,
+    ji.description,
+    ji.budget,
+    ji.qty AS original_qty,
+    ji.unit_price AS original_unit_price,
+    ji.scheduled_value AS original_scheduled_value,
+    ji.qty + COALESCE(a.qty_change, 0) AS qty,
+    COALESCE(lp.unit_price, ji.unit_price) AS unit_price,
+    ji.scheduled_value + COALESCE(a.amount, 0) AS scheduled_value
+FROM job_items ji
+LEFT JOIN adjustments a ON a.job_item_id = ji.id
+LEFT JOIN latest_prices lp ON lp.job_item_id = ji.id;
+
+-- Pay application math runs on revised quantities and unit prices
+CREATE OR REPLACE VIEW pay_application_cumulative AS
+WITH ordered_months AS (
+    SELECT
+        pa.job_item_id,
+        pa.pay_app_month,
+        pa.qty,
+        pa.stored_materials,
+        ji.qty AS total_qty,
+        ji.unit_price,
+        ji.budget,
+        ji.parent_id,
+        ji.job_id
+    FROM pay_applications pa
+    JOIN job_items_revised ji ON pa.job_item_id = ji.id
+),
+cumulative AS (
+    SELECT
+        job_item_id,
+        pay_app_month,
+        qty AS this_month_qty,
+        stored_materials,
+        total_qty,
+        unit_price,
+        budget,
+        parent_id,
+        job_id,
+        SUM(qty::NUMERIC) OVER (
+            PARTITION BY job_item_id
+            ORDER BY pay_app_month
+            ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+        ) AS cumulative_qty_num,
+        COALESCE(
+            SUM(qty::NUMERIC) OVER (
+                PARTITION BY job_item_id
+                ORDER BY pay_app_month
+                ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+            ),
+            0
+        ) AS previous_cumulative_qty_num
+    FROM ordered_months
+)
+SELECT
+    job_item_id,
+    pay_app_month,
+    job_id,
+    parent_id,
+    this_month_qty,
+    stored_materials,
+    total_qty,
+    unit_price,
+    budget,
+    cumulative_qty_num::TEXT AS cumulative_qty,
+    previous_cumulative_qty_num::TEXT AS previous_cumulative_qty,
+    (total_qty::NUMERIC - cumulative_qty_num)::TEXT AS remaining_qty,
+    CASE
+        WHEN total_qty::NUMERIC = 0 THEN '0'
+        ELSE ROUND((cumulative_qty_num / total_qty::NUMERIC) * 100, 4)::TEXT
+    END AS percent_complete,
+    (this_month_qty::NUMERIC * unit_price::NUMERIC)::TEXT AS this_month_amount,
+    (cumulative_qty_num * unit_price::NUMERIC)::TEXT AS cumulative_amount,
+    (previous_cumulative_qty_num * unit_price::NUMERIC)::TEXT AS previous_cumulative_amount
+FROM cumulative;
+
+CREATE OR REPLACE VIEW pay_app_monthly_totals AS
+WITH contract AS (
+    SELECT job_id, SUM(scheduled_value) AS scheduled_value
+    FROM job_items_revised
+    WHERE parent_id IS NULL
+    GROUP BY job_id
+),
+monthly AS (
+    SELECT
+        ji.job_id,
+        pa.pay_app_month,
+        SUM(
+            CASE
+                WHEN ji.unit_price = 0 THEN pa.qty
+                ELSE pa.qty * ji.unit_price
+            END
+        ) AS work_this_period,
+        SUM(pa.stored_materials) AS stored_materials
+    FROM pay_applications pa
+    JOIN job_items_revised ji ON pa.job_item_id = ji.id
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id, pa.pay_app_month
+)
+SELECT
+    m.job_id,
+    m.pay_app_month,
+    COALESCE(c.scheduled_value, 0)::TEXT AS scheduled_value,
+    m.work_this_period::TEXT AS work_this_period,
+    SUM(m.work_this_period) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS work_to_date,
+    m.stored_materials::TEXT AS stored_materials
+FROM monthly m
+LEFT JOIN contract c ON c.job_id = m.job_id;
+
+-- +goose Down
+CREATE OR REPLACE VIEW pay_app_monthly_totals AS
+WITH contract AS (
+    SELECT job_id, SUM(scheduled_value) AS scheduled_value
+    FROM job_items
+    WHERE parent_id IS NULL
+    GROUP BY job_id
+),
+monthly AS (
+    SELECT
+        ji.job_id,
+        pa.pay_app_month,
+        SUM(
+            CASE
+                WHEN ji.unit_price = 0 THEN pa.qty
+                ELSE pa.qty * ji.unit_price
+            END
+        ) AS work_this_period,
+        SUM(pa.stored_materials) AS stored_materials
+    FROM pay_applications pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    WHERE ji.parent_id IS NULL
+    GROUP BY ji.job_id, pa.pay_app_month
+)
+SELECT
+    m.job_id,
+    m.pay_app_month,
+    COALESCE(c.scheduled_value, 0)::TEXT AS scheduled_value,
+    m.work_this_period::TEXT AS work_this_period,
+    SUM(m.work_this_period) OVER (
+        PARTITION BY m.job_id
+        ORDER BY m.pay_app_month
+    )::TEXT AS work_to_date,
+    m.stored_materials::TEXT AS stored_materials
+FROM monthly m
+LEFT JOIN contract c ON c.job_id = m.job_id;
+
+CREATE OR REPLACE VIEW pay_application_cumulative AS
+WITH ordered_months AS (
+    SELECT
+        pa.job_item_id,
+        pa.pay_app_month,
+        pa.qty,
+        pa.stored_materials,
+        ji.qty AS total_qty,
+        ji.unit_price,
+        ji.budget,
+        ji.parent_id,
+        ji.job_id
+    FROM pay_applications pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+),
+cumulative AS (
+    SELECT
+        job_item_id,
+        pay_app_month,
+        qty AS this_month_qty,
+        stored_materials,
+        total_qty,
+        unit_price,
+        budget,
+        parent_id,
+        job_id,
+        SUM(qty::NUMERIC) OVER (
+            PARTITION BY job_item_id
+            ORDER BY pay_app_month
+            ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW
+        ) AS cumulative_qty_num,
+        COALESCE(
+            SUM(qty::NUMERIC) OVER (
+                PARTITION BY job_item_id
+                ORDER BY pay_app_month
+                ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+            ),
+            0
+        ) AS previous_cumulative_qty_num
+    FROM ordered_months
+)
+SELECT
+    job_item_id,
+    pay_app_month,
+    job_id,
+    parent_id,
+    this_month_qty,
+    stored_materials,
+    total_qty,
+    unit_price,
+    budget,
+    cumulative_qty_num::TEXT AS cumulative_qty,
+    previous_cumulative_qty_num::TEXT AS previous_cumulative_qty,
+    (total_qty::NUMERIC - cumulative_qty_num)::TEXT AS remaining_qty,
+    CASE
+        WHEN total_qty::NUMERIC = 0 THEN '0'
+        ELSE ROUND((cumulative_qty_num / total_qty::NUMERIC) * 100, 4)::TEXT
+    END AS percent_complete,
+    (this_month_qty::NUMERIC * unit_price::NUMERIC)::TEXT AS this_month_amount,
+    (cumulative_qty_num * unit_price::NUMERIC)::TEXT AS cumulative_amount,
+    (previous_cumulative_qty_num * unit_price::NUMERIC)::TEXT AS previous_cumulative_amount
+FROM cumulative;
+
+DROP VIEW IF EXISTS job_items_revised;
+DROP INDEX IF EXISTS idx_change_order_items_item;
+DROP TABLE IF EXISTS change_order_items;
+ALTER TABLE job_items DROP COLUMN change_order_id;
+DROP TABLE IF EXISTS change_orders;
@@ -0,0 +1,13 @@
+-- +goose Up
+
+-- Maps ledger cat codes onto the bid's cost type columns so estimated and
+-- actual cost can be compared by type. Cats without a mapping report as unmapped.
+CREATE TABLE cost_type_mappings (
+  cat VARCHAR(50) PRIMARY KEY,
+  cost_type VARCHAR(20) NOT NULL CHECK (cost_type IN ('labor', 'equip', 'material', 'sub', 'trucking', 'misc', 'plug')),
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS cost_type_mappings;
@@ -0,0 +1,12 @@
+-- +goose Up
+
+-- Hours and units reported on payroll cost lines, for productivity tracking.
+-- NULL when the ledger export did not include the columns.
+ALTER TABLE job_cost_ledger
+  ADD COLUMN hours NUMERIC,
+  ADD COLUMN units NUMERIC;
+
+-- +goose Down
+ALTER TABLE job_cost_ledger
+  DROP COLUMN units,
+  DROP COLUMN hours;
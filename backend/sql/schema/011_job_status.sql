@@ -0,0 +1,9 @@
+-- +goose Up
+
+-- Where a job is in its life, for filtering the portfolio
+ALTER TABLE jobs
+  ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'complete', 'closed'));
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN status;
@@ -0,0 +1,26 @@
+-- +goose Up
+
+-- Classifies the ledger's raw transaction types so reports don't hard-code
+-- them. Types missing from this table are left out of every report until
+-- they are classified.
+CREATE TABLE transaction_type_classes (
+  transaction_type VARCHAR(100) PRIMARY KEY,
+  class VARCHAR(20) NOT NULL
+    CHECK (class IN ('cost', 'billing', 'original_budget', 'budget_revision', 'ignore')),
+
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+INSERT INTO transaction_type_classes (transaction_type, class) VALUES
+  ('AP cost', 'cost'),
+  ('JC cost', 'cost'),
+  ('PR cost', 'cost'),
+  ('EQ cost', 'cost'),
+  ('IN cost', 'cost'),
+  ('work billed', 'billing'),
+  ('Original estimate', 'original_budget'),
+  ('Change estimate', 'budget_revision');
+
+-- +goose Down
+DROP TABLE IF EXISTS transaction_type_classes;
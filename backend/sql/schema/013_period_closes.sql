@@ -0,0 +1,33 @@
+-- +goose Up
+
+-- Months closed per job. Pay applications, stored materials, retainage
+-- releases and ledger costs dated in a closed month are rejected until the
+-- month is reopened.
+CREATE TABLE period_closes (
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  period_month DATE NOT NULL,
+  closed_by TEXT NOT NULL,
+
+  closed_at TIMESTAMP DEFAULT NOW(),
+  PRIMARY KEY (job_id, period_month)
+);
+
+-- Every close and reopen, kept after a reopened month's close row is gone
+CREATE TABLE period_close_events (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  period_month DATE NOT NULL,
+  action VARCHAR(10) NOT NULL
+    CHECK (action IN ('close', 'reopen')),
+  actor TEXT NOT NULL,
+  reason TEXT,
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_period_close_events_job ON period_close_events(job_id, period_month);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_period_close_events_job;
+DROP TABLE IF EXISTS period_close_events;
+DROP TABLE IF EXISTS period_closes;
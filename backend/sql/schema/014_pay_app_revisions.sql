@@ -0,0 +1,40 @@
+-- +goose Up
+
+-- Every submission of a job's pay application for a month. The highest
+-- revision is the effective one and matches pay_applications.
+CREATE TABLE pay_app_revisions (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  pay_app_month DATE NOT NULL,
+  revision INT NOT NULL,
+  source TEXT,
+
+  created_at TIMESTAMP DEFAULT NOW(),
+  UNIQUE(job_id, pay_app_month, revision)
+);
+
+-- Item quantities and stored materials as submitted in a revision
+CREATE TABLE pay_app_revision_items (
+  revision_id UUID NOT NULL REFERENCES pay_app_revisions(id) ON DELETE CASCADE,
+  job_item_id UUID NOT NULL REFERENCES job_items(id),
+  qty NUMERIC NOT NULL DEFAULT 0,
+  stored_materials NUMERIC NOT NULL DEFAULT 0,
+
+  PRIMARY KEY (revision_id, job_item_id)
+);
+
+-- Pay applications imported before revisions become revision 1
+INSERT INTO pay_app_revisions (job_id, pay_app_month, revision, source)
+SELECT DISTINCT ji.job_id, pa.pay_app_month, 1, 'existing'
+FROM pay_applications pa
+JOIN job_items ji ON pa.job_item_id = ji.id;
+
+INSERT INTO pay_app_revision_items (revision_id, job_item_id, qty, stored_materials)
+SELECT r.id, pa.job_item_id, pa.qty, pa.stored_materials
+FROM pay_applications pa
+JOIN job_items ji ON pa.job_item_id = ji.id
+JOIN pay_app_revisions r ON r.job_id = ji.job_id AND r.pay_app_month = pa.pay_app_month;
+
+-- +goose Down
+DROP TABLE IF EXISTS pay_app_revision_items;
+DROP TABLE IF EXISTS pay_app_revisions;
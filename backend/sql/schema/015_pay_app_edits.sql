@@ -0,0 +1,25 @@
+-- +goose Up
+
+-- Item-level edits made to a pay application through the API, one row per
+-- changed value. revision is the pay app revision the edit produced.
+CREATE TABLE pay_app_edits (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id),
+  job_item_id UUID NOT NULL REFERENCES job_items(id) ON DELETE CASCADE,
+  pay_app_month DATE NOT NULL,
+  revision INT NOT NULL,
+  field VARCHAR(20) NOT NULL
+    CHECK (field IN ('qty', 'stored_materials')),
+  old_value NUMERIC NOT NULL,
+  new_value NUMERIC NOT NULL,
+  edited_by TEXT NOT NULL,
+  reason TEXT,
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_pay_app_edits_job ON pay_app_edits(job_id, pay_app_month);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_pay_app_edits_job;
+DROP TABLE IF EXISTS pay_app_edits;
@@ -0,0 +1,32 @@
+-- +goose Up
+
+-- Local user accounts. Usernames are stored lowercase; password_hash is a
+-- bcrypt hash. role decides what the user may change (see service.Role).
+CREATE TABLE users (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  username VARCHAR(100) NOT NULL UNIQUE,
+  password_hash TEXT NOT NULL,
+  role VARCHAR(20) NOT NULL
+    CHECK (role IN ('viewer', 'project_manager', 'accounting', 'admin')),
+  disabled BOOLEAN NOT NULL DEFAULT FALSE,
+
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Signed-in sessions. Only a SHA-256 hash of the cookie token is stored, so
+-- a copy of the table can't be used to sign in.
+CREATE TABLE sessions (
+  token_hash TEXT PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+  expires_at TIMESTAMP NOT NULL,
+
+  created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX idx_sessions_user ON sessions(user_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_sessions_user;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,18 @@
+-- +goose Up
+
+-- Every job item with the top-level pay item it sits under and its phase:
+-- its own job_cost_id, or the nearest ancestor's when it has none.
+CREATE OR REPLACE VIEW job_item_roots AS
+WITH RECURSIVE tree AS (
+    SELECT id, job_id, id AS root_id, job_cost_id AS phase
+    FROM job_items
+    WHERE parent_id IS NULL
+    UNION ALL
+    SELECT ji.id, ji.job_id, t.root_id, COALESCE(ji.job_cost_id, t.phase)
+    FROM job_items ji
+    JOIN tree t ON ji.parent_id = t.id
+)
+SELECT id, job_id, root_id, phase FROM tree;
+
+-- +goose Down
+DROP VIEW IF EXISTS job_item_roots;
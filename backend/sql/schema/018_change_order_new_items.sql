@@ -0,0 +1,61 @@
+-- +goose Up
+
+-- A line adding a new item keeps the item's details until its change order is
+-- approved; only then is the job item inserted and job_item_id set.
+ALTER TABLE change_order_items ALTER COLUMN job_item_id DROP NOT NULL;
+ALTER TABLE change_order_items
+  ADD COLUMN new_item_number VARCHAR(50),
+  ADD COLUMN new_parent_id UUID REFERENCES job_items(id),
+  ADD COLUMN new_description TEXT,
+  ADD COLUMN new_unit TEXT,
+  ADD COLUMN new_job_cost_id VARCHAR(20),
+  ADD COLUMN new_budget NUMERIC;
+ALTER TABLE change_order_items ADD CONSTRAINT change_order_items_item_check
+  CHECK (job_item_id IS NOT NULL OR new_item_number IS NOT NULL);
+
+-- Record the details of items change orders have already added
+UPDATE change_order_items coi
+SET new_item_number = ji.item_number,
+    new_parent_id = ji.parent_id,
+    new_description = ji.description,
+    new_unit = ji.unit,
+    new_job_cost_id = ji.job_cost_id,
+    new_budget = ji.budget,
+    new_unit_price = ji.unit_price
+FROM job_items ji
+WHERE coi.job_item_id = ji.id
+  AND ji.change_order_id = coi.change_order_id
+  AND coi.new_item_number IS NULL;
+
+-- Take back items added by change orders that aren't approved, unless they've been billed
+UPDATE change_order_items coi
+SET job_item_id = NULL
+FROM job_items ji, change_orders co
+WHERE coi.job_item_id = ji.id
+  AND ji.change_order_id = coi.change_order_id
+  AND co.id = coi.change_order_id
+  AND co.status <> 'approved'
+  AND NOT EXISTS (SELECT 1 FROM pay_applications pa WHERE pa.job_item_id = ji.id)
+  AND NOT EXISTS (SELECT 1 FROM pay_app_revision_items r WHERE r.job_item_id = ji.id)
+  AND NOT EXISTS (SELECT 1 FROM job_items c WHERE c.parent_id = ji.id);
+
+DELETE FROM job_items ji
+USING change_orders co
+WHERE ji.change_order_id = co.id
+  AND co.status <> 'approved'
+  AND NOT EXISTS (SELECT 1 FROM change_order_items coi WHERE coi.job_item_id = ji.id)
+  AND NOT EXISTS (SELECT 1 FROM pay_applications pa WHERE pa.job_item_id = ji.id)
+  AND NOT EXISTS (SELECT 1 FROM pay_app_revision_items r WHERE r.job_item_id = ji.id)
+  AND NOT EXISTS (SELECT 1 FROM job_items c WHERE c.parent_id = ji.id);
+
+-- +goose Down
+DELETE FROM change_order_items WHERE job_item_id IS NULL;
+ALTER TABLE change_order_items DROP CONSTRAINT IF EXISTS change_order_items_item_check;
+ALTER TABLE change_order_items
+  DROP COLUMN IF EXISTS new_item_number,
+  DROP COLUMN IF EXISTS new_parent_id,
+  DROP COLUMN IF EXISTS new_description,
+  DROP COLUMN IF EXISTS new_unit,
+  DROP COLUMN IF EXISTS new_job_cost_id,
+  DROP COLUMN IF EXISTS new_budget;
+ALTER TABLE change_order_items ALTER COLUMN job_item_id SET NOT NULL;
@@ -0,0 +1,22 @@
+-- +goose Up
+
+-- Every change of a job's status, so reports as of a past date see the status
+-- the job had then. Jobs are active until their first change.
+CREATE TABLE job_status_changes (
+  id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+  job_id UUID NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+  status VARCHAR(20) NOT NULL
+    CHECK (status IN ('active', 'complete', 'closed')),
+  changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_job_status_changes_job ON job_status_changes(job_id, changed_at);
+
+-- Jobs already out of active are taken to have changed at their last update
+INSERT INTO job_status_changes (job_id, status, changed_at)
+SELECT id, status, COALESCE(updated_at, NOW())
+FROM jobs
+WHERE status <> 'active';
+
+-- +goose Down
+DROP TABLE IF EXISTS job_status_changes;
@@ -0,0 +1,20 @@
+-- +goose Up
+
+-- Revisions backfilled from existing pay applications were dated when the
+-- backfill ran, and it ran again on every startup. Date them when their month
+-- was last written instead, so reports as of an earlier date still see them.
+UPDATE pay_app_revisions r
+SET created_at = m.last_written
+FROM (
+    SELECT ji.job_id, pa.pay_app_month, MAX(pa.updated_at) AS last_written
+    FROM pay_applications pa
+    JOIN job_items ji ON pa.job_item_id = ji.id
+    GROUP BY ji.job_id, pa.pay_app_month
+) m
+WHERE r.source = 'existing'
+  AND r.job_id = m.job_id
+  AND r.pay_app_month = m.pay_app_month
+  AND m.last_written < r.created_at;
+
+-- +goose Down
+-- The original backfill dates are not kept